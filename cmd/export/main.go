@@ -0,0 +1,81 @@
+// Command export writes the transfers booked for an address in DATA_DIR as
+// CSV or NDJSON, with block timestamps and token decimals read from
+// RPC_URL. It only reads what the server has imported so far.
+package main
+
+import (
+	"context"
+	"eth_parser/internal/app/export"
+	"eth_parser/internal/app/jsonrpc"
+	"eth_parser/internal/app/parser"
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/config"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+func main() {
+	cfg := config.Load()
+	address := flag.String("address", "", "address to export")
+	format := flag.String("format", "csv", "output format, csv or ndjson")
+	output := flag.String("o", "-", "output file, - for stdout")
+	dataDir := flag.String("data-dir", cfg.DataDir, "data directory of the server")
+	rpcURL := flag.String("rpc", cfg.RPCURL, "JSON-RPC endpoint for block timestamps and token decimals")
+	flag.Parse()
+
+	if *address == "" {
+		log.Fatal("-address is required")
+	}
+	f, err := export.ParseFormat(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	transfers, err := repo.NewFileTransferStore(filepath.Join(*dataDir, "transfers.jsonl"))
+	if err != nil {
+		log.Fatalf("Failed to open transfers: %v", err)
+	}
+	defer transfers.Close()
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	transport, err := jsonrpc.Dial(ctx, *rpcURL, client)
+	if err != nil {
+		log.Fatalf("Failed to connect to %s: %v", *rpcURL, err)
+	}
+	defer transport.Close()
+	chain := parser.NewEthereumParser(client, repo.NewMemoryTransactionRepo(), parser.WithTransport(transport))
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *output, err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	w := export.NewWriter(f, out)
+	rows := 0
+	err = export.NewExporter(transfers, chain).Rows(ctx, *address, export.TimeRange{}, func(row export.Row) error {
+		rows++
+		return w.Write(row)
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		log.Fatalf("Failed to export %s: %v", *address, err)
+	}
+	log.Printf("Exported %d rows of %s", rows, *address)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"eth_parser/internal/config"
 	"eth_parser/internal/delivery/httpserver"
 	"log"
 	"os"
@@ -13,7 +14,10 @@ import (
 func main() {
 	errChan := make(chan error, 1)
 	// Initialize server
-	server := httpserver.NewServer("8080")
+	server, err := httpserver.NewServer(config.Load())
+	if err != nil {
+		log.Fatalf("Failed to initialize server: %v", err)
+	}
 
 	// Create signal channel for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
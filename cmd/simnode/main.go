@@ -0,0 +1,138 @@
+// Command simnode serves a simulated Ethereum chain over JSON-RPC for local
+// development. Blocks with a few ether and token transfers between a fixed
+// set of accounts are mined at a steady pace, and the same seed always
+// produces the same chain.
+package main
+
+import (
+	"context"
+	"eth_parser/internal/app/simnode"
+	"eth_parser/internal/crypto"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"math/rand"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":8545", "address to listen on")
+	seed := flag.Int64("seed", 1, "seed for generated transactions and injected errors")
+	blocks := flag.Int("blocks", 100, "number of blocks mined before serving")
+	blockTime := flag.Duration("block-time", 12*time.Second, "interval between new blocks, 0 disables mining")
+	reorgEvery := flag.Int("reorg-every", 0, "replace the last two blocks every n blocks, 0 disables reorgs")
+	latency := flag.Duration("latency", 0, "delay added to every request")
+	errorRate := flag.Float64("error-rate", 0, "share of requests failing with an internal error")
+	maxLogRange := flag.Uint64("max-log-range", 0, "largest eth_getLogs block range, 0 means unlimited")
+	flag.Parse()
+
+	gen := newGenerator(*seed)
+	cfg := simnode.DefaultConfig()
+	cfg.Accounts = gen.genesis()
+	cfg.Keys = gen.keys
+	chain := simnode.NewChain(cfg)
+	for i := 0; i < *blocks; i++ {
+		chain.Mine(gen.transactions()...)
+	}
+
+	node := simnode.NewNode(chain, *seed)
+	node.SetFaults(simnode.Faults{
+		Latency:     *latency,
+		ErrorRate:   *errorRate,
+		MaxLogRange: *maxLogRange,
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if *blockTime > 0 {
+		go mine(ctx, chain, gen, *blockTime, *reorgEvery)
+	}
+
+	server := &http.Server{Addr: *addr, Handler: node}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	log.Printf("Simulated node (chain ID %d) listening on %s, accounts %v, token %s", cfg.ChainID, *addr, gen.accounts, gen.token)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("Server error: %v", err)
+	}
+}
+
+func mine(ctx context.Context, chain *simnode.Chain, gen *generator, interval time.Duration, reorgEvery int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		block := chain.Mine(gen.transactions()...)
+		if reorgEvery > 0 && block.Number%uint64(reorgEvery) == 0 {
+			if err := chain.Reorg(2, gen.transactions(), gen.transactions()); err != nil {
+				log.Println(fmt.Errorf("failed to reorg: %w", err))
+				continue
+			}
+			log.Printf("Reorged blocks %d-%d", block.Number-1, block.Number)
+		}
+	}
+}
+
+// generator produces deterministic transfers between a few accounts. The
+// accounts have keys, so their transactions carry valid signatures.
+type generator struct {
+	rand     *rand.Rand
+	keys     []*big.Int
+	accounts []string
+	token    string
+}
+
+func newGenerator(seed int64) *generator {
+	g := &generator{
+		rand:  rand.New(rand.NewSource(seed)),
+		token: "0x00000000000000000000000000000000000070c0",
+	}
+	for i := 1; i <= 4; i++ {
+		key := simnode.Key(fmt.Sprintf("account %d", i))
+		g.keys = append(g.keys, key)
+		g.accounts = append(g.accounts, crypto.AddressOf(key))
+	}
+	return g
+}
+
+func (g *generator) genesis() map[string]*big.Int {
+	accounts := make(map[string]*big.Int, len(g.accounts))
+	for _, account := range g.accounts {
+		// 1000 ether each.
+		accounts[account] = new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))
+	}
+	return accounts
+}
+
+func (g *generator) transactions() []simnode.TxSpec {
+	txs := make([]simnode.TxSpec, g.rand.Intn(4))
+	for i := range txs {
+		from := g.accounts[g.rand.Intn(len(g.accounts))]
+		to := g.accounts[g.rand.Intn(len(g.accounts))]
+		amount := big.NewInt(g.rand.Int63n(1e15) + 1)
+
+		if g.rand.Intn(2) == 0 {
+			txs[i] = simnode.TxSpec{From: from, To: to, Value: amount}
+			continue
+		}
+		txs[i] = simnode.TxSpec{
+			From: from,
+			To:   g.token,
+			Logs: []simnode.LogSpec{simnode.TokenTransfer(g.token, from, to, amount)},
+		}
+	}
+	return txs
+}
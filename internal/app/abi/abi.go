@@ -0,0 +1,161 @@
+package abi
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"eth_parser/internal/crypto"
+	"eth_parser/internal/domain/entity"
+	"fmt"
+	"strings"
+)
+
+// Function is a contract function.
+type Function struct {
+	Name   string
+	Inputs []Argument
+}
+
+// Signature returns the canonical signature, e.g. "transfer(address,uint256)".
+func (f Function) Signature() string {
+	return f.Name + "(" + joinTypes(f.Inputs) + ")"
+}
+
+// Selector returns the first four bytes of the hash of the signature, which
+// calls of the function start with.
+func (f Function) Selector() [4]byte {
+	var selector [4]byte
+	copy(selector[:], crypto.Keccak256([]byte(f.Signature())))
+	return selector
+}
+
+// Decode decodes the arguments of a call of f, given the input after the
+// selector.
+func (f Function) Decode(data []byte) (*entity.DecodedInput, error) {
+	args, err := DecodeArguments(f.Inputs, data)
+	if err != nil {
+		return nil, err
+	}
+	selector := f.Selector()
+	return &entity.DecodedInput{
+		Selector:  "0x" + hex.EncodeToString(selector[:]),
+		Signature: f.Signature(),
+		Name:      f.Name,
+		Arguments: args,
+	}, nil
+}
+
+// ABI holds the functions of a contract by selector and its events by
+// topic.
+type ABI struct {
+	Functions map[[4]byte]Function
+	Events    map[string]Event
+}
+
+// jsonArgument is a parameter in a JSON ABI.
+type jsonArgument struct {
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Components []jsonArgument `json:"components"`
+	Indexed    bool           `json:"indexed"`
+}
+
+type jsonEntry struct {
+	Type   string         `json:"type"`
+	Name   string         `json:"name"`
+	Inputs []jsonArgument `json:"inputs"`
+	// Anonymous events do not log their signature, so their logs cannot be
+	// told apart.
+	Anonymous bool `json:"anonymous"`
+}
+
+// Parse parses a JSON ABI as emitted by solc: an array of functions, events
+// and errors. Build artifacts holding the array under "abi" are accepted
+// too.
+func Parse(data []byte) (*ABI, error) {
+	var artifact struct {
+		ABI json.RawMessage `json:"abi"`
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &artifact); err != nil {
+			return nil, fmt.Errorf("invalid ABI: %w", err)
+		}
+		if artifact.ABI == nil {
+			return nil, errors.New("invalid ABI: object without an abi field")
+		}
+		data = artifact.ABI
+	}
+
+	var entries []jsonEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid ABI: %w", err)
+	}
+
+	abi := &ABI{Functions: make(map[[4]byte]Function), Events: make(map[string]Event)}
+	for i, entry := range entries {
+		switch entry.Type {
+		// Entries without a type are functions.
+		case "function", "":
+			if entry.Name == "" {
+				return nil, fmt.Errorf("invalid ABI: entry %d: function without a name", i+1)
+			}
+			inputs, err := parseArguments(entry.Inputs)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ABI: function %s: %w", entry.Name, err)
+			}
+			function := Function{Name: entry.Name, Inputs: inputs}
+			abi.Functions[function.Selector()] = function
+		case "event":
+			if entry.Name == "" {
+				return nil, fmt.Errorf("invalid ABI: entry %d: event without a name", i+1)
+			}
+			inputs, err := parseArguments(entry.Inputs)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ABI: event %s: %w", entry.Name, err)
+			}
+			if entry.Anonymous {
+				continue
+			}
+			event := Event{Name: entry.Name, Inputs: inputs, Indexed: make([]bool, len(inputs))}
+			for j, input := range entry.Inputs {
+				event.Indexed[j] = input.Indexed
+			}
+			abi.Events[event.Topic()] = event
+		}
+	}
+	return abi, nil
+}
+
+func parseArguments(params []jsonArgument) ([]Argument, error) {
+	args := make([]Argument, len(params))
+	for i, param := range params {
+		var components []Argument
+		if param.Components != nil {
+			var err error
+			if components, err = parseArguments(param.Components); err != nil {
+				return nil, err
+			}
+		}
+		t, err := parseType(param.Type, components)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = Argument{Name: param.Name, Type: t}
+	}
+	return args, nil
+}
+
+// ParseSignature parses a text signature such as
+// "swap((address,uint256)[],bytes)" into a function with unnamed inputs.
+func ParseSignature(signature string) (Function, error) {
+	open := strings.IndexByte(signature, '(')
+	if open <= 0 {
+		return Function{}, fmt.Errorf("invalid signature %q", signature)
+	}
+	inputs, err := parseSignatureTypes(signature[open:])
+	if err != nil {
+		return Function{}, fmt.Errorf("invalid signature %q: %w", signature, err)
+	}
+	return Function{Name: signature[:open], Inputs: inputs}, nil
+}
@@ -0,0 +1,345 @@
+package abi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/domain/entity"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	token = "0x00000000000000000000000000000000000000aa"
+	alice = "0x1111111111111111111111111111111111111111"
+)
+
+const orderABI = `[
+	{"type": "constructor", "inputs": [{"name": "owner", "type": "address"}]},
+	{"type": "event", "name": "Submitted", "inputs": [{"name": "id", "type": "uint256", "indexed": true}]},
+	{"type": "function", "name": "submit", "inputs": [
+		{"name": "order", "type": "tuple", "components": [
+			{"name": "maker", "type": "address"},
+			{"name": "amounts", "type": "uint256[]"}
+		]},
+		{"name": "note", "type": "string"},
+		{"name": "ids", "type": "bytes32[2]"},
+		{"name": "delta", "type": "int8"}
+	]}
+]`
+
+// word returns the hex of a 32 byte word holding s, which is left-padded,
+// or right-padded when it starts with "r:".
+func word(s string) string {
+	if r, ok := strings.CutPrefix(s, "r:"); ok {
+		return r + strings.Repeat("0", 64-len(r))
+	}
+	return strings.Repeat("0", 64-len(s)) + s
+}
+
+func call(selector string, words ...string) string {
+	for i := range words {
+		words[i] = word(words[i])
+	}
+	return "0x" + selector + strings.Join(words, "")
+}
+
+func TestDecoder(t *testing.T) {
+	submitSelector := selectorOf("submit((address,uint256[]),string,bytes32[2],int8)")
+	hello := hex.EncodeToString([]byte("hello"))
+
+	tests := []struct {
+		name    string
+		to      string
+		input   string
+		want    *entity.DecodedInput
+		wantErr error
+	}{
+		{
+			name:  "bundled signature",
+			to:    alice,
+			input: call("a9059cbb", alice[2:], "3e8"),
+			want: &entity.DecodedInput{
+				Selector:  "0xa9059cbb",
+				Signature: "transfer(address,uint256)",
+				Name:      "transfer",
+				Source:    SourceSignatures,
+				Arguments: []entity.DecodedArgument{
+					{Type: "address", Value: alice},
+					{Type: "uint256", Value: "1000"},
+				},
+			},
+		},
+		{
+			name: "uploaded ABI with tuples, arrays and dynamic types",
+			to:   strings.ToUpper(token[:2]) + token[2:],
+			input: call(submitSelector,
+				// Heads: offsets of the order and the note, the ids and delta.
+				"a0", "140", "01", "02", strings.Repeat("f", 64),
+				// order: maker, offset of amounts, amounts.
+				alice[2:], "40", "2", "1", "2",
+				// note
+				"5", "r:"+hello,
+			),
+			want: &entity.DecodedInput{
+				Selector:  "0x" + submitSelector,
+				Signature: "submit((address,uint256[]),string,bytes32[2],int8)",
+				Name:      "submit",
+				Source:    SourceABI,
+				Arguments: []entity.DecodedArgument{
+					{Name: "order", Type: "(address,uint256[])", Value: []entity.DecodedArgument{
+						{Name: "maker", Type: "address", Value: alice},
+						{Name: "amounts", Type: "uint256[]", Value: []any{"1", "2"}},
+					}},
+					{Name: "note", Type: "string", Value: "hello"},
+					{Name: "ids", Type: "bytes32[2]", Value: []any{"0x" + word("01"), "0x" + word("02")}},
+					{Name: "delta", Type: "int8", Value: "-1"},
+				},
+			},
+		},
+		{
+			name: "multicall with nested dynamic types",
+			to:   alice,
+			// aggregate((address,bytes)[]) with one call.
+			input: call(selectorOf("aggregate((address,bytes)[])"),
+				"20", "1", "20", token[2:], "40", "4", "r:a9059cbb"),
+			want: &entity.DecodedInput{
+				Selector:  "0x252dba42",
+				Signature: "aggregate((address,bytes)[])",
+				Name:      "aggregate",
+				Source:    SourceSignatures,
+				Arguments: []entity.DecodedArgument{
+					{Type: "(address,bytes)[]", Value: []any{[]entity.DecodedArgument{
+						{Type: "address", Value: token},
+						{Type: "bytes", Value: "0xa9059cbb"},
+					}}},
+				},
+			},
+		},
+		{name: "unknown selector", to: alice, input: call("deadbeef", "1"), wantErr: ErrUnknownSelector},
+		{name: "no call", to: alice, input: "0x", wantErr: ErrUnknownSelector},
+		{name: "address with dirty padding", to: alice, input: call("a9059cbb", "1"+alice[2:], "3e8"), wantErr: ErrUnknownSelector},
+		{name: "truncated arguments", to: alice, input: call("a9059cbb", alice[2:]), wantErr: ErrUnknownSelector},
+		{name: "int out of range", to: token, input: call(submitSelector, "a0", "100", "01", "02", "80", alice[2:], "40", "0", "5", "r:"+hello), wantErr: ErrInvalidEncoding},
+		{name: "offset past the end", to: token, input: call(submitSelector, "ffff", "140", "01", "02", "0"), wantErr: ErrInvalidEncoding},
+		{name: "array longer than the data", to: token, input: call(submitSelector, "a0", "140", "01", "02", "0", alice[2:], "40", "ffffffff"), wantErr: ErrInvalidEncoding},
+	}
+
+	decoder := NewDecoder(repo.NewMemoryABIRepo())
+	if _, err := decoder.Set(token, json.RawMessage(orderABI)); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decoder.Decode(tt.to, tt.input)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Decode() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() = %s, want %s", dump(got), dump(tt.want))
+			}
+		})
+	}
+}
+
+func TestDecoderSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		abi     string
+		wantErr bool
+	}{
+		{name: "ABI", address: token, abi: orderABI},
+		{name: "build artifact", address: token, abi: `{"contractName": "Token", "abi": []}`},
+		{name: "invalid address", address: "0x1234", abi: `[]`, wantErr: true},
+		{name: "not an array", address: token, abi: `"transfer(address,uint256)"`, wantErr: true},
+		{name: "artifact without an ABI", address: token, abi: `{"bytecode": "0x"}`, wantErr: true},
+		{name: "unknown type", address: token, abi: `[{"type": "function", "name": "f", "inputs": [{"type": "uint7"}]}]`, wantErr: true},
+		{name: "tuple without components", address: token, abi: `[{"type": "function", "name": "f", "inputs": [{"type": "tuple[]"}]}]`, wantErr: true},
+		{name: "function without a name", address: token, abi: `[{"type": "function", "inputs": []}]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := NewDecoder(repo.NewMemoryABIRepo())
+			_, err := decoder.Set(tt.address, json.RawMessage(tt.abi))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, ok := decoder.Get(tt.address); ok == tt.wantErr {
+				t.Errorf("Get() found = %v after Set() error %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestDecoderFallsBackAfterDelete(t *testing.T) {
+	decoder := NewDecoder(repo.NewMemoryABIRepo())
+	transferABI := `[{"name": "transfer", "inputs": [{"name": "to", "type": "address"}, {"name": "amount", "type": "uint256"}]}]`
+	if _, err := decoder.Set(token, json.RawMessage(transferABI)); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	txs := decoder.Annotate([]entity.Transaction{
+		{Hash: "0x1", To: &[]string{token}[0], Input: call("a9059cbb", alice[2:], "3e8")},
+		{Hash: "0x2", To: &[]string{alice}[0], Input: "0x"},
+		{Hash: "0x3", Input: "0x6080"},
+	})
+	if got := txs[0].DecodedInput; got == nil || got.Source != SourceABI || got.Arguments[0].Name != "to" {
+		t.Errorf("DecodedInput = %s, want named arguments from the ABI", dump(got))
+	}
+	if txs[1].DecodedInput != nil || txs[2].DecodedInput != nil {
+		t.Error("transactions without a call should not be decoded")
+	}
+
+	if found, err := decoder.Delete(token); !found || err != nil {
+		t.Fatalf("Delete() = %v, %v", found, err)
+	}
+	decoded, err := decoder.Decode(token, txs[0].Input)
+	if err != nil || decoded.Source != SourceSignatures || decoded.Arguments[0].Name != "" {
+		t.Errorf("Decode() = %s, %v, want the bundled signature", dump(decoded), err)
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	tests := []struct {
+		signature string
+		wantErr   bool
+	}{
+		{signature: "deposit()"},
+		{signature: "swap((address,uint256)[2][],bytes,(bool,(string)))"},
+		{signature: "f(uint,int)"},
+		{signature: "f(uint257)", wantErr: true},
+		{signature: "f(bytes33)", wantErr: true},
+		{signature: "f(uint256[0])", wantErr: true},
+		{signature: "f((address)", wantErr: true},
+		{signature: "(address)", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.signature, func(t *testing.T) {
+			function, err := ParseSignature(tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			want := strings.ReplaceAll(strings.ReplaceAll(tt.signature, "uint,", "uint256,"), "int)", "int256)")
+			if got := function.Signature(); got != want {
+				t.Errorf("Signature() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeLog(t *testing.T) {
+	note, err := ParseEvent("event Note(address indexed from, string indexed tag, uint256 amount, string memo)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := "0x" + strings.Repeat("ab", 32)
+	hi := hex.EncodeToString([]byte("hi"))
+
+	d := NewDecoder(repo.NewMemoryABIRepo())
+	if _, err := d.Set(token, json.RawMessage(orderABI)); err != nil {
+		t.Fatal(err)
+	}
+	unnamed, err := ParseEvent("Submitted(uint256)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	submitted, ok := d.Event("0x"+strings.ToUpper(token[2:]), unnamed.Topic())
+	if !ok {
+		t.Fatal("Event() did not find the event of the uploaded ABI")
+	}
+
+	tests := []struct {
+		name    string
+		event   Event
+		topics  []string
+		data    string
+		want    []entity.DecodedArgument
+		wantErr bool
+	}{
+		{
+			name:   "indexed and data arguments",
+			event:  note,
+			topics: []string{note.Topic(), "0x" + word(alice[2:]), tag},
+			data:   call("", "5", "40", "2", "r:"+hi),
+			want: []entity.DecodedArgument{
+				{Name: "from", Type: "address", Value: alice},
+				{Name: "tag", Type: "string", Value: tag},
+				{Name: "amount", Type: "uint256", Value: "5"},
+				{Name: "memo", Type: "string", Value: "hi"},
+			},
+		},
+		{
+			name:   "indexed inferred from topics",
+			event:  unnamed,
+			topics: []string{unnamed.Topic(), "0x" + word("7")},
+			data:   "0x",
+			want:   []entity.DecodedArgument{{Type: "uint256", Value: "7"}},
+		},
+		{name: "event of the uploaded ABI", event: submitted, topics: []string{submitted.Topic(), "0x" + word("7")}, data: "0x", want: []entity.DecodedArgument{{Name: "id", Type: "uint256", Value: "7"}}},
+		{name: "other event", event: note, topics: []string{submitted.Topic()}, wantErr: true},
+		{name: "missing topic", event: note, topics: []string{note.Topic(), "0x" + word(alice[2:])}, data: call("", "5", "40", "2", "r:"+hi), wantErr: true},
+		{name: "truncated data", event: note, topics: []string{note.Topic(), "0x" + word(alice[2:]), tag}, data: call("", "5"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.event.DecodeLog(tt.topics, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeLog() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeLog() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBundledSignatures(t *testing.T) {
+	if len(signatures) == 0 {
+		t.Fatal("no bundled signatures were loaded")
+	}
+	for selector, want := range map[string]string{
+		"a9059cbb": "transfer(address,uint256)",
+		"095ea7b3": "approve(address,uint256)",
+		"23b872dd": "transferFrom(address,address,uint256)",
+		"d0e30db0": "deposit()",
+	} {
+		var key [4]byte
+		b, _ := hex.DecodeString(selector)
+		copy(key[:], b)
+		if functions := signatures[key]; len(functions) == 0 || functions[0].Signature() != want {
+			t.Errorf("signatures[%s] = %v, want %s", selector, functions, want)
+		}
+	}
+}
+
+// selectorOf returns the selector of signature in hex.
+func selectorOf(signature string) string {
+	function, err := ParseSignature(signature)
+	if err != nil {
+		panic(err)
+	}
+	selector := function.Selector()
+	return hex.EncodeToString(selector[:])
+}
+
+func dump(v any) string {
+	b, _ := json.Marshal(v)
+	return fmt.Sprint(string(b))
+}
@@ -0,0 +1,181 @@
+package abi
+
+import (
+	"encoding/hex"
+	"errors"
+	"eth_parser/internal/domain/entity"
+	"fmt"
+	"math/big"
+	"unicode/utf8"
+)
+
+// ErrInvalidEncoding is returned for data that is not a valid encoding of
+// the expected types. Decoding is strict, so that of several functions
+// sharing a selector only the one the call was made for decodes.
+var ErrInvalidEncoding = errors.New("invalid ABI encoding")
+
+// DecodeArguments decodes data encoded as a tuple of args, e.g. the input
+// of a call after the selector.
+func DecodeArguments(args []Argument, data []byte) ([]entity.DecodedArgument, error) {
+	d := &reader{budget: maxValues}
+	return d.decodeTuple(args, data)
+}
+
+// maxValues bounds the values decoded from one input. Offsets may point at
+// the same data from many places, so without a bound a short input could
+// take exponential time to decode.
+const maxValues = 100_000
+
+type reader struct {
+	budget int
+}
+
+func (d *reader) decodeTuple(args []Argument, data []byte) ([]entity.DecodedArgument, error) {
+	values := make([]entity.DecodedArgument, len(args))
+	head := 0
+	for i, arg := range args {
+		value, err := d.decodeField(arg.Type, data, head)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fieldName(arg, i), err)
+		}
+		values[i] = entity.DecodedArgument{Name: arg.Name, Type: arg.Type.String(), Value: value}
+		head += arg.Type.headSize()
+	}
+	return values, nil
+}
+
+func (d *reader) decodeList(elem Type, n int, data []byte) ([]any, error) {
+	// Every element takes at least a word, which bounds n before allocating.
+	if n > len(data)/32 {
+		return nil, fmt.Errorf("%w: %d elements in %d bytes", ErrInvalidEncoding, n, len(data))
+	}
+	values := make([]any, n)
+	for i := range values {
+		value, err := d.decodeField(elem, data, i*elem.headSize())
+		if err != nil {
+			return nil, fmt.Errorf("[%d]: %w", i, err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// decodeField decodes the value whose head is at offset head of data, the
+// encoding of the enclosing tuple or array.
+func (d *reader) decodeField(t Type, data []byte, head int) (any, error) {
+	if !t.dynamic() {
+		if head+t.headSize() > len(data) {
+			return nil, fmt.Errorf("%w: value past the end of the data", ErrInvalidEncoding)
+		}
+		return d.decodeValue(t, data[head:])
+	}
+
+	offset, err := readLength(data, head)
+	if err != nil {
+		return nil, err
+	}
+	return d.decodeValue(t, data[offset:])
+}
+
+// decodeValue decodes the value at the start of data.
+func (d *reader) decodeValue(t Type, data []byte) (any, error) {
+	if d.budget--; d.budget < 0 {
+		return nil, fmt.Errorf("%w: more than %d values", ErrInvalidEncoding, maxValues)
+	}
+	switch t.Kind {
+	case KindTuple:
+		return d.decodeTuple(t.Components, data)
+	case KindArray:
+		return d.decodeList(*t.Elem, t.Size, data)
+	case KindSlice:
+		n, err := readLength(data, 0)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeList(*t.Elem, n, data[32:])
+	case KindBytes, KindString:
+		n, err := readLength(data, 0)
+		if err != nil {
+			return nil, err
+		}
+		padded := (n + 31) / 32 * 32
+		if padded > len(data)-32 {
+			return nil, fmt.Errorf("%w: %d bytes past the end of the data", ErrInvalidEncoding, n)
+		}
+		if !zero(data[32+n : 32+padded]) {
+			return nil, fmt.Errorf("%w: dirty padding", ErrInvalidEncoding)
+		}
+		content := data[32 : 32+n]
+		if t.Kind == KindBytes {
+			return "0x" + hex.EncodeToString(content), nil
+		}
+		if !utf8.Valid(content) {
+			return nil, fmt.Errorf("%w: string is not UTF-8", ErrInvalidEncoding)
+		}
+		return string(content), nil
+	}
+
+	word := data[:32]
+	switch t.Kind {
+	case KindUint:
+		n := new(big.Int).SetBytes(word)
+		if n.BitLen() > t.Size {
+			return nil, fmt.Errorf("%w: %s out of range", ErrInvalidEncoding, t)
+		}
+		return n.String(), nil
+	case KindInt:
+		n := new(big.Int).SetBytes(word)
+		if word[0]&0x80 != 0 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(t.Size-1))
+		if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, fmt.Errorf("%w: %s out of range", ErrInvalidEncoding, t)
+		}
+		return n.String(), nil
+	case KindAddress:
+		if !zero(word[:12]) {
+			return nil, fmt.Errorf("%w: address with dirty padding", ErrInvalidEncoding)
+		}
+		return "0x" + hex.EncodeToString(word[12:]), nil
+	case KindBool:
+		if !zero(word[:31]) || word[31] > 1 {
+			return nil, fmt.Errorf("%w: bool is not 0 or 1", ErrInvalidEncoding)
+		}
+		return word[31] == 1, nil
+	default:
+		if !zero(word[t.Size:]) {
+			return nil, fmt.Errorf("%w: %s with dirty padding", ErrInvalidEncoding, t)
+		}
+		return "0x" + hex.EncodeToString(word[:t.Size]), nil
+	}
+}
+
+// readLength reads the offset or length in the word at pos, which must
+// point into data.
+func readLength(data []byte, pos int) (int, error) {
+	if pos+32 > len(data) {
+		return 0, fmt.Errorf("%w: word past the end of the data", ErrInvalidEncoding)
+	}
+	n := new(big.Int).SetBytes(data[pos : pos+32])
+	if !n.IsInt64() || n.Int64() > int64(len(data)) {
+		return 0, fmt.Errorf("%w: offset or length %s past the end of the data", ErrInvalidEncoding, n)
+	}
+	return int(n.Int64()), nil
+}
+
+func zero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func fieldName(arg Argument, i int) string {
+	if arg.Name != "" {
+		return arg.Name
+	}
+	return fmt.Sprintf("argument %d", i)
+}
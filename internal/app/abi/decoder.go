@@ -0,0 +1,161 @@
+package abi
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// SourceABI marks input decoded with the uploaded ABI of the contract.
+	SourceABI = "abi"
+	// SourceSignatures marks input decoded with the bundled signature
+	// database.
+	SourceSignatures = "signatures"
+)
+
+// ErrUnknownSelector is returned for input no known function decodes.
+var ErrUnknownSelector = errors.New("unknown function selector")
+
+var addressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// Decoder decodes transaction input with the uploaded ABI of the recipient,
+// falling back to the signature database.
+type Decoder struct {
+	repo repository.ABIRepo
+
+	// parsed caches the parsed ABIs by lowercase address.
+	parsed map[string]*ABI
+	mutex  sync.Mutex
+}
+
+func NewDecoder(repo repository.ABIRepo) *Decoder {
+	return &Decoder{
+		repo:   repo,
+		parsed: make(map[string]*ABI),
+	}
+}
+
+// Set validates and stores the ABI of the contract at address.
+func (d *Decoder) Set(address string, data json.RawMessage) (entity.ContractABI, error) {
+	if !addressPattern.MatchString(address) {
+		return entity.ContractABI{}, fmt.Errorf("invalid address %q", address)
+	}
+	abi, err := Parse(data)
+	if err != nil {
+		return entity.ContractABI{}, err
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return entity.ContractABI{}, fmt.Errorf("invalid ABI: %w", err)
+	}
+	contract := entity.ContractABI{Address: strings.ToLower(address), ABI: compact.Bytes()}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.repo.StoreABI(contract); err != nil {
+		return entity.ContractABI{}, fmt.Errorf("failed to store ABI: %w", err)
+	}
+	d.parsed[contract.Address] = abi
+	return contract, nil
+}
+
+func (d *Decoder) Get(address string) (entity.ContractABI, bool) {
+	return d.repo.GetABI(address)
+}
+
+func (d *Decoder) Delete(address string) (bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	delete(d.parsed, strings.ToLower(address))
+	return d.repo.DeleteABI(address)
+}
+
+// Decode decodes input, the hex input of a transaction sent to address.
+func (d *Decoder) Decode(address, input string) (*entity.DecodedInput, error) {
+	data, err := hex.DecodeString(strings.TrimPrefix(input, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("input is not hex: %w", err)
+	}
+	if len(data) < 4 {
+		return nil, ErrUnknownSelector
+	}
+	var selector [4]byte
+	copy(selector[:], data)
+
+	if abi := d.abiOf(address); abi != nil {
+		if function, ok := abi.Functions[selector]; ok {
+			decoded, err := function.Decode(data[4:])
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %w", function.Signature(), err)
+			}
+			decoded.Source = SourceABI
+			return decoded, nil
+		}
+	}
+
+	for _, function := range signatures[selector] {
+		if decoded, err := function.Decode(data[4:]); err == nil {
+			decoded.Source = SourceSignatures
+			return decoded, nil
+		}
+	}
+	return nil, ErrUnknownSelector
+}
+
+// Event returns the event of the uploaded ABI of the contract at address
+// whose logs start with topic.
+func (d *Decoder) Event(address, topic string) (Event, bool) {
+	abi := d.abiOf(address)
+	if abi == nil {
+		return Event{}, false
+	}
+	event, ok := abi.Events[strings.ToLower(topic)]
+	return event, ok
+}
+
+// Annotate returns copies of txs with their decoded input. Transactions
+// without a call, or with one that does not decode, are left as they are.
+func (d *Decoder) Annotate(txs []entity.Transaction) []entity.Transaction {
+	annotated := make([]entity.Transaction, len(txs))
+	for i, tx := range txs {
+		if tx.To != nil {
+			if decoded, err := d.Decode(*tx.To, tx.Input); err == nil {
+				tx.DecodedInput = decoded
+			}
+		}
+		annotated[i] = tx
+	}
+	return annotated
+}
+
+// abiOf returns the parsed ABI of the contract at address, or nil.
+func (d *Decoder) abiOf(address string) *ABI {
+	address = strings.ToLower(address)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if abi, ok := d.parsed[address]; ok {
+		return abi
+	}
+	contract, ok := d.repo.GetABI(address)
+	if !ok {
+		return nil
+	}
+	// Stored ABIs were validated by Set; one that no longer parses is
+	// treated as missing.
+	abi, _ := Parse(contract.ABI)
+	d.parsed[address] = abi
+	return abi
+}
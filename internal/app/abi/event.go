@@ -0,0 +1,148 @@
+package abi
+
+import (
+	"encoding/hex"
+	"eth_parser/internal/crypto"
+	"eth_parser/internal/domain/entity"
+	"fmt"
+	"strings"
+)
+
+// Event is a contract event.
+type Event struct {
+	Name   string
+	Inputs []Argument
+	// Indexed tells which inputs are topics rather than data. It is nil
+	// when the declaration did not say, see DecodeLog.
+	Indexed []bool
+}
+
+// Signature returns the canonical signature, e.g. "Deposit(address,uint256)".
+func (e Event) Signature() string {
+	return e.Name + "(" + joinTypes(e.Inputs) + ")"
+}
+
+// Topic returns the hash of the signature, the first topic of the event's
+// logs.
+func (e Event) Topic() string {
+	return "0x" + hex.EncodeToString(crypto.Keccak256([]byte(e.Signature())))
+}
+
+// ParseEvent parses an event declaration such as "Deposit(address,uint256)"
+// or "Deposit(address indexed user, uint256 amount)".
+func ParseEvent(declaration string) (Event, error) {
+	declaration = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(declaration), "event "))
+	open := strings.IndexByte(declaration, '(')
+	if open <= 0 || !strings.HasSuffix(declaration, ")") {
+		return Event{}, fmt.Errorf("invalid event %q", declaration)
+	}
+
+	event := Event{Name: strings.TrimSpace(declaration[:open])}
+	params := strings.TrimSpace(declaration[open+1 : len(declaration)-1])
+	if params == "" {
+		return event, nil
+	}
+
+	anyIndexed := false
+	for _, param := range splitTopLevel(params) {
+		fields := strings.Fields(param)
+		if len(fields) == 0 {
+			return Event{}, fmt.Errorf("invalid event %q: empty parameter", declaration)
+		}
+		t, err := parseSignatureType(fields[0])
+		if err != nil {
+			return Event{}, fmt.Errorf("invalid event %q: %w", declaration, err)
+		}
+		indexed := len(fields) > 1 && fields[1] == "indexed"
+		if indexed {
+			fields = fields[1:]
+			anyIndexed = true
+		}
+		if len(fields) > 2 {
+			return Event{}, fmt.Errorf("invalid event %q: unexpected %q", declaration, fields[2])
+		}
+
+		arg := Argument{Type: t}
+		if len(fields) == 2 {
+			arg.Name = fields[1]
+		}
+		event.Inputs = append(event.Inputs, arg)
+		event.Indexed = append(event.Indexed, indexed)
+	}
+	if !anyIndexed {
+		event.Indexed = nil
+	}
+	return event, nil
+}
+
+// DecodeLog decodes the arguments of a log of e. When e does not say which
+// inputs are indexed, the first ones are assumed to be, as many as the log
+// has topics after the signature.
+func (e Event) DecodeLog(topics []string, data string) ([]entity.DecodedArgument, error) {
+	if len(topics) == 0 || !strings.EqualFold(topics[0], e.Topic()) {
+		return nil, fmt.Errorf("log is not a %s event", e.Signature())
+	}
+	topics = topics[1:]
+
+	indexed := e.Indexed
+	if indexed == nil {
+		if len(topics) > len(e.Inputs) {
+			return nil, fmt.Errorf("log has %d indexed arguments, %s has %d arguments", len(topics), e.Signature(), len(e.Inputs))
+		}
+		indexed = make([]bool, len(e.Inputs))
+		for i := range topics {
+			indexed[i] = true
+		}
+	}
+
+	var dataInputs []Argument
+	for i, input := range e.Inputs {
+		if !indexed[i] {
+			dataInputs = append(dataInputs, input)
+		}
+	}
+	if len(e.Inputs)-len(dataInputs) != len(topics) {
+		return nil, fmt.Errorf("log has %d indexed arguments, %s has %d", len(topics), e.Signature(), len(e.Inputs)-len(dataInputs))
+	}
+
+	raw, err := hex.DecodeString(strings.TrimPrefix(data, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("log data is not hex: %w", err)
+	}
+	values, err := DecodeArguments(dataInputs, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]entity.DecodedArgument, 0, len(e.Inputs))
+	for i, input := range e.Inputs {
+		if !indexed[i] {
+			args = append(args, values[0])
+			values = values[1:]
+			continue
+		}
+
+		value, err := decodeTopic(input, topics[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fieldName(input, i), err)
+		}
+		args = append(args, entity.DecodedArgument{Name: input.Name, Type: input.Type.String(), Value: value})
+		topics = topics[1:]
+	}
+	return args, nil
+}
+
+// decodeTopic decodes an indexed argument. Only the hash of dynamic types,
+// arrays and tuples is logged, so their value is the topic itself.
+func decodeTopic(input Argument, topic string) (any, error) {
+	word, err := hex.DecodeString(strings.TrimPrefix(topic, "0x"))
+	if err != nil || len(word) != 32 {
+		return nil, fmt.Errorf("invalid topic %q", topic)
+	}
+	switch input.Type.Kind {
+	case KindBytes, KindString, KindSlice, KindArray, KindTuple:
+		return "0x" + hex.EncodeToString(word), nil
+	}
+	r := &reader{budget: 1}
+	return r.decodeValue(input.Type, word)
+}
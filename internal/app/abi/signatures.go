@@ -0,0 +1,35 @@
+package abi
+
+import (
+	"bufio"
+	_ "embed"
+	"log"
+	"strings"
+)
+
+// signaturesFile is the bundled 4-byte signature database.
+//
+//go:embed signatures.txt
+var signaturesFile string
+
+// signatures holds the functions of the database by selector, in file order.
+var signatures = loadSignatures(signaturesFile)
+
+func loadSignatures(file string) map[[4]byte][]Function {
+	functions := make(map[[4]byte][]Function)
+	scanner := bufio.NewScanner(strings.NewReader(file))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		function, err := ParseSignature(line)
+		if err != nil {
+			log.Printf("skipping bundled signature: %v", err)
+			continue
+		}
+		selector := function.Selector()
+		functions[selector] = append(functions[selector], function)
+	}
+	return functions
+}
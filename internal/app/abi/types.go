@@ -0,0 +1,279 @@
+// Package abi decodes contract calls encoded with the Solidity ABI, using
+// uploaded contract ABIs or a bundled database of function signatures.
+package abi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Kind is the kind of an ABI type.
+type Kind int
+
+const (
+	KindUint Kind = iota
+	KindInt
+	KindAddress
+	KindBool
+	// KindFixedBytes is bytes1 to bytes32.
+	KindFixedBytes
+	KindBytes
+	KindString
+	// KindSlice is a dynamic array T[].
+	KindSlice
+	// KindArray is a fixed-size array T[k].
+	KindArray
+	KindTuple
+)
+
+// Type is an ABI type.
+type Type struct {
+	Kind Kind
+	// Size is the width in bits of integers, in bytes of fixed bytes and the
+	// length of fixed-size arrays.
+	Size int
+	// Elem is the element type of arrays.
+	Elem *Type
+	// Components are the fields of tuples.
+	Components []Argument
+}
+
+// Argument is a named function argument or tuple field.
+type Argument struct {
+	Name string
+	Type Type
+}
+
+// String returns the canonical name of the type as used in signatures.
+func (t Type) String() string {
+	switch t.Kind {
+	case KindUint:
+		return "uint" + strconv.Itoa(t.Size)
+	case KindInt:
+		return "int" + strconv.Itoa(t.Size)
+	case KindAddress:
+		return "address"
+	case KindBool:
+		return "bool"
+	case KindFixedBytes:
+		return "bytes" + strconv.Itoa(t.Size)
+	case KindBytes:
+		return "bytes"
+	case KindString:
+		return "string"
+	case KindSlice:
+		return t.Elem.String() + "[]"
+	case KindArray:
+		return t.Elem.String() + "[" + strconv.Itoa(t.Size) + "]"
+	default:
+		return "(" + joinTypes(t.Components) + ")"
+	}
+}
+
+func joinTypes(args []Argument) string {
+	names := make([]string, len(args))
+	for i, arg := range args {
+		names[i] = arg.Type.String()
+	}
+	return strings.Join(names, ",")
+}
+
+// dynamic tells whether values of the type are encoded after the head,
+// behind an offset.
+func (t Type) dynamic() bool {
+	switch t.Kind {
+	case KindBytes, KindString, KindSlice:
+		return true
+	case KindArray:
+		return t.Elem.dynamic()
+	case KindTuple:
+		for _, c := range t.Components {
+			if c.Type.dynamic() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// headSize is the number of bytes a static type takes in place.
+func (t Type) headSize() int {
+	switch {
+	case t.dynamic():
+		return 32
+	case t.Kind == KindArray:
+		return t.Size * t.Elem.headSize()
+	case t.Kind == KindTuple:
+		size := 0
+		for _, c := range t.Components {
+			size += c.Type.headSize()
+		}
+		return size
+	default:
+		return 32
+	}
+}
+
+// parseType parses a type name such as "uint256", "bytes32[]" or
+// "tuple[2]". Tuples take their fields from components, as in JSON ABIs.
+func parseType(name string, components []Argument) (Type, error) {
+	base, dims := name, ""
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		base, dims = name[:i], name[i:]
+	}
+
+	var t Type
+	if base == "tuple" {
+		if components == nil {
+			return Type{}, fmt.Errorf("tuple type %q has no components", name)
+		}
+		t = Type{Kind: KindTuple, Components: components}
+	} else {
+		var err error
+		if t, err = parseElementary(base); err != nil {
+			return Type{}, err
+		}
+	}
+	return wrapArrays(t, dims)
+}
+
+// wrapArrays applies array suffixes such as "[2][]" to t, innermost first.
+func wrapArrays(t Type, dims string) (Type, error) {
+	for dims != "" {
+		end := strings.IndexByte(dims, ']')
+		if dims[0] != '[' || end < 0 {
+			return Type{}, fmt.Errorf("invalid array suffix %q", dims)
+		}
+		elem := t
+		if size := dims[1:end]; size == "" {
+			t = Type{Kind: KindSlice, Elem: &elem}
+		} else {
+			n, err := strconv.Atoi(size)
+			if err != nil || n <= 0 || strconv.Itoa(n) != size {
+				return Type{}, fmt.Errorf("invalid array length %q", size)
+			}
+			t = Type{Kind: KindArray, Size: n, Elem: &elem}
+		}
+		dims = dims[end+1:]
+	}
+	return t, nil
+}
+
+func parseElementary(name string) (Type, error) {
+	switch name {
+	case "address":
+		return Type{Kind: KindAddress}, nil
+	case "bool":
+		return Type{Kind: KindBool}, nil
+	case "bytes":
+		return Type{Kind: KindBytes}, nil
+	case "string":
+		return Type{Kind: KindString}, nil
+	case "uint":
+		return Type{Kind: KindUint, Size: 256}, nil
+	case "int":
+		return Type{Kind: KindInt, Size: 256}, nil
+	case "function":
+		// An address followed by a selector.
+		return Type{Kind: KindFixedBytes, Size: 24}, nil
+	}
+
+	for _, prefix := range []struct {
+		name string
+		kind Kind
+		step int
+		max  int
+	}{
+		{"uint", KindUint, 8, 256},
+		{"int", KindInt, 8, 256},
+		{"bytes", KindFixedBytes, 1, 32},
+	} {
+		size, ok := strings.CutPrefix(name, prefix.name)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(size)
+		if err != nil || n <= 0 || n > prefix.max || n%prefix.step != 0 || strconv.Itoa(n) != size {
+			break
+		}
+		return Type{Kind: prefix.kind, Size: n}, nil
+	}
+	return Type{}, fmt.Errorf("unknown type %q", name)
+}
+
+// parseSignatureTypes parses the parameter list of a text signature such as
+// "(address,(uint256,bytes)[])", where tuples are written in parentheses.
+func parseSignatureTypes(params string) ([]Argument, error) {
+	if len(params) < 2 || params[0] != '(' || params[len(params)-1] != ')' {
+		return nil, fmt.Errorf("invalid parameter list %q", params)
+	}
+	inner := params[1 : len(params)-1]
+	if inner == "" {
+		return []Argument{}, nil
+	}
+
+	var args []Argument
+	for _, part := range splitTopLevel(inner) {
+		t, err := parseSignatureType(part)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, Argument{Type: t})
+	}
+	return args, nil
+}
+
+func parseSignatureType(name string) (Type, error) {
+	if !strings.HasPrefix(name, "(") {
+		return parseType(name, nil)
+	}
+
+	end := closingParen(name)
+	if end < 0 {
+		return Type{}, fmt.Errorf("unbalanced parentheses in %q", name)
+	}
+	components, err := parseSignatureTypes(name[:end+1])
+	if err != nil {
+		return Type{}, err
+	}
+	return wrapArrays(Type{Kind: KindTuple, Components: components}, name[end+1:])
+}
+
+// splitTopLevel splits s at the commas outside parentheses.
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// closingParen returns the index of the parenthesis closing the one s
+// starts with, or -1.
+func closingParen(s string) int {
+	depth := 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,182 @@
+// Package addressbook names addresses, so transactions can show who is on
+// the other side.
+package addressbook
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is the encoding of an import.
+type Format string
+
+const (
+	// FormatCSV has the columns address, label and an optional category.
+	// A header row starting with "address" is skipped.
+	FormatCSV Format = "csv"
+	// FormatJSON is an array of labels.
+	FormatJSON Format = "json"
+)
+
+type Book struct {
+	repo repository.LabelRepo
+}
+
+func NewBook(repo repository.LabelRepo) *Book {
+	return &Book{
+		repo: repo,
+	}
+}
+
+// Set adds or replaces the label of an address.
+func (b *Book) Set(label entity.AddressLabel) (entity.AddressLabel, error) {
+	label, err := normalize(label)
+	if err != nil {
+		return entity.AddressLabel{}, err
+	}
+	if err := b.repo.StoreLabels(label); err != nil {
+		return entity.AddressLabel{}, fmt.Errorf("failed to store label: %w", err)
+	}
+	return label, nil
+}
+
+func (b *Book) Get(address string) (entity.AddressLabel, bool) {
+	return b.repo.GetLabel(address)
+}
+
+func (b *Book) Delete(address string) (bool, error) {
+	return b.repo.DeleteLabel(address)
+}
+
+// List returns the labels of category, or all labels for an empty category.
+func (b *Book) List(category string) []entity.AddressLabel {
+	labels := b.repo.ListLabels()
+	if category == "" {
+		return labels
+	}
+
+	filtered := make([]entity.AddressLabel, 0, len(labels))
+	for _, label := range labels {
+		if strings.EqualFold(label.Category, category) {
+			filtered = append(filtered, label)
+		}
+	}
+	return filtered
+}
+
+// Import reads labels from r and stores them all, or none if any of them
+// is invalid. It returns the number of imported labels.
+func (b *Book) Import(r io.Reader, format Format) (int, error) {
+	var (
+		labels []entity.AddressLabel
+		err    error
+	)
+	switch format {
+	case FormatCSV:
+		labels, err = readCSV(r)
+	case FormatJSON:
+		err = json.NewDecoder(r).Decode(&labels)
+	default:
+		return 0, fmt.Errorf("unknown import format %q", format)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", format, err)
+	}
+
+	for i := range labels {
+		if labels[i], err = normalize(labels[i]); err != nil {
+			return 0, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+	}
+	if err := b.repo.StoreLabels(labels...); err != nil {
+		return 0, fmt.Errorf("failed to store labels: %w", err)
+	}
+	return len(labels), nil
+}
+
+// Annotate returns copies of txs with the labels of their sender and recipient.
+func (b *Book) Annotate(txs []entity.Transaction) []entity.Transaction {
+	annotated := make([]entity.Transaction, len(txs))
+	for i, tx := range txs {
+		if label, ok := b.repo.GetLabel(tx.From); ok {
+			tx.FromLabel = label.Label
+		}
+		if tx.To != nil {
+			if label, ok := b.repo.GetLabel(*tx.To); ok {
+				tx.ToLabel = label.Label
+			}
+		}
+		annotated[i] = tx
+	}
+	return annotated
+}
+
+// FilterByLabel keeps the annotated transactions sent from or to an
+// address labelled label.
+func FilterByLabel(txs []entity.Transaction, label string) []entity.Transaction {
+	filtered := make([]entity.Transaction, 0, len(txs))
+	for _, tx := range txs {
+		if strings.EqualFold(tx.FromLabel, label) || strings.EqualFold(tx.ToLabel, label) {
+			filtered = append(filtered, tx)
+		}
+	}
+	return filtered
+}
+
+func readCSV(r io.Reader) ([]entity.AddressLabel, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var labels []entity.AddressLabel
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return labels, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(record[0], "address") {
+			continue
+		}
+		if len(record) < 2 || len(record) > 3 {
+			return nil, fmt.Errorf("line %d: expected address, label and optional category", line)
+		}
+
+		label := entity.AddressLabel{Address: record[0], Label: record[1]}
+		if len(record) == 3 {
+			label.Category = record[2]
+		}
+		labels = append(labels, label)
+	}
+}
+
+func normalize(label entity.AddressLabel) (entity.AddressLabel, error) {
+	label.Address = strings.ToLower(strings.TrimSpace(label.Address))
+	label.Label = strings.TrimSpace(label.Label)
+	label.Category = strings.ToLower(strings.TrimSpace(label.Category))
+
+	if !isAddress(label.Address) {
+		return entity.AddressLabel{}, fmt.Errorf("invalid address %q", label.Address)
+	}
+	if label.Label == "" {
+		return entity.AddressLabel{}, fmt.Errorf("label of %s is empty", label.Address)
+	}
+	return label, nil
+}
+
+func isAddress(address string) bool {
+	if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+		return false
+	}
+	_, err := hex.DecodeString(address[2:])
+	return err == nil
+}
@@ -0,0 +1,78 @@
+package addressbook
+
+import (
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/domain/entity"
+	"strings"
+	"testing"
+)
+
+const (
+	treasury = "0x1111111111111111111111111111111111111111"
+	binance  = "0x28c6c06298d514db089934071355e5743bf21d60"
+	stranger = "0x3333333333333333333333333333333333333333"
+)
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{"csv with header", FormatCSV, "address,label,category\n" + treasury + ",Treasury,own\n" + binance + ",Binance 14,exchange\n", 2, false},
+		{"csv with one column", FormatCSV, treasury + "\n", 0, true},
+		{"csv two columns", FormatCSV, treasury + ",Treasury\n", 1, false},
+		{"json", FormatJSON, `[{"address":"` + binance + `","label":"Binance 14","category":"Exchange"}]`, 1, false},
+		{"invalid address", FormatJSON, `[{"address":"0x12","label":"short"}]`, 0, true},
+		{"empty label", FormatCSV, treasury + ", \n", 0, true},
+		{"unknown format", "xml", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := NewBook(repo.NewMemoryLabelRepo())
+			n, err := book.Import(strings.NewReader(tt.input), tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Import() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if n != tt.want || len(book.List("")) != tt.want {
+				t.Errorf("expected %d labels, got %d and %d stored", tt.want, n, len(book.List("")))
+			}
+		})
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	book := NewBook(repo.NewMemoryLabelRepo())
+	book.Set(entity.AddressLabel{Address: treasury, Label: "Treasury", Category: "own"})
+	book.Set(entity.AddressLabel{Address: strings.ToUpper(binance), Label: "Binance 14", Category: "exchange"})
+
+	to := func(address string) *string { return &address }
+	txs := book.Annotate([]entity.Transaction{
+		{Hash: "0x1", From: treasury, To: to(binance)},
+		{Hash: "0x2", From: stranger, To: to(treasury)},
+		{Hash: "0x3", From: treasury, To: nil},
+	})
+
+	tests := []struct {
+		hash, fromLabel, toLabel string
+	}{
+		{"0x1", "Treasury", "Binance 14"},
+		{"0x2", "", "Treasury"},
+		{"0x3", "Treasury", ""},
+	}
+	for i, tt := range tests {
+		if txs[i].Hash != tt.hash || txs[i].FromLabel != tt.fromLabel || txs[i].ToLabel != tt.toLabel {
+			t.Errorf("unexpected annotation %+v", txs[i])
+		}
+	}
+
+	if got := FilterByLabel(txs, "binance 14"); len(got) != 1 || got[0].Hash != "0x1" {
+		t.Errorf("unexpected filter result %+v", got)
+	}
+	if got := book.List("EXCHANGE"); len(got) != 1 || got[0].Address != binance {
+		t.Errorf("unexpected labels %+v", got)
+	}
+}
@@ -0,0 +1,265 @@
+// Package alert evaluates the rules of tenants against the transfers found
+// for their addresses and notifies the channels of every matching rule.
+package alert
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"eth_parser/internal/app/webhook"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var _ repository.TransferStore = (*Engine)(nil)
+
+// ErrInvalidRule is returned for rules that do not compile or name an
+// unknown channel.
+var ErrInvalidRule = errors.New("invalid rule")
+
+// Subscribers finds the tenants watching an address.
+type Subscribers interface {
+	Subscribers(address string) []entity.Subscription
+}
+
+// Labels looks addresses up in the address book.
+type Labels interface {
+	Get(address string) (entity.AddressLabel, bool)
+}
+
+// Sender delivers webhook calls, e.g. webhook.Notifier.
+type Sender interface {
+	Send(tenant, url, subject string, payload any)
+}
+
+// Payload is the body POSTed to webhook channels.
+type Payload struct {
+	Rule  entity.AlertRule `json:"rule"`
+	Alert entity.Alert     `json:"alert"`
+}
+
+type compiledRule struct {
+	rule entity.AlertRule
+	expr *Expr
+}
+
+// Engine wraps the transfer store and evaluates every rule of the tenants
+// watching an address against each new transfer booked for it, unless the
+// transfer predates their subscription. A transfer
+// raises at most one alert per rule, even when it is booked for two
+// addresses of the tenant.
+type Engine struct {
+	repository.TransferStore
+	rules       repository.AlertRuleRepo
+	alerts      repository.AlertRepo
+	subscribers Subscribers
+	labels      Labels
+	webhooks    Sender
+	streams     *streams
+	now         func() time.Time
+
+	mutex    sync.RWMutex
+	compiled map[string]compiledRule
+}
+
+// NewEngine loads the stored rules. labels may be nil.
+func NewEngine(store repository.TransferStore, rules repository.AlertRuleRepo, alerts repository.AlertRepo, subscribers Subscribers, labels Labels, webhooks Sender) *Engine {
+	e := &Engine{
+		TransferStore: store,
+		rules:         rules,
+		alerts:        alerts,
+		subscribers:   subscribers,
+		labels:        labels,
+		webhooks:      webhooks,
+		streams:       newStreams(),
+		now:           time.Now,
+		compiled:      make(map[string]compiledRule),
+	}
+
+	for _, rule := range rules.ListRules() {
+		expr, err := Compile(rule.Expression)
+		if err != nil {
+			log.Println(fmt.Errorf("skipping alert rule %s: %w", rule.ID, err))
+			continue
+		}
+		e.compiled[rule.ID] = compiledRule{rule: rule, expr: expr}
+	}
+	return e
+}
+
+// CreateRule validates and stores a new rule of rule.Tenant. Rules without
+// channels are logged.
+func (e *Engine) CreateRule(rule entity.AlertRule) (entity.AlertRule, error) {
+	if rule.Tenant == "" {
+		return entity.AlertRule{}, fmt.Errorf("tenant is required")
+	}
+	expr, err := Compile(rule.Expression)
+	if err != nil {
+		return entity.AlertRule{}, fmt.Errorf("%w: %w", ErrInvalidRule, err)
+	}
+	if len(rule.Channels) == 0 {
+		rule.Channels = []entity.AlertChannel{{Type: entity.AlertLog}}
+	}
+	for _, channel := range rule.Channels {
+		if err := validateChannel(channel); err != nil {
+			return entity.AlertRule{}, fmt.Errorf("%w: %w", ErrInvalidRule, err)
+		}
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return entity.AlertRule{}, fmt.Errorf("failed to generate rule ID: %w", err)
+	}
+	rule.ID = hex.EncodeToString(id)
+	rule.CreatedAt = e.now()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if err := e.rules.StoreRule(rule); err != nil {
+		return entity.AlertRule{}, fmt.Errorf("failed to store rule: %w", err)
+	}
+	e.compiled[rule.ID] = compiledRule{rule: rule, expr: expr}
+	return rule, nil
+}
+
+func validateChannel(channel entity.AlertChannel) error {
+	switch channel.Type {
+	case entity.AlertLog, entity.AlertSSE:
+		return nil
+	case entity.AlertWebhook:
+		return webhook.ValidateURL(channel.URL)
+	}
+	return fmt.Errorf("unknown channel type %q", channel.Type)
+}
+
+// GetRule returns the rule id of tenant.
+func (e *Engine) GetRule(tenant, id string) (entity.AlertRule, bool) {
+	rule, ok := e.rules.GetRule(id)
+	if !ok || rule.Tenant != tenant {
+		return entity.AlertRule{}, false
+	}
+	return rule, true
+}
+
+// ListRules returns the rules of tenant, oldest first.
+func (e *Engine) ListRules(tenant string) []entity.AlertRule {
+	rules := []entity.AlertRule{}
+	for _, rule := range e.rules.ListRules() {
+		if rule.Tenant == tenant {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// DeleteRule removes the rule id of tenant. Its alerts are kept.
+func (e *Engine) DeleteRule(tenant, id string) (bool, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if rule, ok := e.rules.GetRule(id); !ok || rule.Tenant != tenant {
+		return false, nil
+	}
+	if _, err := e.rules.DeleteRule(id); err != nil {
+		return false, fmt.Errorf("failed to delete rule: %w", err)
+	}
+	delete(e.compiled, id)
+	return true, nil
+}
+
+// ListAlerts returns the alerts of tenant, oldest first, optionally only
+// those of one rule.
+func (e *Engine) ListAlerts(tenant, ruleID string) []entity.Alert {
+	alerts := []entity.Alert{}
+	for _, alert := range e.alerts.ListAlerts(tenant) {
+		if ruleID == "" || alert.RuleID == ruleID {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts
+}
+
+// Subscribe streams the new alerts of tenant's rules with an sse channel
+// until cancel is called.
+func (e *Engine) Subscribe(tenant string) (<-chan entity.Alert, func()) {
+	return e.streams.subscribe(tenant)
+}
+
+func (e *Engine) StoreTransfer(address string, transfer entity.Transfer) (bool, error) {
+	stored, err := e.TransferStore.StoreTransfer(address, transfer)
+	if err != nil || !stored {
+		return stored, err
+	}
+
+	// History found by backfills is not news to tenants that subscribed
+	// after it.
+	tenants := make(map[string]bool)
+	for _, sub := range e.subscribers.Subscribers(address) {
+		if transfer.BlockNumber >= sub.CreatedBlock {
+			tenants[sub.Tenant] = true
+		}
+	}
+	if len(tenants) == 0 {
+		return true, nil
+	}
+
+	event := &Event{Address: address, Transfer: transfer}
+	if e.labels != nil {
+		event.From, _ = e.labels.Get(transfer.From)
+		event.To, _ = e.labels.Get(transfer.To)
+	}
+
+	e.mutex.RLock()
+	var matched []entity.AlertRule
+	for _, c := range e.compiled {
+		if tenants[c.rule.Tenant] && c.expr.Match(event) {
+			matched = append(matched, c.rule)
+		}
+	}
+	e.mutex.RUnlock()
+
+	for _, rule := range matched {
+		if err := e.raise(rule, address, transfer); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// raise records the alert of rule and hands it to the rule's channels.
+func (e *Engine) raise(rule entity.AlertRule, address string, transfer entity.Transfer) error {
+	alert := entity.Alert{
+		ID:        rule.ID + ":" + transfer.ID,
+		RuleID:    rule.ID,
+		Tenant:    rule.Tenant,
+		Address:   address,
+		Transfer:  transfer,
+		CreatedAt: e.now(),
+	}
+	stored, err := e.alerts.StoreAlert(alert)
+	if err != nil {
+		return fmt.Errorf("failed to store alert: %w", err)
+	}
+	if !stored {
+		return nil
+	}
+
+	for _, channel := range rule.Channels {
+		switch channel.Type {
+		case entity.AlertLog:
+			log.Printf("alert %s: rule %q of tenant %s matched %s transfer %s of %s", alert.ID, rule.Name, rule.Tenant, transfer.Kind, transfer.TxHash, address)
+		case entity.AlertWebhook:
+			if e.webhooks != nil {
+				e.webhooks.Send(rule.Tenant, channel.URL, "alert "+alert.ID, Payload{Rule: rule, Alert: alert})
+			}
+		case entity.AlertSSE:
+			e.streams.publish(alert)
+		}
+	}
+	return nil
+}
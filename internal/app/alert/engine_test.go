@@ -0,0 +1,148 @@
+package alert
+
+import (
+	"errors"
+	"eth_parser/internal/app/addressbook"
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/domain/entity"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type subscribersFunc func(address string) []entity.Subscription
+
+func (f subscribersFunc) Subscribers(address string) []entity.Subscription {
+	return f(address)
+}
+
+type sent struct {
+	tenant, url string
+	payload     Payload
+}
+
+type senderFunc func(tenant, url, subject string, payload any)
+
+func (f senderFunc) Send(tenant, url, subject string, payload any) {
+	f(tenant, url, subject, payload)
+}
+
+func TestEngine(t *testing.T) {
+	subscribers := subscribersFunc(func(address string) []entity.Subscription {
+		if address != treasury {
+			return nil
+		}
+		return []entity.Subscription{{Tenant: "ops", Address: treasury}, {Tenant: "risk", Address: treasury}}
+	})
+	labels := addressbook.NewBook(repo.NewMemoryLabelRepo())
+	labels.Set(entity.AddressLabel{Address: exchange, Label: "Mixer", Category: "flagged"})
+
+	var webhooks []sent
+	sender := senderFunc(func(tenant, url, subject string, payload any) {
+		webhooks = append(webhooks, sent{tenant, url, payload.(Payload)})
+	})
+	rules := repo.NewMemoryAlertRuleRepo()
+	engine := NewEngine(repo.NewMemoryTransferStore(), rules, repo.NewMemoryAlertRepo(), subscribers, labels, sender)
+
+	if _, err := engine.CreateRule(entity.AlertRule{Tenant: "ops", Expression: `value >`}); !errors.Is(err, ErrInvalidRule) {
+		t.Errorf("expected ErrInvalidRule for a broken expression, got %v", err)
+	}
+	if _, err := engine.CreateRule(entity.AlertRule{Tenant: "ops", Expression: `true`, Channels: []entity.AlertChannel{{Type: "pager"}}}); !errors.Is(err, ErrInvalidRule) {
+		t.Errorf("expected ErrInvalidRule for an unknown channel, got %v", err)
+	}
+
+	large, err := engine.CreateRule(entity.AlertRule{
+		Tenant:     "ops",
+		Name:       "large withdrawal",
+		Expression: `direction == "out" && value > 100 ether`,
+		Channels:   []entity.AlertChannel{{Type: entity.AlertWebhook, URL: "https://203.0.113.10/alerts"}, {Type: entity.AlertSSE}},
+	})
+	if err != nil {
+		t.Fatalf("CreateRule() error = %v", err)
+	}
+	flagged, err := engine.CreateRule(entity.AlertRule{Tenant: "risk", Name: "mixer", Expression: `toCategory == "flagged"`})
+	if err != nil {
+		t.Fatalf("CreateRule() error = %v", err)
+	}
+	if flagged.Channels[0].Type != entity.AlertLog {
+		t.Errorf("expected rules without channels to be logged, got %+v", flagged.Channels)
+	}
+	if _, err := engine.CreateRule(entity.AlertRule{Tenant: "audit", Expression: `true`}); err != nil {
+		t.Fatalf("CreateRule() error = %v", err)
+	}
+
+	stream, cancel := engine.Subscribe("ops")
+	defer cancel()
+
+	transfer := entity.Transfer{ID: "0x1:native", Kind: entity.TransferNative, TxHash: "0x1", Token: entity.NativeToken, From: treasury, To: exchange, Value: "200000000000000000000"}
+	for range 2 {
+		if _, err := engine.StoreTransfer(treasury, transfer); err != nil {
+			t.Fatalf("StoreTransfer() error = %v", err)
+		}
+	}
+	// The tenant of the third rule does not watch the address.
+	if _, err := engine.StoreTransfer(exchange, transfer); err != nil {
+		t.Fatalf("StoreTransfer() error = %v", err)
+	}
+
+	if alerts := engine.ListAlerts("ops", ""); len(alerts) != 1 || alerts[0].RuleID != large.ID || alerts[0].ID != large.ID+":0x1:native" {
+		t.Errorf("unexpected alerts of ops %+v", alerts)
+	}
+	if alerts := engine.ListAlerts("risk", flagged.ID); len(alerts) != 1 {
+		t.Errorf("expected 1 alert of risk, got %+v", alerts)
+	}
+	if alerts := engine.ListAlerts("audit", ""); len(alerts) != 0 {
+		t.Errorf("expected no alerts of audit, got %+v", alerts)
+	}
+	if len(webhooks) != 1 || webhooks[0].url != "https://203.0.113.10/alerts" || webhooks[0].payload.Rule.ID != large.ID {
+		t.Errorf("unexpected webhook calls %+v", webhooks)
+	}
+	select {
+	case alert := <-stream:
+		if alert.RuleID != large.ID {
+			t.Errorf("unexpected streamed alert %+v", alert)
+		}
+	case <-time.After(time.Second):
+		t.Error("alert was not streamed")
+	}
+
+	// Stored rules are compiled again on startup; deleted ones stop matching.
+	if ok, _ := engine.DeleteRule("risk", large.ID); ok {
+		t.Error("deleted the rule of another tenant")
+	}
+	if ok, err := engine.DeleteRule("risk", flagged.ID); !ok || err != nil {
+		t.Errorf("DeleteRule() = %v, %v", ok, err)
+	}
+	restarted := NewEngine(repo.NewMemoryTransferStore(), rules, repo.NewMemoryAlertRepo(), subscribers, labels, sender)
+	if _, err := restarted.StoreTransfer(treasury, transfer); err != nil {
+		t.Fatalf("StoreTransfer() error = %v", err)
+	}
+	if alerts := restarted.ListAlerts("ops", ""); len(alerts) != 1 {
+		t.Errorf("expected the reloaded rule to match, got %+v", alerts)
+	}
+	if alerts := restarted.ListAlerts("risk", ""); len(alerts) != 0 {
+		t.Errorf("expected no alerts of the deleted rule, got %+v", alerts)
+	}
+}
+
+func TestEngineSkipsTransfersBeforeSubscription(t *testing.T) {
+	subscribers := subscribersFunc(func(address string) []entity.Subscription {
+		return []entity.Subscription{{Tenant: "ops", Address: treasury, CreatedBlock: 100}}
+	})
+	engine := NewEngine(repo.NewMemoryTransferStore(), repo.NewMemoryAlertRuleRepo(), repo.NewMemoryAlertRepo(), subscribers, nil, nil)
+	if _, err := engine.CreateRule(entity.AlertRule{Tenant: "ops", Expression: `true`}); err != nil {
+		t.Fatalf("CreateRule() error = %v", err)
+	}
+
+	// A backfill finds a transfer older than the subscription, then the
+	// scan of the head a new one.
+	for _, block := range []uint64{99, 100} {
+		transfer := entity.Transfer{ID: fmt.Sprintf("0x%x:native", block), Kind: entity.TransferNative, BlockNumber: block, Token: entity.NativeToken, From: treasury, To: exchange, Value: "1"}
+		if _, err := engine.StoreTransfer(treasury, transfer); err != nil {
+			t.Fatalf("StoreTransfer() error = %v", err)
+		}
+	}
+	if alerts := engine.ListAlerts("ops", ""); len(alerts) != 1 || alerts[0].Transfer.BlockNumber != 100 {
+		t.Errorf("expected only the transfer of block 100 to raise an alert, got %+v", alerts)
+	}
+}
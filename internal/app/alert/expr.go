@@ -0,0 +1,482 @@
+package alert
+
+import (
+	"eth_parser/internal/domain/entity"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+)
+
+// Event is a transfer booked for a subscribed address, as seen by rules.
+type Event struct {
+	// Address is the subscribed address the transfer was booked for.
+	Address  string
+	Transfer entity.Transfer
+	// From and To are the address book entries of the two parties, if any.
+	From, To entity.AddressLabel
+}
+
+// Direction is "out" when the subscribed address sent the transfer, "in"
+// when it received it and "self" when it did both.
+func (e *Event) Direction() string {
+	from := strings.EqualFold(e.Transfer.From, e.Address)
+	to := strings.EqualFold(e.Transfer.To, e.Address)
+	switch {
+	case from && to:
+		return "self"
+	case from:
+		return "out"
+	default:
+		return "in"
+	}
+}
+
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindNumber
+	kindBool
+)
+
+func (k valueKind) String() string {
+	switch k {
+	case kindString:
+		return "string"
+	case kindNumber:
+		return "number"
+	default:
+		return "bool"
+	}
+}
+
+// fields are the names a rule can refer to. Strings are compared ignoring
+// case, numbers are integers in base units.
+var fields = map[string]struct {
+	kind valueKind
+	get  func(e *Event) any
+}{
+	"kind":         {kindString, func(e *Event) any { return string(e.Transfer.Kind) }},
+	"token":        {kindString, func(e *Event) any { return e.Transfer.Token }},
+	"from":         {kindString, func(e *Event) any { return e.Transfer.From }},
+	"to":           {kindString, func(e *Event) any { return e.Transfer.To }},
+	"address":      {kindString, func(e *Event) any { return e.Address }},
+	"direction":    {kindString, func(e *Event) any { return e.Direction() }},
+	"fromLabel":    {kindString, func(e *Event) any { return e.From.Label }},
+	"toLabel":      {kindString, func(e *Event) any { return e.To.Label }},
+	"fromCategory": {kindString, func(e *Event) any { return e.From.Category }},
+	"toCategory":   {kindString, func(e *Event) any { return e.To.Category }},
+	"value": {kindNumber, func(e *Event) any {
+		value, ok := new(big.Int).SetString(e.Transfer.Value, 10)
+		if !ok {
+			return new(big.Int)
+		}
+		return value
+	}},
+	"block": {kindNumber, func(e *Event) any { return new(big.Int).SetUint64(e.Transfer.BlockNumber) }},
+}
+
+// units scale number literals, e.g. `100 ether`.
+var units = map[string]int{
+	"wei":   0,
+	"gwei":  9,
+	"ether": 18,
+}
+
+// Expr is a compiled rule expression. The language has the usual
+// comparisons (== != < <= > >=), list membership (in, not in), the boolean
+// operators && || ! (also written and, or, not) and parentheses:
+//
+//	direction == "out" && address == "0x..." && token == "ETH" && value > 100 ether
+//	kind == "token" && token not in ["0xa0b8...", "0xdac1..."]
+//	fromCategory == "flagged" || toCategory == "flagged"
+type Expr struct {
+	source string
+	root   condition
+}
+
+// Compile parses and type checks source.
+func Compile(source string) (*Expr, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+	}
+	return &Expr{source: source, root: root}, nil
+}
+
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Match reports whether event satisfies the expression.
+func (e *Expr) Match(event *Event) bool {
+	return e.root.match(event)
+}
+
+type condition interface {
+	match(e *Event) bool
+}
+
+type andCondition struct{ left, right condition }
+
+func (c andCondition) match(e *Event) bool { return c.left.match(e) && c.right.match(e) }
+
+type orCondition struct{ left, right condition }
+
+func (c orCondition) match(e *Event) bool { return c.left.match(e) || c.right.match(e) }
+
+type notCondition struct{ inner condition }
+
+func (c notCondition) match(e *Event) bool { return !c.inner.match(e) }
+
+// operand is a field or a literal.
+type operand struct {
+	kind  valueKind
+	get   func(e *Event) any
+	value any
+}
+
+func (o operand) eval(e *Event) any {
+	if o.get != nil {
+		return o.get(e)
+	}
+	return o.value
+}
+
+func (o operand) match(e *Event) bool {
+	return o.eval(e).(bool)
+}
+
+type compareCondition struct {
+	op          string
+	left, right operand
+}
+
+func (c compareCondition) match(e *Event) bool {
+	left, right := c.left.eval(e), c.right.eval(e)
+
+	var cmp int
+	switch l := left.(type) {
+	case string:
+		if !strings.EqualFold(l, right.(string)) {
+			cmp = 1
+		}
+	case *big.Int:
+		cmp = l.Cmp(right.(*big.Int))
+	case bool:
+		if l != right.(bool) {
+			cmp = 1
+		}
+	}
+
+	switch c.op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+type inCondition struct {
+	left operand
+	// set holds lowercased strings or decimal numbers.
+	set    map[string]bool
+	negate bool
+}
+
+func (c inCondition) match(e *Event) bool {
+	var key string
+	switch v := c.left.eval(e).(type) {
+	case string:
+		key = strings.ToLower(v)
+	case *big.Int:
+		key = v.String()
+	}
+	return c.set[key] != c.negate
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var comparisons = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+var symbols = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ","}
+
+func lex(source string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(source); {
+		c := rune(source[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexRune(source[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, token{tokenString, source[i+1 : i+1+end], i})
+			i += end + 2
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(source) && (unicode.IsDigit(rune(source[i])) || source[i] == '.' || source[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, source[start:i], start})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(source) && (unicode.IsLetter(rune(source[i])) || unicode.IsDigit(rune(source[i])) || source[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, source[start:i], start})
+		default:
+			matched := false
+			for _, symbol := range symbols {
+				if strings.HasPrefix(source[i:], symbol) {
+					tokens = append(tokens, token{tokenSymbol, symbol, i})
+					i += len(symbol)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+		}
+	}
+	return append(tokens, token{tokenEOF, "end of expression", len(source)}), nil
+}
+
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is one of texts.
+func (p *exprParser) accept(texts ...string) bool {
+	tok := p.peek()
+	if tok.kind != tokenSymbol && tok.kind != tokenIdent {
+		return false
+	}
+	for _, text := range texts {
+		if tok.text == text {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) expect(text string) error {
+	if !p.accept(text) {
+		tok := p.peek()
+		return fmt.Errorf("expected %q at %d, got %q", text, tok.pos, tok.text)
+	}
+	return nil
+}
+
+func (p *exprParser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||", "or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orCondition{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&", "and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andCondition{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (condition, error) {
+	if p.accept("!", "not") {
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notCondition{inner}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (condition, error) {
+	if p.accept("(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	}
+
+	start := p.peek()
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if op := p.peek(); op.kind == tokenSymbol && comparisons[op.text] {
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if left.kind != right.kind {
+			return nil, fmt.Errorf("cannot compare %s with %s at %d", left.kind, right.kind, op.pos)
+		}
+		if left.kind != kindNumber && op.text != "==" && op.text != "!=" {
+			return nil, fmt.Errorf("operator %s needs numbers at %d", op.text, op.pos)
+		}
+		return compareCondition{op.text, left, right}, nil
+	}
+
+	negate := false
+	if p.peek().text == "not" && p.tokens[p.pos+1].text == "in" {
+		p.next()
+		negate = true
+	}
+	if p.accept("in") {
+		set, err := p.parseList(left.kind)
+		if err != nil {
+			return nil, err
+		}
+		return inCondition{left, set, negate}, nil
+	}
+
+	if left.kind != kindBool {
+		return nil, fmt.Errorf("%s at %d is not a condition", start.text, start.pos)
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseList(kind valueKind) (map[string]bool, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool)
+	for first := true; !p.accept("]"); first = false {
+		if !first {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		tok := p.peek()
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if item.get != nil || item.kind != kind {
+			return nil, fmt.Errorf("list item at %d must be a %s literal", tok.pos, kind)
+		}
+		switch v := item.value.(type) {
+		case string:
+			set[strings.ToLower(v)] = true
+		case *big.Int:
+			set[v.String()] = true
+		}
+	}
+	return set, nil
+}
+
+func (p *exprParser) parseOperand() (operand, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenString:
+		return operand{kind: kindString, value: tok.text}, nil
+	case tokenNumber:
+		scale := 0
+		if next := p.peek(); next.kind == tokenIdent {
+			if digits, ok := units[next.text]; ok {
+				p.next()
+				scale = digits
+			}
+		}
+		value, err := parseNumber(tok.text, scale)
+		if err != nil {
+			return operand{}, fmt.Errorf("invalid number %q at %d: %w", tok.text, tok.pos, err)
+		}
+		return operand{kind: kindNumber, value: value}, nil
+	case tokenIdent:
+		switch tok.text {
+		case "true", "false":
+			return operand{kind: kindBool, value: tok.text == "true"}, nil
+		}
+		field, ok := fields[tok.text]
+		if !ok {
+			return operand{}, fmt.Errorf("unknown field %q at %d", tok.text, tok.pos)
+		}
+		return operand{kind: field.kind, get: field.get}, nil
+	}
+	return operand{}, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+}
+
+// parseNumber parses a decimal literal and scales it by 10^scale. The result
+// must be a whole number of base units.
+func parseNumber(text string, scale int) (*big.Int, error) {
+	r, ok := new(big.Rat).SetString(strings.ReplaceAll(text, "_", ""))
+	if !ok {
+		return nil, fmt.Errorf("not a decimal number")
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)))
+	if !r.IsInt() {
+		return nil, fmt.Errorf("not a whole number of base units")
+	}
+	return r.Num(), nil
+}
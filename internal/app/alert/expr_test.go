@@ -0,0 +1,101 @@
+package alert
+
+import (
+	"eth_parser/internal/domain/entity"
+	"strings"
+	"testing"
+)
+
+const (
+	treasury = "0x1111111111111111111111111111111111111111"
+	exchange = "0x2222222222222222222222222222222222222222"
+	usdc     = "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"
+)
+
+func TestMatch(t *testing.T) {
+	withdrawal := &Event{
+		Address: treasury,
+		Transfer: entity.Transfer{
+			Kind:  entity.TransferNative,
+			Token: entity.NativeToken,
+			From:  treasury,
+			To:    exchange,
+			Value: "150000000000000000000",
+		},
+		To: entity.AddressLabel{Address: exchange, Label: "Binance 14", Category: "exchange"},
+	}
+	deposit := &Event{
+		Address: treasury,
+		Transfer: entity.Transfer{
+			Kind:  entity.TransferToken,
+			Token: "0x3333333333333333333333333333333333333333",
+			From:  exchange,
+			To:    treasury,
+			Value: "5",
+		},
+		From: entity.AddressLabel{Address: exchange, Label: "Binance 14", Category: "flagged"},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		event      *Event
+		want       bool
+	}{
+		{"large withdrawal", `direction == "out" && token == "ETH" && value > 100 ether`, withdrawal, true},
+		{"below threshold", `direction == "out" && value > 150 ether`, withdrawal, false},
+		{"at threshold", `value >= 150 ether`, withdrawal, true},
+		{"fractional unit", `value > 149.5 ether`, withdrawal, true},
+		{"address ignores case", `from == "` + strings.ToUpper(treasury) + `"`, withdrawal, true},
+		{"token not on allowlist", `kind == "token" && token not in ["` + usdc + `", "0xdac17f958d2ee523a2206206994597c13d831ec7"]`, deposit, true},
+		{"token on allowlist", `kind == "token" && token not in ["` + usdc + `"]`, withdrawal, false},
+		{"flagged counterparty", `fromCategory == "flagged" || toCategory == "flagged"`, deposit, true},
+		{"flagged counterparty words", `fromCategory == "flagged" or toCategory == "flagged"`, withdrawal, false},
+		{"label", `toLabel == "binance 14"`, withdrawal, true},
+		{"negation", `!(direction == "in") and not value < 1`, withdrawal, true},
+		{"in numbers", `value in [1, 5, 10]`, deposit, true},
+		{"precedence", `direction == "in" || direction == "out" && value > 1000 ether`, deposit, true},
+		{"literal", `true`, deposit, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Compile(tt.expression)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if got := expr.Match(tt.event); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		wantErr    string
+	}{
+		{"empty", ``, "unexpected"},
+		{"unknown field", `amount > 1`, `unknown field "amount"`},
+		{"type mismatch", `value == "1"`, "cannot compare number with string"},
+		{"ordered strings", `token > "a"`, "needs numbers"},
+		{"not a condition", `value`, "is not a condition"},
+		{"unterminated string", `token == "ETH`, "unterminated string"},
+		{"list of fields", `token in [from]`, "must be a string literal"},
+		{"fraction of wei", `value > 0.5`, "whole number"},
+		{"missing paren", `(value > 1`, `expected ")"`},
+		{"trailing tokens", `value > 1 value`, "unexpected"},
+		{"unknown symbol", `value = 1`, "unexpected"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.expression)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Compile() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package alert
+
+import (
+	"eth_parser/internal/domain/entity"
+	"log"
+	"sync"
+)
+
+const streamBuffer = 64
+
+// streams fans alerts out to the open event streams of each tenant. A
+// stream that does not keep up loses alerts instead of blocking the scan.
+type streams struct {
+	mutex   sync.Mutex
+	tenants map[string]map[chan entity.Alert]bool
+}
+
+func newStreams() *streams {
+	return &streams{
+		tenants: make(map[string]map[chan entity.Alert]bool),
+	}
+}
+
+func (s *streams) subscribe(tenant string) (<-chan entity.Alert, func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ch := make(chan entity.Alert, streamBuffer)
+	if s.tenants[tenant] == nil {
+		s.tenants[tenant] = make(map[chan entity.Alert]bool)
+	}
+	s.tenants[tenant][ch] = true
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+
+			delete(s.tenants[tenant], ch)
+			if len(s.tenants[tenant]) == 0 {
+				delete(s.tenants, tenant)
+			}
+		})
+	}
+}
+
+func (s *streams) publish(alert entity.Alert) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for ch := range s.tenants[alert.Tenant] {
+		select {
+		case ch <- alert:
+		default:
+			log.Printf("alert stream of tenant %s is full, dropping %s", alert.Tenant, alert.ID)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// secretPrefix marks API keys of this service, e.g. in secret scanners.
+	secretPrefix = "ek_"
+	prefixLength = len(secretPrefix) + 8
+	// bootstrapKeyID is the admin key configured through the environment.
+	bootstrapKeyID = "bootstrap"
+	// bootstrapPrefix stands in for the prefix of the bootstrap key, whose
+	// secret is chosen by the operator and may be short enough for a prefix
+	// to give it away.
+	bootstrapPrefix = "admin"
+)
+
+// Keys issues and checks API keys. Every key belongs to a tenant and only
+// sees the subscriptions of that tenant.
+type Keys struct {
+	keys repository.APIKeyRepo
+	now  func() time.Time
+
+	mutex sync.Mutex
+}
+
+func NewKeys(keys repository.APIKeyRepo) *Keys {
+	return &Keys{
+		keys: keys,
+		now:  time.Now,
+	}
+}
+
+// HashSecret returns the hex SHA-256 of secret. Secrets are random, so an
+// unsalted hash is enough to keep them out of the store.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create issues a new key of tenant and returns it with its secret, which
+// is not stored and cannot be shown again.
+func (k *Keys) Create(name, tenant string, admin bool) (entity.APIKey, string, error) {
+	if tenant == "" {
+		return entity.APIKey{}, "", fmt.Errorf("tenant is required")
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return entity.APIKey{}, "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	secret := secretPrefix + hex.EncodeToString(b)
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return entity.APIKey{}, "", fmt.Errorf("failed to generate key ID: %w", err)
+	}
+
+	key := entity.APIKey{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Tenant:    tenant,
+		Prefix:    secret[:prefixLength],
+		Hash:      HashSecret(secret),
+		Admin:     admin,
+		CreatedAt: k.now(),
+	}
+	if err := k.keys.StoreKey(key); err != nil {
+		return entity.APIKey{}, "", fmt.Errorf("failed to store key: %w", err)
+	}
+	return key, secret, nil
+}
+
+// EnsureAdmin registers secret as the bootstrap admin key. A changed
+// secret replaces the previous one, which stops working. A revoked key
+// stays revoked until the secret is changed.
+func (k *Keys) EnsureAdmin(secret string) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	hash := HashSecret(secret)
+	if key, ok := k.keys.GetKey(bootstrapKeyID); ok && key.Hash == hash && key.Prefix == bootstrapPrefix {
+		if key.Revoked() {
+			log.Println("auth: the ADMIN_KEY was revoked, set a new one to use it again")
+		}
+		return nil
+	}
+
+	return k.keys.StoreKey(entity.APIKey{
+		ID:        bootstrapKeyID,
+		Name:      "bootstrap admin",
+		Tenant:    entity.DefaultTenant,
+		Prefix:    bootstrapPrefix,
+		Hash:      hash,
+		Admin:     true,
+		CreatedAt: k.now(),
+	})
+}
+
+// Revoke disables a key for good. It reports false for unknown keys.
+func (k *Keys) Revoke(id string) (bool, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	key, ok := k.keys.GetKey(id)
+	if !ok {
+		return false, nil
+	}
+	if key.Revoked() {
+		return true, nil
+	}
+
+	now := k.now()
+	key.RevokedAt = &now
+	if err := k.keys.StoreKey(key); err != nil {
+		return false, fmt.Errorf("failed to store key: %w", err)
+	}
+	return true, nil
+}
+
+func (k *Keys) List() []entity.APIKey {
+	return k.keys.ListKeys()
+}
+
+// Authenticate returns the active key matching secret.
+func (k *Keys) Authenticate(secret string) (entity.APIKey, bool) {
+	if secret == "" {
+		return entity.APIKey{}, false
+	}
+
+	hash := HashSecret(secret)
+	key, ok := k.keys.GetKeyByHash(hash)
+	if !ok || key.Revoked() {
+		return entity.APIKey{}, false
+	}
+	return key, true
+}
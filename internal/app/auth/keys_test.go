@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/domain/entity"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeys(t *testing.T) {
+	keys := NewKeys(repo.NewMemoryAPIKeyRepo())
+
+	if _, _, err := keys.Create("ci", "", false); err == nil {
+		t.Error("expected an error for a key without tenant")
+	}
+
+	key, secret, err := keys.Create("ci", "ops", false)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if !strings.HasPrefix(secret, key.Prefix) || strings.Contains(key.Hash, secret) {
+		t.Errorf("unexpected key %+v for secret %s", key, secret)
+	}
+
+	got, ok := keys.Authenticate(secret)
+	if !ok || got.ID != key.ID {
+		t.Fatalf("expected key %s, got %+v, %v", key.ID, got, ok)
+	}
+	if _, ok := keys.Authenticate(secret + "x"); ok {
+		t.Error("authenticated a wrong secret")
+	}
+
+	if got.Tenant != "ops" {
+		t.Errorf("expected tenant ops, got %s", got.Tenant)
+	}
+
+	found, err := keys.Revoke(key.ID)
+	if err != nil || !found {
+		t.Fatalf("Revoke() = %v, %v", found, err)
+	}
+	if _, ok := keys.Authenticate(secret); ok {
+		t.Error("authenticated a revoked key")
+	}
+	if found, _ := keys.Revoke("missing"); found {
+		t.Error("revoked an unknown key")
+	}
+}
+
+func TestEnsureAdmin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	store, err := repo.NewFileAPIKeyRepo(path)
+	if err != nil {
+		t.Fatalf("NewFileAPIKeyRepo() error = %v", err)
+	}
+	keys := NewKeys(store)
+
+	if err := keys.EnsureAdmin("first"); err != nil {
+		t.Fatalf("EnsureAdmin() error = %v", err)
+	}
+	if key, ok := keys.Authenticate("first"); !ok || !key.Admin || key.Tenant != entity.DefaultTenant {
+		t.Errorf("expected an admin key, got %+v, %v", key, ok)
+	}
+	// The operator's secret is not stored, not even partly.
+	if data, err := os.ReadFile(path); err != nil || strings.Contains(string(data), `"fir`) {
+		t.Errorf("keys.json leaks the admin secret: %s, %v", data, err)
+	}
+
+	// A rotated secret replaces the old one, also after a restart.
+	if err := keys.EnsureAdmin("second"); err != nil {
+		t.Fatalf("EnsureAdmin() error = %v", err)
+	}
+	store, err = repo.NewFileAPIKeyRepo(path)
+	if err != nil {
+		t.Fatalf("NewFileAPIKeyRepo() error = %v", err)
+	}
+	keys = NewKeys(store)
+	if _, ok := keys.Authenticate("first"); ok {
+		t.Error("the old admin secret still works")
+	}
+	if _, ok := keys.Authenticate("second"); !ok {
+		t.Error("the new admin secret does not work")
+	}
+	if n := len(keys.List()); n != 1 {
+		t.Errorf("expected 1 key, got %d", n)
+	}
+
+	// A revoked key is not re-enabled by a restart with the same secret.
+	if _, err := keys.Revoke(bootstrapKeyID); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if err := keys.EnsureAdmin("second"); err != nil {
+		t.Fatalf("EnsureAdmin() error = %v", err)
+	}
+	if _, ok := keys.Authenticate("second"); ok {
+		t.Error("the revoked admin secret works again")
+	}
+}
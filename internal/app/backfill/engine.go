@@ -0,0 +1,342 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"eth_parser/internal/domain/repository"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScanFunc scans the inclusive block range [from, to] and returns how many
+// results it found there.
+type ScanFunc func(ctx context.Context, from, to uint64) (int, error)
+
+type Config struct {
+	// InitialChunk is the number of blocks requested by the first eth_getLogs call.
+	InitialChunk uint64
+	// MinChunk and MaxChunk bound the adaptive chunk size.
+	MinChunk uint64
+	MaxChunk uint64
+	// Concurrency is the number of chunks scanned at the same time.
+	Concurrency int
+	// SparseThreshold is the result count under which the chunk size grows.
+	SparseThreshold int
+	// MaxRetries is how many times a failing chunk is retried before giving up.
+	MaxRetries int
+	// RetryDelay is the base delay between retries of a failing chunk.
+	RetryDelay time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		InitialChunk:    2_000,
+		MinChunk:        1,
+		MaxChunk:        100_000,
+		Concurrency:     4,
+		SparseThreshold: 100,
+		MaxRetries:      3,
+		RetryDelay:      500 * time.Millisecond,
+	}
+}
+
+type Result struct {
+	BlocksScanned uint64
+	Found         int
+}
+
+type Engine struct {
+	cfg         Config
+	checkpoints repository.CheckpointRepo
+}
+
+func NewEngine(checkpoints repository.CheckpointRepo, cfg Config) *Engine {
+	if cfg.MinChunk == 0 {
+		cfg.MinChunk = 1
+	}
+	if cfg.MaxChunk < cfg.MinChunk {
+		cfg.MaxChunk = cfg.MinChunk
+	}
+	if cfg.InitialChunk < cfg.MinChunk || cfg.InitialChunk > cfg.MaxChunk {
+		cfg.InitialChunk = cfg.MinChunk
+	}
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+
+	return &Engine{
+		cfg:         cfg,
+		checkpoints: checkpoints,
+	}
+}
+
+// IsRangeTooLarge reports whether err is a provider rejecting an eth_getLogs
+// call because the block range or the result set was too big. Only the
+// phrases providers use for that are matched, so other failures such as
+// rate limits or invalid parameters are not split into ever smaller ranges.
+func IsRangeTooLarge(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, pattern := range []string{
+		// geth, Infura
+		"query returned more than",
+		// Erigon
+		"query exceeds max results",
+		// Alchemy
+		"log response size exceeded",
+		// BSC, Ankr
+		"exceed maximum block range",
+		// publicnode, Erigon
+		"exceeds max block range",
+		// QuickNode
+		"eth_getlogs is limited to",
+		// Chainstack, Cloudflare
+		"block range is too wide",
+		"block range too large",
+		"range is too large",
+	} {
+		if strings.Contains(msg, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+type blockRange struct {
+	from, to uint64
+}
+
+func (r blockRange) size() uint64 {
+	return r.to - r.from + 1
+}
+
+// run holds the state of a single Engine.Run call.
+type run struct {
+	engine *Engine
+	key    string
+	to     uint64
+
+	mutex     sync.Mutex
+	cond      *sync.Cond
+	next      uint64
+	chunk     uint64
+	retry     []blockRange
+	inflight  int
+	completed map[uint64]uint64
+	watermark uint64
+	result    Result
+	err       error
+}
+
+// ErrBeforeStart is returned by Run for a range starting before the first
+// block ever scanned under its key. The checkpoint only tells how far the
+// scan got, so resuming it would skip those blocks.
+var ErrBeforeStart = errors.New("range starts before the blocks scanned under this key")
+
+// Run scans [from, to] in adaptive chunks. A key scanned before resumes from
+// its checkpoint, even when from is later, so the blocks under a key stay
+// contiguous; a from earlier than the first block scanned under key fails
+// with ErrBeforeStart. Progress is checkpointed as contiguous ranges
+// complete.
+func (e *Engine) Run(ctx context.Context, key string, from, to uint64, scan ScanFunc) (Result, error) {
+	if next, ok := e.checkpoints.GetCheckpoint(key); ok {
+		// Keys checkpointed before their start was recorded were scanned
+		// from genesis.
+		start, _ := e.checkpoints.GetCheckpoint(startKey(key))
+		if from < start {
+			return Result{}, fmt.Errorf("%w: %s was scanned from block %d, not %d", ErrBeforeStart, key, start, from)
+		}
+		from = next
+	} else if err := e.checkpoints.StoreCheckpoint(startKey(key), from); err != nil {
+		return Result{}, fmt.Errorf("failed to store checkpoint: %w", err)
+	}
+	if from > to {
+		return Result{}, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r := &run{
+		engine:    e,
+		key:       key,
+		to:        to,
+		next:      from,
+		chunk:     e.cfg.InitialChunk,
+		completed: make(map[uint64]uint64),
+		watermark: from,
+	}
+	r.cond = sync.NewCond(&r.mutex)
+
+	// Wake up idle workers when the run is cancelled from outside.
+	stop := context.AfterFunc(ctx, func() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.cond.Broadcast()
+	})
+	defer stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < e.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx, cancel, scan)
+		}()
+	}
+	wg.Wait()
+
+	if r.err == nil && ctx.Err() != nil {
+		r.err = ctx.Err()
+	}
+	return r.result, r.err
+}
+
+// startKey is where the first block scanned under key is kept.
+func startKey(key string) string {
+	return key + ":start"
+}
+
+func (r *run) work(ctx context.Context, cancel context.CancelFunc, scan ScanFunc) {
+	for {
+		br, ok := r.take(ctx)
+		if !ok {
+			return
+		}
+
+		found, err := r.scanWithRetry(ctx, br, scan)
+		if err != nil && IsRangeTooLarge(err) && br.size() > 1 {
+			r.split(br)
+			continue
+		}
+		if err != nil {
+			r.fail(fmt.Errorf("failed to scan blocks %d-%d: %w", br.from, br.to, err))
+			cancel()
+			return
+		}
+
+		r.complete(br, found)
+	}
+}
+
+// take hands out the next range to scan, waiting while other workers may
+// still push split ranges back into the queue.
+func (r *run) take(ctx context.Context) (blockRange, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for {
+		if ctx.Err() != nil || r.err != nil {
+			return blockRange{}, false
+		}
+
+		if n := len(r.retry); n > 0 {
+			br := r.retry[n-1]
+			r.retry = r.retry[:n-1]
+			r.inflight++
+			return br, true
+		}
+
+		if r.next <= r.to {
+			end := r.to
+			if r.to-r.next >= r.chunk {
+				end = r.next + r.chunk - 1
+			}
+			br := blockRange{from: r.next, to: end}
+			r.next = end + 1
+			r.inflight++
+			return br, true
+		}
+
+		if r.inflight == 0 {
+			return blockRange{}, false
+		}
+		r.cond.Wait()
+	}
+}
+
+func (r *run) scanWithRetry(ctx context.Context, br blockRange, scan ScanFunc) (int, error) {
+	cfg := r.engine.cfg
+
+	var err error
+	for attempt := 0; attempt <= cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(cfg.RetryDelay * time.Duration(attempt)):
+			}
+		}
+
+		var found int
+		found, err = scan(ctx, br.from, br.to)
+		if err == nil || IsRangeTooLarge(err) || errors.Is(err, context.Canceled) {
+			return found, err
+		}
+	}
+	return 0, err
+}
+
+// split re-queues both halves of a range the provider refused and shrinks
+// the chunk size for the ranges that follow.
+func (r *run) split(br blockRange) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	half := br.size() / 2
+	r.retry = append(r.retry,
+		blockRange{from: br.from + half, to: br.to},
+		blockRange{from: br.from, to: br.from + half - 1},
+	)
+
+	r.chunk = max(r.engine.cfg.MinChunk, half)
+	r.inflight--
+	r.cond.Broadcast()
+}
+
+func (r *run) complete(br blockRange, found int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.result.BlocksScanned += br.size()
+	r.result.Found += found
+	if found < r.engine.cfg.SparseThreshold && br.size() >= r.chunk {
+		r.chunk = min(r.engine.cfg.MaxChunk, r.chunk*2)
+	}
+
+	r.completed[br.from] = br.to
+	advanced := false
+	for {
+		end, ok := r.completed[r.watermark]
+		if !ok {
+			break
+		}
+		delete(r.completed, r.watermark)
+		r.watermark = end + 1
+		advanced = true
+	}
+	if advanced {
+		if err := r.engine.checkpoints.StoreCheckpoint(r.key, r.watermark); err != nil && r.err == nil {
+			r.err = fmt.Errorf("failed to store checkpoint: %w", err)
+		}
+	}
+
+	r.inflight--
+	r.cond.Broadcast()
+}
+
+func (r *run) fail(err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.err == nil {
+		r.err = err
+	}
+	r.inflight--
+	r.cond.Broadcast()
+}
@@ -0,0 +1,216 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"eth_parser/internal/app/repo"
+	"sync"
+	"testing"
+)
+
+func testConfig() Config {
+	return Config{
+		InitialChunk:    64,
+		MinChunk:        1,
+		MaxChunk:        1024,
+		Concurrency:     4,
+		SparseThreshold: 10,
+		MaxRetries:      1,
+	}
+}
+
+type coverage struct {
+	mutex   sync.Mutex
+	scanned map[uint64]int
+	calls   int
+}
+
+func (c *coverage) mark(from, to uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.calls++
+	for b := from; b <= to; b++ {
+		c.scanned[b]++
+	}
+}
+
+func TestEngineRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to uint64
+		maxRange uint64
+		wantErr  bool
+	}{
+		{
+			name: "single chunk",
+			from: 0,
+			to:   10,
+		},
+		{
+			name:     "provider limits range",
+			from:     100,
+			to:       5_000,
+			maxRange: 50,
+		},
+		{
+			name:     "provider rejects single blocks",
+			from:     0,
+			to:       3,
+			maxRange: 0,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cov := &coverage{scanned: make(map[uint64]int)}
+			checkpoints := repo.NewMemoryCheckpointRepo()
+			engine := NewEngine(checkpoints, testConfig())
+
+			scan := func(ctx context.Context, from, to uint64) (int, error) {
+				if tt.wantErr {
+					return 0, errors.New("query returned more than 10000 results")
+				}
+				if tt.maxRange > 0 && to-from+1 > tt.maxRange {
+					return 0, errors.New("query returned more than 10000 results")
+				}
+				cov.mark(from, to)
+				return 1, nil
+			}
+
+			result, err := engine.Run(context.Background(), "key", tt.from, tt.to, scan)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			for b := tt.from; b <= tt.to; b++ {
+				if cov.scanned[b] != 1 {
+					t.Fatalf("block %d scanned %d times", b, cov.scanned[b])
+				}
+			}
+			if result.BlocksScanned != tt.to-tt.from+1 {
+				t.Errorf("BlocksScanned = %d, want %d", result.BlocksScanned, tt.to-tt.from+1)
+			}
+			if next, ok := checkpoints.GetCheckpoint("key"); !ok || next != tt.to+1 {
+				t.Errorf("checkpoint = %d, %v, want %d", next, ok, tt.to+1)
+			}
+		})
+	}
+}
+
+func TestEngineGrowsChunkWhenSparse(t *testing.T) {
+	cov := &coverage{scanned: make(map[uint64]int)}
+	cfg := testConfig()
+	cfg.Concurrency = 1
+	engine := NewEngine(repo.NewMemoryCheckpointRepo(), cfg)
+
+	_, err := engine.Run(context.Background(), "key", 0, 10_000, func(ctx context.Context, from, to uint64) (int, error) {
+		cov.mark(from, to)
+		return 0, nil
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// 64+128+256+512 and then 1024 sized chunks.
+	if cov.calls > 14 {
+		t.Errorf("expected chunk size to grow, got %d calls", cov.calls)
+	}
+}
+
+func TestEngineResumesFromCheckpoint(t *testing.T) {
+	checkpoints := repo.NewMemoryCheckpointRepo()
+	checkpoints.StoreCheckpoint("key", 500)
+	engine := NewEngine(checkpoints, testConfig())
+
+	cov := &coverage{scanned: make(map[uint64]int)}
+	_, err := engine.Run(context.Background(), "key", 0, 1_000, func(ctx context.Context, from, to uint64) (int, error) {
+		cov.mark(from, to)
+		return 0, nil
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if cov.scanned[499] != 0 || cov.scanned[500] != 1 || cov.scanned[1_000] != 1 {
+		t.Errorf("expected scan to resume at block 500")
+	}
+}
+
+func TestEngineKeepsScannedBlocksContiguous(t *testing.T) {
+	checkpoints := repo.NewMemoryCheckpointRepo()
+	engine := NewEngine(checkpoints, testConfig())
+	scan := func(ctx context.Context, from, to uint64) (int, error) {
+		return 0, nil
+	}
+
+	if _, err := engine.Run(context.Background(), "key", 100, 200, scan); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, err := engine.Run(context.Background(), "key", 0, 300, scan); !errors.Is(err, ErrBeforeStart) {
+		t.Errorf("Run() from an earlier block error = %v, want ErrBeforeStart", err)
+	}
+
+	// A later start does not leave a gap after the checkpoint.
+	cov := &coverage{scanned: make(map[uint64]int)}
+	_, err := engine.Run(context.Background(), "key", 250, 300, func(ctx context.Context, from, to uint64) (int, error) {
+		cov.mark(from, to)
+		return 0, nil
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if cov.scanned[200] != 0 || cov.scanned[201] != 1 || cov.scanned[300] != 1 {
+		t.Errorf("expected the scan to continue at block 201")
+	}
+}
+
+func TestEngineStopsOnError(t *testing.T) {
+	checkpoints := repo.NewMemoryCheckpointRepo()
+	engine := NewEngine(checkpoints, testConfig())
+
+	_, err := engine.Run(context.Background(), "key", 0, 1_000, func(ctx context.Context, from, to uint64) (int, error) {
+		if from <= 200 && 200 <= to {
+			return 0, errors.New("connection reset")
+		}
+		return 0, nil
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if next, _ := checkpoints.GetCheckpoint("key"); next > 200 {
+		t.Errorf("checkpoint %d moved past the failed block", next)
+	}
+}
+
+func TestIsRangeTooLarge(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("query returned more than 10000 results"), true},
+		{errors.New("Log response size exceeded. You can make eth_getLogs requests with up to a 2K block range"), true},
+		{errors.New("exceed maximum block range: 50000"), true},
+		{errors.New("query exceeds max block range 50000"), true},
+		{errors.New("eth_getLogs is limited to a 10,000 range"), true},
+		{errors.New("block range is too wide"), true},
+		{errors.New("connection refused"), false},
+		{errors.New("invalid block range params"), false},
+		{errors.New("more than 25 requests per second"), false},
+		{errors.New("429 Too Many Requests"), false},
+		{errors.New("daily request count exceeded, request rate limited (-32005)"), false},
+		{errors.New("rate limit exceeded"), false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRangeTooLarge(tt.err); got != tt.want {
+			t.Errorf("IsRangeTooLarge(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package backfill
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// maxJobErrors bounds how many error messages are kept on a job.
+const maxJobErrors = 20
+
+// RangeScanner imports the transactions of addresses for a block range.
+// A job holds LockAddress for its address while it runs, and calls
+// Backfilled once it has imported the whole of [from, to].
+type RangeScanner interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+	LockAddress(ctx context.Context, address string) (unlock func(), err error)
+	ScanRange(ctx context.Context, addresses []string, from, to uint64) (int, error)
+	Backfilled(ctx context.Context, address string, from, to uint64) error
+}
+
+// JobManager runs backfill jobs in the background and keeps their progress
+// in a JobRepo, so unfinished jobs are picked up again by Start.
+type JobManager struct {
+	jobs    repository.JobRepo
+	engine  *Engine
+	scanner RangeScanner
+
+	ctx     context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+	mutex   sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func NewJobManager(jobs repository.JobRepo, engine *Engine, scanner RangeScanner) *JobManager {
+	ctx, stop := context.WithCancel(context.Background())
+	return &JobManager{
+		jobs:    jobs,
+		engine:  engine,
+		scanner: scanner,
+		ctx:     ctx,
+		stop:    stop,
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// Start resumes every job that had not finished when the manager last stopped.
+func (m *JobManager) Start() {
+	for _, job := range m.jobs.ListJobs() {
+		if !job.Done() {
+			log.Printf("Resuming backfill job %s for %s", job.ID, job.Address)
+			m.launch(job)
+		}
+	}
+}
+
+// Stop interrupts running jobs without marking them finished and waits for them to return.
+func (m *JobManager) Stop() {
+	m.stop()
+	m.wg.Wait()
+}
+
+// Create schedules a scan of address from fromBlock to toBlock. A toBlock of
+// zero means the latest block at creation time.
+func (m *JobManager) Create(ctx context.Context, address string, fromBlock, toBlock uint64) (entity.BackfillJob, error) {
+	if toBlock == 0 {
+		latest, err := m.scanner.BlockNumber(ctx)
+		if err != nil {
+			return entity.BackfillJob{}, err
+		}
+		toBlock = latest
+	}
+	if fromBlock > toBlock {
+		return entity.BackfillJob{}, fmt.Errorf("from block %d is after to block %d", fromBlock, toBlock)
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return entity.BackfillJob{}, err
+	}
+
+	now := time.Now().UTC()
+	job := entity.BackfillJob{
+		ID:        id,
+		Address:   address,
+		FromBlock: fromBlock,
+		ToBlock:   toBlock,
+		Status:    entity.JobPending,
+		Errors:    []string{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := m.jobs.StoreJob(job); err != nil {
+		return entity.BackfillJob{}, fmt.Errorf("failed to store job: %w", err)
+	}
+
+	m.launch(job)
+	return job, nil
+}
+
+func (m *JobManager) Get(id string) (entity.BackfillJob, bool) {
+	return m.jobs.GetJob(id)
+}
+
+// Cancel stops a job and keeps it with status cancelled, returning false if
+// it does not exist. Finished jobs are left as they are.
+func (m *JobManager) Cancel(id string) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	job, ok := m.jobs.GetJob(id)
+	if !ok {
+		return false, nil
+	}
+	if job.Done() {
+		return true, nil
+	}
+
+	job.Status = entity.JobCancelled
+	job.UpdatedAt = time.Now().UTC()
+	if err := m.jobs.StoreJob(job); err != nil {
+		return true, fmt.Errorf("failed to store job: %w", err)
+	}
+
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+	}
+	return true, nil
+}
+
+func (m *JobManager) launch(job entity.BackfillJob) {
+	ctx, cancel := context.WithCancel(m.ctx)
+
+	m.mutex.Lock()
+	m.cancels[job.ID] = cancel
+	m.mutex.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer func() {
+			m.mutex.Lock()
+			delete(m.cancels, job.ID)
+			m.mutex.Unlock()
+			cancel()
+		}()
+
+		m.run(ctx, job)
+	}()
+}
+
+func (m *JobManager) run(ctx context.Context, job entity.BackfillJob) {
+	key := jobCheckpointKey(job.ID)
+	progress := &jobProgress{
+		manager:   m,
+		job:       job,
+		watermark: job.FromBlock,
+		completed: make(map[uint64]chunk),
+	}
+
+	if next, ok := m.engine.checkpoints.GetCheckpoint(key); ok && next > job.FromBlock {
+		progress.job.BlocksScanned = next - job.FromBlock
+		progress.watermark = next
+	}
+	progress.update(func(job *entity.BackfillJob) {
+		job.Status = entity.JobRunning
+	})
+
+	err := m.scan(ctx, job, progress)
+
+	switch {
+	case err == nil:
+		progress.update(func(job *entity.BackfillJob) {
+			job.Status = entity.JobCompleted
+			job.BlocksScanned = job.ToBlock - job.FromBlock + 1
+		})
+	case ctx.Err() != nil:
+		// Cancelled jobs are already marked and stopped ones resume on the next Start.
+	default:
+		progress.update(func(job *entity.BackfillJob) {
+			job.Status = entity.JobFailed
+			addJobError(job, err)
+		})
+	}
+}
+
+// scan imports the range of job while no other scan is busy with its
+// address. Retries and ranges split for the provider are recovered from by
+// the engine, so only the error ending the job is returned.
+func (m *JobManager) scan(ctx context.Context, job entity.BackfillJob, progress *jobProgress) error {
+	unlock, err := m.scanner.LockAddress(ctx, job.Address)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	_, err = m.engine.Run(ctx, jobCheckpointKey(job.ID), job.FromBlock, job.ToBlock, func(ctx context.Context, from, to uint64) (int, error) {
+		found, err := m.scanner.ScanRange(ctx, []string{job.Address}, from, to)
+		if err != nil {
+			return found, err
+		}
+		progress.complete(from, to, found)
+		return found, nil
+	})
+	if err != nil {
+		return err
+	}
+	return m.scanner.Backfilled(ctx, job.Address, job.FromBlock, job.ToBlock)
+}
+
+// jobProgress serialises updates of a running job coming from concurrent chunks.
+type jobProgress struct {
+	manager *JobManager
+	mutex   sync.Mutex
+	job     entity.BackfillJob
+
+	// watermark is the first block not scanned yet; completed holds the
+	// chunks scanned past it, by first block.
+	watermark uint64
+	completed map[uint64]chunk
+}
+
+type chunk struct {
+	to    uint64
+	found int
+}
+
+// complete records a scanned chunk. Like the checkpoint, chunks only count
+// once every block before them is scanned, so the chunks past it that are
+// scanned again after a resume are not counted twice.
+func (p *jobProgress) complete(from, to uint64, found int) {
+	p.update(func(job *entity.BackfillJob) {
+		p.completed[from] = chunk{to: to, found: found}
+		for {
+			c, ok := p.completed[p.watermark]
+			if !ok {
+				break
+			}
+			delete(p.completed, p.watermark)
+			job.BlocksScanned += c.to - p.watermark + 1
+			job.TransactionsFound += c.found
+			p.watermark = c.to + 1
+		}
+	})
+}
+
+func (p *jobProgress) update(fn func(job *entity.BackfillJob)) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.manager.mutex.Lock()
+	defer p.manager.mutex.Unlock()
+
+	// The job may have been cancelled in the meantime.
+	if stored, ok := p.manager.jobs.GetJob(p.job.ID); !ok || stored.Status == entity.JobCancelled {
+		return
+	}
+
+	fn(&p.job)
+	p.job.PercentComplete = percent(p.job.BlocksScanned, p.job.ToBlock-p.job.FromBlock+1)
+	p.job.UpdatedAt = time.Now().UTC()
+	if err := p.manager.jobs.StoreJob(p.job); err != nil {
+		log.Println(fmt.Errorf("failed to store job %s: %w", p.job.ID, err))
+	}
+}
+
+func addJobError(job *entity.BackfillJob, err error) {
+	job.Errors = append(job.Errors, err.Error())
+	if len(job.Errors) > maxJobErrors {
+		job.Errors = job.Errors[len(job.Errors)-maxJobErrors:]
+	}
+}
+
+func percent(done, total uint64) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(min(done, total)) * 100 / float64(total)
+}
+
+func jobCheckpointKey(id string) string {
+	return "job:" + id
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
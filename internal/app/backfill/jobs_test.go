@@ -0,0 +1,299 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/domain/entity"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockRangeScanner struct {
+	mutex   sync.Mutex
+	latest  uint64
+	scanned uint64
+	// block, when set, makes scans wait until the context is cancelled.
+	block bool
+	// stallBelow makes scans of ranges starting before it block like block.
+	stallBelow uint64
+	// maxRange, when set, makes wider ranges fail as too large.
+	maxRange uint64
+	// flaky makes the first scan of every range fail.
+	flaky  bool
+	failed map[uint64]bool
+	err    error
+	// lock, when set, is the lock of the scanned address.
+	lock chan struct{}
+}
+
+func (m *mockRangeScanner) BlockNumber(ctx context.Context) (uint64, error) {
+	return m.latest, nil
+}
+
+func (m *mockRangeScanner) LockAddress(ctx context.Context, address string) (func(), error) {
+	if m.lock == nil {
+		return func() {}, nil
+	}
+	select {
+	case m.lock <- struct{}{}:
+		return func() { <-m.lock }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (m *mockRangeScanner) Backfilled(ctx context.Context, address string, from, to uint64) error {
+	return nil
+}
+
+func (m *mockRangeScanner) ScanRange(ctx context.Context, addresses []string, from, to uint64) (int, error) {
+	m.mutex.Lock()
+	block, err := m.block || from < m.stallBelow, m.err
+	if m.maxRange > 0 && to-from+1 > m.maxRange {
+		err = errors.New("query returned more than 10000 results")
+	} else if m.flaky && !m.failed[from] {
+		if m.failed == nil {
+			m.failed = make(map[uint64]bool)
+		}
+		m.failed[from] = true
+		err = errors.New("connection reset by peer")
+	}
+	m.mutex.Unlock()
+
+	if block {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.scanned += to - from + 1
+	// One transaction per block makes double counting visible.
+	return int(to - from + 1), nil
+}
+
+func (m *mockRangeScanner) blocksScanned() uint64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.scanned
+}
+
+func waitForJob(t *testing.T, manager *JobManager, id string, status entity.JobStatus) entity.BackfillJob {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, ok := manager.Get(id)
+		if ok && job.Status == status {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	job, _ := manager.Get(id)
+	t.Fatalf("job %s has status %q, want %q", id, job.Status, status)
+	return job
+}
+
+func TestJobManagerCompletesJob(t *testing.T) {
+	scanner := &mockRangeScanner{latest: 999}
+	manager := NewJobManager(repo.NewMemoryJobRepo(), NewEngine(repo.NewMemoryCheckpointRepo(), testConfig()), scanner)
+	defer manager.Stop()
+
+	job, err := manager.Create(context.Background(), "0x123", 0, 0)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if job.ToBlock != 999 {
+		t.Errorf("ToBlock = %d, want latest block 999", job.ToBlock)
+	}
+
+	job = waitForJob(t, manager, job.ID, entity.JobCompleted)
+	if job.BlocksScanned != 1000 || job.PercentComplete != 100 {
+		t.Errorf("BlocksScanned = %d, PercentComplete = %v", job.BlocksScanned, job.PercentComplete)
+	}
+	if job.TransactionsFound != 1000 {
+		t.Errorf("TransactionsFound = %d, want 1000", job.TransactionsFound)
+	}
+}
+
+func TestJobManagerRecordsOnlyFatalErrors(t *testing.T) {
+	scanner := &mockRangeScanner{latest: 999, maxRange: 16, flaky: true}
+	manager := NewJobManager(repo.NewMemoryJobRepo(), NewEngine(repo.NewMemoryCheckpointRepo(), testConfig()), scanner)
+	defer manager.Stop()
+
+	job, err := manager.Create(context.Background(), "0x123", 0, 0)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	job = waitForJob(t, manager, job.ID, entity.JobCompleted)
+	if len(job.Errors) != 0 {
+		t.Errorf("Errors = %v, want none for retried and split ranges", job.Errors)
+	}
+	if job.TransactionsFound != 1000 {
+		t.Errorf("TransactionsFound = %d, want 1000", job.TransactionsFound)
+	}
+}
+
+func TestJobManagerRecordsFailure(t *testing.T) {
+	scanner := &mockRangeScanner{latest: 10, err: errors.New("connection refused")}
+	manager := NewJobManager(repo.NewMemoryJobRepo(), NewEngine(repo.NewMemoryCheckpointRepo(), testConfig()), scanner)
+	defer manager.Stop()
+
+	job, err := manager.Create(context.Background(), "0x123", 0, 10)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	job = waitForJob(t, manager, job.ID, entity.JobFailed)
+	if len(job.Errors) != 1 {
+		t.Errorf("Errors = %v, want the error that failed the job", job.Errors)
+	}
+}
+
+func TestJobManagerWaitsForOtherScansOfTheAddress(t *testing.T) {
+	scanner := &mockRangeScanner{latest: 99, lock: make(chan struct{}, 1)}
+	manager := NewJobManager(repo.NewMemoryJobRepo(), NewEngine(repo.NewMemoryCheckpointRepo(), testConfig()), scanner)
+	defer manager.Stop()
+
+	// Another scan is busy with the address.
+	scanner.lock <- struct{}{}
+	job, err := manager.Create(context.Background(), "0x123", 0, 0)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	waitForJob(t, manager, job.ID, entity.JobRunning)
+	time.Sleep(20 * time.Millisecond)
+	if scanned := scanner.blocksScanned(); scanned != 0 {
+		t.Fatalf("scanned %d blocks while the address was locked", scanned)
+	}
+
+	<-scanner.lock
+	waitForJob(t, manager, job.ID, entity.JobCompleted)
+	if len(scanner.lock) != 0 {
+		t.Error("the job kept the address locked after finishing")
+	}
+}
+
+func TestJobManagerCancel(t *testing.T) {
+	scanner := &mockRangeScanner{latest: 10, block: true}
+	manager := NewJobManager(repo.NewMemoryJobRepo(), NewEngine(repo.NewMemoryCheckpointRepo(), testConfig()), scanner)
+	defer manager.Stop()
+
+	job, err := manager.Create(context.Background(), "0x123", 0, 10)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if found, err := manager.Cancel(job.ID); !found || err != nil {
+		t.Fatalf("Cancel() = %v, %v", found, err)
+	}
+	if got, ok := manager.Get(job.ID); !ok || got.Status != entity.JobCancelled {
+		t.Errorf("Get() = %+v, %v, want the job with status cancelled", got, ok)
+	}
+	if found, _ := manager.Cancel("unknown"); found {
+		t.Error("cancelling an unknown job should report not found")
+	}
+
+	// The scan returning on cancellation must not overwrite the status.
+	time.Sleep(20 * time.Millisecond)
+	if got, _ := manager.Get(job.ID); got.Status != entity.JobCancelled {
+		t.Errorf("Status = %q, want cancelled", got.Status)
+	}
+
+	done, err := manager.Create(context.Background(), "0x123", 0, 10)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	scanner.mutex.Lock()
+	scanner.block = false
+	scanner.mutex.Unlock()
+	waitForJob(t, manager, done.ID, entity.JobCompleted)
+	if _, err := manager.Cancel(done.ID); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if got, _ := manager.Get(done.ID); got.Status != entity.JobCompleted {
+		t.Errorf("Status = %q, finished jobs should not be cancelled", got.Status)
+	}
+}
+
+func TestJobManagerResumesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	open := func() (*repo.FileJobRepo, *repo.FileCheckpointRepo) {
+		jobs, err := repo.NewFileJobRepo(filepath.Join(dir, "jobs.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkpoints, err := repo.NewFileCheckpointRepo(filepath.Join(dir, "checkpoints.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return jobs, checkpoints
+	}
+
+	jobs, checkpoints := open()
+	scanner := &mockRangeScanner{latest: 100, block: true}
+	manager := NewJobManager(jobs, NewEngine(checkpoints, testConfig()), scanner)
+
+	job, err := manager.Create(context.Background(), "0x123", 0, 100)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	waitForJob(t, manager, job.ID, entity.JobRunning)
+	manager.Stop()
+
+	jobs, checkpoints = open()
+	scanner = &mockRangeScanner{latest: 100}
+	manager = NewJobManager(jobs, NewEngine(checkpoints, testConfig()), scanner)
+	manager.Start()
+	defer manager.Stop()
+
+	waitForJob(t, manager, job.ID, entity.JobCompleted)
+}
+
+func TestJobManagerCountsResumedChunksOnce(t *testing.T) {
+	dir := t.TempDir()
+	open := func() (*repo.FileJobRepo, *repo.FileCheckpointRepo) {
+		jobs, err := repo.NewFileJobRepo(filepath.Join(dir, "jobs.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkpoints, err := repo.NewFileCheckpointRepo(filepath.Join(dir, "checkpoints.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return jobs, checkpoints
+	}
+
+	// The first chunk never finishes, so the chunks after it are scanned
+	// past the checkpoint and scanned again after the restart.
+	jobs, checkpoints := open()
+	scanner := &mockRangeScanner{latest: 999, stallBelow: 1}
+	manager := NewJobManager(jobs, NewEngine(checkpoints, testConfig()), scanner)
+
+	job, err := manager.Create(context.Background(), "0x123", 0, 0)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for scanner.blocksScanned() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	manager.Stop()
+
+	jobs, checkpoints = open()
+	manager = NewJobManager(jobs, NewEngine(checkpoints, testConfig()), &mockRangeScanner{latest: 999})
+	manager.Start()
+	defer manager.Stop()
+
+	job = waitForJob(t, manager, job.ID, entity.JobCompleted)
+	if job.TransactionsFound != 1000 {
+		t.Errorf("TransactionsFound = %d, want 1000", job.TransactionsFound)
+	}
+}
@@ -0,0 +1,290 @@
+package balance
+
+import (
+	"context"
+	"errors"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"eth_parser/internal/utils"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNotScanned is returned when balances are reconciled at a block the
+// history of the address has not been imported up to, or at a block before
+// the address was opened.
+var ErrNotScanned = errors.New("block is not scanned yet")
+
+// ChainReader reads balances from the chain at a given block tag or hex number.
+type ChainReader interface {
+	BalanceAt(ctx context.Context, address, block string) (*big.Int, error)
+	TokenBalanceAt(ctx context.Context, token, address, block string) (*big.Int, error)
+}
+
+// Tracker maintains running balances of addresses from the balances they
+// held when they were opened and the transfers observed for them since.
+type Tracker struct {
+	transfers repository.TransferStore
+	balances  repository.BalanceRepo
+
+	// mutex keeps running balances in step with the openings they start from.
+	mutex sync.Mutex
+}
+
+func NewTracker(transfers repository.TransferStore, balances repository.BalanceRepo) *Tracker {
+	return &Tracker{
+		transfers: transfers,
+		balances:  balances,
+	}
+}
+
+// Open records the balances address held before block, the first block its
+// transfers are booked from: its ether and the tokens it has openings of,
+// read from chain at the block before. Openings only move back, to a block
+// older history has been imported from, so an address opened at block or
+// earlier is left alone.
+func (t *Tracker) Open(ctx context.Context, chain ChainReader, address string, block uint64) error {
+	tokens := []string{entity.NativeToken}
+	if native, ok := t.Opening(address, entity.NativeToken); ok {
+		if native.Block <= block {
+			return nil
+		}
+		tokens = tokens[:0]
+		for _, opening := range t.balances.GetOpenings(address) {
+			tokens = append(tokens, opening.Token)
+		}
+	}
+
+	for _, token := range tokens {
+		if err := t.open(ctx, chain, address, token, block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OpenToken records the balance of token address held at the block its
+// ether was opened at. Tokens opened before and addresses not opened yet
+// are left alone.
+func (t *Tracker) OpenToken(ctx context.Context, chain ChainReader, address, token string) error {
+	native, ok := t.Opening(address, entity.NativeToken)
+	if !ok {
+		return nil
+	}
+	if _, ok := t.Opening(address, token); ok {
+		return nil
+	}
+	return t.open(ctx, chain, address, token, native.Block)
+}
+
+func (t *Tracker) open(ctx context.Context, chain ChainReader, address, token string, block uint64) error {
+	amount := new(big.Int)
+	if block > 0 {
+		var err error
+		before := utils.IntToHex(block - 1)
+		if token == entity.NativeToken {
+			amount, err = chain.BalanceAt(ctx, address, before)
+		} else {
+			amount, err = chain.TokenBalanceAt(ctx, token, address, before)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get the opening %s balance: %w", token, err)
+		}
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// Another call may have opened token at an older block meanwhile.
+	if opened, ok := t.Opening(address, token); ok && opened.Block <= block {
+		return nil
+	}
+	opening := entity.OpeningBalance{Token: token, Block: block, Amount: amount.String()}
+	if err := t.balances.StoreOpening(address, opening); err != nil {
+		return fmt.Errorf("failed to store opening balance: %w", err)
+	}
+	return t.rebase(address, token)
+}
+
+// rebase sets the running balance of token to its opening plus the
+// transfers booked since.
+func (t *Tracker) rebase(address, token string) error {
+	want, ok := t.totals(address, math.MaxUint64)[normalizeToken(token)]
+	if !ok {
+		return nil
+	}
+	have := new(big.Int)
+	for _, balance := range t.balances.GetBalances(address) {
+		if strings.EqualFold(balance.Token, token) {
+			have.SetString(balance.Amount, 10)
+		}
+	}
+	return t.balances.AdjustBalance(address, token, want.Sub(want, have))
+}
+
+// Opening returns the opening balance of token of address.
+func (t *Tracker) Opening(address, token string) (entity.OpeningBalance, bool) {
+	for _, opening := range t.balances.GetOpenings(address) {
+		if strings.EqualFold(opening.Token, token) {
+			return opening, true
+		}
+	}
+	return entity.OpeningBalance{}, false
+}
+
+// Apply books transfer against the balance of address. Transfers already
+// applied for address are ignored, so rescanning a range is harmless, and
+// transfers before the opening of their token are only stored, as the
+// opening includes them.
+func (t *Tracker) Apply(address string, transfer entity.Transfer) error {
+	value, ok := new(big.Int).SetString(transfer.Value, 10)
+	if !ok {
+		return fmt.Errorf("invalid transfer value %q", transfer.Value)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	stored, err := t.transfers.StoreTransfer(address, transfer)
+	if err != nil {
+		return fmt.Errorf("failed to store transfer: %w", err)
+	}
+	if !stored {
+		return nil
+	}
+	if opening, ok := t.Opening(address, transfer.Token); ok && transfer.BlockNumber < opening.Block {
+		return nil
+	}
+
+	return t.balances.AdjustBalance(address, transfer.Token, delta(address, transfer, value))
+}
+
+// delta is what transfer adds to the balance of address.
+func delta(address string, transfer entity.Transfer, value *big.Int) *big.Int {
+	delta := new(big.Int)
+	if strings.EqualFold(transfer.To, address) && transfer.Kind != entity.TransferFee {
+		delta.Add(delta, value)
+	}
+	if strings.EqualFold(transfer.From, address) {
+		delta.Sub(delta, value)
+	}
+	return delta
+}
+
+func (t *Tracker) GetBalances(address string) []entity.Balance {
+	return t.balances.GetBalances(address)
+}
+
+func (t *Tracker) GetTransfers(address string) []entity.Transfer {
+	return t.transfers.GetTransfers(address)
+}
+
+// BalancesAt adds the transfers of address booked up to and including
+// block to its opening balances, ordered like GetBalances. block must not
+// be before the opening.
+func (t *Tracker) BalancesAt(address string, block uint64) []entity.Balance {
+	totals := t.totals(address, block)
+	balances := make([]entity.Balance, 0, len(totals))
+	for token, amount := range totals {
+		balances = append(balances, entity.Balance{Token: token, Amount: amount.String()})
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		if balances[i].Token == entity.NativeToken || balances[j].Token == entity.NativeToken {
+			return balances[i].Token == entity.NativeToken
+		}
+		return balances[i].Token < balances[j].Token
+	})
+	return balances
+}
+
+// totals sums the balances of address up to and including block by token.
+// Addresses opened before openings were recorded count every transfer.
+func (t *Tracker) totals(address string, block uint64) map[string]*big.Int {
+	totals := make(map[string]*big.Int)
+	openings := make(map[string]uint64)
+	for _, opening := range t.balances.GetOpenings(address) {
+		amount, ok := new(big.Int).SetString(opening.Amount, 10)
+		if !ok {
+			continue
+		}
+		token := normalizeToken(opening.Token)
+		totals[token] = amount
+		openings[token] = opening.Block
+	}
+
+	for _, transfer := range t.transfers.GetTransfers(address) {
+		value, ok := new(big.Int).SetString(transfer.Value, 10)
+		if !ok || transfer.BlockNumber > block {
+			continue
+		}
+		token := normalizeToken(transfer.Token)
+		if opened, ok := openings[token]; ok && transfer.BlockNumber < opened {
+			continue
+		}
+		if _, ok := totals[token]; !ok {
+			totals[token] = new(big.Int)
+		}
+		totals[token].Add(totals[token], delta(address, transfer, value))
+	}
+	return totals
+}
+
+func normalizeToken(token string) string {
+	if token == entity.NativeToken {
+		return token
+	}
+	return strings.ToLower(token)
+}
+
+// Reconcile compares the balances of address tracked up to block with the
+// chain state at the same block and reports the drift of each token.
+func (t *Tracker) Reconcile(ctx context.Context, chain ChainReader, address string, block uint64) (entity.Reconciliation, error) {
+	result := entity.Reconciliation{
+		Address:  address,
+		Block:    utils.IntToHex(block),
+		InSync:   true,
+		Balances: []entity.BalanceDrift{},
+	}
+
+	if native, ok := t.Opening(address, entity.NativeToken); ok && block+1 < native.Block {
+		return entity.Reconciliation{}, fmt.Errorf("%w: %s is tracked from block %d", ErrNotScanned, address, native.Block)
+	}
+
+	balances := t.BalancesAt(address, block)
+	if len(balances) == 0 || balances[0].Token != entity.NativeToken {
+		balances = append([]entity.Balance{{Token: entity.NativeToken, Amount: "0"}}, balances...)
+	}
+
+	for _, balance := range balances {
+		var (
+			onChain *big.Int
+			err     error
+		)
+		if balance.Token == entity.NativeToken {
+			onChain, err = chain.BalanceAt(ctx, address, result.Block)
+		} else {
+			onChain, err = chain.TokenBalanceAt(ctx, balance.Token, address, result.Block)
+		}
+		if err != nil {
+			return entity.Reconciliation{}, fmt.Errorf("failed to get %s balance: %w", balance.Token, err)
+		}
+
+		tracked, _ := new(big.Int).SetString(balance.Amount, 10)
+		drift := new(big.Int).Sub(onChain, tracked)
+		if drift.Sign() != 0 {
+			result.InSync = false
+		}
+
+		result.Balances = append(result.Balances, entity.BalanceDrift{
+			Token:   balance.Token,
+			Tracked: tracked.String(),
+			OnChain: onChain.String(),
+			Drift:   drift.String(),
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,161 @@
+package balance
+
+import (
+	"context"
+	"errors"
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/domain/entity"
+	"math/big"
+	"testing"
+)
+
+type mockChainReader struct {
+	native *big.Int
+	tokens map[string]*big.Int
+	// block is the block last asked for.
+	block string
+	// at, when set, answers ether balances by block instead of native.
+	at map[string]*big.Int
+}
+
+func (m *mockChainReader) BalanceAt(ctx context.Context, address, block string) (*big.Int, error) {
+	m.block = block
+	if m.at != nil {
+		return m.at[block], nil
+	}
+	return m.native, nil
+}
+
+func (m *mockChainReader) TokenBalanceAt(ctx context.Context, token, address, block string) (*big.Int, error) {
+	m.block = block
+	return m.tokens[token], nil
+}
+
+func TestTrackerApply(t *testing.T) {
+	tracker := NewTracker(repo.NewMemoryTransferStore(), repo.NewMemoryBalanceRepo())
+
+	transfers := []entity.Transfer{
+		{ID: "1:native", Kind: entity.TransferNative, Token: entity.NativeToken, From: "0xa", To: "0xb", Value: "50"},
+		{ID: "1:fee", Kind: entity.TransferFee, Token: entity.NativeToken, From: "0xb", Value: "3"},
+		{ID: "2:log:0x0", Kind: entity.TransferToken, Token: "0xt", From: "0xb", To: "0xc", Value: "7"},
+		// Applying a transfer twice has no effect.
+		{ID: "1:native", Kind: entity.TransferNative, Token: entity.NativeToken, From: "0xa", To: "0xb", Value: "50"},
+	}
+	for _, transfer := range transfers {
+		if err := tracker.Apply("0xB", transfer); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+	}
+
+	want := []entity.Balance{
+		{Token: entity.NativeToken, Amount: "47"},
+		{Token: "0xt", Amount: "-7"},
+	}
+	got := tracker.GetBalances("0xb")
+	if len(got) != len(want) {
+		t.Fatalf("GetBalances() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("GetBalances()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if err := tracker.Apply("0xb", entity.Transfer{ID: "3", Value: "abc"}); err == nil {
+		t.Error("expected invalid value to be rejected")
+	}
+}
+
+func TestTrackerReconcile(t *testing.T) {
+	tracker := NewTracker(repo.NewMemoryTransferStore(), repo.NewMemoryBalanceRepo())
+	tracker.Apply("0xb", entity.Transfer{ID: "1", BlockNumber: 1, Token: entity.NativeToken, To: "0xb", Value: "100"})
+	tracker.Apply("0xb", entity.Transfer{ID: "2", BlockNumber: 2, Token: "0xt", To: "0xb", Value: "5"})
+	// Booked past the reconciled block, so left out of both sides.
+	tracker.Apply("0xb", entity.Transfer{ID: "3", BlockNumber: 4, Token: entity.NativeToken, To: "0xb", Value: "1"})
+
+	chain := &mockChainReader{
+		native: big.NewInt(120),
+		tokens: map[string]*big.Int{"0xt": big.NewInt(5)},
+	}
+
+	result, err := tracker.Reconcile(context.Background(), chain, "0xb", 3)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if chain.block != "0x3" || result.Block != "0x3" {
+		t.Errorf("reconciled at %s against the chain at %s, want 0x3", result.Block, chain.block)
+	}
+
+	if result.InSync {
+		t.Error("expected drift to be reported")
+	}
+	if len(result.Balances) != 2 {
+		t.Fatalf("Reconcile() balances = %v", result.Balances)
+	}
+	if result.Balances[0].Drift != "20" || result.Balances[1].Drift != "0" {
+		t.Errorf("unexpected drift: %+v", result.Balances)
+	}
+}
+
+func TestTrackerOpen(t *testing.T) {
+	tracker := NewTracker(repo.NewMemoryTransferStore(), repo.NewMemoryBalanceRepo())
+	// 0xb holds 100 wei and 5 tokens when tracking starts at block 10.
+	chain := &mockChainReader{
+		at:     map[string]*big.Int{"0x9": big.NewInt(100), "0x4": big.NewInt(80)},
+		tokens: map[string]*big.Int{"0xt": big.NewInt(5)},
+	}
+	if err := tracker.Open(context.Background(), chain, "0xb", 10); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if chain.block != "0x9" {
+		t.Errorf("opened at %s, want the block before 0xa", chain.block)
+	}
+
+	if err := tracker.OpenToken(context.Background(), chain, "0xb", "0xt"); err != nil {
+		t.Fatalf("OpenToken() error = %v", err)
+	}
+	tracker.Apply("0xb", entity.Transfer{ID: "1", BlockNumber: 12, Token: entity.NativeToken, To: "0xb", Value: "20"})
+	tracker.Apply("0xb", entity.Transfer{ID: "2", BlockNumber: 12, Token: "0xT", From: "0xb", Value: "2"})
+	// Already part of the opening.
+	tracker.Apply("0xb", entity.Transfer{ID: "3", BlockNumber: 7, Token: entity.NativeToken, To: "0xb", Value: "20"})
+
+	want := []entity.Balance{{Token: entity.NativeToken, Amount: "120"}, {Token: "0xt", Amount: "3"}}
+	assertBalances(t, tracker.GetBalances("0xb"), want)
+	assertBalances(t, tracker.BalancesAt("0xb", 12), want)
+
+	chain.tokens["0xt"] = big.NewInt(3)
+	chain.at["0xc"] = big.NewInt(120)
+	result, err := tracker.Reconcile(context.Background(), chain, "0xb", 12)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if !result.InSync {
+		t.Errorf("expected the opening to be reconciled, got %+v", result.Balances)
+	}
+	if _, err := tracker.Reconcile(context.Background(), chain, "0xb", 8); !errors.Is(err, ErrNotScanned) {
+		t.Errorf("Reconcile() before the opening error = %v, want %v", err, ErrNotScanned)
+	}
+
+	// Opening at a later block changes nothing, at an earlier one counts
+	// the transfers since then.
+	if err := tracker.Open(context.Background(), chain, "0xb", 11); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	chain.tokens["0xt"] = big.NewInt(5)
+	if err := tracker.Open(context.Background(), chain, "0xb", 5); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	assertBalances(t, tracker.GetBalances("0xb"), []entity.Balance{{Token: entity.NativeToken, Amount: "120"}, {Token: "0xt", Amount: "3"}})
+}
+
+func assertBalances(t *testing.T, got, want []entity.Balance) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("balances = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("balances[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
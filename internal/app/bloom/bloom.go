@@ -0,0 +1,125 @@
+// Package bloom reads the logs bloom of block headers and receipts, which
+// tells for sure when a block has no log of a contract or with a topic.
+package bloom
+
+import (
+	"encoding/hex"
+	"eth_parser/internal/crypto"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Length is the size of a bloom in bytes.
+const Length = 256
+
+// Bloom is the 2048-bit filter of the addresses and topics of a set of logs.
+type Bloom [Length]byte
+
+// Parse decodes a hex bloom as returned by the node.
+func Parse(s string) (Bloom, error) {
+	var b Bloom
+	raw, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return b, fmt.Errorf("bloom is not hex: %w", err)
+	}
+	if len(raw) != Length {
+		return b, fmt.Errorf("bloom has %d bytes, want %d", len(raw), Length)
+	}
+	copy(b[:], raw)
+	return b, nil
+}
+
+// Of returns the bloom of logs given as their address and topics.
+func Of(logs ...[]string) Bloom {
+	var b Bloom
+	for _, values := range logs {
+		for _, value := range values {
+			b.AddHex(value)
+		}
+	}
+	return b
+}
+
+// Add sets the three bits of data.
+func (b *Bloom) Add(data []byte) {
+	for _, bit := range bits(data) {
+		b[Length-1-bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// AddHex adds a hex address or topic. Values that are not hex are ignored.
+func (b *Bloom) AddHex(value string) {
+	if raw, err := hex.DecodeString(strings.TrimPrefix(value, "0x")); err == nil {
+		b.Add(raw)
+	}
+}
+
+// Test tells whether data may have been added. False positives are
+// possible, false negatives are not.
+func (b Bloom) Test(data []byte) bool {
+	for _, bit := range bits(data) {
+		if b[Length-1-bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// TestHex tests a hex address or topic. Values that are not hex may be in
+// any bloom.
+func (b Bloom) TestHex(value string) bool {
+	raw, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+	return err != nil || b.Test(raw)
+}
+
+func (b Bloom) String() string {
+	return "0x" + hex.EncodeToString(b[:])
+}
+
+// bits are the three 11-bit numbers taken from the start of the hash of data.
+func bits(data []byte) [3]uint {
+	hash := crypto.Keccak256(data)
+	var bits [3]uint
+	for i := range bits {
+		bits[i] = (uint(hash[2*i])<<8 | uint(hash[2*i+1])) & (8*Length - 1)
+	}
+	return bits
+}
+
+// Stats counts the blocks a consumer checked against their bloom. Every
+// skipped block is a log request saved, every false positive one made for
+// nothing. It is safe for concurrent use.
+type Stats struct {
+	checked        atomic.Uint64
+	skipped        atomic.Uint64
+	falsePositives atomic.Uint64
+}
+
+// Snapshot is a copy of the counters of Stats.
+type Snapshot struct {
+	Checked        uint64 `json:"checked"`
+	Skipped        uint64 `json:"skipped"`
+	FalsePositives uint64 `json:"falsePositives"`
+}
+
+// Check records a block whose bloom was tested, and whether it was a hit.
+func (s *Stats) Check(hit bool) {
+	s.checked.Add(1)
+	if !hit {
+		s.skipped.Add(1)
+	}
+}
+
+// FalsePositive records a hit whose logs held nothing of interest.
+func (s *Stats) FalsePositive() {
+	s.falsePositives.Add(1)
+}
+
+func (s *Stats) Snapshot() Snapshot {
+	return Snapshot{
+		Checked:        s.checked.Load(),
+		Skipped:        s.skipped.Load(),
+		FalsePositives: s.falsePositives.Load(),
+	}
+}
@@ -0,0 +1,64 @@
+package bloom
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBloom(t *testing.T) {
+	var b Bloom
+	for _, data := range []string{"testtest", "test", "hallo", "other"} {
+		b.Add([]byte(data))
+	}
+
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{data: "testtest", want: true},
+		{data: "test", want: true},
+		{data: "hallo", want: true},
+		{data: "other", want: true},
+		{data: "tes", want: false},
+		{data: "lo", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			if got := b.Test([]byte(tt.data)); got != tt.want {
+				t.Errorf("Test(%q) = %v, want %v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	const (
+		token    = "0x4444444444444444444444444444444444444444"
+		transfer = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	)
+	b := Of([]string{token, transfer})
+
+	parsed, err := Parse(b.String())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if parsed != b {
+		t.Fatal("Parse() does not return the formatted bloom")
+	}
+	if !parsed.TestHex(token) || !parsed.TestHex(strings.ToUpper(transfer[2:])) {
+		t.Error("expected the added values to be found")
+	}
+	if parsed.TestHex("0x5555555555555555555555555555555555555555") {
+		t.Error("expected another address not to be found")
+	}
+	if !parsed.TestHex("not hex") {
+		t.Error("expected values that are not hex to be possible")
+	}
+
+	for _, s := range []string{"", "0x00", "0x" + strings.Repeat("zz", Length)} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q) should fail", s)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package ens
+
+import (
+	"container/list"
+	"time"
+)
+
+// maxCachedEntries bounds each cache, so that listing transactions of many
+// counterparties does not grow them without end.
+const maxCachedEntries = 10_000
+
+// entry is a cached answer, "" when there is none.
+type entry struct {
+	key     string
+	value   string
+	expires time.Time
+}
+
+// cache keeps the most recently used answers until they expire. It is not
+// safe for concurrent use.
+type cache struct {
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+func newCache(size int) *cache {
+	return &cache{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// get returns the answer for key unless it is missing or expired at now.
+func (c *cache) get(key string, now time.Time) (entry, bool) {
+	element, ok := c.items[key]
+	if !ok {
+		return entry{}, false
+	}
+	cached := element.Value.(entry)
+	if !now.Before(cached.expires) {
+		c.order.Remove(element)
+		delete(c.items, key)
+		return entry{}, false
+	}
+	c.order.MoveToFront(element)
+	return cached, true
+}
+
+// put stores an answer, evicting the least recently used one when full.
+func (c *cache) put(cached entry) {
+	if element, ok := c.items[cached.key]; ok {
+		element.Value = cached
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[cached.key] = c.order.PushFront(cached)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(entry).key)
+	}
+}
+
+func (c *cache) len() int {
+	return c.order.Len()
+}
@@ -0,0 +1,338 @@
+// Package ens resolves ENS names to addresses and addresses to their primary
+// names by calling the registry and resolver contracts.
+package ens
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"eth_parser/internal/app/abi"
+	"eth_parser/internal/crypto"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/rpc"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const zeroAddress = "0x0000000000000000000000000000000000000000"
+
+var (
+	// ErrInvalidName is returned for names that cannot be hashed.
+	ErrInvalidName = errors.New("invalid ENS name")
+	// ErrNotFound is returned for names without a resolver or an address.
+	ErrNotFound = errors.New("ENS name not found")
+)
+
+var (
+	resolverFunction = mustParse("resolver(bytes32)")
+	addrFunction     = mustParse("addr(bytes32)")
+	nameFunction     = mustParse("name(bytes32)")
+	addressResult    = mustParse("result(address)").Inputs
+	stringResult     = mustParse("result(string)").Inputs
+)
+
+var addressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+func mustParse(signature string) abi.Function {
+	function, err := abi.ParseSignature(signature)
+	if err != nil {
+		panic(err)
+	}
+	return function
+}
+
+// IsName tells whether s is meant as an ENS name rather than an address.
+func IsName(s string) bool {
+	return strings.Contains(s, ".") && !addressPattern.MatchString(s)
+}
+
+// Normalize lowercases name and checks that its labels are not empty. Names
+// are not mapped with the full ENSIP-15 rules, so names outside ASCII must
+// already be normalized.
+func Normalize(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", fmt.Errorf("%w: empty name", ErrInvalidName)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || strings.ContainsFunc(label, unicode.IsSpace) {
+			return "", fmt.Errorf("%w: %q", ErrInvalidName, name)
+		}
+	}
+	return name, nil
+}
+
+// Namehash returns the node of a normalized name, as used by the registry.
+func Namehash(name string) [32]byte {
+	var node [32]byte
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		copy(node[:], crypto.Keccak256(node[:], crypto.Keccak256([]byte(labels[i]))))
+	}
+	return node
+}
+
+// Resolver answers from caches whose entries live for ttl and that keep
+// the most recently used maxCachedEntries answers each. Names tracked
+// with Track are re-resolved by Run at the same interval, and the OnChange
+// callbacks are told when they move to another address.
+type Resolver struct {
+	caller   rpc.Caller
+	registry string
+	ttl      time.Duration
+	now      func() time.Time
+
+	mutex    sync.Mutex
+	forward  *cache
+	reverse  *cache
+	tracked  map[string]string
+	onChange []func(name, old, new string)
+}
+
+func NewResolver(caller rpc.Caller, registry string, ttl time.Duration) *Resolver {
+	return &Resolver{
+		caller:   caller,
+		registry: registry,
+		ttl:      ttl,
+		now:      time.Now,
+		forward:  newCache(maxCachedEntries),
+		reverse:  newCache(maxCachedEntries),
+		tracked:  make(map[string]string),
+	}
+}
+
+// OnChange registers f to be called when a tracked name resolves to a new
+// address. Callbacks must be registered before Run.
+func (r *Resolver) OnChange(f func(name, old, new string)) {
+	r.onChange = append(r.onChange, f)
+}
+
+// Track keeps name fresh while Run is going, starting from address.
+func (r *Resolver) Track(name, address string) {
+	name, err := Normalize(name)
+	if err != nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.tracked[name] = strings.ToLower(address)
+}
+
+// Resolve returns the lowercase address name points to.
+func (r *Resolver) Resolve(ctx context.Context, name string) (string, error) {
+	name, err := Normalize(name)
+	if err != nil {
+		return "", err
+	}
+
+	r.mutex.Lock()
+	cached, ok := r.forward.get(name, r.now())
+	r.mutex.Unlock()
+	if !ok {
+		address, err := r.resolve(ctx, name)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return "", err
+		}
+		cached = r.store(r.forward, name, address)
+	}
+	if cached.value == "" {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return cached.value, nil
+}
+
+// Lookup returns the primary name of address. Like other clients, it only
+// trusts a reverse record whose name resolves back to address.
+func (r *Resolver) Lookup(ctx context.Context, address string) (string, bool) {
+	address = strings.ToLower(address)
+
+	r.mutex.Lock()
+	cached, ok := r.reverse.get(address, r.now())
+	r.mutex.Unlock()
+	if ok {
+		return cached.value, cached.value != ""
+	}
+
+	name, err := r.lookup(ctx, address)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to look up the ENS name of %s: %w", address, err))
+		return "", false
+	}
+	cached = r.store(r.reverse, address, name)
+	return cached.value, cached.value != ""
+}
+
+// Annotate returns copies of txs with the primary names of their sender and
+// recipient.
+func (r *Resolver) Annotate(ctx context.Context, txs []entity.Transaction) []entity.Transaction {
+	annotated := make([]entity.Transaction, len(txs))
+	for i, tx := range txs {
+		if name, ok := r.Lookup(ctx, tx.From); ok {
+			tx.FromENS = name
+		}
+		if tx.To != nil {
+			if name, ok := r.Lookup(ctx, *tx.To); ok {
+				tx.ToENS = name
+			}
+		}
+		annotated[i] = tx
+	}
+	return annotated
+}
+
+// Run re-resolves the tracked names every ttl until ctx is cancelled.
+func (r *Resolver) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.ttl)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Refresh(ctx)
+		}
+	}
+}
+
+// Refresh re-resolves the tracked names now. A name that stopped resolving
+// keeps its last address.
+func (r *Resolver) Refresh(ctx context.Context) {
+	r.mutex.Lock()
+	tracked := make(map[string]string, len(r.tracked))
+	for name, address := range r.tracked {
+		tracked[name] = address
+	}
+	r.mutex.Unlock()
+
+	for name, old := range tracked {
+		address, err := r.resolve(ctx, name)
+		if err != nil {
+			log.Println(fmt.Errorf("failed to re-resolve %s: %w", name, err))
+			continue
+		}
+		r.store(r.forward, name, address)
+		if address == old {
+			continue
+		}
+
+		r.mutex.Lock()
+		r.tracked[name] = address
+		r.mutex.Unlock()
+		for _, f := range r.onChange {
+			f(name, old, address)
+		}
+	}
+}
+
+func (r *Resolver) store(cache *cache, key, value string) entry {
+	cached := entry{key: key, value: value, expires: r.now().Add(r.ttl)}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	cache.put(cached)
+	return cached
+}
+
+// resolve asks the resolver of name for its address.
+func (r *Resolver) resolve(ctx context.Context, name string) (string, error) {
+	node := Namehash(name)
+	resolver, err := r.resolverOf(ctx, node)
+	if err != nil {
+		return "", err
+	}
+	address, err := r.callAddress(ctx, resolver, addrFunction, node)
+	if err != nil {
+		return "", fmt.Errorf("failed to call addr on resolver %s: %w", resolver, err)
+	}
+	if address == zeroAddress {
+		return "", ErrNotFound
+	}
+	return address, nil
+}
+
+// lookup reads the reverse record of address and checks it, returning ""
+// when there is no valid one.
+func (r *Resolver) lookup(ctx context.Context, address string) (string, error) {
+	node := Namehash(strings.TrimPrefix(address, "0x") + ".addr.reverse")
+	resolver, err := r.resolverOf(ctx, node)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var result string
+	if err := r.call(ctx, resolver, nameFunction, node, &result); err != nil {
+		return "", fmt.Errorf("failed to call name on resolver %s: %w", resolver, err)
+	}
+	raw, err := hex.DecodeString(strings.TrimPrefix(result, "0x"))
+	if err != nil || len(raw) == 0 {
+		return "", nil
+	}
+	values, err := abi.DecodeArguments(stringResult, raw)
+	if err != nil {
+		return "", nil
+	}
+	name, err := Normalize(values[0].Value.(string))
+	if err != nil {
+		return "", nil
+	}
+
+	forward, err := r.resolve(ctx, name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+	if forward != address {
+		return "", nil
+	}
+	return name, nil
+}
+
+func (r *Resolver) resolverOf(ctx context.Context, node [32]byte) (string, error) {
+	resolver, err := r.callAddress(ctx, r.registry, resolverFunction, node)
+	if err != nil {
+		return "", fmt.Errorf("failed to call the ENS registry: %w", err)
+	}
+	if resolver == zeroAddress {
+		return "", ErrNotFound
+	}
+	return resolver, nil
+}
+
+// callAddress calls function(node) on contract and decodes the address it
+// returns. Contracts that return nothing answer the zero address.
+func (r *Resolver) callAddress(ctx context.Context, contract string, function abi.Function, node [32]byte) (string, error) {
+	var result string
+	if err := r.call(ctx, contract, function, node, &result); err != nil {
+		return "", err
+	}
+	raw, err := hex.DecodeString(strings.TrimPrefix(result, "0x"))
+	if err != nil {
+		return "", fmt.Errorf("result is not hex: %w", err)
+	}
+	if len(raw) == 0 {
+		return zeroAddress, nil
+	}
+	values, err := abi.DecodeArguments(addressResult, raw)
+	if err != nil {
+		return "", err
+	}
+	return values[0].Value.(string), nil
+}
+
+func (r *Resolver) call(ctx context.Context, contract string, function abi.Function, node [32]byte, result *string) error {
+	selector := function.Selector()
+	data := "0x" + hex.EncodeToString(selector[:]) + hex.EncodeToString(node[:])
+	return r.caller.Call(ctx, "eth_call", []any{map[string]any{"to": contract, "data": data}, "latest"}, result)
+}
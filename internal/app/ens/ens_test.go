@@ -0,0 +1,225 @@
+package ens
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"eth_parser/internal/domain/entity"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	registry = "0x00000000000c2e074ec69a0dfb2997ba6c7d2e1e"
+	resolver = "0x4976fb03c32e5b8cfe2b6ccb31c09ba78ebaba41"
+	vitalik  = "0xd8da6bf26964af9d7eed9e10e5a4cb3a2ad0b9dd"
+	other    = "0x1111111111111111111111111111111111111111"
+)
+
+// fakeRegistry answers eth_call for a registry whose names all use one
+// resolver, with the records in addrs and names keyed by name.
+type fakeRegistry struct {
+	addrs map[string]string
+	names map[string]string
+	calls int
+}
+
+func (r *fakeRegistry) Call(ctx context.Context, method string, params []any, result any) error {
+	r.calls++
+	call := params[0].(map[string]any)
+	to, data := call["to"].(string), call["data"].(string)
+
+	nodes := make(map[string]string)
+	for name := range r.addrs {
+		node := Namehash(name)
+		nodes[hex.EncodeToString(node[:])] = name
+	}
+	for address := range r.names {
+		node := Namehash(strings.TrimPrefix(address, "0x") + ".addr.reverse")
+		nodes[hex.EncodeToString(node[:])] = address
+	}
+	key, ok := nodes[data[10:]]
+
+	answer := "0x"
+	switch {
+	case strings.EqualFold(to, registry) && data[:10] == "0x0178b8bf":
+		if ok {
+			answer = word(resolver)
+		}
+	case to == resolver && data[:10] == "0x3b3b57de":
+		if address, ok := r.addrs[key]; ok {
+			answer = word(address)
+		} else {
+			answer = word("0x0")
+		}
+	case to == resolver && data[:10] == "0x691f3431":
+		name := hex.EncodeToString([]byte(r.names[key]))
+		answer = word("0x20") + word(fmt.Sprintf("0x%x", len(r.names[key])))[2:] + name + strings.Repeat("0", (64-len(name)%64)%64)
+	default:
+		return fmt.Errorf("unexpected call to %s: %s", to, data)
+	}
+	*result.(*string) = answer
+	return nil
+}
+
+// word left-pads hex to 32 bytes.
+func word(hex string) string {
+	hex = strings.TrimPrefix(hex, "0x")
+	return "0x" + strings.Repeat("0", 64-len(hex)) + hex
+}
+
+func TestNamehash(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "", want: "0000000000000000000000000000000000000000000000000000000000000000"},
+		{name: "eth", want: "93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae"},
+		{name: "foo.eth", want: "de9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := Namehash(tt.name)
+			if got := hex.EncodeToString(node[:]); got != tt.want {
+				t.Errorf("Namehash(%q) = %s, want %s", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: " Vitalik.ETH ", want: "vitalik.eth"},
+		{name: "", wantErr: true},
+		{name: "vitalik..eth", wantErr: true},
+		{name: "vita lik.eth", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.name)
+			if tt.wantErr != errors.Is(err, ErrInvalidName) {
+				t.Fatalf("Normalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if IsName(vitalik) || !IsName("vitalik.eth") {
+		t.Error("IsName() should tell names from addresses")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	node := &fakeRegistry{addrs: map[string]string{"vitalik.eth": vitalik}}
+	now := time.Unix(0, 0)
+	r := NewResolver(node, registry, time.Hour)
+	r.now = func() time.Time { return now }
+
+	if got, err := r.Resolve(context.Background(), "Vitalik.eth"); err != nil || got != vitalik {
+		t.Fatalf("Resolve() = %s, %v, want %s", got, err, vitalik)
+	}
+	if _, err := r.Resolve(context.Background(), "nobody.eth"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Resolve(nobody.eth) error = %v, want ErrNotFound", err)
+	}
+
+	calls := node.calls
+	r.Resolve(context.Background(), "vitalik.eth")
+	r.Resolve(context.Background(), "nobody.eth")
+	if node.calls != calls {
+		t.Errorf("made %d calls for cached names", node.calls-calls)
+	}
+
+	node.addrs["vitalik.eth"] = other
+	now = now.Add(time.Hour)
+	if got, _ := r.Resolve(context.Background(), "vitalik.eth"); got != other {
+		t.Errorf("Resolve() after expiry = %s, want %s", got, other)
+	}
+}
+
+func TestCache(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := newCache(2)
+	c.put(entry{key: "a", value: "1", expires: now.Add(time.Hour)})
+	c.put(entry{key: "b", value: "2", expires: now.Add(time.Minute)})
+
+	// Reading a keeps it, so b is the least recently used.
+	if cached, ok := c.get("a", now); !ok || cached.value != "1" {
+		t.Errorf("get(a) = %+v, %t", cached, ok)
+	}
+	c.put(entry{key: "c", value: "3", expires: now.Add(time.Hour)})
+	if _, ok := c.get("b", now); ok {
+		t.Error("expected b to be evicted")
+	}
+	if c.len() != 2 {
+		t.Errorf("expected 2 entries, got %d", c.len())
+	}
+
+	// Expired entries are dropped when read.
+	if _, ok := c.get("a", now.Add(time.Hour)); ok {
+		t.Error("expected a to be expired")
+	}
+	if c.len() != 1 {
+		t.Errorf("expected 1 entry, got %d", c.len())
+	}
+}
+
+func TestLookup(t *testing.T) {
+	node := &fakeRegistry{
+		addrs: map[string]string{"vitalik.eth": vitalik},
+		names: map[string]string{vitalik: "vitalik.eth", other: "vitalik.eth"},
+	}
+	r := NewResolver(node, registry, time.Hour)
+
+	if name, ok := r.Lookup(context.Background(), "0x"+strings.ToUpper(vitalik[2:])); !ok || name != "vitalik.eth" {
+		t.Errorf("Lookup() = %q, %v, want vitalik.eth", name, ok)
+	}
+	if name, ok := r.Lookup(context.Background(), other); ok {
+		t.Errorf("Lookup() = %q, want the name not resolving back to be ignored", name)
+	}
+	if name, ok := r.Lookup(context.Background(), "0x2222222222222222222222222222222222222222"); ok {
+		t.Errorf("Lookup() = %q, want no name without a reverse record", name)
+	}
+
+	to := other
+	txs := r.Annotate(context.Background(), []entity.Transaction{{From: vitalik, To: &to}})
+	if txs[0].FromENS != "vitalik.eth" || txs[0].ToENS != "" {
+		t.Errorf("Annotate() = %+v", txs[0])
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	node := &fakeRegistry{addrs: map[string]string{"vitalik.eth": vitalik, "gone.eth": other}}
+	r := NewResolver(node, registry, time.Hour)
+
+	var changes []string
+	r.OnChange(func(name, old, address string) {
+		changes = append(changes, name+":"+old+">"+address)
+	})
+	r.Track("vitalik.eth", vitalik)
+	r.Track("gone.eth", other)
+
+	r.Refresh(context.Background())
+	if len(changes) != 0 {
+		t.Fatalf("unexpected changes %v", changes)
+	}
+
+	node.addrs["vitalik.eth"] = other
+	delete(node.addrs, "gone.eth")
+	r.Refresh(context.Background())
+	r.Refresh(context.Background())
+	if want := "vitalik.eth:" + vitalik + ">" + other; len(changes) != 1 || changes[0] != want {
+		t.Errorf("changes = %v, want [%s]", changes, want)
+	}
+	if got, _ := r.Resolve(context.Background(), "vitalik.eth"); got != other {
+		t.Errorf("Resolve() = %s, want the refreshed address", got)
+	}
+}
@@ -0,0 +1,351 @@
+// Package events stores the logs of the contract events tenants subscribed
+// to, decoded into named arguments where the event is known.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"eth_parser/internal/app/abi"
+	"eth_parser/internal/app/bloom"
+	"eth_parser/internal/app/logfilter"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"eth_parser/internal/domain/rpc"
+	"eth_parser/internal/utils"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidSubscription is returned for subscriptions with an invalid
+// contract, event or topic filter.
+var ErrInvalidSubscription = errors.New("invalid event subscription")
+
+// maxIndexed is the number of topics a log has besides the signature.
+const maxIndexed = 3
+
+var addressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// ABIs looks events up in the uploaded contract ABIs, e.g. abi.Decoder.
+type ABIs interface {
+	Event(address, topic string) (abi.Event, bool)
+}
+
+type compiledSubscription struct {
+	subscription entity.EventSubscription
+	event        abi.Event
+	filter       logfilter.Filter
+}
+
+// Watcher fetches the logs of every subscribed event in each new block and
+// stores them per subscription. It implements scanner.BlockHandler.
+type Watcher struct {
+	caller        rpc.Caller
+	subscriptions repository.EventSubscriptionRepo
+	logs          repository.EventLogRepo
+	abis          ABIs
+	now           func() time.Time
+
+	mutex    sync.RWMutex
+	compiled map[string]compiledSubscription
+	stats    bloom.Stats
+}
+
+// NewWatcher loads the stored subscriptions. abis may be nil.
+func NewWatcher(caller rpc.Caller, subscriptions repository.EventSubscriptionRepo, logs repository.EventLogRepo, abis ABIs) *Watcher {
+	w := &Watcher{
+		caller:        caller,
+		subscriptions: subscriptions,
+		logs:          logs,
+		abis:          abis,
+		now:           time.Now,
+		compiled:      make(map[string]compiledSubscription),
+	}
+
+	for _, subscription := range subscriptions.ListEventSubscriptions() {
+		compiled, err := compile(subscription)
+		if err != nil {
+			log.Println(fmt.Errorf("skipping event subscription %s: %w", subscription.ID, err))
+			continue
+		}
+		w.compiled[subscription.ID] = compiled
+	}
+	return w
+}
+
+// Subscribe validates and stores a new subscription of subscription.Tenant.
+// Its logs are collected from the next scanned block on.
+func (w *Watcher) Subscribe(subscription entity.EventSubscription) (entity.EventSubscription, error) {
+	if subscription.Tenant == "" {
+		return entity.EventSubscription{}, fmt.Errorf("tenant is required")
+	}
+	compiled, err := compile(subscription)
+	if err != nil {
+		return entity.EventSubscription{}, fmt.Errorf("%w: %w", ErrInvalidSubscription, err)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return entity.EventSubscription{}, fmt.Errorf("failed to generate subscription ID: %w", err)
+	}
+	subscription = compiled.subscription
+	subscription.ID = hex.EncodeToString(id)
+	subscription.CreatedAt = w.now()
+	compiled.subscription = subscription
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.subscriptions.StoreEventSubscription(subscription); err != nil {
+		return entity.EventSubscription{}, fmt.Errorf("failed to store event subscription: %w", err)
+	}
+	w.compiled[subscription.ID] = compiled
+	return subscription, nil
+}
+
+// compile normalizes subscription and builds the filter selecting its logs.
+func compile(subscription entity.EventSubscription) (compiledSubscription, error) {
+	if !addressPattern.MatchString(subscription.Contract) {
+		return compiledSubscription{}, fmt.Errorf("invalid contract address %q", subscription.Contract)
+	}
+	event, err := abi.ParseEvent(subscription.Event)
+	if err != nil {
+		return compiledSubscription{}, err
+	}
+
+	indexed := maxIndexed
+	if event.Indexed != nil {
+		indexed = 0
+		for _, ok := range event.Indexed {
+			if ok {
+				indexed++
+			}
+		}
+	}
+	if len(subscription.Topics) > indexed {
+		return compiledSubscription{}, fmt.Errorf("%d topic filters for %d indexed arguments", len(subscription.Topics), indexed)
+	}
+
+	topics := make([][]string, len(subscription.Topics))
+	for i, values := range subscription.Topics {
+		for _, value := range values {
+			topic, err := logfilter.Topic(value)
+			if err != nil {
+				return compiledSubscription{}, err
+			}
+			topics[i] = append(topics[i], topic)
+		}
+	}
+
+	subscription.Contract = strings.ToLower(subscription.Contract)
+	subscription.Event = strings.TrimSpace(subscription.Event)
+	subscription.Topic0 = event.Topic()
+	subscription.Topics = topics
+	if len(topics) == 0 {
+		subscription.Topics = nil
+	}
+	return compiledSubscription{
+		subscription: subscription,
+		event:        event,
+		filter: logfilter.Filter{
+			Addresses: []string{subscription.Contract},
+			Topics:    append([][]string{{subscription.Topic0}}, topics...),
+		},
+	}, nil
+}
+
+// Get returns the subscription id of tenant.
+func (w *Watcher) Get(tenant, id string) (entity.EventSubscription, bool) {
+	subscription, ok := w.subscriptions.GetEventSubscription(id)
+	if !ok || subscription.Tenant != tenant {
+		return entity.EventSubscription{}, false
+	}
+	return subscription, true
+}
+
+// List returns the subscriptions of tenant, oldest first.
+func (w *Watcher) List(tenant string) []entity.EventSubscription {
+	subscriptions := []entity.EventSubscription{}
+	for _, subscription := range w.subscriptions.ListEventSubscriptions() {
+		if subscription.Tenant == tenant {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions
+}
+
+// Delete removes the subscription id of tenant. Its stored logs are kept.
+func (w *Watcher) Delete(tenant, id string) (bool, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if subscription, ok := w.subscriptions.GetEventSubscription(id); !ok || subscription.Tenant != tenant {
+		return false, nil
+	}
+	if _, err := w.subscriptions.DeleteEventSubscription(id); err != nil {
+		return false, fmt.Errorf("failed to delete event subscription: %w", err)
+	}
+	delete(w.compiled, id)
+	return true, nil
+}
+
+// Logs returns the logs stored for the subscription id of tenant, in chain
+// order.
+func (w *Watcher) Logs(tenant, id string) ([]entity.EventLog, bool) {
+	if _, ok := w.Get(tenant, id); !ok {
+		return nil, false
+	}
+	return w.logs.ListEventLogs(id), true
+}
+
+// HandleBlock stores the logs of block matching a subscription. The logs of
+// all subscriptions are fetched with one request, which is not sent when
+// the logs bloom of block rules every subscription out.
+func (w *Watcher) HandleBlock(ctx context.Context, block *entity.Block) error {
+	b, err := bloom.Parse(block.LogsBloom)
+	prefilter := err == nil
+
+	w.mutex.RLock()
+	subscriptions := make([]compiledSubscription, 0, len(w.compiled))
+	filters := make([]logfilter.Filter, 0, len(w.compiled))
+	for _, compiled := range w.compiled {
+		if prefilter && !compiled.filter.MayMatch(b) {
+			continue
+		}
+		subscriptions = append(subscriptions, compiled)
+		filters = append(filters, compiled.filter)
+	}
+	total := len(w.compiled)
+	w.mutex.RUnlock()
+
+	if total == 0 {
+		return nil
+	}
+	if prefilter {
+		w.stats.Check(len(subscriptions) > 0)
+	}
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	logs, err := logfilter.Fetch(ctx, w.caller, []logfilter.Filter{logfilter.Merge(filters...)}, block.Number, block.Number)
+	if err != nil {
+		return err
+	}
+	stored := 0
+	for _, l := range logs {
+		n, err := w.store(l, subscriptions)
+		if err != nil {
+			return err
+		}
+		stored += n
+	}
+	if prefilter && stored == 0 {
+		w.stats.FalsePositive()
+	}
+	return nil
+}
+
+// LogFilters returns the filter selecting the logs of every subscription,
+// for a logs subscription feeding HandleLog, or none without subscriptions.
+func (w *Watcher) LogFilters() []logfilter.Filter {
+	subscriptions := w.subscribed()
+	if len(subscriptions) == 0 {
+		return nil
+	}
+	filters := make([]logfilter.Filter, len(subscriptions))
+	for i, compiled := range subscriptions {
+		filters[i] = compiled.filter
+	}
+	return []logfilter.Filter{logfilter.Merge(filters...)}
+}
+
+// HandleLog stores a log pushed by a logs subscription for the
+// subscriptions it matches, before the scanner reaches its block.
+// HandleBlock still handles every block and logs are only stored once.
+func (w *Watcher) HandleLog(ctx context.Context, l entity.Log) error {
+	_, err := w.store(l, w.subscribed())
+	return err
+}
+
+// subscribed returns the compiled subscriptions ordered by ID.
+func (w *Watcher) subscribed() []compiledSubscription {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	subscriptions := make([]compiledSubscription, 0, len(w.compiled))
+	for _, compiled := range w.compiled {
+		subscriptions = append(subscriptions, compiled)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].subscription.ID < subscriptions[j].subscription.ID
+	})
+	return subscriptions
+}
+
+// store saves l for each of subscriptions it matches and returns for how
+// many. Logs of blocks reorganized away are skipped.
+func (w *Watcher) store(l entity.Log, subscriptions []compiledSubscription) (int, error) {
+	if l.Removed {
+		return 0, nil
+	}
+	stored := 0
+	for _, compiled := range subscriptions {
+		if !compiled.filter.Matches(l) {
+			continue
+		}
+		if _, err := w.logs.StoreEventLog(w.eventLog(compiled, l)); err != nil {
+			return stored, fmt.Errorf("failed to store event log: %w", err)
+		}
+		stored++
+	}
+	return stored, nil
+}
+
+// BloomStats tells how many blocks HandleBlock skipped thanks to their
+// logs bloom.
+func (w *Watcher) BloomStats() bloom.Snapshot {
+	return w.stats.Snapshot()
+}
+
+// eventLog decodes l with the event of the contract's uploaded ABI, which
+// names the arguments and tells which are indexed, or else with the event
+// declared by the subscription. Logs that do not decode are stored without
+// arguments.
+func (w *Watcher) eventLog(compiled compiledSubscription, l entity.Log) entity.EventLog {
+	blockNumber, _ := utils.HexToInt(l.BlockNumber)
+	logIndex, _ := utils.HexToInt(l.LogIndex)
+	eventLog := entity.EventLog{
+		ID:             l.TransactionHash + ":" + l.LogIndex,
+		SubscriptionID: compiled.subscription.ID,
+		Tenant:         compiled.subscription.Tenant,
+		Contract:       strings.ToLower(l.Address),
+		BlockNumber:    uint64(blockNumber),
+		BlockHash:      l.BlockHash,
+		TxHash:         l.TransactionHash,
+		LogIndex:       uint64(logIndex),
+		Event:          compiled.event.Signature(),
+		Topics:         l.Topics,
+		Data:           l.Data,
+	}
+
+	event := compiled.event
+	if w.abis != nil {
+		if known, ok := w.abis.Event(l.Address, compiled.subscription.Topic0); ok {
+			event = known
+		}
+	}
+	args, err := event.DecodeLog(l.Topics, l.Data)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to decode log %s: %w", eventLog.ID, err))
+		return eventLog
+	}
+	eventLog.Arguments = args
+	return eventLog
+}
@@ -0,0 +1,269 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"eth_parser/internal/app/abi"
+	"eth_parser/internal/app/bloom"
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/utils"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	vault = "0x00000000000000000000000000000000000000aa"
+	other = "0x00000000000000000000000000000000000000bb"
+	alice = "0x1111111111111111111111111111111111111111"
+	bob   = "0x2222222222222222222222222222222222222222"
+)
+
+// callerFunc answers eth_getLogs with the logs f returns for its filter.
+type callerFunc func(filter map[string]any) []entity.Log
+
+func (f callerFunc) Call(ctx context.Context, method string, params []any, result any) error {
+	data, err := json.Marshal(f(params[0].(map[string]any)))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+type abisFunc func(address, topic string) (abi.Event, bool)
+
+func (f abisFunc) Event(address, topic string) (abi.Event, bool) {
+	return f(address, topic)
+}
+
+func deposit(t *testing.T, contract, user string, amount string, index string) entity.Log {
+	t.Helper()
+	event, err := abi.ParseEvent("Deposit(address,uint256)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entity.Log{
+		Address:         contract,
+		BlockNumber:     "0x7",
+		TransactionHash: "0xt" + index,
+		LogIndex:        index,
+		Topics:          []string{event.Topic(), utils.AddressToHex(user)},
+		Data:            "0x" + strings.Repeat("0", 64-len(amount)) + amount,
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	withdrawal := deposit(t, vault, alice, "1", "0x3")
+	withdrawal.Topics[0] = "0x" + strings.Repeat("ee", 32)
+	logs := []entity.Log{
+		deposit(t, vault, alice, "64", "0x0"),
+		deposit(t, vault, bob, "5", "0x1"),
+		deposit(t, other, alice, "7", "0x2"),
+		withdrawal,
+	}
+
+	var requests []map[string]any
+	caller := callerFunc(func(filter map[string]any) []entity.Log {
+		requests = append(requests, filter)
+		return logs
+	})
+	named, err := abi.ParseEvent("Deposit(address indexed account, uint256 value)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	abis := abisFunc(func(address, topic string) (abi.Event, bool) {
+		return named, strings.EqualFold(address, other)
+	})
+	w := NewWatcher(caller, repo.NewMemoryEventSubscriptionRepo(), repo.NewMemoryEventLogRepo(), abis)
+
+	all, err := w.Subscribe(entity.EventSubscription{Tenant: "ops", Contract: "0x" + strings.ToUpper(vault[2:]), Event: "Deposit(address indexed user, uint256 amount)"})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	ofAlice, err := w.Subscribe(entity.EventSubscription{Tenant: "ops", Contract: vault, Event: "Deposit(address,uint256)", Topics: [][]string{{alice}}})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	withABI, err := w.Subscribe(entity.EventSubscription{Tenant: "risk", Contract: other, Event: "Deposit(address,uint256)"})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	block := &entity.Block{Number: 7}
+	for range 2 {
+		if err := w.HandleBlock(context.Background(), block); err != nil {
+			t.Fatalf("HandleBlock() error = %v", err)
+		}
+	}
+	if len(requests) != 2 {
+		t.Fatalf("made %d eth_getLogs requests, want one per block", len(requests))
+	}
+	if addresses, ok := requests[0]["address"].([]string); !ok || len(addresses) != 2 {
+		t.Errorf("request address = %v, want both contracts", requests[0]["address"])
+	}
+
+	got, ok := w.Logs("ops", all.ID)
+	if !ok || len(got) != 2 || got[0].TxHash != "0xt0x0" || got[1].TxHash != "0xt0x1" {
+		t.Fatalf("Logs(all) = %+v, want both deposits into the vault once", got)
+	}
+	wantArgs := []entity.DecodedArgument{
+		{Name: "user", Type: "address", Value: alice},
+		{Name: "amount", Type: "uint256", Value: "100"},
+	}
+	if !reflect.DeepEqual(got[0].Arguments, wantArgs) || got[0].Event != "Deposit(address,uint256)" || got[0].BlockNumber != 7 {
+		t.Errorf("Logs(all)[0] = %+v, want arguments %+v", got[0], wantArgs)
+	}
+
+	if got, _ := w.Logs("ops", ofAlice.ID); len(got) != 1 || got[0].Arguments[0].Name != "" || got[0].Arguments[0].Value != alice {
+		t.Errorf("Logs(ofAlice) = %+v, want the deposit of alice with unnamed arguments", got)
+	}
+	if got, _ := w.Logs("risk", withABI.ID); len(got) != 1 || got[0].Arguments[1].Name != "value" {
+		t.Errorf("Logs(withABI) = %+v, want arguments named by the ABI", got)
+	}
+	if _, ok := w.Logs("risk", all.ID); ok {
+		t.Error("logs of another tenant's subscription should not be found")
+	}
+
+	if found, err := w.Delete("ops", all.ID); !found || err != nil {
+		t.Fatalf("Delete() = %v, %v", found, err)
+	}
+	if list := w.List("ops"); len(list) != 1 || list[0].ID != ofAlice.ID {
+		t.Errorf("List() = %+v, want the remaining subscription", list)
+	}
+}
+
+func TestWatcherSkipsBlocksByBloom(t *testing.T) {
+	ofAlice := deposit(t, vault, alice, "64", "0x0")
+	ofBob := deposit(t, vault, bob, "5", "0x1")
+
+	var requests int
+	var logs []entity.Log
+	caller := callerFunc(func(filter map[string]any) []entity.Log {
+		requests++
+		return logs
+	})
+	w := NewWatcher(caller, repo.NewMemoryEventSubscriptionRepo(), repo.NewMemoryEventLogRepo(), nil)
+	subscription, err := w.Subscribe(entity.EventSubscription{Tenant: "ops", Contract: vault, Event: "Deposit(address,uint256)", Topics: [][]string{{alice}}})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		bloom bloom.Bloom
+		logs  []entity.Log
+	}{
+		{name: "other contract", bloom: bloom.Of(append([]string{other}, ofAlice.Topics...))},
+		{name: "deposit of alice", bloom: bloom.Of(append([]string{vault}, ofAlice.Topics...)), logs: []entity.Log{ofAlice}},
+		{name: "deposit of bob", bloom: bloom.Of(append([]string{vault}, ofBob.Topics...))},
+		{name: "false positive", bloom: bloom.Of(append([]string{vault}, ofAlice.Topics...))},
+	}
+	for i, tt := range tests {
+		logs = tt.logs
+		if err := w.HandleBlock(context.Background(), &entity.Block{Number: uint64(i), LogsBloom: tt.bloom.String()}); err != nil {
+			t.Fatalf("HandleBlock(%s) error = %v", tt.name, err)
+		}
+	}
+
+	if requests != 2 {
+		t.Errorf("made %d eth_getLogs requests, want one per bloom hit", requests)
+	}
+	if got, _ := w.Logs("ops", subscription.ID); len(got) != 1 {
+		t.Errorf("Logs() = %+v, want the deposit of alice", got)
+	}
+	if got, want := w.BloomStats(), (bloom.Snapshot{Checked: 4, Skipped: 2, FalsePositives: 1}); got != want {
+		t.Errorf("BloomStats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSubscribeValidates(t *testing.T) {
+	tests := []struct {
+		name         string
+		subscription entity.EventSubscription
+		wantTopics   [][]string
+		wantErr      bool
+	}{
+		{
+			name:         "topic filter",
+			subscription: entity.EventSubscription{Contract: vault, Event: "Transfer(address indexed,address indexed,uint256)", Topics: [][]string{nil, {alice, "0x" + bob[2:]}}},
+			wantTopics:   [][]string{nil, {utils.AddressToHex(alice), utils.AddressToHex(bob)}},
+		},
+		{name: "invalid contract", subscription: entity.EventSubscription{Contract: "0x12", Event: "Deposit(address,uint256)"}, wantErr: true},
+		{name: "invalid event", subscription: entity.EventSubscription{Contract: vault, Event: "Deposit"}, wantErr: true},
+		{name: "unknown type", subscription: entity.EventSubscription{Contract: vault, Event: "Deposit(address,uint7)"}, wantErr: true},
+		{name: "too many topic filters", subscription: entity.EventSubscription{Contract: vault, Event: "Deposit(address indexed,uint256)", Topics: [][]string{{alice}, {bob}}}, wantErr: true},
+		{name: "topic is not hex", subscription: entity.EventSubscription{Contract: vault, Event: "Deposit(address,uint256)", Topics: [][]string{{"alice"}}}, wantErr: true},
+		{name: "topic longer than 32 bytes", subscription: entity.EventSubscription{Contract: vault, Event: "Deposit(address,uint256)", Topics: [][]string{{"0x" + strings.Repeat("ab", 33)}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWatcher(nil, repo.NewMemoryEventSubscriptionRepo(), repo.NewMemoryEventLogRepo(), nil)
+			tt.subscription.Tenant = "ops"
+			got, err := w.Subscribe(tt.subscription)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSubscription) {
+					t.Fatalf("Subscribe() error = %v, want ErrInvalidSubscription", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Subscribe() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Topics, tt.wantTopics) {
+				t.Errorf("Topics = %v, want %v", got.Topics, tt.wantTopics)
+			}
+			if got.Topic0 != "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef" {
+				t.Errorf("Topic0 = %s, want the Transfer topic", got.Topic0)
+			}
+		})
+	}
+}
+
+func TestWatcherHandlesPushedLogs(t *testing.T) {
+	ofAlice := deposit(t, vault, alice, "64", "0x0")
+	ofBob := deposit(t, vault, bob, "5", "0x1")
+
+	var requests int
+	caller := callerFunc(func(filter map[string]any) []entity.Log {
+		requests++
+		return []entity.Log{ofAlice}
+	})
+	w := NewWatcher(caller, repo.NewMemoryEventSubscriptionRepo(), repo.NewMemoryEventLogRepo(), nil)
+	if filters := w.LogFilters(); len(filters) != 0 {
+		t.Errorf("LogFilters() = %+v, want none without subscriptions", filters)
+	}
+	subscription, err := w.Subscribe(entity.EventSubscription{Tenant: "ops", Contract: vault, Event: "Deposit(address,uint256)", Topics: [][]string{{alice}}})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	filters := w.LogFilters()
+	if len(filters) != 1 || !filters[0].Matches(ofAlice) || filters[0].Matches(ofBob) {
+		t.Fatalf("LogFilters() = %+v, want the filter of the subscription", filters)
+	}
+
+	removed := ofAlice
+	removed.Removed = true
+	for _, l := range []entity.Log{removed, ofBob, ofAlice} {
+		if err := w.HandleLog(context.Background(), l); err != nil {
+			t.Fatalf("HandleLog() error = %v", err)
+		}
+	}
+	if got, _ := w.Logs("ops", subscription.ID); len(got) != 1 || got[0].TxHash != ofAlice.TransactionHash {
+		t.Fatalf("Logs() = %+v, want the pushed deposit of alice", got)
+	}
+
+	// The scanner reaching the block stores nothing twice.
+	if err := w.HandleBlock(context.Background(), &entity.Block{Number: 7}); err != nil {
+		t.Fatalf("HandleBlock() error = %v", err)
+	}
+	if got, _ := w.Logs("ops", subscription.ID); len(got) != 1 {
+		t.Errorf("Logs() = %+v, want the deposit once", got)
+	}
+	if requests != 1 {
+		t.Errorf("made %d eth_getLogs requests, want only the one of HandleBlock", requests)
+	}
+}
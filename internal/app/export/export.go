@@ -0,0 +1,207 @@
+// Package export turns the transfers booked for an address into flat rows
+// for spreadsheets, written as CSV or NDJSON.
+package export
+
+import (
+	"context"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/utils"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// nativeDecimals is the number of decimals of ether.
+const nativeDecimals = 18
+
+const (
+	DirectionIn   = "in"
+	DirectionOut  = "out"
+	DirectionSelf = "self"
+)
+
+// Row is one movement of value of the exported address. Amount and Fee are
+// decimal numbers in whole tokens and ether; the fee of a transaction is
+// only given on its first row.
+type Row struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Block        uint64    `json:"block"`
+	Hash         string    `json:"hash"`
+	Direction    string    `json:"direction"`
+	Counterparty string    `json:"counterparty"`
+	Token        string    `json:"token"`
+	Amount       string    `json:"amount"`
+	Fee          string    `json:"fee"`
+}
+
+// TimeRange limits an export to the rows mined between Since and Until,
+// both inclusive. A zero bound leaves that side open.
+type TimeRange struct {
+	Since time.Time
+	Until time.Time
+}
+
+// IsZero reports whether both sides of r are open.
+func (r TimeRange) IsZero() bool {
+	return r.Since.IsZero() && r.Until.IsZero()
+}
+
+// Contains reports whether t lies in r. An unknown, zero time only lies in
+// a range open on both sides.
+func (r TimeRange) Contains(t time.Time) bool {
+	if t.IsZero() {
+		return r.IsZero()
+	}
+	return (r.Since.IsZero() || !t.Before(r.Since)) && (r.Until.IsZero() || !t.After(r.Until))
+}
+
+// Transfers lists the transfers booked for an address.
+type Transfers interface {
+	GetTransfers(address string) []entity.Transfer
+}
+
+// Chain looks up what transfers do not carry.
+type Chain interface {
+	BlockByNumber(ctx context.Context, number uint64) (*entity.Block, error)
+	TokenDecimals(ctx context.Context, token string) (uint8, error)
+}
+
+type Exporter struct {
+	transfers Transfers
+	chain     Chain
+
+	// decimals never change, so they are kept across exports.
+	decimals map[string]uint8
+	mutex    sync.Mutex
+}
+
+func NewExporter(transfers Transfers, chain Chain) *Exporter {
+	return &Exporter{
+		transfers: transfers,
+		chain:     chain,
+		decimals:  map[string]uint8{entity.NativeToken: nativeDecimals},
+	}
+}
+
+// Rows calls emit for every row of address mined in window, in block order.
+// Rows are built one at a time, so a slow consumer does not hold the whole
+// export.
+func (e *Exporter) Rows(ctx context.Context, address string, window TimeRange, emit func(Row) error) error {
+	transfers := e.transfers.GetTransfers(address)
+	sort.SliceStable(transfers, func(i, j int) bool {
+		return transfers[i].BlockNumber < transfers[j].BlockNumber
+	})
+
+	// Fees are booked as transfers of their own; they are folded into the
+	// first row of their transaction instead.
+	fees := make(map[string]*big.Int)
+	moved := make(map[string]bool)
+	for _, transfer := range transfers {
+		switch {
+		case transfer.Kind == entity.TransferFee:
+			if fee, ok := new(big.Int).SetString(transfer.Value, 10); ok {
+				fees[transfer.TxHash] = fee
+			}
+		case involves(transfer, address):
+			moved[transfer.TxHash] = true
+		}
+	}
+
+	timestamps := make(map[uint64]time.Time)
+	feePaid := make(map[string]bool)
+	for _, transfer := range transfers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		isFee := transfer.Kind == entity.TransferFee
+		if isFee && moved[transfer.TxHash] || !isFee && !involves(transfer, address) {
+			continue
+		}
+
+		timestamp, ok := timestamps[transfer.BlockNumber]
+		if !ok {
+			block, err := e.chain.BlockByNumber(ctx, transfer.BlockNumber)
+			if err != nil {
+				return fmt.Errorf("failed to get block %d: %w", transfer.BlockNumber, err)
+			}
+			if block != nil {
+				timestamp = time.Unix(int64(block.Timestamp), 0).UTC()
+			}
+			timestamps[transfer.BlockNumber] = timestamp
+		}
+		if !window.Contains(timestamp) {
+			// Blocks are in order, nothing later is in the window.
+			if !window.Until.IsZero() && timestamp.After(window.Until) {
+				break
+			}
+			continue
+		}
+
+		row := Row{
+			Timestamp: timestamp,
+			Block:     transfer.BlockNumber,
+			Hash:      transfer.TxHash,
+			Token:     transfer.Token,
+			Amount:    "0",
+		}
+		row.Direction, row.Counterparty = direction(transfer, address)
+		if !isFee {
+			row.Amount = e.amount(ctx, transfer.Token, transfer.Value)
+		}
+		if fee, ok := fees[transfer.TxHash]; ok && !feePaid[transfer.TxHash] {
+			row.Fee = utils.FormatUnits(fee, nativeDecimals)
+			feePaid[transfer.TxHash] = true
+		}
+
+		if err := emit(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// amount formats value in whole tokens. Tokens whose decimals cannot be
+// read are exported in base units.
+func (e *Exporter) amount(ctx context.Context, token, value string) string {
+	amount, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return value
+	}
+
+	e.mutex.Lock()
+	decimals, ok := e.decimals[token]
+	e.mutex.Unlock()
+	if !ok {
+		var err error
+		if decimals, err = e.chain.TokenDecimals(ctx, token); err != nil {
+			log.Println(fmt.Errorf("failed to get decimals of %s: %w", token, err))
+			return amount.String()
+		}
+		e.mutex.Lock()
+		e.decimals[token] = decimals
+		e.mutex.Unlock()
+	}
+	return utils.FormatUnits(amount, decimals)
+}
+
+func involves(transfer entity.Transfer, address string) bool {
+	return strings.EqualFold(transfer.From, address) || strings.EqualFold(transfer.To, address)
+}
+
+func direction(transfer entity.Transfer, address string) (string, string) {
+	from := strings.EqualFold(transfer.From, address)
+	to := strings.EqualFold(transfer.To, address)
+	switch {
+	case from && to:
+		return DirectionSelf, transfer.To
+	case to:
+		return DirectionIn, transfer.From
+	default:
+		return DirectionOut, transfer.To
+	}
+}
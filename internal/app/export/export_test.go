@@ -0,0 +1,159 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"eth_parser/internal/domain/entity"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	alice = "0xaaaa000000000000000000000000000000000000"
+	bob   = "0xbbbb000000000000000000000000000000000000"
+	usdc  = "0xcccc000000000000000000000000000000000000"
+)
+
+type transfersFunc func(address string) []entity.Transfer
+
+func (f transfersFunc) GetTransfers(address string) []entity.Transfer {
+	return f(address)
+}
+
+type mockChain struct {
+	decimalCalls int
+}
+
+func (m *mockChain) BlockByNumber(ctx context.Context, number uint64) (*entity.Block, error) {
+	return &entity.Block{Number: number, Timestamp: 1700000000 + number*12}, nil
+}
+
+func (m *mockChain) TokenDecimals(ctx context.Context, token string) (uint8, error) {
+	m.decimalCalls++
+	if token == usdc {
+		return 6, nil
+	}
+	return 0, errors.New("execution reverted")
+}
+
+func TestRows(t *testing.T) {
+	transfers := transfersFunc(func(address string) []entity.Transfer {
+		return []entity.Transfer{
+			{Kind: entity.TransferToken, TxHash: "0x3", BlockNumber: 3, Token: usdc, From: bob, To: alice, Value: "2500000"},
+			{Kind: entity.TransferNative, TxHash: "0x1", BlockNumber: 1, Token: entity.NativeToken, From: alice, To: bob, Value: "1500000000000000000"},
+			{Kind: entity.TransferFee, TxHash: "0x1", BlockNumber: 1, Token: entity.NativeToken, From: alice, Value: "21000000000000"},
+			// A fee of a call that moved nothing of alice's.
+			{Kind: entity.TransferFee, TxHash: "0x2", BlockNumber: 2, Token: entity.NativeToken, From: alice, Value: "1000"},
+			// Ether sent to the token contract by someone else.
+			{Kind: entity.TransferNative, TxHash: "0x3", BlockNumber: 3, Token: entity.NativeToken, From: bob, To: usdc, Value: "1"},
+			{Kind: entity.TransferToken, TxHash: "0x4", BlockNumber: 4, Token: "0xdead", From: alice, To: alice, Value: "7"},
+		}
+	})
+	chain := &mockChain{}
+	exporter := NewExporter(transfers, chain)
+
+	var rows []Row
+	err := exporter.Rows(context.Background(), alice, TimeRange{}, func(row Row) error {
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Rows() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want Row
+	}{
+		{"ether sent with fee", Row{time.Unix(1700000012, 0).UTC(), 1, "0x1", DirectionOut, bob, entity.NativeToken, "1.5", "0.000021"}},
+		{"fee only", Row{time.Unix(1700000024, 0).UTC(), 2, "0x2", DirectionOut, "", entity.NativeToken, "0", "0.000000000000001"}},
+		{"token received", Row{time.Unix(1700000036, 0).UTC(), 3, "0x3", DirectionIn, bob, usdc, "2.5", ""}},
+		{"unknown decimals", Row{time.Unix(1700000048, 0).UTC(), 4, "0x4", DirectionSelf, alice, "0xdead", "7", ""}},
+	}
+	if len(rows) != len(tests) {
+		t.Fatalf("expected %d rows, got %d: %+v", len(tests), len(rows), rows)
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rows[i] != tt.want {
+				t.Errorf("got %+v, want %+v", rows[i], tt.want)
+			}
+		})
+	}
+
+	// Decimals are looked up once per token.
+	exporter.Rows(context.Background(), alice, TimeRange{}, func(Row) error { return nil })
+	if chain.decimalCalls != 3 {
+		t.Errorf("expected 3 decimals lookups, got %d", chain.decimalCalls)
+	}
+
+	// Only the rows of blocks 2 and 3 are in the window, both bounds inclusive.
+	var hashes []string
+	window := TimeRange{Since: time.Unix(1700000024, 0), Until: time.Unix(1700000036, 0)}
+	err = exporter.Rows(context.Background(), alice, window, func(row Row) error {
+		hashes = append(hashes, row.Hash)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Rows() error = %v", err)
+	}
+	if strings.Join(hashes, ",") != "0x2,0x3" {
+		t.Errorf("rows in window = %v, want 0x2,0x3", hashes)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	row := Row{time.Unix(1700000012, 0).UTC(), 1, "0x1", DirectionOut, bob, entity.NativeToken, "1.5", "0.000021"}
+
+	tests := []struct {
+		format Format
+		rows   []Row
+		want   string
+	}{
+		{FormatCSV, []Row{row}, "timestamp,block,hash,direction,counterparty,token,amount,fee\n2023-11-14T22:13:32Z,1,0x1,out," + bob + ",ETH,1.5,0.000021\n"},
+		{FormatCSV, nil, "timestamp,block,hash,direction,counterparty,token,amount,fee\n"},
+		{FormatNDJSON, []Row{row, row}, strings.Repeat(`{"timestamp":"2023-11-14T22:13:32Z","block":1,"hash":"0x1","direction":"out","counterparty":"`+bob+`","token":"ETH","amount":"1.5","fee":"0.000021"}`+"\n", 2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(tt.format, &buf)
+			for _, row := range tt.rows {
+				if err := w.Write(row); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatFor(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   Format
+		wantOK bool
+	}{
+		{"text/csv", FormatCSV, true},
+		{"application/json, application/x-ndjson;q=0.9", FormatNDJSON, true},
+		{"application/json", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			got, ok := FormatFor(tt.accept)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("FormatFor() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is an export file format.
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+// ContentType returns the media type of f.
+func (f Format) ContentType() string {
+	if f == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// ParseFormat accepts a format name such as "csv".
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case FormatCSV:
+		return FormatCSV, nil
+	case FormatNDJSON:
+		return FormatNDJSON, nil
+	}
+	return "", fmt.Errorf("unknown export format %q", name)
+}
+
+// FormatFor picks the format requested by an Accept header, if any.
+func FormatFor(accept string) (Format, bool) {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "text/csv":
+			return FormatCSV, true
+		case "application/x-ndjson", "application/ndjson":
+			return FormatNDJSON, true
+		}
+	}
+	return "", false
+}
+
+// Writer encodes rows. Flush must be called after the last row.
+type Writer interface {
+	Write(row Row) error
+	Flush() error
+}
+
+// NewWriter returns a writer of format f.
+func NewWriter(f Format, w io.Writer) Writer {
+	if f == FormatCSV {
+		return &csvWriter{csv: csv.NewWriter(w)}
+	}
+	return &ndjsonWriter{encoder: json.NewEncoder(w)}
+}
+
+var csvHeader = []string{"timestamp", "block", "hash", "direction", "counterparty", "token", "amount", "fee"}
+
+type csvWriter struct {
+	csv    *csv.Writer
+	header bool
+}
+
+func (w *csvWriter) Write(row Row) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	timestamp := ""
+	if !row.Timestamp.IsZero() {
+		timestamp = row.Timestamp.Format(time.RFC3339)
+	}
+	return w.csv.Write([]string{
+		timestamp,
+		strconv.FormatUint(row.Block, 10),
+		row.Hash,
+		row.Direction,
+		row.Counterparty,
+		row.Token,
+		row.Amount,
+		row.Fee,
+	})
+}
+
+func (w *csvWriter) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// writeHeader writes the header once, also for an export without rows.
+func (w *csvWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	w.header = true
+	return w.csv.Write(csvHeader)
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(row Row) error {
+	return w.encoder.Encode(row)
+}
+
+func (w *ndjsonWriter) Flush() error {
+	return nil
+}
@@ -0,0 +1,294 @@
+// Package finality follows the head, safe and finalized blocks of the chain
+// and tells how deep stored transactions are.
+package finality
+
+import (
+	"context"
+	"errors"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/utils"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// unfinalizedDepth is how deep a block has to be before its hash is
+	// kept across refreshes on chains without a finalized tag.
+	unfinalizedDepth = 64
+	// maxCachedHashes bounds the canonical hashes kept by a Tracker.
+	maxCachedHashes = 4096
+)
+
+// ErrUnknownBlock is returned for a height the node does not have yet, e.g.
+// when one of the nodes behind a load balancer lags behind the tracked
+// head. The transactions there are neither confirmed nor dropped yet.
+var ErrUnknownBlock = errors.New("block is not known to the node yet")
+
+// BlockSource reads blocks by tag from the node.
+type BlockSource interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+	BlockByTag(ctx context.Context, tag string) (*entity.Block, error)
+}
+
+// State is the head of the chain and its safe and finalized blocks. Zero
+// means unknown, e.g. on chains without proof of stake finality.
+type State struct {
+	Head      uint64 `json:"head"`
+	Safe      uint64 `json:"safe"`
+	Finalized uint64 `json:"finalized"`
+}
+
+// Status returns the confirmations and finality of a transaction mined in
+// block. Blocks past the known head have no confirmations yet.
+func (s State) Status(block uint64) (uint64, entity.Finality) {
+	if s.Head == 0 || block > s.Head {
+		return 0, ""
+	}
+
+	confirmations := s.Head - block + 1
+	switch {
+	case s.Finalized != 0 && block <= s.Finalized:
+		return confirmations, entity.FinalityFinalized
+	case s.Safe != 0 && block <= s.Safe:
+		return confirmations, entity.FinalitySafe
+	default:
+		return confirmations, entity.FinalityUnsafe
+	}
+}
+
+// stable returns the highest block whose canonical hash is kept across
+// refreshes: the finalized block or, on chains without one, the block
+// unfinalizedDepth below the head.
+func (s State) stable() uint64 {
+	if s.Finalized != 0 || s.Head <= unfinalizedDepth {
+		return s.Finalized
+	}
+	return s.Head - unfinalizedDepth
+}
+
+// Canonical tells the hash of the canonical block at a height.
+type Canonical interface {
+	CanonicalHash(ctx context.Context, number uint64) (string, error)
+}
+
+// StatusOf returns the confirmations and finality of tx in state. Before
+// counting confirmations, the block hash of tx is compared with the
+// canonical one at its height; a transaction reorganized away is dropped.
+// Finalized blocks cannot be reorganized, so they are not compared, and a
+// nil canonical skips the comparison. A block the node does not have yet
+// fails with ErrUnknownBlock.
+func StatusOf(ctx context.Context, state State, canonical Canonical, tx entity.Transaction) (uint64, entity.Finality, error) {
+	block, ok := BlockOf(tx)
+	if !ok {
+		return 0, "", nil
+	}
+	confirmations, finality := state.Status(block)
+	if confirmations == 0 || finality == entity.FinalityFinalized || tx.BlockHash == nil || canonical == nil {
+		return confirmations, finality, nil
+	}
+
+	hash, err := canonical.CanonicalHash(ctx, block)
+	if err != nil {
+		return 0, "", err
+	}
+	if !strings.EqualFold(hash, *tx.BlockHash) {
+		return 0, entity.FinalityDropped, nil
+	}
+	return confirmations, finality, nil
+}
+
+// Tracker polls the node for the latest, safe and finalized blocks and
+// tells the registered listeners whenever one of them moved.
+type Tracker struct {
+	source    BlockSource
+	interval  time.Duration
+	listeners []func(State)
+
+	mutex sync.RWMutex
+	state State
+	// hashes caches canonical block hashes above the finalized block. Only
+	// those deep enough to be stable survive a refresh, see stable.
+	hashes map[uint64]string
+}
+
+func NewTracker(source BlockSource, interval time.Duration) *Tracker {
+	return &Tracker{
+		source:   source,
+		interval: interval,
+		hashes:   make(map[uint64]string),
+	}
+}
+
+// OnChange registers fn to be called with every new state. Listeners must
+// be added before Run.
+func (t *Tracker) OnChange(fn func(State)) {
+	t.listeners = append(t.listeners, fn)
+}
+
+// State returns the last polled state.
+func (t *Tracker) State() State {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.state
+}
+
+// Run refreshes the state until ctx is cancelled.
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.Refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Println(fmt.Errorf("finality: %w", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh polls the node once. A node that does not know the safe or
+// finalized tags still updates the head.
+func (t *Tracker) Refresh(ctx context.Context) error {
+	head, err := t.source.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get block number: %w", err)
+	}
+
+	state := t.State()
+	state.Head = head
+	var tagErr error
+	for tag, number := range map[string]*uint64{"safe": &state.Safe, "finalized": &state.Finalized} {
+		block, err := t.source.BlockByTag(ctx, tag)
+		if err != nil {
+			tagErr = err
+			continue
+		}
+		if block != nil {
+			*number = block.Number
+		}
+	}
+
+	t.mutex.Lock()
+	changed := state != t.state
+	t.state = state
+	stable := state.stable()
+	for number := range t.hashes {
+		// Finalized blocks are no longer looked up, blocks above stable may
+		// still be reorganized.
+		if number <= state.Finalized || number > stable {
+			delete(t.hashes, number)
+		}
+	}
+	t.mutex.Unlock()
+
+	if changed {
+		for _, fn := range t.listeners {
+			fn(state)
+		}
+	}
+	return tagErr
+}
+
+// CanonicalHash returns the hash of the canonical block at number, or
+// ErrUnknownBlock when the node does not have it.
+func (t *Tracker) CanonicalHash(ctx context.Context, number uint64) (string, error) {
+	t.mutex.RLock()
+	hash, ok := t.hashes[number]
+	t.mutex.RUnlock()
+	if ok {
+		return hash, nil
+	}
+
+	block, err := t.source.BlockByTag(ctx, utils.IntToHex(number))
+	if err != nil {
+		return "", fmt.Errorf("failed to get block %d: %w", number, err)
+	}
+	if block == nil {
+		return "", fmt.Errorf("%w: %d", ErrUnknownBlock, number)
+	}
+
+	t.mutex.Lock()
+	if len(t.hashes) >= maxCachedHashes {
+		// The lowest block is the first to become finalized, and then it
+		// is not looked up anymore.
+		lowest := number
+		for cached := range t.hashes {
+			lowest = min(lowest, cached)
+		}
+		delete(t.hashes, lowest)
+	}
+	t.hashes[number] = block.Hash
+	t.mutex.Unlock()
+	return block.Hash, nil
+}
+
+// hashMemo remembers the canonical hashes looked up during one call, so
+// transactions of the same block cost a single lookup.
+type hashMemo struct {
+	canonical Canonical
+	hashes    map[uint64]string
+	// unknown holds the errors of the blocks the node does not have.
+	unknown map[uint64]error
+}
+
+func (m *hashMemo) CanonicalHash(ctx context.Context, number uint64) (string, error) {
+	if hash, ok := m.hashes[number]; ok {
+		return hash, nil
+	}
+	if err, ok := m.unknown[number]; ok {
+		return "", err
+	}
+	hash, err := m.canonical.CanonicalHash(ctx, number)
+	if errors.Is(err, ErrUnknownBlock) {
+		m.unknown[number] = err
+	}
+	if err != nil {
+		return "", err
+	}
+	m.hashes[number] = hash
+	return hash, nil
+}
+
+// Annotate fills in the confirmations and finality of txs. Transactions
+// whose block was reorganized away are marked dropped. Each block above the
+// finalized one is looked up at most once. Transactions of blocks the node
+// does not have yet are left unannotated, and so is the rest when the node
+// cannot tell the canonical blocks.
+func (t *Tracker) Annotate(ctx context.Context, txs []entity.Transaction) []entity.Transaction {
+	state := t.State()
+	canonical := &hashMemo{canonical: t, hashes: make(map[uint64]string), unknown: make(map[uint64]error)}
+	annotated := make([]entity.Transaction, len(txs))
+	copy(annotated, txs)
+	for i := range annotated {
+		confirmations, finality, err := StatusOf(ctx, state, canonical, annotated[i])
+		if errors.Is(err, ErrUnknownBlock) {
+			continue
+		}
+		if err != nil {
+			log.Println(fmt.Errorf("finality: %w", err))
+			break
+		}
+		annotated[i].Confirmations, annotated[i].Finality = confirmations, finality
+	}
+	return annotated
+}
+
+// BlockOf returns the number of the block tx was mined in.
+func BlockOf(tx entity.Transaction) (uint64, bool) {
+	if tx.BlockNumber == nil {
+		return 0, false
+	}
+	number, err := utils.HexToInt(*tx.BlockNumber)
+	if err != nil || number < 0 {
+		return 0, false
+	}
+	return uint64(number), true
+}
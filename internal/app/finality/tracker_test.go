@@ -0,0 +1,203 @@
+package finality
+
+import (
+	"context"
+	"errors"
+	"eth_parser/internal/app/parser"
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/app/simnode"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/utils"
+	"testing"
+	"time"
+)
+
+func TestStateStatus(t *testing.T) {
+	state := State{Head: 100, Safe: 68, Finalized: 36}
+
+	tests := []struct {
+		name              string
+		state             State
+		block             uint64
+		wantConfirmations uint64
+		wantFinality      entity.Finality
+	}{
+		{"head", state, 100, 1, entity.FinalityUnsafe},
+		{"above safe", state, 69, 32, entity.FinalityUnsafe},
+		{"safe", state, 68, 33, entity.FinalitySafe},
+		{"finalized", state, 36, 65, entity.FinalityFinalized},
+		{"past the head", state, 101, 0, ""},
+		{"unknown head", State{}, 1, 0, ""},
+		{"no finality tags", State{Head: 100}, 1, 100, entity.FinalityUnsafe},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			confirmations, finality := tt.state.Status(tt.block)
+			if confirmations != tt.wantConfirmations || finality != tt.wantFinality {
+				t.Errorf("Status(%d) = %d, %q, want %d, %q", tt.block, confirmations, finality, tt.wantConfirmations, tt.wantFinality)
+			}
+		})
+	}
+}
+
+func TestTracker(t *testing.T) {
+	chain := simnode.NewChain(simnode.DefaultConfig())
+	for range 100 {
+		chain.Mine()
+	}
+	node := simnode.NewNode(chain, 1)
+	tracker := NewTracker(parser.NewEthereumParser(node, repo.NewMemoryTransactionRepo()), time.Second)
+
+	var changes []State
+	tracker.OnChange(func(state State) { changes = append(changes, state) })
+
+	if err := tracker.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if err := tracker.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	want := State{Head: 100, Safe: 68, Finalized: 36}
+	if got := tracker.State(); got != want {
+		t.Errorf("State() = %+v, want %+v", got, want)
+	}
+	if len(changes) != 1 {
+		t.Errorf("expected listeners to be called once, got %d calls", len(changes))
+	}
+
+	mined := func(number uint64) entity.Transaction {
+		block, _ := chain.BlockByNumber(number)
+		blockNumber, blockHash := utils.IntToHex(number), block.Hash
+		return entity.Transaction{Hash: "0x1", BlockNumber: &blockNumber, BlockHash: &blockHash}
+	}
+	txs := tracker.Annotate(context.Background(), []entity.Transaction{mined(50), {Hash: "0x2"}, mined(100)})
+	if txs[0].Confirmations != 51 || txs[0].Finality != entity.FinalitySafe {
+		t.Errorf("unexpected annotation %+v", txs[0])
+	}
+	if txs[1].Confirmations != 0 || txs[1].Finality != "" {
+		t.Errorf("expected a transaction without block to stay unannotated, got %+v", txs[1])
+	}
+	if txs[2].Confirmations != 1 || txs[2].Finality != entity.FinalityUnsafe {
+		t.Errorf("unexpected annotation %+v", txs[2])
+	}
+
+	// The head block is replaced at the same height, so the transaction in
+	// it was reorganized away.
+	if err := chain.Reorg(1, nil); err != nil {
+		t.Fatalf("Reorg() error = %v", err)
+	}
+	if err := tracker.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	txs = tracker.Annotate(context.Background(), txs)
+	if txs[0].Confirmations != 51 || txs[0].Finality != entity.FinalitySafe {
+		t.Errorf("unexpected annotation %+v", txs[0])
+	}
+	if txs[2].Confirmations != 0 || txs[2].Finality != entity.FinalityDropped {
+		t.Errorf("expected the reorganized transaction to be dropped, got %+v", txs[2])
+	}
+
+	// Nodes without the finality tags still move the head.
+	node.FailNext("eth_getBlockByNumber", -32000, "unknown block tag")
+	chain.Mine()
+	if err := tracker.Refresh(context.Background()); err == nil {
+		t.Error("expected the failed tag to be reported")
+	}
+	if got := tracker.State().Head; got != 101 {
+		t.Errorf("expected head 101, got %d", got)
+	}
+}
+
+// stubSource is a chain whose canonical block at n has hash "0x<n>".
+type stubSource struct {
+	head, finalized uint64
+	lookups         map[uint64]int
+	// lagging, when set, is the last block the node has.
+	lagging uint64
+}
+
+func (s *stubSource) BlockNumber(ctx context.Context) (uint64, error) {
+	return s.head, nil
+}
+
+func (s *stubSource) BlockByTag(ctx context.Context, tag string) (*entity.Block, error) {
+	switch tag {
+	case "safe":
+		return nil, nil
+	case "finalized":
+		if s.finalized == 0 {
+			return nil, nil
+		}
+		return &entity.Block{Number: s.finalized}, nil
+	}
+	number, err := utils.HexToInt(tag)
+	if err != nil {
+		return nil, err
+	}
+	s.lookups[uint64(number)]++
+	if s.lagging != 0 && uint64(number) > s.lagging {
+		return nil, nil
+	}
+	return &entity.Block{Number: uint64(number), Hash: utils.IntToHex(uint64(number))}, nil
+}
+
+func TestTrackerLookups(t *testing.T) {
+	tx := func(number uint64) entity.Transaction {
+		blockNumber := utils.IntToHex(number)
+		return entity.Transaction{BlockNumber: &blockNumber, BlockHash: &blockNumber}
+	}
+
+	// Finalized blocks are not looked up and a block shared by several
+	// transactions is looked up once.
+	source := &stubSource{head: 1000, finalized: 900, lookups: make(map[uint64]int)}
+	tracker := NewTracker(source, time.Second)
+	if err := tracker.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	txs := tracker.Annotate(context.Background(), []entity.Transaction{tx(10), tx(900), tx(950), tx(950)})
+	if txs[0].Finality != entity.FinalityFinalized || txs[2].Finality != entity.FinalityUnsafe || txs[3].Finality != entity.FinalityUnsafe {
+		t.Errorf("unexpected annotations %+v", txs)
+	}
+	if len(source.lookups) != 1 || source.lookups[950] != 1 {
+		t.Errorf("expected a single lookup of block 950, got %v", source.lookups)
+	}
+
+	// Without a finalized tag, deep blocks stay cached across refreshes and
+	// recent ones are looked up again.
+	source = &stubSource{head: 1000, lookups: make(map[uint64]int)}
+	tracker = NewTracker(source, time.Second)
+	for range 2 {
+		if err := tracker.Refresh(context.Background()); err != nil {
+			t.Fatalf("Refresh() error = %v", err)
+		}
+		tracker.Annotate(context.Background(), []entity.Transaction{tx(10), tx(1000)})
+	}
+	if source.lookups[10] != 1 || source.lookups[1000] != 2 {
+		t.Errorf("unexpected lookups %v", source.lookups)
+	}
+}
+
+func TestTrackerKeepsBlocksTheNodeLacksUnannotated(t *testing.T) {
+	tx := func(number uint64) entity.Transaction {
+		blockNumber := utils.IntToHex(number)
+		return entity.Transaction{BlockNumber: &blockNumber, BlockHash: &blockNumber}
+	}
+
+	source := &stubSource{head: 1000, lagging: 999, lookups: make(map[uint64]int)}
+	tracker := NewTracker(source, time.Second)
+	if err := tracker.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if _, err := tracker.CanonicalHash(context.Background(), 1000); !errors.Is(err, ErrUnknownBlock) {
+		t.Errorf("CanonicalHash() error = %v, want %v", err, ErrUnknownBlock)
+	}
+
+	txs := tracker.Annotate(context.Background(), []entity.Transaction{tx(1000), tx(999)})
+	if txs[0].Finality != "" || txs[0].Confirmations != 0 {
+		t.Errorf("expected the transaction of the missing block to stay unannotated, got %+v", txs[0])
+	}
+	if txs[1].Finality != entity.FinalityUnsafe || txs[1].Confirmations != 2 {
+		t.Errorf("unexpected annotation %+v", txs[1])
+	}
+}
@@ -0,0 +1,159 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"eth_parser/internal/domain/rpc"
+	"fmt"
+	"sync/atomic"
+)
+
+const version = "2.0"
+
+var _ rpc.Caller = (*Client)(nil)
+
+type request struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+	ID      uint64 `json:"id"`
+}
+
+type response struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// Error is an error object returned by the node.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("RPC error %d: %s", e.Code, e.Message)
+}
+
+// BatchElem is one request of a batch. Result and Error are filled in by BatchCall.
+type BatchElem struct {
+	Method string
+	Params []any
+	Result any
+	Error  error
+}
+
+// Subscriber is a transport that can deliver eth_subscribe notifications.
+type Subscriber interface {
+	Subscribe(ctx context.Context, params []any) (*Subscription, error)
+}
+
+// Client issues JSON-RPC calls over any Transport.
+type Client struct {
+	transport rpc.Transport
+	nextID    atomic.Uint64
+}
+
+func NewClient(transport rpc.Transport) *Client {
+	return &Client{
+		transport: transport,
+	}
+}
+
+func (c *Client) Call(ctx context.Context, method string, params []any, result any) error {
+	body, err := json.Marshal(request{JSONRPC: version, Method: method, Params: params, ID: c.nextID.Add(1)})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	raw, err := c.transport.RoundTrip(ctx, body)
+	if err != nil {
+		return err
+	}
+
+	var resp response
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return decodeResult(method, resp, result)
+}
+
+// BatchCall sends all elements in one message. Transport failures are
+// returned, while errors of single requests are stored in their element.
+func (c *Client) BatchCall(ctx context.Context, batch []BatchElem) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	requests := make([]request, len(batch))
+	index := make(map[uint64]int, len(batch))
+	for i, elem := range batch {
+		id := c.nextID.Add(1)
+		requests[i] = request{JSONRPC: version, Method: elem.Method, Params: elem.Params, ID: id}
+		index[id] = i
+	}
+
+	body, err := json.Marshal(requests)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch: %w", err)
+	}
+
+	raw, err := c.transport.RoundTrip(ctx, body)
+	if err != nil {
+		return err
+	}
+
+	var responses []response
+	if err := json.Unmarshal(raw, &responses); err != nil {
+		return fmt.Errorf("failed to unmarshal batch response: %w", err)
+	}
+
+	answered := make([]bool, len(batch))
+	for _, resp := range responses {
+		var id uint64
+		if err := json.Unmarshal(resp.ID, &id); err != nil {
+			continue
+		}
+		i, ok := index[id]
+		if !ok {
+			continue
+		}
+		answered[i] = true
+		batch[i].Error = decodeResult(batch[i].Method, resp, batch[i].Result)
+	}
+	for i := range batch {
+		if !answered[i] {
+			batch[i].Error = errors.New("missing response in batch")
+		}
+	}
+	return nil
+}
+
+// Subscribe starts an eth_subscribe subscription, e.g. with params
+// []any{"newHeads"}. It fails for transports without notifications.
+func (c *Client) Subscribe(ctx context.Context, params []any) (*Subscription, error) {
+	subscriber, ok := c.transport.(Subscriber)
+	if !ok {
+		return nil, errors.New("transport does not support subscriptions")
+	}
+	return subscriber.Subscribe(ctx, params)
+}
+
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
+
+func decodeResult(method string, resp response, result any) error {
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("failed to unmarshal %s result: %w", method, err)
+	}
+	return nil
+}
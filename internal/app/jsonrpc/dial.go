@@ -0,0 +1,44 @@
+package jsonrpc
+
+import (
+	"context"
+	httpclient "eth_parser/internal/domain/http_client"
+	"eth_parser/internal/domain/rpc"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Dial picks the transport by the scheme of rawURL: http(s)://, ws(s)://,
+// or ipc:// and unix:// followed by the socket path. A bare path is taken
+// as an IPC socket too. client is only used for HTTP.
+func Dial(ctx context.Context, rawURL string, client httpclient.HTTPClient) (rpc.Transport, error) {
+	if strings.HasPrefix(rawURL, "/") {
+		return stream(NewIPCTransport(ctx, rawURL))
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RPC URL: %w", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return NewHTTPTransport(client, rawURL), nil
+	case "ws", "wss":
+		return stream(NewWebSocketTransport(ctx, rawURL))
+	case "ipc", "unix":
+		// ipc:///path and ipc://relative/path both name a file.
+		return stream(NewIPCTransport(ctx, u.Host+u.Path))
+	}
+	return nil, fmt.Errorf("unsupported RPC URL scheme %q", u.Scheme)
+}
+
+// stream keeps a failed dial from returning a non-nil interface holding a
+// nil *StreamTransport.
+func stream(t *StreamTransport, err error) (rpc.Transport, error) {
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
@@ -0,0 +1,239 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/rpc"
+	"eth_parser/internal/utils"
+	"fmt"
+	"log"
+	"maps"
+)
+
+const (
+	methodBlockNum     = "eth_blockNumber"
+	methodBlockByNum   = "eth_getBlockByNumber"
+	methodLogs         = "eth_getLogs"
+	subscriptionHeads  = "newHeads"
+	subscriptionLogs   = "logs"
+	logsRetainedBlocks = 64
+)
+
+type header struct {
+	Number     string `json:"number"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parentHash"`
+	Timestamp  string `json:"timestamp"`
+	LogsBloom  string `json:"logsBloom"`
+}
+
+func (h header) block() (*entity.Block, error) {
+	number, err := utils.HexToInt(h.Number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse block number: %w", err)
+	}
+	timestamp, err := utils.HexToInt(h.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse block timestamp: %w", err)
+	}
+	return &entity.Block{
+		Number:     uint64(number),
+		Hash:       h.Hash,
+		ParentHash: h.ParentHash,
+		Timestamp:  uint64(timestamp),
+		LogsBloom:  h.LogsBloom,
+	}, nil
+}
+
+// Follower turns subscriptions into gapless streams. Notifications missed
+// while the connection was down, or skipped by the node, are fetched with
+// plain calls, usually over HTTP.
+type Follower struct {
+	subscriber Subscriber
+	caller     rpc.Caller
+}
+
+func NewFollower(subscriber Subscriber, caller rpc.Caller) *Follower {
+	return &Follower{
+		subscriber: subscriber,
+		caller:     caller,
+	}
+}
+
+// Heads sends every new block header to out until ctx is cancelled or the
+// subscription fails. Headers carry no transactions. After a reorg the new
+// head is sent again even if its number was already seen.
+func (f *Follower) Heads(ctx context.Context, out chan<- *entity.Block) error {
+	sub, err := f.subscriber.Subscribe(ctx, []any{subscriptionHeads})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to new heads: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	var last uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-sub.Err():
+			return err
+		case <-sub.Resubscribed():
+			latest, err := f.blockNumber(ctx)
+			if err != nil {
+				log.Println(fmt.Errorf("jsonrpc: failed to fill missed heads: %w", err))
+				continue
+			}
+			if err := f.fillHeads(ctx, last, latest+1, out); err != nil {
+				log.Println(fmt.Errorf("jsonrpc: failed to fill missed heads: %w", err))
+				continue
+			}
+			last = max(last, latest)
+		case raw := <-sub.Notifications():
+			var h header
+			if err := json.Unmarshal(raw, &h); err != nil {
+				log.Println(fmt.Errorf("jsonrpc: invalid head: %w", err))
+				continue
+			}
+			block, err := h.block()
+			if err != nil {
+				log.Println(fmt.Errorf("jsonrpc: invalid head: %w", err))
+				continue
+			}
+
+			if err := f.fillHeads(ctx, last, block.Number, out); err != nil {
+				log.Println(fmt.Errorf("jsonrpc: failed to fill missed heads: %w", err))
+			}
+			if !send(ctx, out, block) {
+				return ctx.Err()
+			}
+			last = max(last, block.Number)
+		}
+	}
+}
+
+// fillHeads fetches the headers after last and before next. Nothing is
+// filled before the first head was seen.
+func (f *Follower) fillHeads(ctx context.Context, last, next uint64, out chan<- *entity.Block) error {
+	if last == 0 {
+		return nil
+	}
+	for number := last + 1; number < next; number++ {
+		var h *header
+		if err := f.caller.Call(ctx, methodBlockByNum, []any{utils.IntToHex(number), false}, &h); err != nil {
+			return fmt.Errorf("failed to get block %d: %w", number, err)
+		}
+		if h == nil {
+			return nil
+		}
+		block, err := h.block()
+		if err != nil {
+			return err
+		}
+		if !send(ctx, out, block) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Logs sends every log matching filter to out until ctx is cancelled or the
+// subscription fails. filter takes the address and topics fields of
+// eth_getLogs. After a reconnect the missed blocks are queried with
+// eth_getLogs, and logs already sent are not sent twice.
+func (f *Follower) Logs(ctx context.Context, filter map[string]any, out chan<- entity.Log) error {
+	sub, err := f.subscriber.Subscribe(ctx, []any{subscriptionLogs, filter})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to logs: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	var (
+		watermark uint64
+		seen      = make(map[string]uint64)
+	)
+	deliver := func(entry entity.Log) bool {
+		block, err := utils.HexToInt(entry.BlockNumber)
+		if err != nil {
+			log.Println(fmt.Errorf("jsonrpc: invalid log: %w", err))
+			return true
+		}
+
+		// A removed log is sent again with the flag set, so keep it apart.
+		key := fmt.Sprintf("%s:%s:%t", entry.TransactionHash, entry.LogIndex, entry.Removed)
+		if _, ok := seen[key]; ok {
+			return true
+		}
+		seen[key] = uint64(block)
+		if uint64(block) > watermark {
+			watermark = uint64(block)
+			maps.DeleteFunc(seen, func(_ string, number uint64) bool {
+				return number+logsRetainedBlocks < watermark
+			})
+		}
+		return send(ctx, out, entry)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-sub.Err():
+			return err
+		case <-sub.Resubscribed():
+			if watermark == 0 {
+				continue
+			}
+			latest, err := f.blockNumber(ctx)
+			if err != nil {
+				log.Println(fmt.Errorf("jsonrpc: failed to fill missed logs: %w", err))
+				continue
+			}
+
+			query := maps.Clone(filter)
+			// The last block may have been cut short by the disconnect.
+			query["fromBlock"] = utils.IntToHex(watermark)
+			query["toBlock"] = utils.IntToHex(latest)
+			var missed []entity.Log
+			if err := f.caller.Call(ctx, methodLogs, []any{query}, &missed); err != nil {
+				log.Println(fmt.Errorf("jsonrpc: failed to fill missed logs: %w", err))
+				continue
+			}
+			for _, entry := range missed {
+				if !deliver(entry) {
+					return ctx.Err()
+				}
+			}
+		case raw := <-sub.Notifications():
+			var entry entity.Log
+			if err := json.Unmarshal(raw, &entry); err != nil {
+				log.Println(fmt.Errorf("jsonrpc: invalid log: %w", err))
+				continue
+			}
+			if !deliver(entry) {
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+func (f *Follower) blockNumber(ctx context.Context) (uint64, error) {
+	var latestHex string
+	if err := f.caller.Call(ctx, methodBlockNum, nil, &latestHex); err != nil {
+		return 0, fmt.Errorf("failed to get block number: %w", err)
+	}
+	latest, err := utils.HexToInt(latestHex)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse block number: %w", err)
+	}
+	return uint64(latest), nil
+}
+
+func send[T any](ctx context.Context, out chan<- T, value T) bool {
+	select {
+	case out <- value:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
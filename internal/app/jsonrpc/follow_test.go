@@ -0,0 +1,120 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"eth_parser/internal/domain/entity"
+	"testing"
+)
+
+// callerFunc answers calls made over HTTP while the socket reconnects.
+type callerFunc func(method string, params []any) any
+
+func (f callerFunc) Call(ctx context.Context, method string, params []any, result any) error {
+	data, err := json.Marshal(f(method, params))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+func head(number string) map[string]any {
+	return map[string]any{"number": number, "hash": "0xh" + number, "timestamp": "0x64"}
+}
+
+func connect(t *testing.T, node *fakeNode) *StreamTransport {
+	t.Helper()
+	transport, err := NewWebSocketTransport(context.Background(), node.url())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { transport.Close() })
+	return transport
+}
+
+func TestFollowerFillsMissedHeads(t *testing.T) {
+	node := newFakeNode(t, nil)
+	caller := callerFunc(func(method string, params []any) any {
+		switch method {
+		case methodBlockNum:
+			return "0x15"
+		case methodBlockByNum:
+			return head(params[0].(string))
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	heads := make(chan *entity.Block, 10)
+	go NewFollower(connect(t, node), caller).Heads(ctx, heads)
+
+	conn := receive(t, node.conns)
+	id := receive(t, node.subscribed)
+
+	expect := func(numbers ...uint64) {
+		t.Helper()
+		for _, number := range numbers {
+			if block := receive(t, heads); block.Number != number {
+				t.Fatalf("expected block %d, got %d", number, block.Number)
+			}
+		}
+	}
+
+	notify(t, conn, id, head("0x10"))
+	expect(0x10)
+
+	// The node skipped two heads.
+	notify(t, conn, id, head("0x13"))
+	expect(0x11, 0x12, 0x13)
+
+	// Heads announced while disconnected are fetched up to the current block.
+	conn.Close()
+	receive(t, node.conns)
+	receive(t, node.subscribed)
+	expect(0x14, 0x15)
+}
+
+func TestFollowerFillsMissedLogs(t *testing.T) {
+	seen := entity.Log{BlockNumber: "0x10", TransactionHash: "0xaa", LogIndex: "0x0"}
+	missed := entity.Log{BlockNumber: "0x11", TransactionHash: "0xbb", LogIndex: "0x0"}
+
+	var query map[string]any
+	node := newFakeNode(t, nil)
+	caller := callerFunc(func(method string, params []any) any {
+		switch method {
+		case methodBlockNum:
+			return "0x12"
+		case methodLogs:
+			query = params[0].(map[string]any)
+			return []entity.Log{seen, missed}
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logs := make(chan entity.Log, 10)
+	filter := map[string]any{"address": "0x1234"}
+	go NewFollower(connect(t, node), caller).Logs(ctx, filter, logs)
+
+	conn := receive(t, node.conns)
+	id := receive(t, node.subscribed)
+
+	notify(t, conn, id, seen)
+	if got := receive(t, logs); got.TransactionHash != seen.TransactionHash {
+		t.Fatalf("expected %s, got %s", seen.TransactionHash, got.TransactionHash)
+	}
+
+	conn.Close()
+	receive(t, node.conns)
+	receive(t, node.subscribed)
+
+	// The log delivered before the disconnect is not sent again.
+	if got := receive(t, logs); got.TransactionHash != missed.TransactionHash {
+		t.Fatalf("expected %s, got %s", missed.TransactionHash, got.TransactionHash)
+	}
+	if query["fromBlock"] != "0x10" || query["toBlock"] != "0x12" || query["address"] != "0x1234" {
+		t.Errorf("unexpected eth_getLogs filter %v", query)
+	}
+}
@@ -0,0 +1,51 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	httpclient "eth_parser/internal/domain/http_client"
+	"eth_parser/internal/domain/rpc"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var _ rpc.Transport = (*HTTPTransport)(nil)
+
+// HTTPTransport posts JSON-RPC messages to a node over HTTP.
+type HTTPTransport struct {
+	client httpclient.HTTPClient
+	url    string
+}
+
+func NewHTTPTransport(client httpclient.HTTPClient, url string) *HTTPTransport {
+	return &HTTPTransport{
+		client: client,
+		url:    url,
+	}
+}
+
+func (t *HTTPTransport) RoundTrip(ctx context.Context, body []byte) ([]byte, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return respBody, nil
+}
+
+func (t *HTTPTransport) Close() error {
+	return nil
+}
@@ -0,0 +1,62 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// ipcConn carries JSON-RPC messages over a Unix domain socket. The node
+// does not frame messages, so they are split by decoding the JSON stream.
+type ipcConn struct {
+	conn    net.Conn
+	decoder *json.Decoder
+
+	writeMutex sync.Mutex
+}
+
+func newIPCConn(conn net.Conn) *ipcConn {
+	return &ipcConn{
+		conn:    conn,
+		decoder: json.NewDecoder(conn),
+	}
+}
+
+func dialIPC(ctx context.Context, path string) (*ipcConn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", path, err)
+	}
+	return newIPCConn(conn), nil
+}
+
+func (c *ipcConn) ReadMessage() ([]byte, error) {
+	var message json.RawMessage
+	if err := c.decoder.Decode(&message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (c *ipcConn) WriteMessage(message []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	_, err := c.conn.Write(append(message, '\n'))
+	return err
+}
+
+func (c *ipcConn) Close() error {
+	return c.conn.Close()
+}
+
+// NewIPCTransport connects to the IPC socket of a local node, e.g.
+// ~/.ethereum/geth.ipc.
+func NewIPCTransport(ctx context.Context, path string) (*StreamTransport, error) {
+	return newStreamTransport(ctx, func(ctx context.Context) (messageConn, error) {
+		return dialIPC(ctx, path)
+	})
+}
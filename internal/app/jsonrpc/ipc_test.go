@@ -0,0 +1,113 @@
+package jsonrpc
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// newFakeIPCNode serves fakeNode on a Unix socket and returns its path.
+func newFakeIPCNode(t *testing.T, results map[string]any) (*fakeNode, string) {
+	n := &fakeNode{
+		results:    results,
+		conns:      make(chan messageConn, 10),
+		subscribed: make(chan string, 10),
+	}
+
+	path := filepath.Join(t.TempDir(), "node.ipc")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			ipc := newIPCConn(conn)
+			n.conns <- ipc
+			go n.serve(ipc)
+		}
+	}()
+	return n, path
+}
+
+func TestIPCTransport(t *testing.T) {
+	node, path := newFakeIPCNode(t, map[string]any{
+		"eth_blockNumber": "0x10",
+		"eth_chainId":     "0x1",
+	})
+
+	transport, err := Dial(context.Background(), "ipc://"+path, nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer transport.Close()
+	client := NewClient(transport)
+
+	var number, chainID string
+	batch := []BatchElem{
+		{Method: "eth_blockNumber", Result: &number},
+		{Method: "eth_chainId", Result: &chainID},
+	}
+	if err := client.BatchCall(context.Background(), batch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if number != "0x10" || chainID != "0x1" {
+		t.Errorf("unexpected batch results %s, %s", number, chainID)
+	}
+
+	sub, err := client.Subscribe(context.Background(), []any{"newHeads"})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	conn := receive(t, node.conns)
+	id := receive(t, node.subscribed)
+
+	// Messages on a socket are not framed, so send two in one go.
+	notify(t, conn, id, "0x11")
+	notify(t, conn, id, "0x12")
+	for _, want := range []string{`"0x11"`, `"0x12"`} {
+		if got := string(receive(t, sub.Notifications())); got != want {
+			t.Errorf("expected notification %s, got %s", want, got)
+		}
+	}
+
+	conn.Close()
+	conn = receive(t, node.conns)
+	id = receive(t, node.subscribed)
+	receive(t, sub.Resubscribed())
+
+	notify(t, conn, id, "0x13")
+	if got := string(receive(t, sub.Notifications())); got != `"0x13"` {
+		t.Errorf("unexpected notification %s", got)
+	}
+}
+
+func TestDial(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{name: "http", url: "https://ethereum-rpc.publicnode.com/"},
+		{name: "unknown scheme", url: "ftp://node", wantErr: true},
+		{name: "missing socket", url: "ipc:///nonexistent/geth.ipc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, err := Dial(context.Background(), tt.url, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Dial() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if transport != nil {
+				transport.Close()
+			}
+		})
+	}
+}
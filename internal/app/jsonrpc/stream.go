@@ -0,0 +1,576 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"eth_parser/internal/domain/rpc"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	methodSubscribe    = "eth_subscribe"
+	methodUnsubscribe  = "eth_unsubscribe"
+	methodNotification = "eth_subscription"
+
+	minReconnectDelay = 100 * time.Millisecond
+	maxReconnectDelay = 10 * time.Second
+
+	// notificationBuffer is how many notifications a subscription queues
+	// for a consumer that is busy.
+	notificationBuffer = 256
+)
+
+// requestTimeout bounds the requests whose context has no deadline, so a
+// node that never answers does not hold the caller forever.
+var requestTimeout = 30 * time.Second
+
+var (
+	errClosed         = errors.New("transport closed")
+	errConnectionLost = errors.New("connection lost")
+	// errRequested is returned when a subscription was already requested on
+	// the current connection by a concurrent call.
+	errRequested = errors.New("subscription already requested on this connection")
+)
+
+// messageConn is a connection that exchanges whole JSON-RPC messages.
+type messageConn interface {
+	ReadMessage() ([]byte, error)
+	WriteMessage(message []byte) error
+	Close() error
+}
+
+type dialFunc func(ctx context.Context) (messageConn, error)
+
+var (
+	_ rpc.Transport = (*StreamTransport)(nil)
+	_ Subscriber    = (*StreamTransport)(nil)
+)
+
+// pendingCall waits for the response to one request. When sub is set the
+// request is an eth_subscribe call and the reader maps the returned ID to
+// sub before it reads the next message, so no notification is missed.
+type pendingCall struct {
+	response chan json.RawMessage
+	sub      *Subscription
+}
+
+// StreamTransport multiplexes requests and subscriptions over a persistent
+// connection. Request IDs are rewritten so callers can reuse theirs, and
+// after a dropped connection it redials and renews every subscription.
+type StreamTransport struct {
+	dial dialFunc
+
+	mutex     sync.Mutex
+	conn      messageConn
+	connected chan struct{}
+	nextID    uint64
+	pending   map[uint64]pendingCall
+	// subscriptions maps the node's subscription IDs on the current connection.
+	subscriptions map[string]*Subscription
+	active        map[*Subscription]bool
+	closed        bool
+	done          chan struct{}
+}
+
+func newStreamTransport(ctx context.Context, dial dialFunc) (*StreamTransport, error) {
+	conn, err := dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &StreamTransport{
+		dial:          dial,
+		connected:     make(chan struct{}),
+		pending:       make(map[uint64]pendingCall),
+		subscriptions: make(map[string]*Subscription),
+		active:        make(map[*Subscription]bool),
+		done:          make(chan struct{}),
+	}
+	t.setConn(conn)
+	return t, nil
+}
+
+// NewWebSocketTransport connects to a ws:// or wss:// endpoint.
+func NewWebSocketTransport(ctx context.Context, url string) (*StreamTransport, error) {
+	return newStreamTransport(ctx, func(ctx context.Context) (messageConn, error) {
+		return dialWebSocket(ctx, url)
+	})
+}
+
+func (t *StreamTransport) setConn(conn messageConn) {
+	t.mutex.Lock()
+	t.conn = conn
+	close(t.connected)
+	t.mutex.Unlock()
+
+	go t.read(conn)
+}
+
+// RoundTrip sends a request or batch and waits for all of its responses.
+func (t *StreamTransport) RoundTrip(ctx context.Context, body []byte) ([]byte, error) {
+	trimmed := bytes.TrimSpace(body)
+	batch := len(trimmed) > 0 && trimmed[0] == '['
+
+	var messages []map[string]json.RawMessage
+	if batch {
+		if err := json.Unmarshal(trimmed, &messages); err != nil {
+			return nil, fmt.Errorf("invalid batch: %w", err)
+		}
+	} else {
+		var message map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &message); err != nil {
+			return nil, fmt.Errorf("invalid request: %w", err)
+		}
+		messages = []map[string]json.RawMessage{message}
+	}
+
+	responses, err := t.exchange(ctx, messages, batch, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if !batch {
+		return responses[0], nil
+	}
+	return json.Marshal(responses)
+}
+
+// exchange writes messages with fresh IDs and returns the responses with
+// the callers' IDs restored. Without a deadline on ctx, it gives up after
+// requestTimeout.
+func (t *StreamTransport) exchange(ctx context.Context, messages []map[string]json.RawMessage, batch bool, sub *Subscription) ([]json.RawMessage, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+	}
+
+	conn, err := t.waitConnected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	originalIDs := make([]json.RawMessage, len(messages))
+	calls := make([]pendingCall, len(messages))
+	ids := make([]uint64, len(messages))
+
+	t.mutex.Lock()
+	if sub != nil {
+		// Subscribe and the replay after a reconnect can both request a
+		// subscription on the new connection; only the first one does.
+		if sub.conn == conn {
+			t.mutex.Unlock()
+			return nil, errRequested
+		}
+		sub.conn = conn
+	}
+	for i, message := range messages {
+		t.nextID++
+		ids[i] = t.nextID
+		originalIDs[i] = message["id"]
+		message["id"] = json.RawMessage(fmt.Sprint(ids[i]))
+
+		calls[i] = pendingCall{response: make(chan json.RawMessage, 1), sub: sub}
+		t.pending[ids[i]] = calls[i]
+	}
+	t.mutex.Unlock()
+
+	defer func() {
+		t.mutex.Lock()
+		for _, id := range ids {
+			delete(t.pending, id)
+		}
+		t.mutex.Unlock()
+	}()
+
+	var payload []byte
+	if batch {
+		payload, err = json.Marshal(messages)
+	} else {
+		payload, err = json.Marshal(messages[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.WriteMessage(payload); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	responses := make([]json.RawMessage, len(messages))
+	for i, call := range calls {
+		select {
+		case raw, ok := <-call.response:
+			if !ok {
+				return nil, errConnectionLost
+			}
+			responses[i] = restoreID(raw, originalIDs[i])
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-t.done:
+			return nil, errClosed
+		}
+	}
+	return responses, nil
+}
+
+func (t *StreamTransport) waitConnected(ctx context.Context) (messageConn, error) {
+	for {
+		t.mutex.Lock()
+		conn, connected, closed := t.conn, t.connected, t.closed
+		t.mutex.Unlock()
+
+		if closed {
+			return nil, errClosed
+		}
+		if conn != nil {
+			return conn, nil
+		}
+
+		select {
+		case <-connected:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-t.done:
+			return nil, errClosed
+		}
+	}
+}
+
+// restoreID puts the caller's ID back into a response.
+func restoreID(raw json.RawMessage, id json.RawMessage) json.RawMessage {
+	var message map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &message); err != nil {
+		return raw
+	}
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	message["id"] = id
+	restored, err := json.Marshal(message)
+	if err != nil {
+		return raw
+	}
+	return restored
+}
+
+type incoming struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+	Params struct {
+		Subscription string          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	} `json:"params"`
+}
+
+func (t *StreamTransport) read(conn messageConn) {
+	for {
+		raw, err := conn.ReadMessage()
+		if err != nil {
+			t.disconnected(conn, err)
+			return
+		}
+
+		raw = bytes.TrimSpace(raw)
+		if len(raw) > 0 && raw[0] == '[' {
+			var batch []json.RawMessage
+			if err := json.Unmarshal(raw, &batch); err != nil {
+				log.Println(fmt.Errorf("jsonrpc: invalid batch from node: %w", err))
+				continue
+			}
+			for _, message := range batch {
+				t.dispatch(message)
+			}
+			continue
+		}
+		t.dispatch(raw)
+	}
+}
+
+func (t *StreamTransport) dispatch(raw json.RawMessage) {
+	var message incoming
+	if err := json.Unmarshal(raw, &message); err != nil {
+		log.Println(fmt.Errorf("jsonrpc: invalid message from node: %w", err))
+		return
+	}
+
+	if message.Method == methodNotification {
+		t.mutex.Lock()
+		sub := t.subscriptions[message.Params.Subscription]
+		t.mutex.Unlock()
+		if sub != nil {
+			sub.deliver(message.Params.Result)
+		}
+		return
+	}
+
+	var id uint64
+	if err := json.Unmarshal(message.ID, &id); err != nil {
+		return
+	}
+
+	t.mutex.Lock()
+	call, ok := t.pending[id]
+	delete(t.pending, id)
+	if ok && call.sub != nil && message.Error == nil {
+		var subID string
+		if err := json.Unmarshal(message.Result, &subID); err == nil {
+			t.subscriptions[subID] = call.sub
+		}
+	}
+	t.mutex.Unlock()
+
+	if ok {
+		call.response <- raw
+	}
+}
+
+// disconnected fails the requests in flight and reconnects in the background.
+func (t *StreamTransport) disconnected(conn messageConn, err error) {
+	conn.Close()
+
+	t.mutex.Lock()
+	if t.conn != conn {
+		t.mutex.Unlock()
+		return
+	}
+	t.conn = nil
+	t.connected = make(chan struct{})
+	for id, call := range t.pending {
+		close(call.response)
+		delete(t.pending, id)
+	}
+	t.subscriptions = make(map[string]*Subscription)
+	closed := t.closed
+	t.mutex.Unlock()
+
+	if closed {
+		return
+	}
+	log.Println(fmt.Errorf("jsonrpc: connection lost, reconnecting: %w", err))
+	go t.reconnect()
+}
+
+func (t *StreamTransport) reconnect() {
+	delay := minReconnectDelay
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-time.After(delay):
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), maxReconnectDelay)
+		conn, err := t.dial(ctx)
+		cancel()
+		if err == nil {
+			t.setConn(conn)
+			break
+		}
+
+		log.Println(fmt.Errorf("jsonrpc: failed to reconnect: %w", err))
+		delay = min(delay*2, maxReconnectDelay)
+	}
+
+	t.mutex.Lock()
+	subscriptions := make([]*Subscription, 0, len(t.active))
+	for sub := range t.active {
+		subscriptions = append(subscriptions, sub)
+	}
+	t.mutex.Unlock()
+
+	for _, sub := range subscriptions {
+		ctx, cancel := context.WithTimeout(context.Background(), maxReconnectDelay)
+		err := t.subscribe(ctx, sub)
+		cancel()
+		if errors.Is(err, errRequested) {
+			// Subscribed while reconnecting, so nothing was missed.
+			continue
+		}
+		if err != nil {
+			sub.fail(fmt.Errorf("failed to resubscribe: %w", err))
+			continue
+		}
+		sub.signalResubscribed()
+	}
+}
+
+// Subscribe calls eth_subscribe with params and delivers the notifications
+// of the subscription until it is unsubscribed or the transport is closed.
+func (t *StreamTransport) Subscribe(ctx context.Context, params []any) (*Subscription, error) {
+	sub := newSubscription(t, params)
+
+	// The subscription is active before it is requested, so a connection
+	// lost in the meantime renews it like the others.
+	t.mutex.Lock()
+	if t.closed {
+		t.mutex.Unlock()
+		return nil, errClosed
+	}
+	t.active[sub] = true
+	t.mutex.Unlock()
+
+	err := t.subscribe(ctx, sub)
+	if errors.Is(err, errConnectionLost) || errors.Is(err, errRequested) {
+		// Renewed and signalled on Resubscribed once reconnected, or
+		// already renewed on the new connection.
+		return sub, nil
+	}
+	if err != nil {
+		sub.Unsubscribe()
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (t *StreamTransport) subscribe(ctx context.Context, sub *Subscription) error {
+	params, err := json.Marshal(sub.params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal subscription params: %w", err)
+	}
+	message := map[string]json.RawMessage{
+		"jsonrpc": json.RawMessage(`"` + version + `"`),
+		"method":  json.RawMessage(`"` + methodSubscribe + `"`),
+		"params":  params,
+	}
+
+	responses, err := t.exchange(ctx, []map[string]json.RawMessage{message}, false, sub)
+	if err != nil {
+		return err
+	}
+
+	var resp response
+	if err := json.Unmarshal(responses[0], &resp); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return nil
+}
+
+func (t *StreamTransport) unsubscribe(sub *Subscription) {
+	t.mutex.Lock()
+	delete(t.active, sub)
+	id := ""
+	for serverID, s := range t.subscriptions {
+		if s == sub {
+			id = serverID
+			delete(t.subscriptions, serverID)
+		}
+	}
+	closed := t.closed
+	t.mutex.Unlock()
+
+	if id == "" || closed {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := NewClient(t).Call(ctx, methodUnsubscribe, []any{id}, nil); err != nil {
+		log.Println(fmt.Errorf("jsonrpc: failed to unsubscribe %s: %w", id, err))
+	}
+}
+
+func (t *StreamTransport) Close() error {
+	t.mutex.Lock()
+	if t.closed {
+		t.mutex.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.done)
+	conn := t.conn
+	subscriptions := t.active
+	t.active = make(map[*Subscription]bool)
+	t.mutex.Unlock()
+
+	for sub := range subscriptions {
+		sub.fail(errClosed)
+	}
+	if conn != nil {
+		return conn.Close()
+	}
+	return nil
+}
+
+// Subscription receives the notifications of an eth_subscribe call.
+type Subscription struct {
+	transport *StreamTransport
+	params    []any
+	// conn is the connection the subscription was last requested on,
+	// guarded by the transport's mutex.
+	conn          messageConn
+	notifications chan json.RawMessage
+	resubscribed  chan struct{}
+	err           chan error
+	done          chan struct{}
+	once          sync.Once
+}
+
+func newSubscription(t *StreamTransport, params []any) *Subscription {
+	return &Subscription{
+		transport:     t,
+		params:        params,
+		notifications: make(chan json.RawMessage, notificationBuffer),
+		resubscribed:  make(chan struct{}, 1),
+		err:           make(chan error, 1),
+		done:          make(chan struct{}),
+	}
+}
+
+// Notifications delivers the result of every notification, in order.
+func (s *Subscription) Notifications() <-chan json.RawMessage {
+	return s.notifications
+}
+
+// Resubscribed is signalled when notifications were lost: after the
+// subscription was renewed on a new connection, or after the consumer fell
+// so far behind that its buffer overflowed. Consumers use it to fill the gap.
+func (s *Subscription) Resubscribed() <-chan struct{} {
+	return s.resubscribed
+}
+
+// Err receives an error when the subscription ends for good.
+func (s *Subscription) Err() <-chan error {
+	return s.err
+}
+
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.done)
+		s.transport.unsubscribe(s)
+	})
+}
+
+// deliver never blocks, as one read loop serves every subscription of the
+// connection. A notification the buffer has no room for is dropped.
+func (s *Subscription) deliver(result json.RawMessage) {
+	select {
+	case s.notifications <- result:
+	case <-s.done:
+	default:
+		log.Println("jsonrpc: subscription buffer is full, dropping a notification")
+		s.signalResubscribed()
+	}
+}
+
+func (s *Subscription) signalResubscribed() {
+	select {
+	case s.resubscribed <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Subscription) fail(err error) {
+	select {
+	case s.err <- err:
+	default:
+	}
+}
@@ -0,0 +1,384 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeNode is a JSON-RPC server over WebSocket or IPC. Every accepted
+// connection is sent on conns and every subscription ID on subscribed, so
+// tests can push notifications and drop connections.
+type fakeNode struct {
+	server     *httptest.Server
+	results    map[string]any
+	conns      chan messageConn
+	subscribed chan string
+	nextSub    atomic.Int64
+	// dropSubscribe makes the node close the connection instead of
+	// answering the next eth_subscribe request.
+	dropSubscribe atomic.Bool
+	// silent makes the node accept connections but never read from or
+	// write to them, like a node that died without closing them.
+	silent atomic.Bool
+}
+
+func newFakeNode(t *testing.T, results map[string]any) *fakeNode {
+	n := &fakeNode{
+		results:    results,
+		conns:      make(chan messageConn, 10),
+		subscribed: make(chan string, 10),
+	}
+	n.server = httptest.NewServer(http.HandlerFunc(n.upgrade))
+	t.Cleanup(n.server.Close)
+	return n
+}
+
+func (n *fakeNode) url() string {
+	return "ws" + strings.TrimPrefix(n.server.URL, "http")
+}
+
+func (n *fakeNode) upgrade(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", acceptKey(key))
+	rw.Flush()
+
+	ws := newWSConn(conn, rw.Reader, false)
+	n.conns <- ws
+	if !n.silent.Load() {
+		go n.serve(ws)
+	}
+}
+
+func (n *fakeNode) serve(conn messageConn) {
+	for {
+		raw, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var reply any
+		if strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+			var batch []map[string]json.RawMessage
+			json.Unmarshal(raw, &batch)
+			replies := make([]any, len(batch))
+			for i, req := range batch {
+				replies[i] = n.answer(req)
+			}
+			reply = replies
+		} else {
+			var req map[string]json.RawMessage
+			json.Unmarshal(raw, &req)
+			if string(req["method"]) == `"`+methodSubscribe+`"` && n.dropSubscribe.CompareAndSwap(true, false) {
+				conn.Close()
+				return
+			}
+			reply = n.answer(req)
+		}
+
+		data, _ := json.Marshal(reply)
+		if err := conn.WriteMessage(data); err != nil {
+			return
+		}
+	}
+}
+
+func (n *fakeNode) answer(req map[string]json.RawMessage) map[string]any {
+	var method string
+	json.Unmarshal(req["method"], &method)
+
+	result := n.results[method]
+	if method == methodSubscribe {
+		id := fmt.Sprintf("0xs%d", n.nextSub.Add(1))
+		n.subscribed <- id
+		result = id
+	}
+	return map[string]any{"jsonrpc": "2.0", "id": req["id"], "result": result}
+}
+
+func notify(t *testing.T, conn messageConn, subscription string, result any) {
+	t.Helper()
+	data, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  methodNotification,
+		"params":  map[string]any{"subscription": subscription, "result": result},
+	})
+	if err := conn.WriteMessage(data); err != nil {
+		t.Fatalf("failed to send notification: %v", err)
+	}
+}
+
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+	var zero T
+	return zero
+}
+
+func TestWebSocketCall(t *testing.T) {
+	node := newFakeNode(t, map[string]any{
+		"eth_blockNumber": "0x10",
+		"eth_chainId":     "0x1",
+	})
+	transport, err := NewWebSocketTransport(context.Background(), node.url())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer transport.Close()
+	client := NewClient(transport)
+
+	var number string
+	if err := client.Call(context.Background(), "eth_blockNumber", nil, &number); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if number != "0x10" {
+		t.Errorf("expected 0x10, got %s", number)
+	}
+
+	// Raw requests keep their own IDs even though they are rewritten on the wire.
+	raw, err := transport.RoundTrip(context.Background(), []byte(`{"jsonrpc":"2.0","method":"eth_chainId","id":"abc"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var resp response
+	json.Unmarshal(raw, &resp)
+	if string(resp.ID) != `"abc"` {
+		t.Errorf("expected id \"abc\", got %s", resp.ID)
+	}
+
+	var chainID string
+	batch := []BatchElem{
+		{Method: "eth_blockNumber", Result: &number},
+		{Method: "eth_chainId", Result: &chainID},
+	}
+	if err := client.BatchCall(context.Background(), batch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, elem := range batch {
+		if elem.Error != nil {
+			t.Errorf("unexpected error for %s: %v", elem.Method, elem.Error)
+		}
+	}
+	if number != "0x10" || chainID != "0x1" {
+		t.Errorf("unexpected batch results %s, %s", number, chainID)
+	}
+}
+
+// setKeepAlive shortens the WebSocket keepalive for one test.
+func setKeepAlive(t *testing.T, interval, timeout time.Duration) {
+	savedInterval, savedTimeout := wsPingInterval, wsReadTimeout
+	wsPingInterval, wsReadTimeout = interval, timeout
+	t.Cleanup(func() { wsPingInterval, wsReadTimeout = savedInterval, savedTimeout })
+}
+
+func TestWebSocketKeepsIdleConnectionsUp(t *testing.T) {
+	setKeepAlive(t, 10*time.Millisecond, 50*time.Millisecond)
+	node := newFakeNode(t, map[string]any{"eth_blockNumber": "0x10"})
+	transport, err := NewWebSocketTransport(context.Background(), node.url())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer transport.Close()
+	receive(t, node.conns)
+
+	// Pongs keep the connection from timing out while nothing is asked.
+	time.Sleep(200 * time.Millisecond)
+	var number string
+	if err := NewClient(transport).Call(context.Background(), "eth_blockNumber", nil, &number); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case <-node.conns:
+		t.Error("the idle connection was dropped")
+	default:
+	}
+}
+
+func TestWebSocketReconnectsToSilentNode(t *testing.T) {
+	setKeepAlive(t, 10*time.Millisecond, 50*time.Millisecond)
+	node := newFakeNode(t, nil)
+	node.silent.Store(true)
+	transport, err := NewWebSocketTransport(context.Background(), node.url())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer transport.Close()
+	receive(t, node.conns)
+
+	// No pong comes back, so the read times out and the client redials.
+	receive(t, node.conns)
+}
+
+func TestWebSocketBoundsRequestsWithoutDeadline(t *testing.T) {
+	saved := requestTimeout
+	requestTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { requestTimeout = saved })
+
+	node := newFakeNode(t, nil)
+	node.silent.Store(true)
+	transport, err := NewWebSocketTransport(context.Background(), node.url())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer transport.Close()
+
+	var number string
+	err = NewClient(transport).Call(context.Background(), "eth_blockNumber", nil, &number)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the request to time out, got %v", err)
+	}
+}
+
+func TestWebSocketResubscribesAfterReconnect(t *testing.T) {
+	node := newFakeNode(t, nil)
+	transport, err := NewWebSocketTransport(context.Background(), node.url())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer transport.Close()
+
+	sub, err := NewClient(transport).Subscribe(context.Background(), []any{"newHeads"})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	conn := receive(t, node.conns)
+	id := receive(t, node.subscribed)
+
+	notify(t, conn, id, map[string]any{"number": "0x1"})
+	if got := string(receive(t, sub.Notifications())); got != `{"number":"0x1"}` {
+		t.Errorf("unexpected notification %s", got)
+	}
+
+	conn.Close()
+	conn = receive(t, node.conns)
+	id = receive(t, node.subscribed)
+	receive(t, sub.Resubscribed())
+
+	notify(t, conn, id, map[string]any{"number": "0x2"})
+	if got := string(receive(t, sub.Notifications())); got != `{"number":"0x2"}` {
+		t.Errorf("unexpected notification %s", got)
+	}
+}
+
+func TestWebSocketSubscribeDuringReconnect(t *testing.T) {
+	node := newFakeNode(t, nil)
+	transport, err := NewWebSocketTransport(context.Background(), node.url())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer transport.Close()
+	receive(t, node.conns)
+
+	// The connection is lost before the subscription is confirmed, so it
+	// is requested again on the new one.
+	node.dropSubscribe.Store(true)
+	sub, err := NewClient(transport).Subscribe(context.Background(), []any{"newHeads"})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	conn := receive(t, node.conns)
+	id := receive(t, node.subscribed)
+	receive(t, sub.Resubscribed())
+
+	notify(t, conn, id, map[string]any{"number": "0x1"})
+	if got := string(receive(t, sub.Notifications())); got != `{"number":"0x1"}` {
+		t.Errorf("unexpected notification %s", got)
+	}
+}
+
+func TestWebSocketSubscribeWhileReconnecting(t *testing.T) {
+	node := newFakeNode(t, nil)
+	transport, err := NewWebSocketTransport(context.Background(), node.url())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer transport.Close()
+	conn := receive(t, node.conns)
+
+	client := NewClient(transport)
+	if _, err := client.Subscribe(context.Background(), []any{"newHeads"}); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	receive(t, node.subscribed)
+
+	conn.Close()
+	for {
+		transport.mutex.Lock()
+		lost := transport.conn == nil
+		transport.mutex.Unlock()
+		if lost {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Requested on the new connection, so the replay leaves it alone.
+	if _, err := client.Subscribe(context.Background(), []any{"logs", map[string]any{}}); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	receive(t, node.subscribed)
+	receive(t, node.subscribed)
+	select {
+	case id := <-node.subscribed:
+		t.Errorf("expected one request per subscription, got another %s", id)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWebSocketSlowSubscriberDoesNotBlockOthers(t *testing.T) {
+	node := newFakeNode(t, nil)
+	transport, err := NewWebSocketTransport(context.Background(), node.url())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer transport.Close()
+	conn := receive(t, node.conns)
+
+	client := NewClient(transport)
+	slow, err := client.Subscribe(context.Background(), []any{"newHeads"})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	slowID := receive(t, node.subscribed)
+	fast, err := client.Subscribe(context.Background(), []any{"logs", map[string]any{}})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	fastID := receive(t, node.subscribed)
+
+	// slow is never read, so its buffer overflows.
+	for i := 0; i <= notificationBuffer; i++ {
+		notify(t, conn, slowID, map[string]any{"number": fmt.Sprintf("0x%x", i)})
+	}
+	notify(t, conn, fastID, map[string]any{"logIndex": "0x0"})
+
+	if got := string(receive(t, fast.Notifications())); got != `{"logIndex":"0x0"}` {
+		t.Errorf("unexpected notification %s", got)
+	}
+	// The dropped notification is reported as a gap to fill.
+	receive(t, slow.Resubscribed())
+}
+
+func TestClientSubscribeRequiresStreamTransport(t *testing.T) {
+	client := NewClient(NewHTTPTransport(http.DefaultClient, "http://localhost"))
+	if _, err := client.Subscribe(context.Background(), []any{"newHeads"}); err == nil {
+		t.Error("expected an error for an HTTP transport")
+	}
+}
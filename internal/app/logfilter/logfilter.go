@@ -0,0 +1,195 @@
+// Package logfilter selects event logs the way eth_getLogs does, so the
+// same filter can be sent to the node and checked against logs locally.
+package logfilter
+
+import (
+	"context"
+	"encoding/hex"
+	"eth_parser/internal/app/bloom"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/rpc"
+	"eth_parser/internal/utils"
+	"fmt"
+	"strings"
+)
+
+const methodLogs = "eth_getLogs"
+
+// Filter selects the logs emitted by one of Addresses, or by any contract
+// when it is empty, whose topics match Topics position by position. An
+// empty position matches any topic and a position listing several topics
+// matches any of them.
+type Filter struct {
+	Addresses []string
+	Topics    [][]string
+}
+
+// Params returns the filter object of an eth_getLogs request for the
+// blocks [from, to].
+func (f Filter) Params(from, to uint64) map[string]any {
+	params := f.SubscribeParams()
+	params["fromBlock"] = utils.IntToHex(from)
+	params["toBlock"] = utils.IntToHex(to)
+	return params
+}
+
+// SubscribeParams returns the filter object of an eth_subscribe logs
+// request, which takes no block range.
+func (f Filter) SubscribeParams() map[string]any {
+	params := map[string]any{}
+	if len(f.Addresses) > 0 {
+		params["address"] = oneOrMany(f.Addresses)
+	}
+
+	topics := f.Topics
+	for len(topics) > 0 && len(topics[len(topics)-1]) == 0 {
+		topics = topics[:len(topics)-1]
+	}
+	if len(topics) > 0 {
+		positions := make([]any, len(topics))
+		for i, values := range topics {
+			if len(values) > 0 {
+				positions[i] = oneOrMany(values)
+			}
+		}
+		params["topics"] = positions
+	}
+	return params
+}
+
+// oneOrMany passes a single value as a string and several as a list, as
+// both are accepted by eth_getLogs.
+func oneOrMany(values []string) any {
+	if len(values) == 1 {
+		return values[0]
+	}
+	return values
+}
+
+// Matches tells whether the filter selects l.
+func (f Filter) Matches(l entity.Log) bool {
+	if len(f.Addresses) > 0 && !containsFold(f.Addresses, l.Address) {
+		return false
+	}
+	for i, values := range f.Topics {
+		if len(values) == 0 {
+			continue
+		}
+		if i >= len(l.Topics) || !containsFold(values, l.Topics[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// MayMatch tells whether a block with the logs bloom b may hold a log the
+// filter selects. When it returns false, fetching the logs of the block
+// is not needed.
+func (f Filter) MayMatch(b bloom.Bloom) bool {
+	if len(f.Addresses) > 0 && !anyInBloom(b, f.Addresses) {
+		return false
+	}
+	for _, values := range f.Topics {
+		if len(values) > 0 && !anyInBloom(b, values) {
+			return false
+		}
+	}
+	return true
+}
+
+func anyInBloom(b bloom.Bloom, values []string) bool {
+	for _, value := range values {
+		if b.TestHex(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge returns a filter selecting every log one of filters selects, and
+// possibly more. It lets one request serve many filters whose results are
+// then told apart with Matches.
+func Merge(filters ...Filter) Filter {
+	if len(filters) == 0 {
+		return Filter{}
+	}
+
+	merged := Filter{Addresses: union(nil, filters[0].Addresses), Topics: make([][]string, len(filters[0].Topics))}
+	for i, values := range filters[0].Topics {
+		merged.Topics[i] = union(nil, values)
+	}
+	for _, f := range filters[1:] {
+		if len(merged.Addresses) > 0 && len(f.Addresses) > 0 {
+			merged.Addresses = union(merged.Addresses, f.Addresses)
+		} else {
+			merged.Addresses = nil
+		}
+		if len(f.Topics) < len(merged.Topics) {
+			merged.Topics = merged.Topics[:len(f.Topics)]
+		}
+		for i := range merged.Topics {
+			if len(merged.Topics[i]) > 0 && len(f.Topics[i]) > 0 {
+				merged.Topics[i] = union(merged.Topics[i], f.Topics[i])
+			} else {
+				merged.Topics[i] = nil
+			}
+		}
+	}
+	return merged
+}
+
+// Fetch returns the logs of [from, to] selected by any of filters. A log
+// selected by several filters is returned once.
+func Fetch(ctx context.Context, caller rpc.Caller, filters []Filter, from, to uint64) ([]entity.Log, error) {
+	var logs []entity.Log
+	seen := make(map[string]bool)
+	for _, f := range filters {
+		var found []entity.Log
+		if err := caller.Call(ctx, methodLogs, []any{f.Params(from, to)}, &found); err != nil {
+			return nil, fmt.Errorf("failed to get logs: %w", err)
+		}
+		for _, l := range found {
+			key := l.TransactionHash + ":" + l.LogIndex
+			if !seen[key] {
+				seen[key] = true
+				logs = append(logs, l)
+			}
+		}
+	}
+	return logs, nil
+}
+
+// Topic left-pads a hex value of up to 32 bytes, e.g. an address, to a
+// topic.
+func Topic(value string) (string, error) {
+	digits := strings.ToLower(strings.TrimPrefix(value, "0x"))
+	if len(digits) > 64 {
+		return "", fmt.Errorf("topic %q is longer than 32 bytes", value)
+	}
+	if len(digits)%2 == 1 {
+		digits = "0" + digits
+	}
+	if _, err := hex.DecodeString(digits); err != nil {
+		return "", fmt.Errorf("topic %q is not hex", value)
+	}
+	return "0x" + strings.Repeat("0", 64-len(digits)) + digits, nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// union appends the values of b missing from a, ignoring case.
+func union(a, b []string) []string {
+	for _, v := range b {
+		if !containsFold(a, v) {
+			a = append(a, strings.ToLower(v))
+		}
+	}
+	return a
+}
@@ -0,0 +1,106 @@
+package logfilter
+
+import (
+	"encoding/json"
+	"eth_parser/internal/app/bloom"
+	"eth_parser/internal/domain/entity"
+	"testing"
+)
+
+const (
+	transfer = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	approval = "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"
+	token    = "0x00000000000000000000000000000000000000aa"
+	alice    = "0x0000000000000000000000001111111111111111111111111111111111111111"
+	bob      = "0x0000000000000000000000002222222222222222222222222222222222222222"
+)
+
+func TestParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{name: "everything", filter: Filter{}, want: `{"fromBlock":"0x1","toBlock":"0x2"}`},
+		{name: "one value per position", filter: Filter{Addresses: []string{token}, Topics: [][]string{{transfer}, nil, {alice}}}, want: `{"address":"` + token + `","fromBlock":"0x1","toBlock":"0x2","topics":["` + transfer + `",null,"` + alice + `"]}`},
+		{name: "alternatives", filter: Filter{Topics: [][]string{{transfer, approval}}}, want: `{"fromBlock":"0x1","toBlock":"0x2","topics":[["` + transfer + `","` + approval + `"]]}`},
+		{name: "trailing wildcards", filter: Filter{Topics: [][]string{{transfer}, nil, nil}}, want: `{"fromBlock":"0x1","toBlock":"0x2","topics":["` + transfer + `"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := json.Marshal(tt.filter.Params(1, 2))
+			if string(got) != tt.want {
+				t.Errorf("Params() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeMatches(t *testing.T) {
+	fromAlice := Filter{Addresses: []string{token}, Topics: [][]string{{transfer}, {alice}}}
+	toBob := Filter{Addresses: []string{token}, Topics: [][]string{{transfer}, nil, {bob}}}
+	approvals := Filter{Topics: [][]string{{approval}}}
+
+	tests := []struct {
+		name   string
+		log    entity.Log
+		filter Filter
+		want   bool
+	}{
+		{name: "from alice", log: entity.Log{Address: token, Topics: []string{transfer, alice, bob}}, filter: fromAlice, want: true},
+		{name: "contract is compared ignoring case", log: entity.Log{Address: "0x00000000000000000000000000000000000000AA", Topics: []string{transfer, alice}}, filter: fromAlice, want: true},
+		{name: "from bob", log: entity.Log{Address: token, Topics: []string{transfer, bob, bob}}, filter: fromAlice, want: false},
+		{name: "other contract", log: entity.Log{Address: "0xbb", Topics: []string{transfer, alice}}, filter: fromAlice, want: false},
+		{name: "missing topic", log: entity.Log{Address: token, Topics: []string{transfer, alice}}, filter: toBob, want: false},
+		{name: "merged covers both", log: entity.Log{Address: token, Topics: []string{transfer, bob, bob}}, filter: Merge(fromAlice, toBob), want: true},
+		{name: "merged covers any contract", log: entity.Log{Address: "0xbb", Topics: []string{approval}}, filter: Merge(fromAlice, approvals), want: true},
+		{name: "merged still filters topic0", log: entity.Log{Address: token, Topics: []string{"0x01"}}, filter: Merge(fromAlice, approvals), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(tt.log); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMayMatch(t *testing.T) {
+	// A block with one transfer from alice to bob.
+	b := bloom.Of([]string{token, transfer, alice, bob})
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{name: "everything", filter: Filter{}, want: true},
+		{name: "from alice", filter: Filter{Addresses: []string{token}, Topics: [][]string{{transfer}, {alice}}}, want: true},
+		{name: "any of several contracts", filter: Filter{Addresses: []string{"0xbb", token}}, want: true},
+		{name: "other contract", filter: Filter{Addresses: []string{"0xbb"}, Topics: [][]string{{transfer}}}, want: false},
+		{name: "other event", filter: Filter{Topics: [][]string{{approval}}}, want: false},
+		{name: "wildcard position", filter: Filter{Topics: [][]string{{transfer}, nil, {approval, bob}}}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.MayMatch(b); got != tt.want {
+				t.Errorf("MayMatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopic(t *testing.T) {
+	if got, err := Topic("0x1111111111111111111111111111111111111111"); err != nil || got != "0x0000000000000000000000001111111111111111111111111111111111111111" {
+		t.Errorf("Topic(address) = %s, %v", got, err)
+	}
+	if got, err := Topic("0xABC"); err != nil || got != "0x"+"0000000000000000000000000000000000000000000000000000000000000abc" {
+		t.Errorf("Topic(0xABC) = %s, %v", got, err)
+	}
+	if _, err := Topic("0xzz"); err == nil {
+		t.Error("expected non-hex topics to be rejected")
+	}
+}
@@ -0,0 +1,351 @@
+package mempool
+
+import (
+	"context"
+	"eth_parser/internal/app/txcodec"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"eth_parser/internal/domain/rpc"
+	"eth_parser/internal/utils"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	methodNewPendingFilter = "eth_newPendingTransactionFilter"
+	methodFilterChanges    = "eth_getFilterChanges"
+	methodTxPoolContent    = "txpool_content"
+	methodTxByHash         = "eth_getTransactionByHash"
+	methodTxCount          = "eth_getTransactionCount"
+
+	// codeMethodNotFound is the JSON-RPC error for a method the node does not serve.
+	codeMethodNotFound = "-32601"
+
+	// transferSelector and transferFromSelector are the 4-byte selectors of
+	// ERC-20 transfer(address,uint256) and transferFrom(address,address,uint256).
+	transferSelector     = "0xa9059cbb"
+	transferFromSelector = "0x23b872dd"
+)
+
+// Subscriptions tells the watcher which addresses it should look for.
+type Subscriptions interface {
+	IsSubscribed(address string) bool
+}
+
+type Config struct {
+	// Interval between two polls of the mempool.
+	Interval time.Duration
+	// DropAfter is how long a transaction may stay unconfirmed before the
+	// watcher checks whether the node still knows it.
+	DropAfter time.Duration
+	// Retention is how long included and dropped transactions are kept.
+	Retention time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Interval:  2 * time.Second,
+		DropAfter: 30 * time.Minute,
+		Retention: time.Hour,
+	}
+}
+
+// Watcher stores mempool transactions involving subscribed addresses and
+// moves them to included or dropped as blocks arrive. It implements
+// scanner.BlockHandler.
+type Watcher struct {
+	caller        rpc.Caller
+	subscriptions Subscriptions
+	store         repository.PendingStore
+	cfg           Config
+	now           func() time.Time
+
+	mutex    sync.Mutex
+	filterID string
+	// txpool is cleared once the node turns out not to serve txpool_content.
+	txpool bool
+}
+
+func NewWatcher(caller rpc.Caller, subscriptions Subscriptions, store repository.PendingStore, cfg Config) *Watcher {
+	return &Watcher{
+		caller:        caller,
+		subscriptions: subscriptions,
+		store:         store,
+		cfg:           cfg,
+		now:           time.Now,
+		txpool:        true,
+	}
+}
+
+// Run polls the mempool until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.Poll(ctx); err != nil && ctx.Err() == nil {
+			log.Println(fmt.Errorf("mempool: %w", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll fetches the transactions that entered the mempool since the last poll.
+func (w *Watcher) Poll(ctx context.Context) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.txpool {
+		err := w.pollTxPool(ctx)
+		if err == nil {
+			return nil
+		}
+		if !isMethodNotFound(err) {
+			return err
+		}
+		log.Println("mempool: txpool_content is not available, falling back to a pending transaction filter")
+		w.txpool = false
+	}
+	return w.pollFilter(ctx)
+}
+
+func (w *Watcher) pollTxPool(ctx context.Context) error {
+	var content map[string]map[string]map[string]entity.Transaction
+	if err := w.caller.Call(ctx, methodTxPoolContent, nil, &content); err != nil {
+		return err
+	}
+
+	// Only executable transactions are reported, queued ones wait for a nonce gap.
+	for _, byNonce := range content["pending"] {
+		for _, tx := range byNonce {
+			if err := w.observe(tx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) pollFilter(ctx context.Context) error {
+	if w.filterID == "" {
+		if err := w.caller.Call(ctx, methodNewPendingFilter, nil, &w.filterID); err != nil {
+			return fmt.Errorf("failed to create pending transaction filter: %w", err)
+		}
+	}
+
+	var hashes []string
+	if err := w.caller.Call(ctx, methodFilterChanges, []any{w.filterID}, &hashes); err != nil {
+		// Filters expire when they are not polled for a while, so start over.
+		w.filterID = ""
+		return fmt.Errorf("failed to get filter changes: %w", err)
+	}
+
+	for _, hash := range hashes {
+		if _, ok := w.store.GetPending(hash); ok {
+			continue
+		}
+
+		var tx *entity.Transaction
+		if err := w.caller.Call(ctx, methodTxByHash, []any{hash}, &tx); err != nil {
+			return fmt.Errorf("failed to get transaction by hash: %w", err)
+		}
+		if tx == nil {
+			continue
+		}
+		if err := w.observe(*tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) observe(tx entity.Transaction) error {
+	if err := tx.Normalize(); err != nil {
+		log.Println(fmt.Errorf("skipping pending transaction: %w", err))
+		return nil
+	}
+
+	subscribed := false
+	for _, party := range parties(tx) {
+		if w.subscriptions.IsSubscribed(party) {
+			subscribed = true
+			break
+		}
+	}
+	if !subscribed {
+		return nil
+	}
+	// Pending transactions are in no block, so the chain plays no part.
+	if err := txcodec.Check(&tx, 0); err != nil {
+		log.Println(fmt.Errorf("failed to verify pending transaction %s: %w", tx.Hash, err))
+	}
+	if _, ok := w.store.GetPending(tx.Hash); ok {
+		return nil
+	}
+
+	now := w.now()
+	return w.store.StorePending(entity.PendingTransaction{
+		Transaction: tx,
+		State:       entity.PendingStatePending,
+		FirstSeen:   now,
+		UpdatedAt:   now,
+	})
+}
+
+// HandleBlock marks pending transactions mined in block as included and
+// the ones that can no longer be mined as dropped.
+func (w *Watcher) HandleBlock(ctx context.Context, block *entity.Block) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	included := make(map[string]bool, len(block.Transactions))
+	for _, hash := range block.Transactions {
+		included[strings.ToLower(hash)] = true
+	}
+
+	// Pending transactions of a sender share its transaction count.
+	counts := make(map[string]int64)
+
+	now := w.now()
+	for _, tx := range w.store.ListPending() {
+		if tx.State != entity.PendingStatePending {
+			if now.Sub(tx.UpdatedAt) > w.cfg.Retention {
+				if err := w.store.DeletePending(tx.Hash); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if included[strings.ToLower(tx.Hash)] {
+			if err := w.include(tx, block.Number); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := w.checkDropped(ctx, tx, counts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDropped drops a transaction once its sender used its nonce for
+// another transaction, or once the node forgot it after DropAfter. counts
+// caches the transaction count of senders for the block being handled.
+func (w *Watcher) checkDropped(ctx context.Context, tx entity.PendingTransaction, counts map[string]int64) error {
+	nonce, err := utils.HexToInt(tx.Nonce)
+	if err != nil {
+		nonce = -1
+	}
+
+	replaced := false
+	if nonce >= 0 {
+		sender := strings.ToLower(tx.From)
+		count, ok := counts[sender]
+		if !ok {
+			var countHex string
+			if err := w.caller.Call(ctx, methodTxCount, []any{tx.From, "latest"}, &countHex); err != nil {
+				return fmt.Errorf("failed to get transaction count: %w", err)
+			}
+			if count, err = utils.HexToInt(countHex); err != nil {
+				return fmt.Errorf("failed to parse transaction count: %w", err)
+			}
+			counts[sender] = count
+		}
+		replaced = count > nonce
+	}
+
+	expired := w.now().Sub(tx.FirstSeen) > w.cfg.DropAfter
+	if !replaced && !expired {
+		return nil
+	}
+
+	// The nonce may also have been used by this very transaction in a block
+	// the scanner did not report to us, so ask the node before dropping it.
+	var current *struct {
+		BlockNumber *string `json:"blockNumber"`
+	}
+	if err := w.caller.Call(ctx, methodTxByHash, []any{tx.Hash}, &current); err != nil {
+		return fmt.Errorf("failed to get transaction by hash: %w", err)
+	}
+	if current != nil && current.BlockNumber != nil {
+		number, err := utils.HexToInt(*current.BlockNumber)
+		if err != nil {
+			return fmt.Errorf("failed to parse block number: %w", err)
+		}
+		return w.include(tx, uint64(number))
+	}
+	if current != nil && !replaced {
+		// Still known to the node, keep waiting.
+		return nil
+	}
+
+	tx.State = entity.PendingStateDropped
+	tx.UpdatedAt = w.now()
+	return w.store.StorePending(tx)
+}
+
+func (w *Watcher) include(tx entity.PendingTransaction, block uint64) error {
+	tx.State = entity.PendingStateIncluded
+	tx.IncludedIn = &block
+	tx.UpdatedAt = w.now()
+	return w.store.StorePending(tx)
+}
+
+// PendingFor lists the mempool transactions sent from or to address, or
+// moving its tokens.
+func (w *Watcher) PendingFor(address string) []entity.PendingTransaction {
+	result := []entity.PendingTransaction{}
+	for _, tx := range w.store.ListPending() {
+		for _, party := range parties(tx.Transaction) {
+			if strings.EqualFold(party, address) {
+				result = append(result, tx)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// parties returns the sender and recipient of tx and, when it calls ERC-20
+// transfer or transferFrom, the holders the tokens move between.
+func parties(tx entity.Transaction) []string {
+	parties := []string{tx.From}
+	if tx.To != nil {
+		parties = append(parties, *tx.To)
+	}
+
+	input := strings.ToLower(tx.Input)
+	holders := 0
+	switch {
+	case strings.HasPrefix(input, transferSelector):
+		holders = 1
+	case strings.HasPrefix(input, transferFromSelector):
+		holders = 2
+	}
+	args := input[min(len(input), len(transferSelector)):]
+	if len(args) < (holders+1)*64 {
+		return parties
+	}
+	for i := 0; i < holders; i++ {
+		parties = append(parties, utils.TopicToAddress(args[i*64:(i+1)*64]))
+	}
+	return parties
+}
+
+func isMethodNotFound(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, codeMethodNotFound) || strings.Contains(msg, "not found") ||
+		strings.Contains(msg, "does not exist") || strings.Contains(msg, "not available") ||
+		strings.Contains(msg, "not supported")
+}
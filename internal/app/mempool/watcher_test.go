@@ -0,0 +1,190 @@
+package mempool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/domain/entity"
+	"testing"
+	"time"
+)
+
+const (
+	watched = "0x1111111111111111111111111111111111111111"
+	sender  = "0x2222222222222222222222222222222222222222"
+)
+
+// mockCaller answers each method with a canned result or error.
+type mockCaller struct {
+	results map[string]any
+	errors  map[string]error
+	calls   map[string]int
+}
+
+func (m *mockCaller) Call(ctx context.Context, method string, params []any, result any) error {
+	if m.calls == nil {
+		m.calls = make(map[string]int)
+	}
+	m.calls[method]++
+
+	if err := m.errors[method]; err != nil {
+		return err
+	}
+	data, err := json.Marshal(m.results[method])
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+type mockSubscriptions map[string]bool
+
+func (m mockSubscriptions) IsSubscribed(address string) bool {
+	return m[address]
+}
+
+func pendingJSON(hash, from, to, nonce string) map[string]any {
+	return map[string]any{"hash": hash, "from": from, "to": to, "nonce": nonce, "value": "0x1"}
+}
+
+func TestWatcherFallsBackToFilter(t *testing.T) {
+	caller := &mockCaller{
+		results: map[string]any{
+			methodNewPendingFilter: "0xf1",
+			methodFilterChanges:    []string{"0xaa", "0xbb"},
+			methodTxByHash:         pendingJSON("0xaa", sender, watched, "0x5"),
+		},
+		errors: map[string]error{
+			methodTxPoolContent: errors.New("RPC error -32601: the method txpool_content does not exist/is not available"),
+		},
+	}
+	store := repo.NewMemoryPendingStore()
+	w := NewWatcher(caller, mockSubscriptions{watched: true}, store, DefaultConfig())
+
+	if err := w.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	if err := w.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+
+	if caller.calls[methodTxPoolContent] != 1 {
+		t.Errorf("txpool_content called %d times, want 1", caller.calls[methodTxPoolContent])
+	}
+	if caller.calls[methodNewPendingFilter] != 1 {
+		t.Errorf("filter created %d times, want 1", caller.calls[methodNewPendingFilter])
+	}
+
+	pending := w.PendingFor(watched)
+	if len(pending) != 1 || pending[0].State != entity.PendingStatePending || pending[0].Hash != "0xaa" {
+		t.Fatalf("PendingFor() = %+v", pending)
+	}
+}
+
+func TestWatcherStateTransitions(t *testing.T) {
+	caller := &mockCaller{
+		results: map[string]any{
+			methodTxPoolContent: map[string]any{
+				"pending": map[string]any{
+					sender: map[string]any{
+						"5": pendingJSON("0xaa", sender, watched, "0x5"),
+						"6": pendingJSON("0xbb", sender, watched, "0x6"),
+						"7": pendingJSON("0xcc", sender, watched, "0x7"),
+					},
+					"0x3333333333333333333333333333333333333333": map[string]any{
+						"0": pendingJSON("0xdd", "0x3333333333333333333333333333333333333333", sender, "0x0"),
+					},
+				},
+			},
+			// Nonces 5 and 6 are used, 0xbb was replaced by another transaction.
+			methodTxCount:  "0x7",
+			methodTxByHash: nil,
+		},
+	}
+	store := repo.NewMemoryPendingStore()
+	w := NewWatcher(caller, mockSubscriptions{watched: true}, store, DefaultConfig())
+
+	if err := w.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	if got := len(w.PendingFor(watched)); got != 3 {
+		t.Fatalf("PendingFor() returned %d transactions, want 3", got)
+	}
+
+	if err := w.HandleBlock(context.Background(), &entity.Block{Number: 10, Transactions: []string{"0xAA"}}); err != nil {
+		t.Fatalf("HandleBlock() error = %v", err)
+	}
+	// 0xbb and 0xcc share a sender, whose transaction count is asked once.
+	if got := caller.calls[methodTxCount]; got != 1 {
+		t.Errorf("eth_getTransactionCount called %d times, want 1", got)
+	}
+
+	want := map[string]entity.PendingState{
+		"0xaa": entity.PendingStateIncluded,
+		"0xbb": entity.PendingStateDropped,
+		"0xcc": entity.PendingStatePending,
+	}
+	for hash, state := range want {
+		tx, ok := store.GetPending(hash)
+		if !ok || tx.State != state {
+			t.Errorf("transaction %s has state %q, want %q", hash, tx.State, state)
+		}
+	}
+	if tx, _ := store.GetPending("0xaa"); tx.IncludedIn == nil || *tx.IncludedIn != 10 {
+		t.Errorf("expected 0xaa to be included in block 10")
+	}
+
+	// Finished transactions are forgotten after the retention period.
+	w.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	caller.results[methodTxCount] = "0x0"
+	if err := w.HandleBlock(context.Background(), &entity.Block{Number: 11}); err != nil {
+		t.Fatalf("HandleBlock() error = %v", err)
+	}
+	if _, ok := store.GetPending("0xaa"); ok {
+		t.Error("expected included transaction to be pruned")
+	}
+}
+
+func TestWatcherMatchesTokenTransferCalls(t *testing.T) {
+	const (
+		token = "0x4444444444444444444444444444444444444444"
+		other = "0x3333333333333333333333333333333333333333"
+		// word pads an address to a 32-byte argument.
+		word   = "000000000000000000000000"
+		amount = "00000000000000000000000000000000000000000000000000000000000003e8"
+	)
+
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{name: "transfer to watched", input: transferSelector + word + watched[2:] + amount, want: true},
+		{name: "transferFrom watched", input: transferFromSelector + word + watched[2:] + word + other[2:] + amount, want: true},
+		{name: "transferFrom to watched", input: transferFromSelector + word + other[2:] + word + watched[2:] + amount, want: true},
+		{name: "transfer to other", input: transferSelector + word + other[2:] + amount},
+		{name: "truncated transfer", input: transferSelector + word + watched[2:]},
+		{name: "other function", input: "0x095ea7b3" + word + watched[2:] + amount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := pendingJSON("0xaa", sender, token, "0x5")
+			tx["input"] = tt.input
+			caller := &mockCaller{
+				results: map[string]any{
+					methodTxPoolContent: map[string]any{"pending": map[string]any{sender: map[string]any{"5": tx}}},
+				},
+			}
+			w := NewWatcher(caller, mockSubscriptions{watched: true}, repo.NewMemoryPendingStore(), DefaultConfig())
+
+			if err := w.Poll(context.Background()); err != nil {
+				t.Fatalf("Poll() error = %v", err)
+			}
+			if got := len(w.PendingFor(watched)) == 1; got != tt.want {
+				t.Errorf("watched transaction = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
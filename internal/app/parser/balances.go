@@ -0,0 +1,145 @@
+package parser
+
+import (
+	"context"
+	"eth_parser/internal/app/balance"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/utils"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	// balanceOfSelector is the 4-byte selector of balanceOf(address).
+	balanceOfSelector = "0x70a08231"
+	// decimalsSelector is the 4-byte selector of decimals().
+	decimalsSelector = "0x313ce567"
+)
+
+// GetBalances returns the tracked balances of address, or nil when balances are not tracked.
+func (ep *EthereumParser) GetBalances(address string) []entity.Balance {
+	if ep.balances == nil {
+		return nil
+	}
+	return ep.balances.GetBalances(address)
+}
+
+// GetTransfers returns the transfers booked for address, or nil when balances are not tracked.
+func (ep *EthereumParser) GetTransfers(address string) []entity.Transfer {
+	if ep.balances == nil {
+		return nil
+	}
+	return ep.balances.GetTransfers(address)
+}
+
+// Reconcile compares the tracked balances of address with the chain at block,
+// which is a block tag such as "latest" or a hex block number. Both sides are
+// taken at the same height: "latest" is the last block the history of
+// address is imported up to, and later blocks fail with
+// balance.ErrNotScanned.
+func (ep *EthereumParser) Reconcile(ctx context.Context, address, block string) (entity.Reconciliation, error) {
+	if !ep.tracksBalances() {
+		return entity.Reconciliation{}, fmt.Errorf("balance tracking is disabled")
+	}
+
+	next, ok := ep.checkpoints.GetCheckpoint(checkpointKey(address))
+	if !ok || next == 0 {
+		return entity.Reconciliation{}, fmt.Errorf("history of %s: %w", address, balance.ErrNotScanned)
+	}
+	height := next - 1
+
+	if block != "latest" {
+		b, err := ep.BlockByTag(ctx, block)
+		if err != nil {
+			return entity.Reconciliation{}, err
+		}
+		if b == nil || b.Number > height {
+			return entity.Reconciliation{}, fmt.Errorf("block %s of %s: %w", block, address, balance.ErrNotScanned)
+		}
+		height = b.Number
+	}
+	return ep.balances.Reconcile(ctx, ep, address, height)
+}
+
+// tracksBalances tells whether balances are tracked in full, which needs
+// the plain transfers as well.
+func (ep *EthereumParser) tracksBalances() bool {
+	return ep.balances != nil && ep.plainTransfers
+}
+
+// open records the balances address holds before block, where its history
+// starts, when it was not opened at block or earlier yet.
+func (ep *EthereumParser) open(ctx context.Context, address string, block uint64) error {
+	if !ep.tracksBalances() {
+		return nil
+	}
+	return ep.balances.Open(ctx, ep, address, block)
+}
+
+// Backfilled moves the opening balances of address back to from once the
+// history of [from, to] is imported, provided it reaches the block address
+// is tracked from, so no transfers in between are missing. It implements
+// backfill.RangeScanner.
+func (ep *EthereumParser) Backfilled(ctx context.Context, address string, from, to uint64) error {
+	if !ep.tracksBalances() {
+		return nil
+	}
+	opening, ok := ep.balances.Opening(address, entity.NativeToken)
+	if !ok || to+1 < opening.Block {
+		return nil
+	}
+	return ep.open(ctx, address, from)
+}
+
+// BalanceAt returns the ether balance of address at block.
+func (ep *EthereumParser) BalanceAt(ctx context.Context, address, block string) (*big.Int, error) {
+	chainID, err := ep.getChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+
+	var balance string
+	if err := ep.call(ctx, chainID, methodBalance, []any{address, block}, &balance); err != nil {
+		return nil, err
+	}
+	return utils.HexToBig(balance)
+}
+
+// TokenBalanceAt calls balanceOf(address) on an ERC-20 token at block.
+func (ep *EthereumParser) TokenBalanceAt(ctx context.Context, token, address, block string) (*big.Int, error) {
+	chainID, err := ep.getChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+
+	call := map[string]any{
+		"to":   token,
+		"data": balanceOfSelector + strings.TrimPrefix(utils.AddressToHex(address), "0x"),
+	}
+
+	var balance string
+	if err := ep.call(ctx, chainID, methodCall, []any{call, block}, &balance); err != nil {
+		return nil, err
+	}
+	return utils.HexToBig(balance)
+}
+
+// TokenDecimals calls decimals() on an ERC-20 token.
+func (ep *EthereumParser) TokenDecimals(ctx context.Context, token string) (uint8, error) {
+	chainID, err := ep.getChainID(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+
+	var result string
+	call := map[string]any{"to": token, "data": decimalsSelector}
+	if err := ep.call(ctx, chainID, methodCall, []any{call, "latest"}, &result); err != nil {
+		return 0, err
+	}
+	decimals, err := utils.HexToBig(result)
+	if err != nil || !decimals.IsUint64() || decimals.Uint64() > 255 {
+		return 0, fmt.Errorf("invalid decimals %q of token %s", result, token)
+	}
+	return uint8(decimals.Uint64()), nil
+}
@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"context"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/utils"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	methodBlockByNum  = "eth_getBlockByNumber"
+	methodBlockByHash = "eth_getBlockByHash"
+
+	// maxCachedHeaders bounds the header cache of BlockByHash.
+	maxCachedHeaders = 4096
+)
+
+type rpcBlock struct {
+	Number       string   `json:"number"`
+	Hash         string   `json:"hash"`
+	ParentHash   string   `json:"parentHash"`
+	Timestamp    string   `json:"timestamp"`
+	LogsBloom    string   `json:"logsBloom"`
+	Transactions []string `json:"transactions"`
+}
+
+// BlockByNumber returns the header and transaction hashes of a block, or
+// nil when the node does not have it yet.
+func (ep *EthereumParser) BlockByNumber(ctx context.Context, number uint64) (*entity.Block, error) {
+	return ep.BlockByTag(ctx, utils.IntToHex(number))
+}
+
+// BlockByTag returns the block a tag such as "safe" or "finalized", or a hex
+// number, points to. It returns nil when the node does not have the block.
+func (ep *EthereumParser) BlockByTag(ctx context.Context, tag string) (*entity.Block, error) {
+	var raw *rpcBlock
+	if err := ep.Call(ctx, methodBlockByNum, []any{tag, false}, &raw); err != nil {
+		return nil, fmt.Errorf("failed to get block %s: %w", tag, err)
+	}
+	if raw == nil {
+		return nil, nil
+	}
+	return raw.block()
+}
+
+// BlockByHash returns the header and transaction hashes of the block with
+// hash, or nil when the node does not know it. A hash always names the same
+// block, so answers are cached.
+func (ep *EthereumParser) BlockByHash(ctx context.Context, hash string) (*entity.Block, error) {
+	if block, ok := ep.headers.get(hash); ok {
+		return block, nil
+	}
+
+	var raw *rpcBlock
+	if err := ep.Call(ctx, methodBlockByHash, []any{hash, false}, &raw); err != nil {
+		return nil, fmt.Errorf("failed to get block %s: %w", hash, err)
+	}
+	if raw == nil {
+		return nil, nil
+	}
+	block, err := raw.block()
+	if err != nil {
+		return nil, err
+	}
+	ep.headers.add(block)
+	return block, nil
+}
+
+func (raw *rpcBlock) block() (*entity.Block, error) {
+	number, err := utils.HexToInt(raw.Number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse block number: %w", err)
+	}
+	timestamp, err := utils.HexToInt(raw.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse block timestamp: %w", err)
+	}
+
+	return &entity.Block{
+		Number:       uint64(number),
+		Hash:         raw.Hash,
+		ParentHash:   raw.ParentHash,
+		Timestamp:    uint64(timestamp),
+		LogsBloom:    raw.LogsBloom,
+		Transactions: raw.Transactions,
+	}, nil
+}
+
+// headerCache keeps the most recently added block headers by hash.
+type headerCache struct {
+	mutex  sync.Mutex
+	blocks map[string]*entity.Block
+	// order holds the cached hashes, oldest first.
+	order []string
+}
+
+func newHeaderCache() *headerCache {
+	return &headerCache{blocks: make(map[string]*entity.Block)}
+}
+
+func (c *headerCache) get(hash string) (*entity.Block, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	block, ok := c.blocks[strings.ToLower(hash)]
+	return block, ok
+}
+
+func (c *headerCache) add(block *entity.Block) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	hash := strings.ToLower(block.Hash)
+	if _, ok := c.blocks[hash]; ok {
+		return
+	}
+	if len(c.order) >= maxCachedHeaders {
+		delete(c.blocks, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.blocks[hash] = block
+	c.order = append(c.order, hash)
+}
+
+// dateTransaction sets the block timestamp of a mined transaction, and its
+// block number when the node left it out.
+func (ep *EthereumParser) dateTransaction(ctx context.Context, tx *entity.Transaction) error {
+	if tx.BlockHash == nil || tx.BlockTimestamp != nil {
+		return nil
+	}
+	block, err := ep.BlockByHash(ctx, *tx.BlockHash)
+	if err != nil {
+		return err
+	}
+	if block == nil {
+		return nil
+	}
+
+	timestamp := utils.IntToHex(block.Timestamp)
+	tx.BlockTimestamp = &timestamp
+	if tx.BlockNumber == nil {
+		number := utils.IntToHex(block.Number)
+		tx.BlockNumber = &number
+	}
+	return nil
+}
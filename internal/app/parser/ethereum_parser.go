@@ -1,19 +1,27 @@
 package parser
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"eth_parser/internal/app/backfill"
+	"eth_parser/internal/app/balance"
+	"eth_parser/internal/app/bloom"
+	"eth_parser/internal/app/jsonrpc"
+	"eth_parser/internal/app/logfilter"
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/app/trace"
+	"eth_parser/internal/app/txcodec"
 	"eth_parser/internal/domain/entity"
 	httpclient "eth_parser/internal/domain/http_client"
 	"eth_parser/internal/domain/parser"
 	"eth_parser/internal/domain/repository"
+	"eth_parser/internal/domain/rpc"
 	"eth_parser/internal/utils"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
+	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -24,6 +32,12 @@ const (
 	methodChainID  = "eth_chainId"
 	methodLogs     = "eth_getLogs"
 	methodTxByHash = "eth_getTransactionByHash"
+	methodReceipt  = "eth_getTransactionReceipt"
+	methodBalance  = "eth_getBalance"
+	methodCall     = "eth_call"
+
+	// currentBlockTimeout bounds GetCurrentBlock, which holds the mutex.
+	currentBlockTimeout = 10 * time.Second
 )
 
 type rpcRequest struct {
@@ -45,37 +59,128 @@ type rpcError struct {
 	Message string `json:"message"`
 }
 
-type logEntry struct {
-	Address         string `json:"address"`
-	BlockHash       string `json:"blockHash"`
-	BlockNumber     string `json:"blockNumber"`
-	TransactionHash string `json:"transactionHash"`
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("RPC error %d: %s", e.Code, e.Message)
 }
 
-type logsResponse struct {
-	Logs []logEntry `json:"result"`
+// transferParties returns the from and to topics of a Transfer log.
+func transferParties(l entity.Log) (from, to string) {
+	if len(l.Topics) > 1 {
+		from = strings.ToLower(l.Topics[1])
+	}
+	if len(l.Topics) > 2 {
+		to = strings.ToLower(l.Topics[2])
+	}
+	return from, to
 }
 
 type EthereumParser struct {
-	mutex  sync.RWMutex
-	client httpclient.HTTPClient
-	repo   repository.SubscriptionRepo
+	mutex        sync.RWMutex
+	transport    rpc.Transport
+	repo         repository.SubscriptionRepo
+	transactions repository.TransactionStore
+	checkpoints  repository.CheckpointRepo
+	backfillCfg  backfill.Config
+	backfill     *backfill.Engine
+	balances     *balance.Tracker
+	// plainTransfers reads blocks with their transactions to book plain
+	// ether transfers, which leave no logs.
+	plainTransfers bool
+	tracer         *trace.Tracer
+	headers        *headerCache
+	bloomStats     bloom.Stats
+
+	// scanLocks serialise the scans of each address, wake makes Run catch
+	// up right away.
+	scanMutex sync.Mutex
+	scanLocks map[string]scanLock
+	wake      chan struct{}
 }
 
 var _ parser.Parser = (*EthereumParser)(nil)
 
-func NewEthereumParser(client httpclient.HTTPClient, repo repository.SubscriptionRepo) *EthereumParser {
-	return &EthereumParser{
-		client: client,
-		repo:   repo,
+type Option func(*EthereumParser)
+
+// WithTransport sends requests over transport instead of HTTP, e.g. a
+// WebSocket connection.
+func WithTransport(transport rpc.Transport) Option {
+	return func(ep *EthereumParser) {
+		ep.transport = transport
+	}
+}
+
+// WithTransactionStore replaces the in-memory store for found transactions.
+func WithTransactionStore(store repository.TransactionStore) Option {
+	return func(ep *EthereumParser) {
+		ep.transactions = store
+	}
+}
+
+// WithCheckpointRepo replaces the in-memory store for backfill progress.
+func WithCheckpointRepo(checkpoints repository.CheckpointRepo) Option {
+	return func(ep *EthereumParser) {
+		ep.checkpoints = checkpoints
 	}
 }
 
+// WithBackfillConfig tunes chunking and concurrency of historical scans.
+func WithBackfillConfig(cfg backfill.Config) Option {
+	return func(ep *EthereumParser) {
+		ep.backfillCfg = cfg
+	}
+}
+
+// WithBalanceTracker books the native, token and fee transfers of the
+// transactions found through their logs, at one receipt lookup per
+// transaction. Balances only add up with WithPlainTransfers as well.
+func WithBalanceTracker(tracker *balance.Tracker) Option {
+	return func(ep *EthereumParser) {
+		ep.balances = tracker
+	}
+}
+
+// WithPlainTransfers also books the ether sent by transactions without
+// logs, so tracked balances add up and can be reconciled. It costs a full
+// block read per new head and per block of a range asked for by a backfill
+// job or ScanRange, so it is left off unless balances are needed.
+func WithPlainTransfers() Option {
+	return func(ep *EthereumParser) {
+		ep.plainTransfers = true
+	}
+}
+
+// WithTracer also imports ether moved to or from addresses by internal
+// calls. Every scanned block is traced, which needs a node with trace APIs.
+func WithTracer(mode trace.Mode) Option {
+	return func(ep *EthereumParser) {
+		ep.tracer = trace.NewTracer(ep, mode)
+	}
+}
+
+func NewEthereumParser(client httpclient.HTTPClient, subscriptions repository.SubscriptionRepo, opts ...Option) *EthereumParser {
+	ep := &EthereumParser{
+		transport:    jsonrpc.NewHTTPTransport(client, rpcURL),
+		repo:         subscriptions,
+		transactions: repo.NewMemoryTransactionStore(),
+		checkpoints:  repo.NewMemoryCheckpointRepo(),
+		backfillCfg:  backfill.DefaultConfig(),
+		headers:      newHeaderCache(),
+		scanLocks:    make(map[string]scanLock),
+		wake:         make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(ep)
+	}
+	ep.backfill = backfill.NewEngine(ep.checkpoints, ep.backfillCfg)
+	return ep
+}
+
 func (ep *EthereumParser) GetCurrentBlock() int {
 	ep.mutex.Lock()
 	defer ep.mutex.Unlock()
 
-	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(context.Background(), currentBlockTimeout)
+	defer cancel()
 
 	chainID, err := ep.getChainID(ctx)
 	if err != nil {
@@ -114,81 +219,350 @@ func (ep *EthereumParser) GetCurrentBlock() int {
 	return int(blockNum)
 }
 
+// Subscribe stores address and wakes Run to follow it from the head.
 func (ep *EthereumParser) Subscribe(address string) bool {
 	if !ep.repo.IsSubscribed(address) {
 		ep.repo.StoreSubscription(address)
+		select {
+		case ep.wake <- struct{}{}:
+		default:
+		}
 		return true
 	}
 
 	return true
 }
 
+// GetTransactions returns the stored transactions of address. Its history
+// is imported in the background by Run and new blocks by HandleBlock, so
+// nothing is scanned here.
 func (ep *EthereumParser) GetTransactions(address string) []entity.Transaction {
 	if !ep.repo.IsSubscribed(address) {
 		log.Println(fmt.Errorf("address %s is not subscribed", address))
 		return []entity.Transaction{}
 	}
 
-	ep.mutex.Lock()
-	defer ep.mutex.Unlock()
-
+	// Transactions stored before timestamps were recorded are dated on the
+	// way out.
 	ctx := context.Background()
+	txs := ep.transactions.GetTransactions(address)
+	for i := range txs {
+		if err := ep.dateTransaction(ctx, &txs[i]); err != nil {
+			log.Println(fmt.Errorf("failed to date transaction %s: %w", txs[i].Hash, err))
+		}
+	}
+	return txs
+}
+
+// Run keeps subscribed addresses up with the head in the background until
+// ctx is cancelled: new subscriptions right away, addresses that fell
+// behind the head every interval.
+func (ep *EthereumParser) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ep.CatchUp(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-ep.wake:
+		}
+	}
+}
+
+// CatchUp scans every subscribed address from its checkpoint to the head.
+// Addresses never scanned start at the head, with the balances they hold
+// there; their older history is only imported when asked for, by a
+// backfill job. Addresses another scan is busy with are left for the next
+// call.
+func (ep *EthereumParser) CatchUp(ctx context.Context) {
+	chainID, latest, err := ep.head(ctx)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to catch up with the head: %w", err))
+		return
+	}
+
+	for _, address := range ep.repo.Subscriptions() {
+		if ctx.Err() != nil {
+			return
+		}
+		from := latest
+		if next, ok := ep.checkpoints.GetCheckpoint(checkpointKey(address)); ok {
+			if next > latest {
+				continue
+			}
+			from = next
+		}
+
+		lock := ep.scanLock(address)
+		if !lock.TryLock() {
+			continue
+		}
+		err := ep.open(ctx, address, from)
+		if err == nil {
+			err = ep.backfillAddress(ctx, chainID, address, from, latest)
+		}
+		lock.Unlock()
+		if err != nil && ctx.Err() == nil {
+			log.Println(fmt.Errorf("failed to scan transactions for %s: %w", address, err))
+		}
+	}
+}
+
+func (ep *EthereumParser) backfillAddress(ctx context.Context, chainID int64, address string, fromBlock, latest uint64) error {
+	_, err := ep.backfill.Run(ctx, checkpointKey(address), fromBlock, latest, func(ctx context.Context, from, to uint64) (int, error) {
+		return ep.scanRange(ctx, chainID, []string{address}, from, to)
+	})
+	return err
+}
 
+// head returns the chain ID and the latest block.
+func (ep *EthereumParser) head(ctx context.Context) (int64, uint64, error) {
 	chainID, err := ep.getChainID(ctx)
 	if err != nil {
-		log.Println(fmt.Errorf("failed to get chain ID: %w", err))
-		return []entity.Transaction{}
+		return 0, 0, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+	latest, err := ep.blockNumber(ctx, chainID)
+	if err != nil {
+		return 0, 0, err
+	}
+	return chainID, latest, nil
+}
+
+// scanLock returns the lock serialising the scans of address.
+func (ep *EthereumParser) scanLock(address string) scanLock {
+	ep.scanMutex.Lock()
+	defer ep.scanMutex.Unlock()
+
+	key := strings.ToLower(address)
+	lock, ok := ep.scanLocks[key]
+	if !ok {
+		lock = make(scanLock, 1)
+		ep.scanLocks[key] = lock
+	}
+	return lock
+}
+
+// LockAddress waits until no other scan is busy with address and keeps
+// the others off it until unlock is called, or fails when ctx is done
+// first. It implements backfill.RangeScanner, so jobs and the scans of
+// Run and HandleBlock do not store and book the same address at once.
+func (ep *EthereumParser) LockAddress(ctx context.Context, address string) (func(), error) {
+	lock := ep.scanLock(address)
+	if err := lock.lock(ctx); err != nil {
+		return nil, err
+	}
+	return lock.Unlock, nil
+}
+
+// scanLock is a mutex whose waiters can give up.
+type scanLock chan struct{}
+
+func (l scanLock) lock(ctx context.Context) error {
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l scanLock) TryLock() bool {
+	select {
+	case l <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l scanLock) Unlock() {
+	<-l
+}
+
+// BlockNumber returns the latest block known to the node.
+func (ep *EthereumParser) BlockNumber(ctx context.Context) (uint64, error) {
+	chainID, err := ep.getChainID(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+	return ep.blockNumber(ctx, chainID)
+}
+
+// ScanRange stores the transactions of addresses found in [from, to] and
+// returns how many matching logs the range contained.
+func (ep *EthereumParser) ScanRange(ctx context.Context, addresses []string, from, to uint64) (int, error) {
+	chainID, err := ep.getChainID(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get chain ID: %w", err)
+	}
+	return ep.scanRange(ctx, chainID, addresses, from, to)
+}
+
+func (ep *EthereumParser) blockNumber(ctx context.Context, chainID int64) (uint64, error) {
+	var latestHex string
+	if err := ep.call(ctx, chainID, methodBlockNum, nil, &latestHex); err != nil {
+		return 0, fmt.Errorf("failed to get block number: %w", err)
+	}
+	latest, err := utils.HexToInt(latestHex)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse block number: %w", err)
+	}
+	return uint64(latest), nil
+}
+
+// scanRange looks for transfers from or to addresses in [from, to], stores
+// the transactions that made them and returns how many logs, transactions
+// and internal transfers matched.
+func (ep *EthereumParser) scanRange(ctx context.Context, chainID int64, addresses []string, from, to uint64) (int, error) {
+	scan := newTransferScan(ep, chainID)
+
+	// Blocks come first, so the transactions behind matching logs are
+	// usually known already.
+	found, err := scan.recordBlocks(ctx, addresses, from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	logs, err := scan.recordLogs(ctx, addresses, from, to)
+	if err != nil {
+		return 0, err
+	}
+	found += logs
+
+	internal, err := scan.recordTraces(ctx, addresses, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return found + internal, nil
+}
+
+// recordLogs looks for ERC-20 transfers from or to addresses in [from, to],
+// stores the transactions that emitted them and returns how many logs matched.
+func (s *transferScan) recordLogs(ctx context.Context, addresses []string, from, to uint64) (int, error) {
+	topics := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		topics = append(topics, utils.AddressToHex(address))
 	}
 
-	params := []any{
-		map[string]any{
-			"fromBlock": "0x0",
-			"toBlock":   "latest",
-			"topics": []any{
-				erc20Transfer, []string{utils.AddressToHex(address), utils.AddressToHex(address)},
-			},
-		},
+	logs, err := logfilter.Fetch(ctx, s.parser, transferFilters(topics), from, to)
+	if err != nil {
+		return 0, err
 	}
 
-	logs, err := ep.fetchLogs(ctx, chainID, params)
+	for _, entry := range logs {
+		fromTopic, toTopic := transferParties(entry)
+		for i, address := range addresses {
+			if topics[i] != fromTopic && topics[i] != toTopic {
+				continue
+			}
+			if err := s.record(ctx, address, entry); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(logs), nil
+}
 
-	var transactions []entity.Transaction
-	for _, logEntry := range logs {
-		tx, err := ep.getTransaction(ctx, chainID, logEntry.TransactionHash)
+// recordTraces imports the ether moved to or from addresses by internal
+// calls in [from, to] when tracing, and returns how many transfers matched.
+func (s *transferScan) recordTraces(ctx context.Context, addresses []string, from, to uint64) (int, error) {
+	if s.parser.tracer == nil {
+		return 0, nil
+	}
+
+	found := 0
+	for block := from; block <= to; block++ {
+		transfers, err := s.parser.tracer.InternalTransfers(ctx, block, addresses)
 		if err != nil {
-			log.Println(fmt.Errorf("failed to get transaction by hash: %w", err))
-			continue
+			return 0, err
+		}
+		for _, transfer := range transfers {
+			for _, address := range addresses {
+				if !strings.EqualFold(transfer.From, address) && !strings.EqualFold(transfer.To, address) {
+					continue
+				}
+				if err := s.recordInternal(ctx, address, transfer); err != nil {
+					return 0, err
+				}
+			}
 		}
-		transactions = append(transactions, *tx)
+		found += len(transfers)
 	}
+	return found, nil
+}
 
-	return transactions
+// transferFilters select the Transfer logs from or to one of topics.
+// Transfer(address indexed from, address indexed to, uint256 value) is
+// filtered once per side, as topic positions are ANDed by eth_getLogs.
+func transferFilters(topics []string) []logfilter.Filter {
+	return []logfilter.Filter{
+		{Topics: [][]string{{erc20Transfer}, topics}},
+		{Topics: [][]string{{erc20Transfer}, nil, topics}},
+	}
 }
 
+func checkpointKey(address string) string {
+	return "address:" + strings.ToLower(address)
+}
+
+// getTransaction returns nil when the node does not know the transaction.
 func (ep *EthereumParser) getTransaction(ctx context.Context, chainID int64, hash string) (*entity.Transaction, error) {
-	transactionRaw, err := ep.sendRPCRequest(ctx, methodTxByHash, chainID, []any{hash})
-	if err != nil {
+	var tx *entity.Transaction
+	if err := ep.call(ctx, chainID, methodTxByHash, []any{hash}, &tx); err != nil {
 		return nil, err
 	}
+	if tx != nil {
+		if err := verifyTransaction(tx, chainID); err != nil {
+			return nil, err
+		}
+	}
+	return tx, nil
+}
 
-	var tx entity.Transaction
-	if err := json.Unmarshal([]byte(transactionRaw), &tx); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal transaction: %w", err)
+// verifyTransaction normalizes tx as returned by the node of chain chainID
+// and flags it when its hash or signature do not match its fields.
+func verifyTransaction(tx *entity.Transaction, chainID int64) error {
+	if err := tx.Normalize(); err != nil {
+		return err
+	}
+	if err := txcodec.Check(tx, chainID); err != nil {
+		log.Println(fmt.Errorf("failed to verify transaction %s: %w", tx.Hash, err))
 	}
-	return &tx, nil
+	return nil
 }
 
-func (ep *EthereumParser) fetchLogs(ctx context.Context, chainID int64, params []any) ([]logEntry, error) {
-	logsRaw, err := ep.sendRPCRequest(ctx, methodLogs, chainID, params)
+// Call sends a JSON-RPC request to the node and decodes its result.
+func (ep *EthereumParser) Call(ctx context.Context, method string, params []any, result any) error {
+	return ep.call(ctx, 1, method, params, result)
+}
+
+// call sends a JSON-RPC request and decodes its result into result,
+// returning the node's error as *rpcError.
+func (ep *EthereumParser) call(ctx context.Context, chainID int64, method string, params []any, result any) error {
+	raw, err := ep.sendRPCRequest(ctx, method, chainID, params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get logs: %w", err)
+		return err
 	}
 
-	var logs logsResponse
-	if err = json.Unmarshal(logsRaw, &logs); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal logs: %w", err)
+	var response rpcResponse
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if response.Error != nil {
+		return response.Error
 	}
-	return logs.Logs, nil
+	if result == nil || len(response.Result) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("failed to unmarshal %s result: %w", method, err)
+	}
+	return nil
 }
 
 func (ep *EthereumParser) getChainID(ctx context.Context) (int64, error) {
@@ -228,22 +602,5 @@ func (ep *EthereumParser) sendRPCRequest(ctx context.Context, method string, id
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, rpcURL, bytes.NewBuffer(body))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := ep.client.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	return respBody, nil
+	return ep.transport.RoundTrip(ctx, body)
 }
@@ -4,9 +4,21 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"eth_parser/internal/app/backfill"
+	"eth_parser/internal/app/balance"
+	"eth_parser/internal/app/bloom"
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/app/simnode"
+	"eth_parser/internal/app/trace"
+	"eth_parser/internal/crypto"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/utils"
 	"io"
+	"math/big"
 	"net/http"
 	"testing"
+	"time"
 )
 
 type mockHTTPClient struct {
@@ -50,6 +62,14 @@ func (m *mockSubscriptionRepo) IsSubscribed(address string) bool {
 	return m.subscriptions[address]
 }
 
+func (m *mockSubscriptionRepo) Subscriptions() []string {
+	addresses := make([]string, 0, len(m.subscriptions))
+	for address := range m.subscriptions {
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
 func TestGetCurrentBlock(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -148,19 +168,21 @@ func TestGetTransactions(t *testing.T) {
 		address       string
 		subscribed    bool
 		chainIDResp   []byte
+		blockNumResp  []byte
 		logsResp      []byte
 		txResp        []byte
 		expectedTxs   int
 		expectedError bool
 	}{
 		{
-			name:        "successful transaction retrieval",
-			address:     "0x123",
-			subscribed:  true,
-			chainIDResp: []byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`),
-			logsResp:    []byte(`{"jsonrpc":"2.0","id":1,"result":[{"address":"0x123","blockHash":"0x1","blockNumber":"0x1","transactionHash":"0x1"}]}`),
-			txResp:      []byte(`{"jsonrpc":"2.0","id":1,"result":{"hash":"0x1","from":"0x123","to":"0x456"}}`),
-			expectedTxs: 1,
+			name:         "successful transaction retrieval",
+			address:      "0x123",
+			subscribed:   true,
+			chainIDResp:  []byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`),
+			blockNumResp: []byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`),
+			logsResp:     []byte(`{"jsonrpc":"2.0","id":1,"result":[{"address":"0x123","blockHash":"0x1","blockNumber":"0x1","transactionHash":"0x1","topics":["0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef","0x0000000000000000000000000000000000000000000000000000000000000123","0x0000000000000000000000000000000000000000000000000000000000000456"]}]}`),
+			txResp:       []byte(`{"jsonrpc":"2.0","id":1,"result":{"hash":"0x1","from":"0x123","to":"0x456"}}`),
+			expectedTxs:  1,
 		},
 		{
 			name:        "not subscribed",
@@ -175,6 +197,7 @@ func TestGetTransactions(t *testing.T) {
 			mockClient := &mockHTTPClient{
 				responses: map[string][]byte{
 					methodChainID:  tt.chainIDResp,
+					methodBlockNum: tt.blockNumResp,
 					methodLogs:     tt.logsResp,
 					methodTxByHash: tt.txResp,
 				},
@@ -186,8 +209,13 @@ func TestGetTransactions(t *testing.T) {
 			}
 
 			parser := NewEthereumParser(mockClient, mockRepo)
-			txs := parser.GetTransactions(tt.address)
+			// Nothing is scanned on the request path.
+			if txs := parser.GetTransactions(tt.address); len(txs) != 0 {
+				t.Fatalf("expected no transactions before catching up, got %d", len(txs))
+			}
 
+			parser.CatchUp(context.Background())
+			txs := parser.GetTransactions(tt.address)
 			if len(txs) != tt.expectedTxs {
 				t.Errorf("expected %d transactions, got %d", tt.expectedTxs, len(txs))
 			}
@@ -195,6 +223,41 @@ func TestGetTransactions(t *testing.T) {
 	}
 }
 
+func TestCatchUpStartsNewSubscriptionsAtTheHead(t *testing.T) {
+	const (
+		alice = "0x1111111111111111111111111111111111111111"
+		bob   = "0x2222222222222222222222222222222222222222"
+		token = "0x4444444444444444444444444444444444444444"
+	)
+
+	cfg := simnode.DefaultConfig()
+	cfg.Accounts = map[string]*big.Int{alice: big.NewInt(1e18)}
+	chain := simnode.NewChain(cfg)
+	chain.Mine(simnode.TxSpec{From: alice, To: token, Logs: []simnode.LogSpec{simnode.TokenTransfer(token, alice, bob, big.NewInt(5))}})
+	for range 20 {
+		chain.Mine()
+	}
+	node := simnode.NewNode(chain, 1)
+
+	subscriptions := repo.NewMemoryTransactionRepo()
+	parser := NewEthereumParser(node, subscriptions)
+	parser.Subscribe(bob)
+	parser.CatchUp(context.Background())
+
+	if txs := parser.GetTransactions(bob); len(txs) != 0 {
+		t.Errorf("expected the history before the subscription to be left out, got %d transactions", len(txs))
+	}
+	if next, ok := parser.checkpoints.GetCheckpoint(checkpointKey(bob)); !ok || next != chain.Head()+1 {
+		t.Errorf("checkpoint = %d, %v, want %d", next, ok, chain.Head()+1)
+	}
+
+	chain.Mine(simnode.TxSpec{From: alice, To: token, Logs: []simnode.LogSpec{simnode.TokenTransfer(token, alice, bob, big.NewInt(7))}})
+	parser.CatchUp(context.Background())
+	if txs := parser.GetTransactions(bob); len(txs) != 1 {
+		t.Errorf("expected the transfer after the subscription, got %d transactions", len(txs))
+	}
+}
+
 func TestSendRPCRequest(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -235,3 +298,516 @@ func TestSendRPCRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestScanRangeTracksBalances(t *testing.T) {
+	const (
+		holder = "0x1111111111111111111111111111111111111111"
+		other  = "0x2222222222222222222222222222222222222222"
+		token  = "0x3333333333333333333333333333333333333333"
+	)
+
+	mockClient := &mockHTTPClient{
+		responses: map[string][]byte{
+			methodChainID: []byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`),
+			// Sends 0x64 tokens from holder to other.
+			methodLogs: []byte(`{"jsonrpc":"2.0","id":1,"result":[{"address":"` + token + `","blockNumber":"0x10","transactionHash":"0xaa","logIndex":"0x0","data":"0x64","topics":["` + erc20Transfer + `","` + utils.AddressToHex(holder) + `","` + utils.AddressToHex(other) + `"]}]}`),
+			// The same transaction also sends 1000 wei to other.
+			methodTxByHash: []byte(`{"jsonrpc":"2.0","id":1,"result":{"hash":"0xaa","from":"` + holder + `","to":"` + other + `","value":"0x3e8","gasPrice":"0x2"}}`),
+			methodReceipt:  []byte(`{"jsonrpc":"2.0","id":1,"result":{"status":"0x1","gasUsed":"0x5","effectiveGasPrice":"0x3"}}`),
+			// The block also holds a plain transfer of 7 wei from holder to
+			// other, which leaves no log.
+			methodBlockByNum: []byte(`{"jsonrpc":"2.0","id":1,"result":{"number":"0x10","timestamp":"0x5","transactions":[` +
+				`{"hash":"0xaa","blockNumber":"0x10","from":"` + holder + `","to":"` + other + `","value":"0x3e8","gasPrice":"0x2"},` +
+				`{"hash":"0xbb","blockNumber":"0x10","from":"` + holder + `","to":"` + other + `","value":"0x7","gasPrice":"0x2"},` +
+				`{"hash":"0xcc","blockNumber":"0x10","from":"0x9999999999999999999999999999999999999999","to":"` + token + `","value":"0x1","gasPrice":"0x2"}]}}`),
+		},
+	}
+
+	tracker := balance.NewTracker(repo.NewMemoryTransferStore(), repo.NewMemoryBalanceRepo())
+	mockRepo := &mockSubscriptionRepo{subscriptions: make(map[string]bool)}
+	parser := NewEthereumParser(mockClient, mockRepo, WithBalanceTracker(tracker), WithPlainTransfers())
+
+	// Scanning twice must not book the transfers twice.
+	for i := 0; i < 2; i++ {
+		if _, err := parser.ScanRange(context.Background(), []string{holder, other}, 0x10, 0x10); err != nil {
+			t.Fatalf("ScanRange() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		address string
+		want    map[string]string
+	}{
+		// 1000 and 7 wei sent, plus two fees of 5 * 3 wei.
+		{address: holder, want: map[string]string{entity.NativeToken: "-1037", token: "-100"}},
+		{address: other, want: map[string]string{entity.NativeToken: "1007", token: "100"}},
+	}
+
+	if txs := parser.transactions.GetTransactions(holder); len(txs) != 2 {
+		t.Errorf("expected the token and the plain transfer of holder to be stored, got %d", len(txs))
+	}
+
+	for _, tt := range tests {
+		balances := parser.GetBalances(tt.address)
+		if len(balances) != len(tt.want) {
+			t.Fatalf("GetBalances(%s) = %v", tt.address, balances)
+		}
+		for _, b := range balances {
+			if tt.want[b.Token] != b.Amount {
+				t.Errorf("%s balance of %s = %s, want %s", b.Token, tt.address, b.Amount, tt.want[b.Token])
+			}
+		}
+	}
+}
+
+func TestScanRangeTracesInternalTransfers(t *testing.T) {
+	const (
+		watched  = "0x1111111111111111111111111111111111111111"
+		multisig = "0x2222222222222222222222222222222222222222"
+	)
+
+	mockClient := &mockHTTPClient{
+		responses: map[string][]byte{
+			methodChainID:    []byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`),
+			methodLogs:       []byte(`{"jsonrpc":"2.0","id":1,"result":[]}`),
+			methodTxByHash:   []byte(`{"jsonrpc":"2.0","id":1,"result":{"hash":"0xaa","from":"0x3333333333333333333333333333333333333333","to":"` + multisig + `","value":"0x0"}}`),
+			methodBlockByNum: []byte(`{"jsonrpc":"2.0","id":1,"result":{"number":"0x5","timestamp":"0x5","transactions":[]}}`),
+			"debug_traceBlockByNumber": []byte(`{"jsonrpc":"2.0","id":1,"result":[{"txHash":"0xaa","result":{"type":"CALL","from":"0x3333333333333333333333333333333333333333","to":"` + multisig + `","value":"0x0",` +
+				`"calls":[{"type":"CALL","from":"` + multisig + `","to":"` + watched + `","value":"0x64"}]}}]}`),
+		},
+	}
+
+	tracker := balance.NewTracker(repo.NewMemoryTransferStore(), repo.NewMemoryBalanceRepo())
+	mockRepo := &mockSubscriptionRepo{subscriptions: make(map[string]bool)}
+	parser := NewEthereumParser(mockClient, mockRepo, WithBalanceTracker(tracker), WithTracer(trace.ModeDebug))
+
+	found, err := parser.ScanRange(context.Background(), []string{watched}, 5, 5)
+	if err != nil {
+		t.Fatalf("ScanRange() error = %v", err)
+	}
+	if found != 1 {
+		t.Errorf("ScanRange() found %d, want 1", found)
+	}
+
+	transfers := tracker.GetTransfers(watched)
+	if len(transfers) != 1 || transfers[0].TracePath != "0" || transfers[0].Value != "100" {
+		t.Fatalf("GetTransfers() = %+v", transfers)
+	}
+	txs := parser.transactions.GetTransactions(watched)
+	if len(txs) != 1 {
+		t.Fatalf("expected the payout transaction to be stored, got %d", len(txs))
+	}
+	// The mock node reports a made-up hash.
+	if !txs[0].HashMismatch {
+		t.Error("expected the transaction to be flagged")
+	}
+	if balances := parser.GetBalances(watched); len(balances) != 1 || balances[0].Amount != "100" {
+		t.Errorf("GetBalances() = %+v", balances)
+	}
+}
+
+func TestBackfillAgainstSimulatedNode(t *testing.T) {
+	const (
+		alice = "0x1111111111111111111111111111111111111111"
+		bob   = "0x2222222222222222222222222222222222222222"
+		carol = "0x3333333333333333333333333333333333333333"
+		token = "0x4444444444444444444444444444444444444444"
+	)
+
+	cfg := simnode.DefaultConfig()
+	cfg.Accounts = map[string]*big.Int{alice: big.NewInt(1e18), carol: big.NewInt(1e18)}
+	chain := simnode.NewChain(cfg)
+	for number := 1; number <= 40; number++ {
+		switch number {
+		case 3:
+			chain.Mine(simnode.TxSpec{From: alice, To: token, Logs: []simnode.LogSpec{simnode.TokenTransfer(token, alice, carol, big.NewInt(50))}})
+		case 10:
+			chain.Mine(simnode.TxSpec{From: carol, To: token, Logs: []simnode.LogSpec{simnode.TokenTransfer(token, carol, bob, big.NewInt(20))}})
+		case 27:
+			chain.Mine(simnode.TxSpec{From: alice, To: token, Logs: []simnode.LogSpec{simnode.TokenTransfer(token, alice, bob, big.NewInt(5))}})
+		default:
+			chain.Mine()
+		}
+	}
+
+	// The provider caps log ranges and fails once, like public endpoints do.
+	node := simnode.NewNode(chain, 1)
+	node.SetFaults(simnode.Faults{MaxLogRange: 8})
+	node.FailNext(methodLogs, -32603, "internal error")
+
+	subscriptions := repo.NewMemoryTransactionRepo()
+	subscriptions.StoreSubscription(bob)
+
+	backfillCfg := backfill.DefaultConfig()
+	backfillCfg.RetryDelay = time.Millisecond
+	parser := NewEthereumParser(node, subscriptions,
+		WithBackfillConfig(backfillCfg),
+		WithBalanceTracker(balance.NewTracker(repo.NewMemoryTransferStore(), repo.NewMemoryBalanceRepo())),
+		WithPlainTransfers(),
+	)
+
+	importHistory(t, parser, bob)
+
+	txs := parser.GetTransactions(bob)
+	if len(txs) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(txs))
+	}
+	// Chunks are scanned concurrently, so transactions are stored in no
+	// particular order. Confirmations are counted from the block of the
+	// stored transaction.
+	var fromCarol *entity.Transaction
+	for i := range txs {
+		if txs[i].BlockNumber != nil && *txs[i].BlockNumber == "0xa" {
+			fromCarol = &txs[i]
+		}
+	}
+	if fromCarol == nil {
+		t.Fatalf("expected the transaction of block 10, got %+v", txs)
+	}
+	if want := utils.IntToHex(cfg.GenesisTime + 10*cfg.BlockTime); fromCarol.BlockTimestamp == nil || *fromCarol.BlockTimestamp != want {
+		t.Errorf("expected the timestamp %s of block 10, got %v", want, fromCarol.BlockTimestamp)
+	}
+	// Carol has no key on the simulated node, so the signature of her
+	// transaction recovers to another address.
+	if fromCarol.HashMismatch || !fromCarol.SenderMismatch {
+		t.Errorf("expected only the sender to be flagged, got hash %t, sender %t", fromCarol.HashMismatch, fromCarol.SenderMismatch)
+	}
+
+	reconciliation, err := parser.Reconcile(context.Background(), bob, "latest")
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if !reconciliation.InSync {
+		t.Errorf("expected tracked balances to match the chain, got %+v", reconciliation.Balances)
+	}
+}
+
+func TestReconcileAgainstSimulatedNode(t *testing.T) {
+	const (
+		alice = "0x1111111111111111111111111111111111111111"
+		bob   = "0x2222222222222222222222222222222222222222"
+		carol = "0x3333333333333333333333333333333333333333"
+		token = "0x4444444444444444444444444444444444444444"
+	)
+
+	cfg := simnode.DefaultConfig()
+	cfg.Accounts = map[string]*big.Int{alice: big.NewInt(1e18)}
+	chain := simnode.NewChain(cfg)
+	chain.Mine(simnode.TxSpec{From: alice, To: bob, Value: big.NewInt(1e15)})
+	chain.Mine()
+	// Plain sends of bob emit no logs, but their value and fee count.
+	chain.Mine(simnode.TxSpec{From: bob, To: carol, Value: big.NewInt(1e14)})
+	chain.Mine(simnode.TxSpec{From: alice, To: token, Logs: []simnode.LogSpec{simnode.TokenTransfer(token, alice, bob, big.NewInt(5))}})
+	chain.Mine(simnode.TxSpec{From: bob, To: carol, Value: big.NewInt(1), Failed: true})
+	scanned := chain.Head()
+
+	subscriptions := repo.NewMemoryTransactionRepo()
+	subscriptions.StoreSubscription(bob)
+	parser := NewEthereumParser(simnode.NewNode(chain, 1), subscriptions,
+		WithBalanceTracker(balance.NewTracker(repo.NewMemoryTransferStore(), repo.NewMemoryBalanceRepo())),
+		WithPlainTransfers(),
+	)
+	importHistory(t, parser, bob)
+	if txs := parser.GetTransactions(bob); len(txs) != 4 {
+		t.Errorf("expected 4 transactions of bob, got %d", len(txs))
+	}
+
+	// Blocks mined after the scan move ether of bob the tracker has not
+	// seen yet, so latest is the last scanned block.
+	chain.Mine(simnode.TxSpec{From: alice, To: bob, Value: big.NewInt(1e15)})
+
+	tests := []struct {
+		block     string
+		wantBlock uint64
+		wantErr   error
+	}{
+		{block: "latest", wantBlock: scanned},
+		{block: utils.IntToHex(2), wantBlock: 2},
+		{block: utils.IntToHex(chain.Head()), wantErr: balance.ErrNotScanned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.block, func(t *testing.T) {
+			reconciliation, err := parser.Reconcile(context.Background(), bob, tt.block)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Reconcile() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if reconciliation.Block != utils.IntToHex(tt.wantBlock) {
+				t.Errorf("reconciled at %s, want block %d", reconciliation.Block, tt.wantBlock)
+			}
+			if !reconciliation.InSync {
+				t.Errorf("expected tracked balances to match the chain, got %+v", reconciliation.Balances)
+			}
+		})
+	}
+}
+
+func TestCatchUpOpensBalancesHeldBeforeSubscribing(t *testing.T) {
+	const (
+		alice = "0x1111111111111111111111111111111111111111"
+		bob   = "0x2222222222222222222222222222222222222222"
+		token = "0x4444444444444444444444444444444444444444"
+	)
+
+	cfg := simnode.DefaultConfig()
+	cfg.Accounts = map[string]*big.Int{alice: big.NewInt(1e18)}
+	chain := simnode.NewChain(cfg)
+	// bob holds ether and tokens before he is subscribed.
+	chain.Mine(simnode.TxSpec{From: alice, To: bob, Value: big.NewInt(1e15)})
+	chain.Mine(simnode.TxSpec{From: alice, To: token, Logs: []simnode.LogSpec{simnode.TokenTransfer(token, alice, bob, big.NewInt(5))}})
+	chain.Mine()
+
+	subscriptions := repo.NewMemoryTransactionRepo()
+	subscriptions.StoreSubscription(bob)
+	parser := NewEthereumParser(simnode.NewNode(chain, 1), subscriptions,
+		WithBalanceTracker(balance.NewTracker(repo.NewMemoryTransferStore(), repo.NewMemoryBalanceRepo())),
+		WithPlainTransfers(),
+	)
+	parser.CatchUp(context.Background())
+
+	chain.Mine(simnode.TxSpec{From: alice, To: bob, Value: big.NewInt(1e14)})
+	chain.Mine(simnode.TxSpec{From: alice, To: token, Logs: []simnode.LogSpec{simnode.TokenTransfer(token, alice, bob, big.NewInt(2))}})
+	parser.CatchUp(context.Background())
+
+	want := map[string]string{entity.NativeToken: "1100000000000000", token: "7"}
+	balances := parser.GetBalances(bob)
+	if len(balances) != len(want) {
+		t.Fatalf("GetBalances() = %v, want %v", balances, want)
+	}
+	for _, b := range balances {
+		if want[b.Token] != b.Amount {
+			t.Errorf("%s balance = %s, want %s", b.Token, b.Amount, want[b.Token])
+		}
+	}
+
+	reconciliation, err := parser.Reconcile(context.Background(), bob, "latest")
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if !reconciliation.InSync {
+		t.Errorf("expected tracked balances to match the chain, got %+v", reconciliation.Balances)
+	}
+	// Before the opening there is nothing to compare.
+	if _, err := parser.Reconcile(context.Background(), bob, utils.IntToHex(1)); !errors.Is(err, balance.ErrNotScanned) {
+		t.Errorf("Reconcile() before the opening error = %v, want %v", err, balance.ErrNotScanned)
+	}
+}
+
+func TestHandleLogImportsPushedTransfers(t *testing.T) {
+	const (
+		alice = "0x1111111111111111111111111111111111111111"
+		bob   = "0x2222222222222222222222222222222222222222"
+		carol = "0x3333333333333333333333333333333333333333"
+		token = "0x4444444444444444444444444444444444444444"
+	)
+
+	cfg := simnode.DefaultConfig()
+	cfg.Accounts = map[string]*big.Int{alice: big.NewInt(1e18)}
+	chain := simnode.NewChain(cfg)
+	chain.Mine(
+		simnode.TxSpec{From: alice, To: token, Logs: []simnode.LogSpec{simnode.TokenTransfer(token, alice, bob, big.NewInt(5))}},
+		simnode.TxSpec{From: alice, To: token, Logs: []simnode.LogSpec{simnode.TokenTransfer(token, alice, carol, big.NewInt(7))}},
+	)
+
+	subscriptions := repo.NewMemoryTransactionRepo()
+	subscriptions.StoreSubscription(bob)
+	parser := NewEthereumParser(simnode.NewNode(chain, 1), subscriptions)
+
+	filters := parser.LogFilters()
+	var logs []entity.Log
+	if err := parser.Call(context.Background(), methodLogs, []any{map[string]any{"fromBlock": "0x1", "toBlock": "0x1"}}, &logs); err != nil {
+		t.Fatalf("eth_getLogs error = %v", err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("expected 2 logs, got %d", len(logs))
+	}
+	for _, l := range logs {
+		if got, want := filters[0].Matches(l) || filters[1].Matches(l), l.Topics[2] == utils.AddressToHex(bob); got != want {
+			t.Errorf("LogFilters() select the log to %s: %v, want %v", l.Topics[2], got, want)
+		}
+		if err := parser.HandleLog(context.Background(), l); err != nil {
+			t.Fatalf("HandleLog() error = %v", err)
+		}
+	}
+
+	if txs := parser.GetTransactions(bob); len(txs) != 1 {
+		t.Errorf("expected the pushed transfer to bob to be stored, got %d transactions", len(txs))
+	}
+}
+
+func TestScanRangeDecodesTypedTransactions(t *testing.T) {
+	const (
+		bob   = "0x2222222222222222222222222222222222222222"
+		token = "0x4444444444444444444444444444444444444444"
+	)
+	aliceKey := simnode.Key("alice")
+	alice := crypto.AddressOf(aliceKey)
+
+	tests := []struct {
+		txType     entity.TxType
+		accessList bool
+		dynamicFee bool
+		blob       bool
+		setCode    bool
+	}{
+		{txType: entity.LegacyTxType},
+		{txType: entity.AccessListTxType, accessList: true},
+		{txType: entity.DynamicFeeTxType, accessList: true, dynamicFee: true},
+		{txType: entity.BlobTxType, accessList: true, dynamicFee: true, blob: true},
+		{txType: entity.SetCodeTxType, accessList: true, dynamicFee: true, setCode: true},
+	}
+
+	cfg := simnode.DefaultConfig()
+	cfg.Accounts = map[string]*big.Int{alice: big.NewInt(1e18)}
+	cfg.Keys = []*big.Int{aliceKey}
+	chain := simnode.NewChain(cfg)
+	for _, tt := range tests {
+		chain.Mine(simnode.TxSpec{Type: tt.txType, From: alice, To: token, Logs: []simnode.LogSpec{simnode.TokenTransfer(token, alice, bob, big.NewInt(1))}})
+	}
+
+	subscriptions := repo.NewMemoryTransactionRepo()
+	subscriptions.StoreSubscription(alice)
+	subscriptions.StoreSubscription(bob)
+	parser := NewEthereumParser(simnode.NewNode(chain, 1), subscriptions,
+		WithBalanceTracker(balance.NewTracker(repo.NewMemoryTransferStore(), repo.NewMemoryBalanceRepo())),
+		WithPlainTransfers(),
+	)
+	if _, err := parser.ScanRange(context.Background(), []string{alice, bob}, 0, chain.Head()); err != nil {
+		t.Fatalf("ScanRange() error = %v", err)
+	}
+
+	txs := parser.GetTransactions(bob)
+	if len(txs) != len(tests) {
+		t.Fatalf("expected %d transactions, got %d", len(tests), len(txs))
+	}
+	for i, tt := range tests {
+		tx := txs[i]
+		if tx.Type != tt.txType {
+			t.Errorf("transaction %d has type %d, want %d", i, tx.Type, tt.txType)
+		}
+		if tx.ChainID != "0x539" {
+			t.Errorf("transaction %d has chain ID %q", i, tx.ChainID)
+		}
+		if tx.HashMismatch || tx.SenderMismatch {
+			t.Errorf("transaction %d failed verification: hash %t, sender %t", i, tx.HashMismatch, tx.SenderMismatch)
+		}
+		if (tx.AccessListFields != nil) != tt.accessList {
+			t.Errorf("type %d access list = %+v", tt.txType, tx.AccessListFields)
+		}
+		if (tx.DynamicFeeFields != nil) != tt.dynamicFee {
+			t.Errorf("type %d fee caps = %+v", tt.txType, tx.DynamicFeeFields)
+		}
+		if (tx.BlobFields != nil) != tt.blob {
+			t.Errorf("type %d blob fields = %+v", tt.txType, tx.BlobFields)
+		}
+		if (tx.SetCodeFields != nil) != tt.setCode {
+			t.Errorf("type %d authorizations = %+v", tt.txType, tx.SetCodeFields)
+		}
+	}
+
+	// The fees booked for alice include the blob gas.
+	spent := new(big.Int).Sub(chain.BalanceAt(alice, chain.Head()), big.NewInt(1e18))
+	for _, b := range parser.GetBalances(alice) {
+		if b.Token == entity.NativeToken && b.Amount != spent.String() {
+			t.Errorf("native balance of alice = %s, want %s", b.Amount, spent)
+		}
+	}
+}
+
+func TestHandleBlockSkipsBlocksByBloom(t *testing.T) {
+	const (
+		alice = "0x1111111111111111111111111111111111111111"
+		bob   = "0x2222222222222222222222222222222222222222"
+		carol = "0x3333333333333333333333333333333333333333"
+		dave  = "0x5555555555555555555555555555555555555555"
+		token = "0x4444444444444444444444444444444444444444"
+	)
+
+	cfg := simnode.DefaultConfig()
+	cfg.Accounts = map[string]*big.Int{alice: big.NewInt(1e18), carol: big.NewInt(1e18)}
+	chain := simnode.NewChain(cfg)
+	chain.Mine()
+	node := simnode.NewNode(chain, 1)
+
+	subscriptions := repo.NewMemoryTransactionRepo()
+	subscriptions.StoreSubscription(bob)
+	// Without plain transfers, booking transfers reads no full blocks.
+	parser := NewEthereumParser(node, subscriptions,
+		WithBalanceTracker(balance.NewTracker(repo.NewMemoryTransferStore(), repo.NewMemoryBalanceRepo())),
+	)
+	importHistory(t, parser, bob)
+	// dave was never scanned, so new blocks leave him to Run.
+	subscriptions.StoreSubscription(dave)
+
+	heads := [][]simnode.TxSpec{
+		{{From: alice, To: token, Logs: []simnode.LogSpec{simnode.TokenTransfer(token, alice, bob, big.NewInt(5))}}},
+		nil,
+		{{From: alice, To: token, Logs: []simnode.LogSpec{simnode.TokenTransfer(token, alice, carol, big.NewInt(7))}}},
+		{{From: carol, To: token, Logs: []simnode.LogSpec{simnode.TokenTransfer(token, carol, bob, big.NewInt(2))}}},
+	}
+	calls := node.Calls(methodLogs)
+	for _, txs := range heads {
+		mined := chain.Mine(txs...)
+		block, err := parser.BlockByNumber(context.Background(), mined.Number)
+		if err != nil {
+			t.Fatalf("BlockByNumber() error = %v", err)
+		}
+		blockCalls := node.Calls(methodBlockByNum)
+		if err := parser.HandleBlock(context.Background(), block); err != nil {
+			t.Fatalf("HandleBlock() error = %v", err)
+		}
+		if got := node.Calls(methodBlockByNum) - blockCalls; got != 0 {
+			t.Errorf("HandleBlock() read %d blocks without plain transfers", got)
+		}
+	}
+
+	// Each hit costs one eth_getLogs request per side of the transfer.
+	if got := node.Calls(methodLogs) - calls; got != 4 {
+		t.Errorf("made %d eth_getLogs requests, want 4 for the 2 blocks moving tokens of bob", got)
+	}
+	if got, want := parser.BloomStats(), (bloom.Snapshot{Checked: 4, Skipped: 2}); got != want {
+		t.Errorf("BloomStats() = %+v, want %+v", got, want)
+	}
+
+	calls = node.Calls(methodLogs)
+	if txs := parser.GetTransactions(bob); len(txs) != 2 {
+		t.Errorf("expected 2 transactions, got %d", len(txs))
+	}
+	if got := node.Calls(methodLogs) - calls; got != 0 {
+		t.Errorf("GetTransactions() made %d eth_getLogs requests for blocks already handled", got)
+	}
+}
+
+// importHistory imports the whole history of a subscribed address the way
+// the service does: Run catches it up from the head, and a backfill job
+// imports the blocks before.
+func importHistory(t *testing.T, parser *EthereumParser, address string) {
+	t.Helper()
+
+	parser.CatchUp(context.Background())
+	next, ok := parser.checkpoints.GetCheckpoint(checkpointKey(address))
+	if !ok {
+		t.Fatalf("%s was not caught up with the head", address)
+	}
+	if next < 2 {
+		return
+	}
+
+	jobs := backfill.NewJobManager(repo.NewMemoryJobRepo(), backfill.NewEngine(parser.checkpoints, parser.backfillCfg), parser)
+	defer jobs.Stop()
+	job, err := jobs.Create(context.Background(), address, 0, next-2)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !job.Done() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		job, _ = jobs.Get(job.ID)
+	}
+	if job.Status != entity.JobCompleted {
+		t.Fatalf("backfill job of %s ended %q: %v", address, job.Status, job.Errors)
+	}
+}
@@ -0,0 +1,144 @@
+package parser
+
+import (
+	"context"
+	"eth_parser/internal/app/bloom"
+	"eth_parser/internal/app/logfilter"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/utils"
+	"fmt"
+)
+
+// HandleBlock imports the transfers of subscribed addresses in a new block,
+// so their history keeps up with the head between the rounds of Run. It
+// implements scanner.BlockHandler.
+//
+// Only addresses whose history is scanned right up to block are handled;
+// Run catches the others up from their checkpoint. The logs bloom of block
+// rules out most of them without a single request: logs are only fetched
+// for addresses the bloom may hold a Transfer of. Plain ether transfers and
+// internal ones leave no logs, so the transactions of block are still read
+// when plain transfers are booked, and its traces when tracing.
+func (ep *EthereumParser) HandleBlock(ctx context.Context, block *entity.Block) error {
+	// Addresses being caught up by another scan are left to it.
+	var due []string
+	for _, address := range ep.repo.Subscriptions() {
+		lock := ep.scanLock(address)
+		if !lock.TryLock() {
+			continue
+		}
+		if next, ok := ep.checkpoints.GetCheckpoint(checkpointKey(address)); ok && next == block.Number {
+			due = append(due, address)
+			defer lock.Unlock()
+		} else {
+			lock.Unlock()
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	candidates := due
+	b, err := bloom.Parse(block.LogsBloom)
+	prefilter := err == nil
+	if prefilter {
+		candidates = mayTransfer(b, due)
+		ep.bloomStats.Check(len(candidates) > 0)
+	}
+
+	if len(candidates) > 0 || (ep.balances != nil && ep.plainTransfers) || ep.tracer != nil {
+		chainID, err := ep.getChainID(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get chain ID: %w", err)
+		}
+		scan := newTransferScan(ep, chainID)
+		if _, err := scan.recordBlocks(ctx, due, block.Number, block.Number); err != nil {
+			return err
+		}
+		if len(candidates) > 0 {
+			found, err := scan.recordLogs(ctx, candidates, block.Number, block.Number)
+			if err != nil {
+				return err
+			}
+			if prefilter && found == 0 {
+				ep.bloomStats.FalsePositive()
+			}
+		}
+		if _, err := scan.recordTraces(ctx, due, block.Number, block.Number); err != nil {
+			return err
+		}
+	}
+
+	for _, address := range due {
+		if err := ep.checkpoints.StoreCheckpoint(checkpointKey(address), block.Number+1); err != nil {
+			return fmt.Errorf("failed to store checkpoint: %w", err)
+		}
+	}
+	return nil
+}
+
+// LogFilters select the Transfer logs of subscribed addresses, for a logs
+// subscription feeding HandleLog.
+func (ep *EthereumParser) LogFilters() []logfilter.Filter {
+	addresses := ep.repo.Subscriptions()
+	if len(addresses) == 0 {
+		return nil
+	}
+	topics := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		topics = append(topics, utils.AddressToHex(address))
+	}
+	return transferFilters(topics)
+}
+
+// HandleLog imports a Transfer log pushed by a logs subscription, so the
+// transfer is listed before the scanner reaches its block. HandleBlock
+// still handles every block and importing a log twice is harmless.
+func (ep *EthereumParser) HandleLog(ctx context.Context, l entity.Log) error {
+	if l.Removed || len(l.Topics) == 0 || l.Topics[0] != erc20Transfer {
+		return nil
+	}
+
+	fromTopic, toTopic := transferParties(l)
+	var matched []string
+	for _, address := range ep.repo.Subscriptions() {
+		if topic := utils.AddressToHex(address); topic == fromTopic || topic == toTopic {
+			matched = append(matched, address)
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+
+	chainID, err := ep.getChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get chain ID: %w", err)
+	}
+	scan := newTransferScan(ep, chainID)
+	for _, address := range matched {
+		if err := scan.record(ctx, address, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BloomStats tells how many blocks HandleBlock skipped thanks to their
+// logs bloom.
+func (ep *EthereumParser) BloomStats() bloom.Snapshot {
+	return ep.bloomStats.Snapshot()
+}
+
+// mayTransfer returns the addresses b may hold a Transfer from or to.
+func mayTransfer(b bloom.Bloom, addresses []string) []string {
+	if !b.TestHex(erc20Transfer) {
+		return nil
+	}
+	var candidates []string
+	for _, address := range addresses {
+		if b.TestHex(utils.AddressToHex(address)) {
+			candidates = append(candidates, address)
+		}
+	}
+	return candidates
+}
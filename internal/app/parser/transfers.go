@@ -0,0 +1,321 @@
+package parser
+
+import (
+	"context"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/utils"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const receiptStatusFailed = "0x0"
+
+type receipt struct {
+	Status            string `json:"status"`
+	GasUsed           string `json:"gasUsed"`
+	EffectiveGasPrice string `json:"effectiveGasPrice"`
+	// BlobGasUsed and BlobGasPrice are only set for blob transactions.
+	BlobGasUsed  string `json:"blobGasUsed"`
+	BlobGasPrice string `json:"blobGasPrice"`
+}
+
+// transferScan stores what a scanned range contains for subscribed
+// addresses, looking each transaction and receipt up only once.
+type transferScan struct {
+	parser       *EthereumParser
+	chainID      int64
+	transactions map[string]*entity.Transaction
+	receipts     map[string]*receipt
+}
+
+func newTransferScan(ep *EthereumParser, chainID int64) *transferScan {
+	return &transferScan{
+		parser:       ep,
+		chainID:      chainID,
+		transactions: make(map[string]*entity.Transaction),
+		receipts:     make(map[string]*receipt),
+	}
+}
+
+// record stores the transaction behind a Transfer log for address and,
+// when balances are tracked, books the transfers it caused.
+func (s *transferScan) record(ctx context.Context, address string, entry entity.Log) error {
+	tx, err := s.transaction(ctx, entry.TransactionHash)
+	if err != nil {
+		return fmt.Errorf("failed to get transaction by hash: %w", err)
+	}
+	if tx == nil {
+		return nil
+	}
+
+	if _, err := s.parser.transactions.StoreTransaction(address, *tx); err != nil {
+		return fmt.Errorf("failed to store transaction: %w", err)
+	}
+
+	if s.parser.balances == nil {
+		return nil
+	}
+
+	transfers, err := s.transfers(ctx, address, tx, entry)
+	if err != nil {
+		return err
+	}
+	return s.apply(ctx, address, transfers)
+}
+
+// recordBlocks books the ether sent and the fees paid by the transactions
+// of blocks [from, to] from or to addresses, and stores these transactions.
+// Plain ether transfers leave no log, so every block is read with its
+// transactions. It does nothing unless plain transfers are booked and
+// returns how many transactions matched.
+func (s *transferScan) recordBlocks(ctx context.Context, addresses []string, from, to uint64) (int, error) {
+	if s.parser.balances == nil || !s.parser.plainTransfers {
+		return 0, nil
+	}
+
+	found := 0
+	for number := from; number <= to; number++ {
+		var block *rpcFullBlock
+		if err := s.parser.call(ctx, s.chainID, methodBlockByNum, []any{utils.IntToHex(number), true}, &block); err != nil {
+			return 0, fmt.Errorf("failed to get block %d: %w", number, err)
+		}
+		if block == nil {
+			return 0, fmt.Errorf("block %d not found", number)
+		}
+
+		for i := range block.Transactions {
+			tx := &block.Transactions[i]
+			matched := sentOrReceived(tx, addresses)
+			if len(matched) == 0 {
+				continue
+			}
+			if err := verifyTransaction(tx, s.chainID); err != nil {
+				return 0, err
+			}
+			if tx.BlockTimestamp == nil {
+				tx.BlockTimestamp = &block.Timestamp
+			}
+			s.transactions[tx.Hash] = tx
+
+			for _, address := range matched {
+				if _, err := s.parser.transactions.StoreTransaction(address, *tx); err != nil {
+					return 0, fmt.Errorf("failed to store transaction: %w", err)
+				}
+				transfers, err := s.nativeTransfers(ctx, address, tx, number)
+				if err != nil {
+					return 0, err
+				}
+				if err := s.apply(ctx, address, transfers); err != nil {
+					return 0, err
+				}
+			}
+			found++
+		}
+	}
+	return found, nil
+}
+
+// rpcFullBlock is a block returned with its transactions in full.
+type rpcFullBlock struct {
+	Timestamp    string               `json:"timestamp"`
+	Transactions []entity.Transaction `json:"transactions"`
+}
+
+// sentOrReceived returns the addresses tx is sent from or to.
+func sentOrReceived(tx *entity.Transaction, addresses []string) []string {
+	var matched []string
+	for _, address := range addresses {
+		if strings.EqualFold(tx.From, address) || (tx.To != nil && strings.EqualFold(*tx.To, address)) {
+			matched = append(matched, address)
+		}
+	}
+	return matched
+}
+
+// apply books transfers for address, opening the balance of a token the
+// first time one of its transfers is booked.
+func (s *transferScan) apply(ctx context.Context, address string, transfers []entity.Transfer) error {
+	for _, transfer := range transfers {
+		if s.parser.tracksBalances() && transfer.Token != entity.NativeToken {
+			if err := s.parser.balances.OpenToken(ctx, s.parser, address, transfer.Token); err != nil {
+				return err
+			}
+		}
+		if err := s.parser.balances.Apply(address, transfer); err != nil {
+			return fmt.Errorf("failed to apply transfer %s: %w", transfer.ID, err)
+		}
+	}
+	return nil
+}
+
+// recordInternal stores the transaction that caused an internal transfer
+// for address and books the transfer when balances are tracked.
+func (s *transferScan) recordInternal(ctx context.Context, address string, transfer entity.Transfer) error {
+	tx, err := s.transaction(ctx, transfer.TxHash)
+	if err != nil {
+		return fmt.Errorf("failed to get transaction by hash: %w", err)
+	}
+	if tx != nil {
+		if _, err := s.parser.transactions.StoreTransaction(address, *tx); err != nil {
+			return fmt.Errorf("failed to store transaction: %w", err)
+		}
+	}
+
+	if s.parser.balances == nil {
+		return nil
+	}
+	return s.apply(ctx, address, []entity.Transfer{transfer})
+}
+
+func (s *transferScan) transfers(ctx context.Context, address string, tx *entity.Transaction, entry entity.Log) ([]entity.Transfer, error) {
+	blockNumber, _ := utils.HexToInt(entry.BlockNumber)
+
+	var transfers []entity.Transfer
+	if token, ok := tokenTransfer(entry, uint64(blockNumber)); ok {
+		transfers = append(transfers, token)
+	}
+
+	native, err := s.nativeTransfers(ctx, address, tx, uint64(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	return append(transfers, native...), nil
+}
+
+// nativeTransfers returns the ether tx sent and, when address sent it, the
+// fee it paid.
+func (s *transferScan) nativeTransfers(ctx context.Context, address string, tx *entity.Transaction, blockNumber uint64) ([]entity.Transfer, error) {
+	r, err := s.receipt(ctx, tx.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipt: %w", err)
+	}
+	if r == nil {
+		return nil, nil
+	}
+
+	var transfers []entity.Transfer
+
+	to := ""
+	if tx.To != nil {
+		to = strings.ToLower(*tx.To)
+	}
+
+	value, err := utils.HexToBig(tx.Value)
+	if err == nil && value.Sign() > 0 && r.Status != receiptStatusFailed {
+		transfers = append(transfers, entity.Transfer{
+			ID:          tx.Hash + ":native",
+			Kind:        entity.TransferNative,
+			TxHash:      tx.Hash,
+			BlockNumber: blockNumber,
+			Token:       entity.NativeToken,
+			From:        strings.ToLower(tx.From),
+			To:          to,
+			Value:       value.String(),
+		})
+	}
+
+	if strings.EqualFold(tx.From, address) {
+		if fee, ok := r.fee(tx); ok {
+			transfers = append(transfers, entity.Transfer{
+				ID:          tx.Hash + ":fee",
+				Kind:        entity.TransferFee,
+				TxHash:      tx.Hash,
+				BlockNumber: blockNumber,
+				Token:       entity.NativeToken,
+				From:        strings.ToLower(tx.From),
+				Value:       fee.String(),
+			})
+		}
+	}
+
+	return transfers, nil
+}
+
+func (s *transferScan) transaction(ctx context.Context, hash string) (*entity.Transaction, error) {
+	if tx, ok := s.transactions[hash]; ok {
+		return tx, nil
+	}
+
+	tx, err := s.parser.getTransaction(ctx, s.chainID, hash)
+	if err != nil {
+		return nil, err
+	}
+	if tx != nil {
+		if err := s.parser.dateTransaction(ctx, tx); err != nil {
+			return nil, err
+		}
+	}
+	s.transactions[hash] = tx
+	return tx, nil
+}
+
+func (s *transferScan) receipt(ctx context.Context, hash string) (*receipt, error) {
+	if r, ok := s.receipts[hash]; ok {
+		return r, nil
+	}
+
+	var r *receipt
+	if err := s.parser.call(ctx, s.chainID, methodReceipt, []any{hash}, &r); err != nil {
+		return nil, err
+	}
+	s.receipts[hash] = r
+	return r, nil
+}
+
+// fee is gasUsed times the effective gas price, falling back to the
+// transaction gas price for nodes that predate EIP-1559 receipts. Blob
+// transactions also pay for their blob gas.
+func (r *receipt) fee(tx *entity.Transaction) (*big.Int, bool) {
+	gasUsed, err := utils.HexToBig(r.GasUsed)
+	if err != nil {
+		return nil, false
+	}
+
+	price := r.EffectiveGasPrice
+	if price == "" {
+		price = tx.GasPrice
+	}
+	gasPrice, err := utils.HexToBig(price)
+	if err != nil {
+		return nil, false
+	}
+
+	fee := new(big.Int).Mul(gasUsed, gasPrice)
+	if r.BlobGasUsed != "" {
+		blobGasUsed, err := utils.HexToBig(r.BlobGasUsed)
+		if err != nil {
+			return nil, false
+		}
+		blobGasPrice, err := utils.HexToBig(r.BlobGasPrice)
+		if err != nil {
+			return nil, false
+		}
+		fee.Add(fee, blobGasUsed.Mul(blobGasUsed, blobGasPrice))
+	}
+	return fee, true
+}
+
+// tokenTransfer decodes an ERC-20 Transfer log. ERC-721 transfers share the
+// event signature but index the token ID, so they carry four topics and are skipped.
+func tokenTransfer(entry entity.Log, blockNumber uint64) (entity.Transfer, bool) {
+	if len(entry.Topics) != 3 || entry.Topics[0] != erc20Transfer {
+		return entity.Transfer{}, false
+	}
+
+	value, err := utils.HexToBig(entry.Data)
+	if err != nil {
+		return entity.Transfer{}, false
+	}
+
+	return entity.Transfer{
+		ID:          entry.TransactionHash + ":log:" + entry.LogIndex,
+		Kind:        entity.TransferToken,
+		TxHash:      entry.TransactionHash,
+		BlockNumber: blockNumber,
+		Token:       strings.ToLower(entry.Address),
+		From:        utils.TopicToAddress(entry.Topics[1]),
+		To:          utils.TopicToAddress(entry.Topics[2]),
+		Value:       value.String(),
+	}, true
+}
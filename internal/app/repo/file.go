@@ -0,0 +1,99 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// readJSONFile decodes path into v, leaving v untouched if the file does not exist yet.
+func readJSONFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSONFile replaces path atomically so a crash never leaves a torn file behind.
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// readJSONLines decodes every line of the JSON lines file at path with
+// decode, doing nothing if the file does not exist yet. A crash while
+// appending leaves the last line cut short, so a last line that does not
+// decode is logged and truncated away; a bad line before it is an error.
+func readJSONLines(path string, decode func(line []byte) error) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		last := errors.Is(err, io.EOF)
+		if len(bytes.TrimSpace(line)) > 0 {
+			if decodeErr := decode(line); decodeErr != nil {
+				if !last {
+					if _, peekErr := reader.Peek(1); !errors.Is(peekErr, io.EOF) {
+						return fmt.Errorf("failed to decode %s at byte %d: %w", path, offset, decodeErr)
+					}
+				}
+				log.Printf("truncating the torn last line of %s at byte %d: %v", path, offset, decodeErr)
+				if err := file.Truncate(offset); err != nil {
+					return fmt.Errorf("failed to truncate %s: %w", path, err)
+				}
+				return nil
+			}
+			if last {
+				// Appends would join a complete last line without its newline.
+				if _, err := file.WriteAt([]byte{'\n'}, offset+int64(len(line))); err != nil {
+					return fmt.Errorf("failed to terminate the last line of %s: %w", path, err)
+				}
+			}
+		}
+		if last {
+			return nil
+		}
+		offset += int64(len(line))
+	}
+}
@@ -0,0 +1,51 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"sync"
+)
+
+var _ repository.ABIRepo = (*FileABIRepo)(nil)
+
+// FileABIRepo keeps the contract ABIs in memory and mirrors them to a JSON file.
+type FileABIRepo struct {
+	*MemoryABIRepo
+	path  string
+	mutex sync.Mutex
+}
+
+func NewFileABIRepo(path string) (*FileABIRepo, error) {
+	r := &FileABIRepo{
+		MemoryABIRepo: NewMemoryABIRepo(),
+		path:          path,
+	}
+
+	var abis []entity.ContractABI
+	if err := readJSONFile(path, &abis); err != nil {
+		return nil, err
+	}
+	for _, abi := range abis {
+		r.MemoryABIRepo.StoreABI(abi)
+	}
+	return r, nil
+}
+
+func (r *FileABIRepo) StoreABI(abi entity.ContractABI) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.MemoryABIRepo.StoreABI(abi)
+	return writeJSONFile(r.path, r.ListABIs())
+}
+
+func (r *FileABIRepo) DeleteABI(address string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	found, _ := r.MemoryABIRepo.DeleteABI(address)
+	if !found {
+		return false, nil
+	}
+	return true, writeJSONFile(r.path, r.ListABIs())
+}
@@ -0,0 +1,120 @@
+package repo
+
+import (
+	"encoding/json"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	_ repository.AlertRuleRepo = (*FileAlertRuleRepo)(nil)
+	_ repository.AlertRepo     = (*FileAlertRepo)(nil)
+)
+
+// FileAlertRuleRepo keeps alert rules in memory and mirrors them to a JSON file.
+type FileAlertRuleRepo struct {
+	*MemoryAlertRuleRepo
+	path  string
+	mutex sync.Mutex
+}
+
+func NewFileAlertRuleRepo(path string) (*FileAlertRuleRepo, error) {
+	r := &FileAlertRuleRepo{
+		MemoryAlertRuleRepo: NewMemoryAlertRuleRepo(),
+		path:                path,
+	}
+
+	var rules []entity.AlertRule
+	if err := readJSONFile(path, &rules); err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		r.MemoryAlertRuleRepo.StoreRule(rule)
+	}
+	return r, nil
+}
+
+func (r *FileAlertRuleRepo) StoreRule(rule entity.AlertRule) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.MemoryAlertRuleRepo.StoreRule(rule)
+	return writeJSONFile(r.path, r.ListRules())
+}
+
+func (r *FileAlertRuleRepo) DeleteRule(id string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	found, _ := r.MemoryAlertRuleRepo.DeleteRule(id)
+	if !found {
+		return false, nil
+	}
+	return true, writeJSONFile(r.path, r.ListRules())
+}
+
+// FileAlertRepo keeps alerts in memory and appends every new one to a JSON
+// lines file that is replayed on startup.
+type FileAlertRepo struct {
+	*MemoryAlertRepo
+	file  *os.File
+	mutex sync.Mutex
+}
+
+func NewFileAlertRepo(path string) (*FileAlertRepo, error) {
+	r := &FileAlertRepo{
+		MemoryAlertRepo: NewMemoryAlertRepo(),
+	}
+
+	if err := r.load(path); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	r.file = file
+	return r, nil
+}
+
+func (r *FileAlertRepo) load(path string) error {
+	return readJSONLines(path, func(line []byte) error {
+		var alert entity.Alert
+		if err := json.Unmarshal(line, &alert); err != nil {
+			return err
+		}
+		r.MemoryAlertRepo.StoreAlert(alert)
+		return nil
+	})
+}
+
+func (r *FileAlertRepo) StoreAlert(alert entity.Alert) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, _ := r.MemoryAlertRepo.StoreAlert(alert)
+	if !stored {
+		return false, nil
+	}
+
+	line, err := json.Marshal(alert)
+	if err != nil {
+		return true, fmt.Errorf("failed to encode alert: %w", err)
+	}
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		return true, fmt.Errorf("failed to append alert: %w", err)
+	}
+	return true, nil
+}
+
+func (r *FileAlertRepo) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,40 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"sync"
+)
+
+var _ repository.APIKeyRepo = (*FileAPIKeyRepo)(nil)
+
+// FileAPIKeyRepo keeps API keys in memory and mirrors them to a JSON file.
+type FileAPIKeyRepo struct {
+	*MemoryAPIKeyRepo
+	path  string
+	mutex sync.Mutex
+}
+
+func NewFileAPIKeyRepo(path string) (*FileAPIKeyRepo, error) {
+	r := &FileAPIKeyRepo{
+		MemoryAPIKeyRepo: NewMemoryAPIKeyRepo(),
+		path:             path,
+	}
+
+	var keys []entity.APIKey
+	if err := readJSONFile(path, &keys); err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		r.MemoryAPIKeyRepo.StoreKey(key)
+	}
+	return r, nil
+}
+
+func (r *FileAPIKeyRepo) StoreKey(key entity.APIKey) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.MemoryAPIKeyRepo.StoreKey(key)
+	return writeJSONFile(r.path, r.ListKeys())
+}
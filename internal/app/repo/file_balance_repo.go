@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"math/big"
+	"sync"
+)
+
+var _ repository.BalanceRepo = (*FileBalanceRepo)(nil)
+
+// FileBalanceRepo keeps balances in memory and mirrors them to a JSON file,
+// and their opening balances to another.
+type FileBalanceRepo struct {
+	*MemoryBalanceRepo
+	path         string
+	openingsPath string
+	mutex        sync.Mutex
+}
+
+func NewFileBalanceRepo(path, openingsPath string) (*FileBalanceRepo, error) {
+	r := &FileBalanceRepo{
+		MemoryBalanceRepo: NewMemoryBalanceRepo(),
+		path:              path,
+		openingsPath:      openingsPath,
+	}
+
+	if err := readJSONFile(path, &r.MemoryBalanceRepo.balances); err != nil {
+		return nil, err
+	}
+	if err := readJSONFile(openingsPath, &r.MemoryBalanceRepo.openings); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *FileBalanceRepo) AdjustBalance(address, token string, delta *big.Int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.MemoryBalanceRepo.AdjustBalance(address, token, delta)
+
+	r.MemoryBalanceRepo.mutex.RLock()
+	defer r.MemoryBalanceRepo.mutex.RUnlock()
+	return writeJSONFile(r.path, r.MemoryBalanceRepo.balances)
+}
+
+func (r *FileBalanceRepo) StoreOpening(address string, opening entity.OpeningBalance) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.MemoryBalanceRepo.StoreOpening(address, opening)
+
+	r.MemoryBalanceRepo.mutex.RLock()
+	defer r.MemoryBalanceRepo.mutex.RUnlock()
+	return writeJSONFile(r.openingsPath, r.MemoryBalanceRepo.openings)
+}
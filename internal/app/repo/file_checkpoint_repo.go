@@ -0,0 +1,43 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/repository"
+	"sync"
+)
+
+var _ repository.CheckpointRepo = (*FileCheckpointRepo)(nil)
+
+// FileCheckpointRepo keeps checkpoints in memory and mirrors them to a JSON file.
+type FileCheckpointRepo struct {
+	path        string
+	checkpoints map[string]uint64
+	mutex       sync.RWMutex
+}
+
+func NewFileCheckpointRepo(path string) (*FileCheckpointRepo, error) {
+	r := &FileCheckpointRepo{
+		path:        path,
+		checkpoints: make(map[string]uint64),
+	}
+
+	if err := readJSONFile(path, &r.checkpoints); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *FileCheckpointRepo) StoreCheckpoint(key string, next uint64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.checkpoints[key] = next
+	return writeJSONFile(r.path, r.checkpoints)
+}
+
+func (r *FileCheckpointRepo) GetCheckpoint(key string) (uint64, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	next, ok := r.checkpoints[key]
+	return next, ok
+}
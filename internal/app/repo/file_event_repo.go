@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"encoding/json"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	_ repository.EventSubscriptionRepo = (*FileEventSubscriptionRepo)(nil)
+	_ repository.EventLogRepo          = (*FileEventLogRepo)(nil)
+)
+
+// FileEventSubscriptionRepo keeps event subscriptions in memory and mirrors
+// them to a JSON file.
+type FileEventSubscriptionRepo struct {
+	*MemoryEventSubscriptionRepo
+	path  string
+	mutex sync.Mutex
+}
+
+func NewFileEventSubscriptionRepo(path string) (*FileEventSubscriptionRepo, error) {
+	r := &FileEventSubscriptionRepo{
+		MemoryEventSubscriptionRepo: NewMemoryEventSubscriptionRepo(),
+		path:                        path,
+	}
+
+	var subscriptions []entity.EventSubscription
+	if err := readJSONFile(path, &subscriptions); err != nil {
+		return nil, err
+	}
+	for _, subscription := range subscriptions {
+		r.MemoryEventSubscriptionRepo.StoreEventSubscription(subscription)
+	}
+	return r, nil
+}
+
+func (r *FileEventSubscriptionRepo) StoreEventSubscription(subscription entity.EventSubscription) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.MemoryEventSubscriptionRepo.StoreEventSubscription(subscription)
+	return writeJSONFile(r.path, r.ListEventSubscriptions())
+}
+
+func (r *FileEventSubscriptionRepo) DeleteEventSubscription(id string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	found, _ := r.MemoryEventSubscriptionRepo.DeleteEventSubscription(id)
+	if !found {
+		return false, nil
+	}
+	return true, writeJSONFile(r.path, r.ListEventSubscriptions())
+}
+
+// FileEventLogRepo keeps event logs in memory and appends every new one to
+// a JSON lines file that is replayed on startup.
+type FileEventLogRepo struct {
+	*MemoryEventLogRepo
+	file  *os.File
+	mutex sync.Mutex
+}
+
+func NewFileEventLogRepo(path string) (*FileEventLogRepo, error) {
+	r := &FileEventLogRepo{
+		MemoryEventLogRepo: NewMemoryEventLogRepo(),
+	}
+
+	if err := r.load(path); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	r.file = file
+	return r, nil
+}
+
+func (r *FileEventLogRepo) load(path string) error {
+	return readJSONLines(path, func(line []byte) error {
+		var log entity.EventLog
+		if err := json.Unmarshal(line, &log); err != nil {
+			return err
+		}
+		r.MemoryEventLogRepo.StoreEventLog(log)
+		return nil
+	})
+}
+
+func (r *FileEventLogRepo) StoreEventLog(log entity.EventLog) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, _ := r.MemoryEventLogRepo.StoreEventLog(log)
+	if !stored {
+		return false, nil
+	}
+
+	line, err := json.Marshal(log)
+	if err != nil {
+		return true, fmt.Errorf("failed to encode event log: %w", err)
+	}
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		return true, fmt.Errorf("failed to append event log: %w", err)
+	}
+	return true, nil
+}
+
+func (r *FileEventLogRepo) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,49 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"sync"
+)
+
+var _ repository.HeldNotificationRepo = (*FileHeldNotificationRepo)(nil)
+
+// FileHeldNotificationRepo keeps held webhook calls in memory and mirrors
+// them to a JSON file.
+type FileHeldNotificationRepo struct {
+	*MemoryHeldNotificationRepo
+	path  string
+	mutex sync.Mutex
+}
+
+func NewFileHeldNotificationRepo(path string) (*FileHeldNotificationRepo, error) {
+	r := &FileHeldNotificationRepo{
+		MemoryHeldNotificationRepo: NewMemoryHeldNotificationRepo(),
+		path:                       path,
+	}
+
+	var notifications []entity.HeldNotification
+	if err := readJSONFile(path, &notifications); err != nil {
+		return nil, err
+	}
+	for _, notification := range notifications {
+		r.MemoryHeldNotificationRepo.StoreHeldNotification(notification)
+	}
+	return r, nil
+}
+
+func (r *FileHeldNotificationRepo) StoreHeldNotification(notification entity.HeldNotification) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.MemoryHeldNotificationRepo.StoreHeldNotification(notification)
+	return writeJSONFile(r.path, r.ListHeldNotifications())
+}
+
+func (r *FileHeldNotificationRepo) DeleteHeldNotification(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.MemoryHeldNotificationRepo.DeleteHeldNotification(id)
+	return writeJSONFile(r.path, r.ListHeldNotifications())
+}
@@ -0,0 +1,48 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"sync"
+)
+
+var _ repository.JobRepo = (*FileJobRepo)(nil)
+
+// FileJobRepo keeps backfill jobs in memory and mirrors them to a JSON file.
+type FileJobRepo struct {
+	*MemoryJobRepo
+	path  string
+	mutex sync.Mutex
+}
+
+func NewFileJobRepo(path string) (*FileJobRepo, error) {
+	r := &FileJobRepo{
+		MemoryJobRepo: NewMemoryJobRepo(),
+		path:          path,
+	}
+
+	var jobs []entity.BackfillJob
+	if err := readJSONFile(path, &jobs); err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		r.MemoryJobRepo.StoreJob(job)
+	}
+	return r, nil
+}
+
+func (r *FileJobRepo) StoreJob(job entity.BackfillJob) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.MemoryJobRepo.StoreJob(job)
+	return writeJSONFile(r.path, r.ListJobs())
+}
+
+func (r *FileJobRepo) DeleteJob(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.MemoryJobRepo.DeleteJob(id)
+	return writeJSONFile(r.path, r.ListJobs())
+}
@@ -0,0 +1,49 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"sync"
+)
+
+var _ repository.LabelRepo = (*FileLabelRepo)(nil)
+
+// FileLabelRepo keeps the address book in memory and mirrors it to a JSON file.
+type FileLabelRepo struct {
+	*MemoryLabelRepo
+	path  string
+	mutex sync.Mutex
+}
+
+func NewFileLabelRepo(path string) (*FileLabelRepo, error) {
+	r := &FileLabelRepo{
+		MemoryLabelRepo: NewMemoryLabelRepo(),
+		path:            path,
+	}
+
+	var labels []entity.AddressLabel
+	if err := readJSONFile(path, &labels); err != nil {
+		return nil, err
+	}
+	r.MemoryLabelRepo.StoreLabels(labels...)
+	return r, nil
+}
+
+func (r *FileLabelRepo) StoreLabels(labels ...entity.AddressLabel) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.MemoryLabelRepo.StoreLabels(labels...)
+	return writeJSONFile(r.path, r.ListLabels())
+}
+
+func (r *FileLabelRepo) DeleteLabel(address string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	found, _ := r.MemoryLabelRepo.DeleteLabel(address)
+	if !found {
+		return false, nil
+	}
+	return true, writeJSONFile(r.path, r.ListLabels())
+}
@@ -0,0 +1,204 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileReposSurviveReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	jobs, err := NewFileJobRepo(filepath.Join(dir, "jobs.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoints, err := NewFileCheckpointRepo(filepath.Join(dir, "checkpoints.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactions, err := NewFileTransactionStore(filepath.Join(dir, "transactions.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscriptions, err := NewFileTenantSubscriptionRepo(filepath.Join(dir, "subscriptions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels, err := NewFileLabelRepo(filepath.Join(dir, "labels.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := NewFileAlertRuleRepo(filepath.Join(dir, "rules.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerts, err := NewFileAlertRepo(filepath.Join(dir, "alerts.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	abis, err := NewFileABIRepo(filepath.Join(dir, "abis.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventSubscriptions, err := NewFileEventSubscriptionRepo(filepath.Join(dir, "events.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventLogs, err := NewFileEventLogRepo(filepath.Join(dir, "event_logs.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	held, err := NewFileHeldNotificationRepo(filepath.Join(dir, "held_webhooks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs.StoreJob(entity.BackfillJob{ID: "a", Status: entity.JobRunning})
+	jobs.StoreJob(entity.BackfillJob{ID: "b", Status: entity.JobPending})
+	jobs.DeleteJob("b")
+	checkpoints.StoreCheckpoint("job:a", 42)
+	transactions.StoreTransaction("0xabc", entity.Transaction{Hash: "0x1"})
+	transactions.StoreTransaction("0xabc", entity.Transaction{Hash: "0x1"})
+	transactions.Close()
+	subscriptions.StoreTenantSubscription(entity.Subscription{Tenant: "ops", Address: "0xABC", Label: "hot wallet"})
+	subscriptions.StoreTenantSubscription(entity.Subscription{Tenant: "risk", Address: "0xabc"})
+	subscriptions.StoreTenantSubscription(entity.Subscription{Tenant: "risk", Address: "0xdef", ENS: "vault.eth"})
+	subscriptions.DeleteTenantSubscription("risk", "0xDEF")
+	labels.StoreLabels(entity.AddressLabel{Address: "0xABC", Label: "Treasury"}, entity.AddressLabel{Address: "0xdef", Label: "Binance"})
+	labels.DeleteLabel("0xdef")
+	rules.StoreRule(entity.AlertRule{ID: "r1", Tenant: "ops", Expression: `value > 1 ether`})
+	rules.StoreRule(entity.AlertRule{ID: "r2", Tenant: "ops"})
+	rules.DeleteRule("r2")
+	alerts.StoreAlert(entity.Alert{ID: "r1:0x1:native", RuleID: "r1", Tenant: "ops"})
+	alerts.StoreAlert(entity.Alert{ID: "r1:0x1:native", RuleID: "r1", Tenant: "ops"})
+	alerts.Close()
+	abis.StoreABI(entity.ContractABI{Address: "0xABC", ABI: []byte(`[]`)})
+	abis.StoreABI(entity.ContractABI{Address: "0xdef", ABI: []byte(`[]`)})
+	abis.DeleteABI("0xdef")
+	eventSubscriptions.StoreEventSubscription(entity.EventSubscription{ID: "e1", Tenant: "ops", Event: "Deposit(address,uint256)"})
+	eventSubscriptions.StoreEventSubscription(entity.EventSubscription{ID: "e2", Tenant: "ops"})
+	eventSubscriptions.DeleteEventSubscription("e2")
+	eventLogs.StoreEventLog(entity.EventLog{ID: "0x1:0x0", SubscriptionID: "e1", BlockNumber: 2})
+	eventLogs.StoreEventLog(entity.EventLog{ID: "0x1:0x0", SubscriptionID: "e1", BlockNumber: 2})
+	eventLogs.StoreEventLog(entity.EventLog{ID: "0x0:0x0", SubscriptionID: "e1", BlockNumber: 1})
+	eventLogs.Close()
+	block := "0x64"
+	held.StoreHeldNotification(entity.HeldNotification{Subscription: entity.Subscription{Tenant: "risk", Address: "0xABC", Confirmations: 12}, Transaction: entity.Transaction{Hash: "0x1", BlockNumber: &block}})
+	held.StoreHeldNotification(entity.HeldNotification{Subscription: entity.Subscription{Tenant: "ops", Address: "0xabc"}, Transaction: entity.Transaction{Hash: "0x2"}})
+	held.DeleteHeldNotification("ops:0xabc:0x2")
+
+	jobs, _ = NewFileJobRepo(filepath.Join(dir, "jobs.json"))
+	checkpoints, _ = NewFileCheckpointRepo(filepath.Join(dir, "checkpoints.json"))
+	transactions, _ = NewFileTransactionStore(filepath.Join(dir, "transactions.jsonl"))
+	defer transactions.Close()
+	subscriptions, _ = NewFileTenantSubscriptionRepo(filepath.Join(dir, "subscriptions.json"))
+	labels, _ = NewFileLabelRepo(filepath.Join(dir, "labels.json"))
+	rules, _ = NewFileAlertRuleRepo(filepath.Join(dir, "rules.json"))
+	alerts, _ = NewFileAlertRepo(filepath.Join(dir, "alerts.jsonl"))
+	defer alerts.Close()
+	abis, _ = NewFileABIRepo(filepath.Join(dir, "abis.json"))
+	eventSubscriptions, _ = NewFileEventSubscriptionRepo(filepath.Join(dir, "events.json"))
+	eventLogs, _ = NewFileEventLogRepo(filepath.Join(dir, "event_logs.jsonl"))
+	defer eventLogs.Close()
+	held, _ = NewFileHeldNotificationRepo(filepath.Join(dir, "held_webhooks.json"))
+
+	if job, ok := jobs.GetJob("a"); !ok || job.Status != entity.JobRunning {
+		t.Errorf("GetJob(a) = %+v, %v", job, ok)
+	}
+	if _, ok := jobs.GetJob("b"); ok {
+		t.Error("deleted job should not be reloaded")
+	}
+	if next, ok := checkpoints.GetCheckpoint("job:a"); !ok || next != 42 {
+		t.Errorf("GetCheckpoint() = %d, %v", next, ok)
+	}
+	if txs := transactions.GetTransactions("0xabc"); len(txs) != 1 {
+		t.Errorf("GetTransactions() returned %d transactions, want 1", len(txs))
+	}
+	if sub, ok := subscriptions.GetTenantSubscription("ops", "0xabc"); !ok || sub.Label != "hot wallet" {
+		t.Errorf("GetTenantSubscription() = %+v, %v", sub, ok)
+	}
+	if subs := subscriptions.Subscribers("0xAbc"); len(subs) != 2 {
+		t.Errorf("Subscribers() returned %d subscriptions, want 2", len(subs))
+	}
+	if subs := subscriptions.AllTenantSubscriptions(); len(subs) != 2 {
+		t.Errorf("AllTenantSubscriptions() returned %d subscriptions, want the deleted one gone", len(subs))
+	}
+	if label, ok := labels.GetLabel("0xabc"); !ok || label.Label != "Treasury" {
+		t.Errorf("GetLabel() = %+v, %v", label, ok)
+	}
+	if _, ok := labels.GetLabel("0xdef"); ok {
+		t.Error("deleted label should not be reloaded")
+	}
+	if rule, ok := rules.GetRule("r1"); !ok || rule.Expression != `value > 1 ether` {
+		t.Errorf("GetRule() = %+v, %v", rule, ok)
+	}
+	if list := rules.ListRules(); len(list) != 1 {
+		t.Errorf("ListRules() returned %d rules, want 1", len(list))
+	}
+	if list := alerts.ListAlerts("ops"); len(list) != 1 {
+		t.Errorf("ListAlerts() returned %d alerts, want 1", len(list))
+	}
+	if abi, ok := abis.GetABI("0xabc"); !ok || string(abi.ABI) != `[]` {
+		t.Errorf("GetABI() = %+v, %v", abi, ok)
+	}
+	if _, ok := abis.GetABI("0xdef"); ok {
+		t.Error("deleted ABI should not be reloaded")
+	}
+	if sub, ok := eventSubscriptions.GetEventSubscription("e1"); !ok || sub.Event != "Deposit(address,uint256)" {
+		t.Errorf("GetEventSubscription() = %+v, %v", sub, ok)
+	}
+	if list := eventSubscriptions.ListEventSubscriptions(); len(list) != 1 {
+		t.Errorf("ListEventSubscriptions() returned %d subscriptions, want 1", len(list))
+	}
+	if logs := eventLogs.ListEventLogs("e1"); len(logs) != 2 || logs[0].BlockNumber != 1 {
+		t.Errorf("ListEventLogs() = %+v, want 2 logs in chain order", logs)
+	}
+	if list := held.ListHeldNotifications(); len(list) != 1 || list[0].Subscription.Confirmations != 12 || *list[0].Transaction.BlockNumber != "0x64" {
+		t.Errorf("ListHeldNotifications() = %+v, want the one not deleted", list)
+	}
+}
+
+func TestFileTransactionStoreRecoversTornLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transactions.jsonl")
+	transactions, err := NewFileTransactionStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactions.StoreTransaction("0xabc", entity.Transaction{Hash: "0x1"})
+	transactions.Close()
+
+	// A crash cut the second append short.
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"address":"0xabc","transaction":{"hash":"0x`)
+	file.Close()
+
+	transactions, err = NewFileTransactionStore(path)
+	if err != nil {
+		t.Fatalf("NewFileTransactionStore() error = %v", err)
+	}
+	if stored, err := transactions.StoreTransaction("0xabc", entity.Transaction{Hash: "0x2"}); !stored || err != nil {
+		t.Fatalf("StoreTransaction() = %v, %v", stored, err)
+	}
+	transactions.Close()
+
+	transactions, err = NewFileTransactionStore(path)
+	if err != nil {
+		t.Fatalf("NewFileTransactionStore() error = %v", err)
+	}
+	defer transactions.Close()
+	if txs := transactions.GetTransactions("0xabc"); len(txs) != 2 || txs[0].Hash != "0x1" || txs[1].Hash != "0x2" {
+		t.Errorf("unexpected transactions %+v", txs)
+	}
+
+	// A bad line followed by good ones is not a torn append.
+	corrupt := filepath.Join(t.TempDir(), "transactions.jsonl")
+	os.WriteFile(corrupt, []byte("{\"address\":\n{\"address\":\"0xabc\",\"transaction\":{\"hash\":\"0x1\"}}\n"), 0o644)
+	if _, err := NewFileTransactionStore(corrupt); err == nil {
+		t.Error("expected a corrupt line before the last one to fail")
+	}
+}
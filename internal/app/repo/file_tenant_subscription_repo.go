@@ -0,0 +1,52 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"sync"
+)
+
+var _ repository.TenantSubscriptionRepo = (*FileTenantSubscriptionRepo)(nil)
+
+// FileTenantSubscriptionRepo keeps tenant subscriptions in memory and
+// mirrors them to a JSON file.
+type FileTenantSubscriptionRepo struct {
+	*MemoryTenantSubscriptionRepo
+	path  string
+	mutex sync.Mutex
+}
+
+func NewFileTenantSubscriptionRepo(path string) (*FileTenantSubscriptionRepo, error) {
+	r := &FileTenantSubscriptionRepo{
+		MemoryTenantSubscriptionRepo: NewMemoryTenantSubscriptionRepo(),
+		path:                         path,
+	}
+
+	var subs []entity.Subscription
+	if err := readJSONFile(path, &subs); err != nil {
+		return nil, err
+	}
+	for _, sub := range subs {
+		r.MemoryTenantSubscriptionRepo.StoreTenantSubscription(sub)
+	}
+	return r, nil
+}
+
+func (r *FileTenantSubscriptionRepo) StoreTenantSubscription(sub entity.Subscription) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.MemoryTenantSubscriptionRepo.StoreTenantSubscription(sub)
+	return writeJSONFile(r.path, r.AllTenantSubscriptions())
+}
+
+func (r *FileTenantSubscriptionRepo) DeleteTenantSubscription(tenant, address string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	found, _ := r.MemoryTenantSubscriptionRepo.DeleteTenantSubscription(tenant, address)
+	if !found {
+		return false, nil
+	}
+	return true, writeJSONFile(r.path, r.AllTenantSubscriptions())
+}
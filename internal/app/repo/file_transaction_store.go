@@ -0,0 +1,81 @@
+package repo
+
+import (
+	"encoding/json"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var _ repository.TransactionStore = (*FileTransactionStore)(nil)
+
+type storedTransaction struct {
+	Address     string             `json:"address"`
+	Transaction entity.Transaction `json:"transaction"`
+}
+
+// FileTransactionStore keeps transactions in memory and appends every new
+// one, and every one mined again in another block, to a JSON lines file
+// that is replayed on startup.
+type FileTransactionStore struct {
+	*MemoryTransactionStore
+	file  *os.File
+	mutex sync.Mutex
+}
+
+func NewFileTransactionStore(path string) (*FileTransactionStore, error) {
+	s := &FileTransactionStore{
+		MemoryTransactionStore: NewMemoryTransactionStore(),
+	}
+
+	if err := s.load(path); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	s.file = file
+	return s, nil
+}
+
+func (s *FileTransactionStore) load(path string) error {
+	return readJSONLines(path, func(line []byte) error {
+		var record storedTransaction
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		s.MemoryTransactionStore.StoreTransaction(record.Address, record.Transaction)
+		return nil
+	})
+}
+
+func (s *FileTransactionStore) StoreTransaction(address string, tx entity.Transaction) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stored, _ := s.MemoryTransactionStore.StoreTransaction(address, tx)
+	if !stored {
+		return false, nil
+	}
+
+	line, err := json.Marshal(storedTransaction{Address: address, Transaction: tx})
+	if err != nil {
+		return true, fmt.Errorf("failed to encode transaction: %w", err)
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return true, fmt.Errorf("failed to append transaction: %w", err)
+	}
+	return true, nil
+}
+
+func (s *FileTransactionStore) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,80 @@
+package repo
+
+import (
+	"encoding/json"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var _ repository.TransferStore = (*FileTransferStore)(nil)
+
+type storedTransfer struct {
+	Address  string          `json:"address"`
+	Transfer entity.Transfer `json:"transfer"`
+}
+
+// FileTransferStore keeps transfers in memory and appends every new one to
+// a JSON lines file that is replayed on startup.
+type FileTransferStore struct {
+	*MemoryTransferStore
+	file  *os.File
+	mutex sync.Mutex
+}
+
+func NewFileTransferStore(path string) (*FileTransferStore, error) {
+	s := &FileTransferStore{
+		MemoryTransferStore: NewMemoryTransferStore(),
+	}
+
+	if err := s.load(path); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	s.file = file
+	return s, nil
+}
+
+func (s *FileTransferStore) load(path string) error {
+	return readJSONLines(path, func(line []byte) error {
+		var record storedTransfer
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		s.MemoryTransferStore.StoreTransfer(record.Address, record.Transfer)
+		return nil
+	})
+}
+
+func (s *FileTransferStore) StoreTransfer(address string, transfer entity.Transfer) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stored, _ := s.MemoryTransferStore.StoreTransfer(address, transfer)
+	if !stored {
+		return false, nil
+	}
+
+	line, err := json.Marshal(storedTransfer{Address: address, Transfer: transfer})
+	if err != nil {
+		return true, fmt.Errorf("failed to encode transfer: %w", err)
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return true, fmt.Errorf("failed to append transfer: %w", err)
+	}
+	return true, nil
+}
+
+func (s *FileTransferStore) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,65 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var _ repository.ABIRepo = (*MemoryABIRepo)(nil)
+
+type MemoryABIRepo struct {
+	abis  map[string]entity.ContractABI
+	mutex sync.RWMutex
+}
+
+func NewMemoryABIRepo() *MemoryABIRepo {
+	return &MemoryABIRepo{
+		abis: make(map[string]entity.ContractABI),
+	}
+}
+
+func (r *MemoryABIRepo) StoreABI(abi entity.ContractABI) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	abi.Address = strings.ToLower(abi.Address)
+	r.abis[abi.Address] = abi
+	return nil
+}
+
+func (r *MemoryABIRepo) GetABI(address string) (entity.ContractABI, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	abi, ok := r.abis[strings.ToLower(address)]
+	return abi, ok
+}
+
+func (r *MemoryABIRepo) DeleteABI(address string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	address = strings.ToLower(address)
+	if _, ok := r.abis[address]; !ok {
+		return false, nil
+	}
+	delete(r.abis, address)
+	return true, nil
+}
+
+func (r *MemoryABIRepo) ListABIs() []entity.ContractABI {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	abis := make([]entity.ContractABI, 0, len(r.abis))
+	for _, abi := range r.abis {
+		abis = append(abis, abi)
+	}
+	sort.Slice(abis, func(i, j int) bool {
+		return abis[i].Address < abis[j].Address
+	})
+	return abis
+}
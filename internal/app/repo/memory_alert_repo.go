@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"sort"
+	"sync"
+)
+
+var (
+	_ repository.AlertRuleRepo = (*MemoryAlertRuleRepo)(nil)
+	_ repository.AlertRepo     = (*MemoryAlertRepo)(nil)
+)
+
+type MemoryAlertRuleRepo struct {
+	rules map[string]entity.AlertRule
+	mutex sync.RWMutex
+}
+
+func NewMemoryAlertRuleRepo() *MemoryAlertRuleRepo {
+	return &MemoryAlertRuleRepo{
+		rules: make(map[string]entity.AlertRule),
+	}
+}
+
+func (r *MemoryAlertRuleRepo) StoreRule(rule entity.AlertRule) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.rules[rule.ID] = rule
+	return nil
+}
+
+func (r *MemoryAlertRuleRepo) GetRule(id string) (entity.AlertRule, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	rule, ok := r.rules[id]
+	return rule, ok
+}
+
+func (r *MemoryAlertRuleRepo) DeleteRule(id string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.rules[id]; !ok {
+		return false, nil
+	}
+	delete(r.rules, id)
+	return true, nil
+}
+
+func (r *MemoryAlertRuleRepo) ListRules() []entity.AlertRule {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	rules := make([]entity.AlertRule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if !rules[i].CreatedAt.Equal(rules[j].CreatedAt) {
+			return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	return rules
+}
+
+type MemoryAlertRepo struct {
+	alerts map[string][]entity.Alert
+	seen   map[string]bool
+	mutex  sync.RWMutex
+}
+
+func NewMemoryAlertRepo() *MemoryAlertRepo {
+	return &MemoryAlertRepo{
+		alerts: make(map[string][]entity.Alert),
+		seen:   make(map[string]bool),
+	}
+}
+
+func (r *MemoryAlertRepo) StoreAlert(alert entity.Alert) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.seen[alert.ID] {
+		return false, nil
+	}
+	r.seen[alert.ID] = true
+	r.alerts[alert.Tenant] = append(r.alerts[alert.Tenant], alert)
+	return true, nil
+}
+
+func (r *MemoryAlertRepo) ListAlerts(tenant string) []entity.Alert {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	alerts := r.alerts[tenant]
+	result := make([]entity.Alert, len(alerts))
+	copy(result, alerts)
+	return result
+}
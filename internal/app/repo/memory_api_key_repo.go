@@ -0,0 +1,68 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"sort"
+	"sync"
+)
+
+var _ repository.APIKeyRepo = (*MemoryAPIKeyRepo)(nil)
+
+type MemoryAPIKeyRepo struct {
+	keys   map[string]entity.APIKey
+	byHash map[string]string
+	mutex  sync.RWMutex
+}
+
+func NewMemoryAPIKeyRepo() *MemoryAPIKeyRepo {
+	return &MemoryAPIKeyRepo{
+		keys:   make(map[string]entity.APIKey),
+		byHash: make(map[string]string),
+	}
+}
+
+func (r *MemoryAPIKeyRepo) StoreKey(key entity.APIKey) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if old, ok := r.keys[key.ID]; ok && old.Hash != key.Hash {
+		delete(r.byHash, old.Hash)
+	}
+	r.keys[key.ID] = key
+	r.byHash[key.Hash] = key.ID
+	return nil
+}
+
+func (r *MemoryAPIKeyRepo) GetKey(id string) (entity.APIKey, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	key, ok := r.keys[id]
+	return key, ok
+}
+
+func (r *MemoryAPIKeyRepo) GetKeyByHash(hash string) (entity.APIKey, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	id, ok := r.byHash[hash]
+	if !ok {
+		return entity.APIKey{}, false
+	}
+	return r.keys[id], true
+}
+
+func (r *MemoryAPIKeyRepo) ListKeys() []entity.APIKey {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	keys := make([]entity.APIKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys
+}
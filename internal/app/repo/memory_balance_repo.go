@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var _ repository.BalanceRepo = (*MemoryBalanceRepo)(nil)
+
+type MemoryBalanceRepo struct {
+	balances map[string]map[string]*big.Int
+	openings map[string]map[string]entity.OpeningBalance
+	mutex    sync.RWMutex
+}
+
+func NewMemoryBalanceRepo() *MemoryBalanceRepo {
+	return &MemoryBalanceRepo{
+		balances: make(map[string]map[string]*big.Int),
+		openings: make(map[string]map[string]entity.OpeningBalance),
+	}
+}
+
+func (r *MemoryBalanceRepo) AdjustBalance(address, token string, delta *big.Int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	address = strings.ToLower(address)
+	tokens, ok := r.balances[address]
+	if !ok {
+		tokens = make(map[string]*big.Int)
+		r.balances[address] = tokens
+	}
+
+	token = normalizeToken(token)
+	if _, ok := tokens[token]; !ok {
+		tokens[token] = new(big.Int)
+	}
+	tokens[token].Add(tokens[token], delta)
+	return nil
+}
+
+// GetBalances lists ether first, followed by tokens ordered by contract address.
+func (r *MemoryBalanceRepo) GetBalances(address string) []entity.Balance {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	tokens := r.balances[strings.ToLower(address)]
+	balances := make([]entity.Balance, 0, len(tokens))
+	for token, amount := range tokens {
+		balances = append(balances, entity.Balance{Token: token, Amount: amount.String()})
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		if balances[i].Token == entity.NativeToken || balances[j].Token == entity.NativeToken {
+			return balances[i].Token == entity.NativeToken
+		}
+		return balances[i].Token < balances[j].Token
+	})
+	return balances
+}
+
+func (r *MemoryBalanceRepo) StoreOpening(address string, opening entity.OpeningBalance) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	address = strings.ToLower(address)
+	tokens, ok := r.openings[address]
+	if !ok {
+		tokens = make(map[string]entity.OpeningBalance)
+		r.openings[address] = tokens
+	}
+	opening.Token = normalizeToken(opening.Token)
+	tokens[opening.Token] = opening
+	return nil
+}
+
+// GetOpenings lists ether first, followed by tokens ordered by contract address.
+func (r *MemoryBalanceRepo) GetOpenings(address string) []entity.OpeningBalance {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	tokens := r.openings[strings.ToLower(address)]
+	openings := make([]entity.OpeningBalance, 0, len(tokens))
+	for _, opening := range tokens {
+		openings = append(openings, opening)
+	}
+	sort.Slice(openings, func(i, j int) bool {
+		if openings[i].Token == entity.NativeToken || openings[j].Token == entity.NativeToken {
+			return openings[i].Token == entity.NativeToken
+		}
+		return openings[i].Token < openings[j].Token
+	})
+	return openings
+}
+
+// normalizeToken lowercases token contract addresses and keeps ether as is.
+func normalizeToken(token string) string {
+	token = strings.ToLower(token)
+	if token == strings.ToLower(entity.NativeToken) {
+		return entity.NativeToken
+	}
+	return token
+}
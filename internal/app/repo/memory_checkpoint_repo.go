@@ -0,0 +1,35 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/repository"
+	"sync"
+)
+
+var _ repository.CheckpointRepo = (*MemoryCheckpointRepo)(nil)
+
+type MemoryCheckpointRepo struct {
+	checkpoints map[string]uint64
+	mutex       sync.RWMutex
+}
+
+func NewMemoryCheckpointRepo() *MemoryCheckpointRepo {
+	return &MemoryCheckpointRepo{
+		checkpoints: make(map[string]uint64),
+	}
+}
+
+func (r *MemoryCheckpointRepo) StoreCheckpoint(key string, next uint64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.checkpoints[key] = next
+	return nil
+}
+
+func (r *MemoryCheckpointRepo) GetCheckpoint(key string) (uint64, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	next, ok := r.checkpoints[key]
+	return next, ok
+}
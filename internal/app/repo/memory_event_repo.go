@@ -0,0 +1,109 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"sort"
+	"sync"
+)
+
+var (
+	_ repository.EventSubscriptionRepo = (*MemoryEventSubscriptionRepo)(nil)
+	_ repository.EventLogRepo          = (*MemoryEventLogRepo)(nil)
+)
+
+type MemoryEventSubscriptionRepo struct {
+	subscriptions map[string]entity.EventSubscription
+	mutex         sync.RWMutex
+}
+
+func NewMemoryEventSubscriptionRepo() *MemoryEventSubscriptionRepo {
+	return &MemoryEventSubscriptionRepo{
+		subscriptions: make(map[string]entity.EventSubscription),
+	}
+}
+
+func (r *MemoryEventSubscriptionRepo) StoreEventSubscription(subscription entity.EventSubscription) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.subscriptions[subscription.ID] = subscription
+	return nil
+}
+
+func (r *MemoryEventSubscriptionRepo) GetEventSubscription(id string) (entity.EventSubscription, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	subscription, ok := r.subscriptions[id]
+	return subscription, ok
+}
+
+func (r *MemoryEventSubscriptionRepo) DeleteEventSubscription(id string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.subscriptions[id]; !ok {
+		return false, nil
+	}
+	delete(r.subscriptions, id)
+	return true, nil
+}
+
+func (r *MemoryEventSubscriptionRepo) ListEventSubscriptions() []entity.EventSubscription {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	subscriptions := make([]entity.EventSubscription, 0, len(r.subscriptions))
+	for _, subscription := range r.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		if !subscriptions[i].CreatedAt.Equal(subscriptions[j].CreatedAt) {
+			return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+		}
+		return subscriptions[i].ID < subscriptions[j].ID
+	})
+	return subscriptions
+}
+
+type MemoryEventLogRepo struct {
+	logs  map[string][]entity.EventLog
+	seen  map[string]bool
+	mutex sync.RWMutex
+}
+
+func NewMemoryEventLogRepo() *MemoryEventLogRepo {
+	return &MemoryEventLogRepo{
+		logs: make(map[string][]entity.EventLog),
+		seen: make(map[string]bool),
+	}
+}
+
+func (r *MemoryEventLogRepo) StoreEventLog(log entity.EventLog) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := log.SubscriptionID + ":" + log.ID
+	if r.seen[key] {
+		return false, nil
+	}
+	r.seen[key] = true
+	r.logs[log.SubscriptionID] = append(r.logs[log.SubscriptionID], log)
+	return true, nil
+}
+
+func (r *MemoryEventLogRepo) ListEventLogs(subscriptionID string) []entity.EventLog {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	logs := make([]entity.EventLog, len(r.logs[subscriptionID]))
+	copy(logs, r.logs[subscriptionID])
+	sort.SliceStable(logs, func(i, j int) bool {
+		if logs[i].BlockNumber != logs[j].BlockNumber {
+			return logs[i].BlockNumber < logs[j].BlockNumber
+		}
+		return logs[i].LogIndex < logs[j].LogIndex
+	})
+	return logs
+}
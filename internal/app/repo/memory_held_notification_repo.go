@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"sort"
+	"sync"
+)
+
+var _ repository.HeldNotificationRepo = (*MemoryHeldNotificationRepo)(nil)
+
+type MemoryHeldNotificationRepo struct {
+	notifications map[string]entity.HeldNotification
+	mutex         sync.RWMutex
+}
+
+func NewMemoryHeldNotificationRepo() *MemoryHeldNotificationRepo {
+	return &MemoryHeldNotificationRepo{
+		notifications: make(map[string]entity.HeldNotification),
+	}
+}
+
+func (r *MemoryHeldNotificationRepo) StoreHeldNotification(notification entity.HeldNotification) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.notifications[notification.ID()] = notification
+	return nil
+}
+
+func (r *MemoryHeldNotificationRepo) DeleteHeldNotification(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.notifications, id)
+	return nil
+}
+
+func (r *MemoryHeldNotificationRepo) ListHeldNotifications() []entity.HeldNotification {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	notifications := make([]entity.HeldNotification, 0, len(r.notifications))
+	for _, notification := range r.notifications {
+		notifications = append(notifications, notification)
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		if !notifications[i].HeldAt.Equal(notifications[j].HeldAt) {
+			return notifications[i].HeldAt.Before(notifications[j].HeldAt)
+		}
+		return notifications[i].ID() < notifications[j].ID()
+	})
+	return notifications
+}
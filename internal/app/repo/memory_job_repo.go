@@ -0,0 +1,59 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"sort"
+	"sync"
+)
+
+var _ repository.JobRepo = (*MemoryJobRepo)(nil)
+
+type MemoryJobRepo struct {
+	jobs  map[string]entity.BackfillJob
+	mutex sync.RWMutex
+}
+
+func NewMemoryJobRepo() *MemoryJobRepo {
+	return &MemoryJobRepo{
+		jobs: make(map[string]entity.BackfillJob),
+	}
+}
+
+func (r *MemoryJobRepo) StoreJob(job entity.BackfillJob) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.jobs[job.ID] = job
+	return nil
+}
+
+func (r *MemoryJobRepo) GetJob(id string) (entity.BackfillJob, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	job, ok := r.jobs[id]
+	return job, ok
+}
+
+func (r *MemoryJobRepo) DeleteJob(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.jobs, id)
+	return nil
+}
+
+func (r *MemoryJobRepo) ListJobs() []entity.BackfillJob {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	jobs := make([]entity.BackfillJob, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs
+}
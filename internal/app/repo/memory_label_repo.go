@@ -0,0 +1,67 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var _ repository.LabelRepo = (*MemoryLabelRepo)(nil)
+
+type MemoryLabelRepo struct {
+	labels map[string]entity.AddressLabel
+	mutex  sync.RWMutex
+}
+
+func NewMemoryLabelRepo() *MemoryLabelRepo {
+	return &MemoryLabelRepo{
+		labels: make(map[string]entity.AddressLabel),
+	}
+}
+
+func (r *MemoryLabelRepo) StoreLabels(labels ...entity.AddressLabel) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, label := range labels {
+		label.Address = strings.ToLower(label.Address)
+		r.labels[label.Address] = label
+	}
+	return nil
+}
+
+func (r *MemoryLabelRepo) GetLabel(address string) (entity.AddressLabel, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	label, ok := r.labels[strings.ToLower(address)]
+	return label, ok
+}
+
+func (r *MemoryLabelRepo) DeleteLabel(address string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	address = strings.ToLower(address)
+	if _, ok := r.labels[address]; !ok {
+		return false, nil
+	}
+	delete(r.labels, address)
+	return true, nil
+}
+
+func (r *MemoryLabelRepo) ListLabels() []entity.AddressLabel {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	labels := make([]entity.AddressLabel, 0, len(r.labels))
+	for _, label := range r.labels {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Address < labels[j].Address
+	})
+	return labels
+}
@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var _ repository.PendingStore = (*MemoryPendingStore)(nil)
+
+type MemoryPendingStore struct {
+	transactions map[string]entity.PendingTransaction
+	mutex        sync.RWMutex
+}
+
+func NewMemoryPendingStore() *MemoryPendingStore {
+	return &MemoryPendingStore{
+		transactions: make(map[string]entity.PendingTransaction),
+	}
+}
+
+func (s *MemoryPendingStore) StorePending(tx entity.PendingTransaction) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.transactions[strings.ToLower(tx.Hash)] = tx
+	return nil
+}
+
+func (s *MemoryPendingStore) GetPending(hash string) (entity.PendingTransaction, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	tx, ok := s.transactions[strings.ToLower(hash)]
+	return tx, ok
+}
+
+func (s *MemoryPendingStore) DeletePending(hash string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.transactions, strings.ToLower(hash))
+	return nil
+}
+
+// ListPending returns the transactions in the order they were first seen.
+func (s *MemoryPendingStore) ListPending() []entity.PendingTransaction {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	transactions := make([]entity.PendingTransaction, 0, len(s.transactions))
+	for _, tx := range s.transactions {
+		transactions = append(transactions, tx)
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[i].FirstSeen.Before(transactions[j].FirstSeen)
+	})
+	return transactions
+}
@@ -0,0 +1,104 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var _ repository.TenantSubscriptionRepo = (*MemoryTenantSubscriptionRepo)(nil)
+
+type MemoryTenantSubscriptionRepo struct {
+	// subscriptions maps tenant and lowercase address to the subscription.
+	subscriptions map[string]map[string]entity.Subscription
+	mutex         sync.RWMutex
+}
+
+func NewMemoryTenantSubscriptionRepo() *MemoryTenantSubscriptionRepo {
+	return &MemoryTenantSubscriptionRepo{
+		subscriptions: make(map[string]map[string]entity.Subscription),
+	}
+}
+
+func (r *MemoryTenantSubscriptionRepo) StoreTenantSubscription(sub entity.Subscription) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.subscriptions[sub.Tenant] == nil {
+		r.subscriptions[sub.Tenant] = make(map[string]entity.Subscription)
+	}
+	r.subscriptions[sub.Tenant][strings.ToLower(sub.Address)] = sub
+	return nil
+}
+
+func (r *MemoryTenantSubscriptionRepo) GetTenantSubscription(tenant, address string) (entity.Subscription, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	sub, ok := r.subscriptions[tenant][strings.ToLower(address)]
+	return sub, ok
+}
+
+func (r *MemoryTenantSubscriptionRepo) ListTenantSubscriptions(tenant string) []entity.Subscription {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	subs := make([]entity.Subscription, 0, len(r.subscriptions[tenant]))
+	for _, sub := range r.subscriptions[tenant] {
+		subs = append(subs, sub)
+	}
+	sortSubscriptions(subs)
+	return subs
+}
+
+func (r *MemoryTenantSubscriptionRepo) DeleteTenantSubscription(tenant, address string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	address = strings.ToLower(address)
+	if _, ok := r.subscriptions[tenant][address]; !ok {
+		return false, nil
+	}
+	delete(r.subscriptions[tenant], address)
+	return true, nil
+}
+
+func (r *MemoryTenantSubscriptionRepo) Subscribers(address string) []entity.Subscription {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	address = strings.ToLower(address)
+	var subs []entity.Subscription
+	for _, byAddress := range r.subscriptions {
+		if sub, ok := byAddress[address]; ok {
+			subs = append(subs, sub)
+		}
+	}
+	sortSubscriptions(subs)
+	return subs
+}
+
+func (r *MemoryTenantSubscriptionRepo) AllTenantSubscriptions() []entity.Subscription {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var subs []entity.Subscription
+	for _, byAddress := range r.subscriptions {
+		for _, sub := range byAddress {
+			subs = append(subs, sub)
+		}
+	}
+	sortSubscriptions(subs)
+	return subs
+}
+
+func sortSubscriptions(subs []entity.Subscription) {
+	sort.Slice(subs, func(i, j int) bool {
+		if subs[i].Tenant != subs[j].Tenant {
+			return subs[i].Tenant < subs[j].Tenant
+		}
+		return subs[i].Address < subs[j].Address
+	})
+}
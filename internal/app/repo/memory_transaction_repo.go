@@ -2,6 +2,8 @@ package repo
 
 import (
 	"eth_parser/internal/domain/repository"
+	"sort"
+	"strings"
 	"sync"
 )
 
@@ -22,7 +24,7 @@ func (r *MemorySubscriptionRepo) StoreSubscription(address string) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	r.subscriptions[address] = true
+	r.subscriptions[strings.ToLower(address)] = true
 	return nil
 }
 
@@ -30,6 +32,18 @@ func (r *MemorySubscriptionRepo) IsSubscribed(address string) bool {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	_, ok := r.subscriptions[address]
+	_, ok := r.subscriptions[strings.ToLower(address)]
 	return ok
 }
+
+func (r *MemorySubscriptionRepo) Subscriptions() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	addresses := make([]string, 0, len(r.subscriptions))
+	for address := range r.subscriptions {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
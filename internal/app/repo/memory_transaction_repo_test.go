@@ -82,4 +82,7 @@ func TestMemorySubscriptionRepoConcurrent(t *testing.T) {
 			t.Errorf("Address %s should be subscribed", addr)
 		}
 	}
+	if got := repo.Subscriptions(); len(got) != len(addresses) {
+		t.Errorf("Subscriptions() = %v, want %v", got, addresses)
+	}
 }
@@ -0,0 +1,64 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"strings"
+	"sync"
+)
+
+var _ repository.TransactionStore = (*MemoryTransactionStore)(nil)
+
+type MemoryTransactionStore struct {
+	transactions map[string][]entity.Transaction
+	// seen holds the index of every stored transaction by address and hash.
+	seen  map[string]int
+	mutex sync.RWMutex
+}
+
+func NewMemoryTransactionStore() *MemoryTransactionStore {
+	return &MemoryTransactionStore{
+		transactions: make(map[string][]entity.Transaction),
+		seen:         make(map[string]int),
+	}
+}
+
+// StoreTransaction stores tx for address. A transaction stored before in
+// another block, i.e. mined again after a reorg, is replaced and reported
+// as stored too.
+func (s *MemoryTransactionStore) StoreTransaction(address string, tx entity.Transaction) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	address = strings.ToLower(address)
+	key := address + ":" + strings.ToLower(tx.Hash)
+	if i, ok := s.seen[key]; ok {
+		if !movedBlock(s.transactions[address][i], tx) {
+			return false, nil
+		}
+		s.transactions[address][i] = tx
+		return true, nil
+	}
+
+	s.seen[key] = len(s.transactions[address])
+	s.transactions[address] = append(s.transactions[address], tx)
+	return true, nil
+}
+
+// movedBlock reports whether tx was mined in another block than stored.
+func movedBlock(stored, tx entity.Transaction) bool {
+	if tx.BlockHash == nil {
+		return false
+	}
+	return stored.BlockHash == nil || !strings.EqualFold(*stored.BlockHash, *tx.BlockHash)
+}
+
+func (s *MemoryTransactionStore) GetTransactions(address string) []entity.Transaction {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	transactions := s.transactions[strings.ToLower(address)]
+	result := make([]entity.Transaction, len(transactions))
+	copy(result, transactions)
+	return result
+}
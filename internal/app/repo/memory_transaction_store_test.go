@@ -0,0 +1,30 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"testing"
+)
+
+func TestMemoryTransactionStore(t *testing.T) {
+	store := NewMemoryTransactionStore()
+
+	stored, err := store.StoreTransaction("0xABC", entity.Transaction{Hash: "0x1"})
+	if err != nil || !stored {
+		t.Fatalf("StoreTransaction() = %v, %v", stored, err)
+	}
+
+	// The same transaction is only kept once per address.
+	stored, _ = store.StoreTransaction("0xabc", entity.Transaction{Hash: "0x1"})
+	if stored {
+		t.Error("expected duplicate transaction to be ignored")
+	}
+
+	store.StoreTransaction("0xdef", entity.Transaction{Hash: "0x1"})
+
+	if got := store.GetTransactions("0xabc"); len(got) != 1 {
+		t.Errorf("GetTransactions() returned %d transactions, want 1", len(got))
+	}
+	if got := store.GetTransactions("0x999"); len(got) != 0 {
+		t.Errorf("GetTransactions() returned %d transactions, want 0", len(got))
+	}
+}
@@ -0,0 +1,48 @@
+package repo
+
+import (
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"strings"
+	"sync"
+)
+
+var _ repository.TransferStore = (*MemoryTransferStore)(nil)
+
+type MemoryTransferStore struct {
+	transfers map[string][]entity.Transfer
+	seen      map[string]bool
+	mutex     sync.RWMutex
+}
+
+func NewMemoryTransferStore() *MemoryTransferStore {
+	return &MemoryTransferStore{
+		transfers: make(map[string][]entity.Transfer),
+		seen:      make(map[string]bool),
+	}
+}
+
+func (s *MemoryTransferStore) StoreTransfer(address string, transfer entity.Transfer) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	address = strings.ToLower(address)
+	key := address + ":" + transfer.ID
+	if s.seen[key] {
+		return false, nil
+	}
+
+	s.seen[key] = true
+	s.transfers[address] = append(s.transfers[address], transfer)
+	return true, nil
+}
+
+func (s *MemoryTransferStore) GetTransfers(address string) []entity.Transfer {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	transfers := s.transfers[strings.ToLower(address)]
+	result := make([]entity.Transfer, len(transfers))
+	copy(result, transfers)
+	return result
+}
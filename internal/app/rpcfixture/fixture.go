@@ -0,0 +1,79 @@
+// Package rpcfixture records JSON-RPC exchanges to a directory and serves
+// them back, so tests can replay real node answers without network access.
+package rpcfixture
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Fixture is one recorded request and the node's answer to it. It is
+// stored as <method>-<hash of params>.json in the fixtures directory.
+type Fixture struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+}
+
+// fileName identifies a request by its method and params. Params are
+// re-encoded first so that key order and whitespace do not matter.
+func fileName(method string, params json.RawMessage) (string, error) {
+	canonical, err := canonicalize(params)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return fmt.Sprintf("%s-%s.json", method, hex.EncodeToString(sum[:8])), nil
+}
+
+func canonicalize(params json.RawMessage) ([]byte, error) {
+	if len(bytes.TrimSpace(params)) == 0 {
+		return []byte("[]"), nil
+	}
+
+	var value any
+	if err := json.Unmarshal(params, &value); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	if value == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(value)
+}
+
+// decodeRequests reads a single request or a batch.
+func decodeRequests(body []byte) ([]request, bool, error) {
+	body = bytes.TrimSpace(body)
+	if strings.HasPrefix(string(body), "[") {
+		var batch []request
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, true, fmt.Errorf("invalid batch: %w", err)
+		}
+		return batch, true, nil
+	}
+
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, false, fmt.Errorf("invalid request: %w", err)
+	}
+	return []request{req}, false, nil
+}
@@ -0,0 +1,130 @@
+package rpcfixture
+
+import (
+	"bytes"
+	"encoding/json"
+	httpclient "eth_parser/internal/domain/http_client"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var _ httpclient.HTTPClient = (*Recorder)(nil)
+
+// Recorder passes requests on to a real client and writes every exchange
+// to dir. Recording the same request again replaces the fixture.
+type Recorder struct {
+	client httpclient.HTTPClient
+	dir    string
+
+	mutex sync.Mutex
+}
+
+func NewRecorder(client httpclient.HTTPClient, dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create fixtures directory: %w", err)
+	}
+	return &Recorder{
+		client: client,
+		dir:    dir,
+	}, nil
+}
+
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	// Failed HTTP exchanges are not JSON-RPC answers worth replaying.
+	if resp.StatusCode == http.StatusOK {
+		if err := r.record(body, respBody); err != nil {
+			log.Println(fmt.Errorf("failed to record fixture: %w", err))
+		}
+	}
+	return resp, nil
+}
+
+func (r *Recorder) record(body, respBody []byte) error {
+	requests, batch, err := decodeRequests(body)
+	if err != nil {
+		return err
+	}
+
+	var responses []response
+	if batch {
+		err = json.Unmarshal(respBody, &responses)
+	} else {
+		var resp response
+		err = json.Unmarshal(respBody, &resp)
+		responses = []response{resp}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+
+	byID := make(map[string]response, len(responses))
+	for _, resp := range responses {
+		byID[string(resp.ID)] = resp
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, req := range requests {
+		resp, ok := byID[string(req.ID)]
+		if !ok {
+			continue
+		}
+		if err := r.write(Fixture{Method: req.Method, Params: req.Params, Result: resp.Result, Error: resp.Error}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Recorder) write(fixture Fixture) error {
+	name, err := fileName(fixture.Method, fixture.Params)
+	if err != nil {
+		return err
+	}
+	if fixture.Params, err = canonicalize(fixture.Params); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+
+	path := filepath.Join(r.dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package rpcfixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	httpclient "eth_parser/internal/domain/http_client"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+var _ httpclient.HTTPClient = (*Replayer)(nil)
+
+// ErrNoFixture is returned for a request that was never recorded.
+var ErrNoFixture = errors.New("no fixture recorded")
+
+// Replayer answers requests from the fixtures in dir and never touches the
+// network. Response IDs are taken from the request being replayed.
+type Replayer struct {
+	dir string
+}
+
+func NewReplayer(dir string) (*Replayer, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fixtures directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	return &Replayer{
+		dir: dir,
+	}, nil
+}
+
+func (r *Replayer) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	requests, batch, err := decodeRequests(body)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]response, len(requests))
+	for i, rpcReq := range requests {
+		fixture, err := r.lookup(rpcReq.Method, rpcReq.Params)
+		if err != nil {
+			return nil, err
+		}
+		responses[i] = response{JSONRPC: "2.0", ID: rpcReq.ID, Result: fixture.Result, Error: fixture.Error}
+	}
+
+	var respBody []byte
+	if batch {
+		respBody, err = json.Marshal(responses)
+	} else {
+		respBody, err = json.Marshal(responses[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(respBody)),
+		Request:    req,
+	}, nil
+}
+
+func (r *Replayer) lookup(method string, params json.RawMessage) (Fixture, error) {
+	name, err := fileName(method, params)
+	if err != nil {
+		return Fixture{}, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(r.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return Fixture{}, fmt.Errorf("%w for %s %s", ErrNoFixture, method, params)
+	}
+	if err != nil {
+		return Fixture{}, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return Fixture{}, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	if fixture.Result == nil && fixture.Error == nil {
+		// A recorded null result, e.g. for an unknown transaction.
+		fixture.Result = json.RawMessage("null")
+	}
+	return fixture, nil
+}
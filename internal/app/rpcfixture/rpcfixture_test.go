@@ -0,0 +1,112 @@
+package rpcfixture
+
+import (
+	"context"
+	"errors"
+	"eth_parser/internal/app/jsonrpc"
+	"eth_parser/internal/app/parser"
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/app/simnode"
+	"math/big"
+	"testing"
+)
+
+const (
+	holder = "0x1111111111111111111111111111111111111111"
+	other  = "0x2222222222222222222222222222222222222222"
+	token  = "0x3333333333333333333333333333333333333333"
+)
+
+func newNode() *simnode.Node {
+	chain := simnode.NewChain(simnode.DefaultConfig())
+	chain.Mine(simnode.TxSpec{From: other, To: token, Logs: []simnode.LogSpec{simnode.TokenTransfer(token, other, holder, big.NewInt(7))}})
+	chain.Mine()
+	chain.Mine(simnode.TxSpec{From: holder, To: token, Logs: []simnode.LogSpec{simnode.TokenTransfer(token, holder, other, big.NewInt(2))}})
+	return simnode.NewNode(chain, 1)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	node := newNode()
+
+	recorder, err := NewRecorder(node, dir)
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	recorded := parser.NewEthereumParser(recorder, repo.NewMemoryTransactionRepo())
+	if _, err := recorded.ScanRange(context.Background(), []string{holder}, 0, 3); err != nil {
+		t.Fatalf("ScanRange() error = %v", err)
+	}
+	calls := node.Calls("eth_getLogs")
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatalf("NewReplayer() error = %v", err)
+	}
+	store := repo.NewMemoryTransactionStore()
+	replayed := parser.NewEthereumParser(replayer, repo.NewMemoryTransactionRepo(), parser.WithTransactionStore(store))
+	found, err := replayed.ScanRange(context.Background(), []string{holder}, 0, 3)
+	if err != nil {
+		t.Fatalf("ScanRange() error = %v", err)
+	}
+
+	if found != 2 {
+		t.Errorf("expected 2 logs, got %d", found)
+	}
+	if txs := store.GetTransactions(holder); len(txs) != 2 {
+		t.Errorf("expected 2 transactions, got %d", len(txs))
+	}
+	if node.Calls("eth_getLogs") != calls {
+		t.Error("replaying reached the node")
+	}
+
+	// Another range was never recorded.
+	if _, err := replayed.ScanRange(context.Background(), []string{holder}, 0, 2); !errors.Is(err, ErrNoFixture) {
+		t.Errorf("expected ErrNoFixture, got %v", err)
+	}
+}
+
+func TestReplayBatch(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(newNode(), dir)
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+
+	var number, balance string
+	batch := []jsonrpc.BatchElem{
+		{Method: "eth_blockNumber", Result: &number},
+		{Method: "eth_getBalance", Params: []any{holder, "latest"}, Result: &balance},
+		{Method: "eth_getTransactionByHash", Params: []any{"0xunknown"}},
+		{Method: "txpool_content"},
+	}
+	client := jsonrpc.NewClient(jsonrpc.NewHTTPTransport(recorder, "http://node"))
+	if err := client.BatchCall(context.Background(), batch); err != nil {
+		t.Fatalf("BatchCall() error = %v", err)
+	}
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatalf("NewReplayer() error = %v", err)
+	}
+	client = jsonrpc.NewClient(jsonrpc.NewHTTPTransport(replayer, "http://node"))
+
+	// Requests match one by one, whether sent alone or batched.
+	var replayedNumber string
+	if err := client.Call(context.Background(), "eth_blockNumber", nil, &replayedNumber); err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if replayedNumber != number {
+		t.Errorf("expected %s, got %s", number, replayedNumber)
+	}
+
+	var tx *struct{}
+	if err := client.Call(context.Background(), "eth_getTransactionByHash", []any{"0xunknown"}, &tx); err != nil || tx != nil {
+		t.Errorf("expected a null transaction, got %v, %v", tx, err)
+	}
+
+	// Node errors are recorded as well.
+	if err := client.Call(context.Background(), "txpool_content", nil, nil); err == nil {
+		t.Error("expected the recorded error")
+	}
+}
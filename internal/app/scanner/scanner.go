@@ -0,0 +1,117 @@
+package scanner
+
+import (
+	"context"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"fmt"
+	"log"
+	"time"
+)
+
+const checkpointKey = "scanner"
+
+// BlockSource reads blocks from the node.
+type BlockSource interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+	BlockByNumber(ctx context.Context, number uint64) (*entity.Block, error)
+}
+
+// BlockHandler is called for every new block, in order. A block is handed
+// out again when a handler fails on it, so handlers must be idempotent.
+type BlockHandler interface {
+	HandleBlock(ctx context.Context, block *entity.Block) error
+}
+
+// Scanner follows the head of the chain and hands every new block to its
+// handlers. The last handled block is checkpointed so a restart continues
+// where it stopped.
+type Scanner struct {
+	source      BlockSource
+	checkpoints repository.CheckpointRepo
+	interval    time.Duration
+	handlers    []BlockHandler
+	trigger     chan struct{}
+}
+
+func NewScanner(source BlockSource, checkpoints repository.CheckpointRepo, interval time.Duration) *Scanner {
+	return &Scanner{
+		source:      source,
+		checkpoints: checkpoints,
+		interval:    interval,
+		trigger:     make(chan struct{}, 1),
+	}
+}
+
+// AddHandler registers h. Handlers must be added before Run.
+func (s *Scanner) AddHandler(h BlockHandler) {
+	s.handlers = append(s.handlers, h)
+}
+
+// Trigger makes Run poll right away instead of waiting for the next tick,
+// e.g. when a new head was announced over a subscription.
+func (s *Scanner) Trigger() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run polls for new blocks until ctx is cancelled.
+func (s *Scanner) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Poll(ctx); err != nil && ctx.Err() == nil {
+			log.Println(fmt.Errorf("scanner: %w", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.trigger:
+		}
+	}
+}
+
+// Poll handles every block between the last handled one and the current head.
+func (s *Scanner) Poll(ctx context.Context) error {
+	latest, err := s.source.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+
+	next, ok := s.checkpoints.GetCheckpoint(checkpointKey)
+	if !ok {
+		// Start following from the current head on first run.
+		next = latest
+	}
+
+	for ; next <= latest; next++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		block, err := s.source.BlockByNumber(ctx, next)
+		if err != nil {
+			return err
+		}
+		if block == nil {
+			// The node has not caught up with the number it reported yet.
+			return nil
+		}
+
+		for _, h := range s.handlers {
+			if err := h.HandleBlock(ctx, block); err != nil {
+				return fmt.Errorf("failed to handle block %d: %w", block.Number, err)
+			}
+		}
+
+		if err := s.checkpoints.StoreCheckpoint(checkpointKey, next+1); err != nil {
+			return fmt.Errorf("failed to store checkpoint: %w", err)
+		}
+	}
+	return nil
+}
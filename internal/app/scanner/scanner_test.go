@@ -0,0 +1,115 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/domain/entity"
+	"testing"
+	"time"
+)
+
+type mockBlockSource struct {
+	latest uint64
+}
+
+func (m *mockBlockSource) BlockNumber(ctx context.Context) (uint64, error) {
+	return m.latest, nil
+}
+
+func (m *mockBlockSource) BlockByNumber(ctx context.Context, number uint64) (*entity.Block, error) {
+	if number > m.latest {
+		return nil, nil
+	}
+	return &entity.Block{Number: number}, nil
+}
+
+type recordingHandler struct {
+	blocks []uint64
+	failAt uint64
+}
+
+func (h *recordingHandler) HandleBlock(ctx context.Context, block *entity.Block) error {
+	if block.Number == h.failAt {
+		h.failAt = 0
+		return errors.New("temporary failure")
+	}
+	h.blocks = append(h.blocks, block.Number)
+	return nil
+}
+
+func TestScannerPoll(t *testing.T) {
+	source := &mockBlockSource{latest: 100}
+	checkpoints := repo.NewMemoryCheckpointRepo()
+	handler := &recordingHandler{failAt: 103}
+
+	s := NewScanner(source, checkpoints, time.Second)
+	s.AddHandler(handler)
+
+	// The first poll starts at the current head.
+	if err := s.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+
+	source.latest = 104
+	if err := s.Poll(context.Background()); err == nil {
+		t.Fatal("expected handler failure to be reported")
+	}
+
+	// The failed block is handed out again.
+	if err := s.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+
+	want := []uint64{100, 101, 102, 103, 104}
+	if len(handler.blocks) != len(want) {
+		t.Fatalf("handled blocks %v, want %v", handler.blocks, want)
+	}
+	for i := range want {
+		if handler.blocks[i] != want[i] {
+			t.Fatalf("handled blocks %v, want %v", handler.blocks, want)
+		}
+	}
+
+	if next, _ := checkpoints.GetCheckpoint(checkpointKey); next != 105 {
+		t.Errorf("checkpoint = %d, want 105", next)
+	}
+}
+
+func TestScannerTrigger(t *testing.T) {
+	source := &mockBlockSource{latest: 100}
+	checkpoints := repo.NewMemoryCheckpointRepo()
+
+	// The interval is long enough that only Trigger can cause a second poll.
+	s := NewScanner(source, checkpoints, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	for {
+		if next, _ := checkpoints.GetCheckpoint(checkpointKey); next == 101 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	source.latest = 101
+	s.Trigger()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if next, _ := checkpoints.GetCheckpoint(checkpointKey); next == 102 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Trigger() did not wake up Run")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
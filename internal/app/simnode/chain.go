@@ -0,0 +1,499 @@
+package simnode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"eth_parser/internal/app/txcodec"
+	"eth_parser/internal/crypto"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/utils"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// TransferTopic is the topic of the ERC-20 Transfer(address,address,uint256) event.
+const TransferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+const (
+	defaultGasUsed  = 21000
+	defaultGasPrice = 1_000_000_000
+	// Blob transactions carry one blob, priced at the minimum blob gas price.
+	blobGasPerBlob = 131072
+	blobGasPrice   = 1
+)
+
+type Config struct {
+	ChainID uint64
+	// GenesisTime is the timestamp of block 0; every later block is BlockTime seconds apart.
+	GenesisTime uint64
+	BlockTime   uint64
+	// SafeDepth and FinalizedDepth place the safe and finalized tags below the head.
+	SafeDepth      uint64
+	FinalizedDepth uint64
+	// Accounts holds the ether balances at genesis.
+	Accounts map[string]*big.Int
+	// Keys sign the transactions of their accounts, see Key. Transactions
+	// of other senders carry signatures that recover to another address.
+	Keys []*big.Int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ChainID:        1337,
+		GenesisTime:    1_700_000_000,
+		BlockTime:      12,
+		SafeDepth:      32,
+		FinalizedDepth: 64,
+	}
+}
+
+// TxSpec describes a transaction to mine. Zero gas fields take defaults.
+type TxSpec struct {
+	Type  entity.TxType
+	From  string
+	To    string
+	Value *big.Int
+	Input string
+	Logs  []LogSpec
+	// Failed transactions pay their fee but move no value and emit no logs.
+	Failed   bool
+	GasUsed  uint64
+	GasPrice *big.Int
+}
+
+type LogSpec struct {
+	Address string
+	Topics  []string
+	Data    string
+}
+
+// TokenTransfer is the log an ERC-20 token emits when moving amount.
+func TokenTransfer(token, from, to string, amount *big.Int) LogSpec {
+	return LogSpec{
+		Address: token,
+		Topics:  []string{TransferTopic, utils.AddressToHex(from), utils.AddressToHex(to)},
+		Data:    "0x" + fmt.Sprintf("%064x", amount),
+	}
+}
+
+type Block struct {
+	Number       uint64
+	Hash         string
+	ParentHash   string
+	Timestamp    uint64
+	Transactions []*Tx
+}
+
+type Tx struct {
+	Hash        string
+	BlockNumber uint64
+	BlockHash   string
+	Index       uint64
+	Type        entity.TxType
+	ChainID     uint64
+	From        string
+	To          string
+	Value       *big.Int
+	Input       string
+	Nonce       uint64
+	// R, S and Parity are the signature. Senders without a key get stand-in
+	// values that change with every reorg.
+	R        *big.Int
+	S        *big.Int
+	Parity   byte
+	GasPrice *big.Int
+	GasUsed  uint64
+	Failed   bool
+	Logs     []*Log
+}
+
+// BlobGasUsed is the blob gas paid for on top of the execution gas.
+func (tx *Tx) BlobGasUsed() uint64 {
+	if tx.Type != entity.BlobTxType {
+		return 0
+	}
+	return blobGasPerBlob
+}
+
+type Log struct {
+	Address     string
+	Topics      []string
+	Data        string
+	BlockNumber uint64
+	BlockHash   string
+	TxHash      string
+	TxIndex     uint64
+	Index       uint64
+}
+
+// Chain is a deterministic chain: the same sequence of Mine and Reorg calls
+// always yields the same hashes.
+type Chain struct {
+	cfg Config
+
+	keys map[string]*big.Int
+
+	mutex  sync.RWMutex
+	blocks []*Block
+	txs    map[string]*Tx
+	// forks makes blocks mined after a reorg differ from the ones they replace.
+	forks uint64
+}
+
+func NewChain(cfg Config) *Chain {
+	c := &Chain{
+		cfg:  cfg,
+		keys: make(map[string]*big.Int),
+		txs:  make(map[string]*Tx),
+	}
+	for _, key := range cfg.Keys {
+		c.keys[crypto.AddressOf(key)] = key
+	}
+	c.blocks = []*Block{{
+		Number:     0,
+		Hash:       hash("block", "genesis", fmt.Sprint(cfg.ChainID)),
+		ParentHash: "0x" + strings.Repeat("0", 64),
+		Timestamp:  cfg.GenesisTime,
+	}}
+	return c
+}
+
+func (c *Chain) Head() uint64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return uint64(len(c.blocks) - 1)
+}
+
+// Mine appends a block holding txs and returns it.
+func (c *Chain) Mine(txs ...TxSpec) *Block {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.mine(txs)
+}
+
+func (c *Chain) mine(specs []TxSpec) *Block {
+	parent := c.blocks[len(c.blocks)-1]
+	number := parent.Number + 1
+
+	nonces := make(map[string]uint64)
+	block := &Block{
+		Number:     number,
+		ParentHash: parent.Hash,
+		Timestamp:  c.cfg.GenesisTime + number*c.cfg.BlockTime,
+	}
+
+	var txHashes []string
+	for i, spec := range specs {
+		from := strings.ToLower(spec.From)
+		if _, ok := nonces[from]; !ok {
+			nonces[from] = c.nonce(from, parent.Number)
+		}
+
+		tx := &Tx{
+			BlockNumber: number,
+			Index:       uint64(i),
+			Type:        spec.Type,
+			ChainID:     c.cfg.ChainID,
+			From:        from,
+			To:          strings.ToLower(spec.To),
+			Value:       orZero(spec.Value),
+			Input:       spec.Input,
+			Nonce:       nonces[from],
+			GasPrice:    spec.GasPrice,
+			GasUsed:     spec.GasUsed,
+			Failed:      spec.Failed,
+		}
+		nonces[from]++
+		if tx.Input == "" {
+			tx.Input = "0x"
+		}
+		if tx.GasPrice == nil {
+			tx.GasPrice = big.NewInt(defaultGasPrice)
+		}
+		if tx.GasUsed == 0 {
+			tx.GasUsed = defaultGasUsed
+		}
+		tx.R = signature("r", tx.From, fmt.Sprint(tx.Nonce), fmt.Sprint(c.forks))
+		tx.S = signature("s", tx.From, fmt.Sprint(tx.Nonce), fmt.Sprint(c.forks))
+		if key, ok := c.keys[tx.From]; ok {
+			sign(tx, key)
+		}
+		t := txJSON(tx)
+		if txHash, err := txcodec.Hash(&t); err == nil {
+			tx.Hash = txHash
+		} else {
+			// Specs with malformed addresses or input have no encoding.
+			tx.Hash = hash("tx", fmt.Sprint(c.cfg.ChainID), tx.From, fmt.Sprint(tx.Nonce), tx.To, tx.Value.String(), tx.Input, fmt.Sprint(c.forks))
+		}
+		txHashes = append(txHashes, tx.Hash)
+		block.Transactions = append(block.Transactions, tx)
+	}
+
+	block.Hash = hash("block", parent.Hash, fmt.Sprint(number), fmt.Sprint(c.forks), strings.Join(txHashes, ","))
+
+	var logIndex uint64
+	for i, tx := range block.Transactions {
+		tx.BlockHash = block.Hash
+		if !tx.Failed {
+			for _, spec := range specs[i].Logs {
+				topics := make([]string, len(spec.Topics))
+				for j, topic := range spec.Topics {
+					topics[j] = strings.ToLower(topic)
+				}
+				data := spec.Data
+				if data == "" {
+					data = "0x"
+				}
+				tx.Logs = append(tx.Logs, &Log{
+					Address:     strings.ToLower(spec.Address),
+					Topics:      topics,
+					Data:        data,
+					BlockNumber: number,
+					BlockHash:   block.Hash,
+					TxHash:      tx.Hash,
+					TxIndex:     tx.Index,
+					Index:       logIndex,
+				})
+				logIndex++
+			}
+		}
+		c.txs[tx.Hash] = tx
+	}
+
+	c.blocks = append(c.blocks, block)
+	return block
+}
+
+// Reorg drops the last depth blocks and mines one replacement block per
+// entry of replacements. The replacements get new hashes even when they
+// hold the same transactions.
+func (c *Chain) Reorg(depth int, replacements ...[]TxSpec) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if depth <= 0 || depth >= len(c.blocks) {
+		return fmt.Errorf("invalid reorg depth %d at head %d", depth, len(c.blocks)-1)
+	}
+
+	for _, block := range c.blocks[len(c.blocks)-depth:] {
+		for _, tx := range block.Transactions {
+			delete(c.txs, tx.Hash)
+		}
+	}
+	c.blocks = c.blocks[:len(c.blocks)-depth]
+	c.forks++
+
+	for _, txs := range replacements {
+		c.mine(txs)
+	}
+	return nil
+}
+
+// BlockByNumber returns the block at number on the canonical chain.
+func (c *Chain) BlockByNumber(number uint64) (*Block, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if number >= uint64(len(c.blocks)) {
+		return nil, false
+	}
+	return c.blocks[number], true
+}
+
+func (c *Chain) BlockByHash(blockHash string) (*Block, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	for _, block := range c.blocks {
+		if strings.EqualFold(block.Hash, blockHash) {
+			return block, true
+		}
+	}
+	return nil, false
+}
+
+func (c *Chain) Transaction(txHash string) (*Tx, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	tx, ok := c.txs[strings.ToLower(txHash)]
+	return tx, ok
+}
+
+// BalanceAt replays the chain up to block to compute the ether balance of address.
+func (c *Chain) BalanceAt(address string, block uint64) *big.Int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	address = strings.ToLower(address)
+	balance := new(big.Int)
+	for account, amount := range c.cfg.Accounts {
+		if strings.EqualFold(account, address) {
+			balance.Add(balance, amount)
+		}
+	}
+
+	for _, b := range c.blocks[:min(block+1, uint64(len(c.blocks)))] {
+		for _, tx := range b.Transactions {
+			if tx.From == address {
+				fee := new(big.Int).Mul(tx.GasPrice, new(big.Int).SetUint64(tx.GasUsed))
+				fee.Add(fee, new(big.Int).SetUint64(tx.BlobGasUsed()*blobGasPrice))
+				balance.Sub(balance, fee)
+				if !tx.Failed {
+					balance.Sub(balance, tx.Value)
+				}
+			}
+			if tx.To == address && !tx.Failed {
+				balance.Add(balance, tx.Value)
+			}
+		}
+	}
+	return balance
+}
+
+// TokenBalanceAt sums the Transfer logs of token up to block.
+func (c *Chain) TokenBalanceAt(token, address string, block uint64) *big.Int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	token = strings.ToLower(token)
+	topic := utils.AddressToHex(address)
+	balance := new(big.Int)
+	for _, b := range c.blocks[:min(block+1, uint64(len(c.blocks)))] {
+		for _, tx := range b.Transactions {
+			for _, l := range tx.Logs {
+				if l.Address != token || len(l.Topics) != 3 || l.Topics[0] != TransferTopic {
+					continue
+				}
+				amount, err := utils.HexToBig(l.Data)
+				if err != nil {
+					continue
+				}
+				if l.Topics[1] == topic {
+					balance.Sub(balance, amount)
+				}
+				if l.Topics[2] == topic {
+					balance.Add(balance, amount)
+				}
+			}
+		}
+	}
+	return balance
+}
+
+// NonceAt is the number of transactions sent by address up to block.
+func (c *Chain) NonceAt(address string, block uint64) uint64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.nonce(strings.ToLower(address), block)
+}
+
+func (c *Chain) nonce(address string, block uint64) uint64 {
+	var nonce uint64
+	for _, b := range c.blocks[:min(block+1, uint64(len(c.blocks)))] {
+		for _, tx := range b.Transactions {
+			if tx.From == address {
+				nonce++
+			}
+		}
+	}
+	return nonce
+}
+
+// Logs returns the logs of blocks [from, to] that match filter.
+func (c *Chain) Logs(from, to uint64, filter LogFilter) []*Log {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	var logs []*Log
+	for number := from; number <= to && number < uint64(len(c.blocks)); number++ {
+		logs = append(logs, c.blockLogs(c.blocks[number], filter)...)
+	}
+	return logs
+}
+
+func (c *Chain) blockLogs(block *Block, filter LogFilter) []*Log {
+	var logs []*Log
+	for _, tx := range block.Transactions {
+		for _, l := range tx.Logs {
+			if filter.Matches(l) {
+				logs = append(logs, l)
+			}
+		}
+	}
+	return logs
+}
+
+// LogFilter has eth_getLogs semantics: a log matches when its address is
+// one of Addresses (or Addresses is empty) and every topic position
+// matches one of its alternatives. An empty position is a wildcard.
+type LogFilter struct {
+	Addresses []string
+	Topics    [][]string
+}
+
+func (f LogFilter) Matches(l *Log) bool {
+	if len(f.Addresses) > 0 && !containsFold(f.Addresses, l.Address) {
+		return false
+	}
+	if len(f.Topics) > len(l.Topics) {
+		return false
+	}
+	for i, alternatives := range f.Topics {
+		if len(alternatives) > 0 && !containsFold(alternatives, l.Topics[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func hash(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return "0x" + hex.EncodeToString(sum[:])
+}
+
+// sign replaces the stand-in signature of tx with one made by key.
+func sign(tx *Tx, key *big.Int) {
+	t := txJSON(tx)
+	hash, err := txcodec.SigningHash(&t)
+	if err != nil {
+		return
+	}
+	r, s, parity, err := crypto.Sign(hash, key)
+	if err != nil {
+		return
+	}
+	tx.R, tx.S, tx.Parity = r, s, parity
+}
+
+// Key derives the private key of the test account name. Its address is
+// crypto.AddressOf(Key(name)).
+func Key(name string) *big.Int {
+	sum := sha256.Sum256([]byte(name))
+	return new(big.Int).SetBytes(sum[:])
+}
+
+// signature derives a stand-in signature value below half the curve order.
+func signature(parts ...string) *big.Int {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	sum[0] &= 0x7f
+	return new(big.Int).SetBytes(sum[:])
+}
+
+func orZero(value *big.Int) *big.Int {
+	if value == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(value)
+}
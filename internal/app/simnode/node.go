@@ -0,0 +1,670 @@
+package simnode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"eth_parser/internal/app/bloom"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/utils"
+	"fmt"
+	"io"
+	"math/big"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	codeInvalidParams  = -32602
+	codeMethodNotFound = -32601
+	codeInternal       = -32603
+	codeServer         = -32000
+	codeLimitExceeded  = -32005
+
+	balanceOfSelector = "0x70a08231"
+	decimalsSelector  = "0x313ce567"
+	// tokenDecimals is what decimals() returns for every token.
+	tokenDecimals = 18
+)
+
+// Faults makes the node misbehave like a real provider.
+type Faults struct {
+	// Latency delays every request.
+	Latency time.Duration
+	// ErrorRate is the share of requests, between 0 and 1, failing with an internal error.
+	ErrorRate float64
+	// MaxLogRange rejects eth_getLogs queries spanning more blocks. Zero means unlimited.
+	MaxLogRange uint64
+}
+
+// Error is a JSON-RPC error returned by the node.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("RPC error %d: %s", e.Code, e.Message)
+}
+
+type request struct {
+	JSONRPC string            `json:"jsonrpc"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+	ID      json.RawMessage   `json:"id"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Node answers the JSON-RPC methods used by the parser from a Chain. It is
+// both an http.Handler and an httpclient.HTTPClient, so tests can hand it
+// to the parser without opening a port.
+type Node struct {
+	chain *Chain
+
+	mutex    sync.Mutex
+	faults   Faults
+	rand     *rand.Rand
+	failures map[string][]*Error
+	calls    map[string]int
+}
+
+// NewNode serves chain. seed makes injected errors reproducible.
+func NewNode(chain *Chain, seed int64) *Node {
+	return &Node{
+		chain:    chain,
+		rand:     rand.New(rand.NewSource(seed)),
+		failures: make(map[string][]*Error),
+		calls:    make(map[string]int),
+	}
+}
+
+func (n *Node) Chain() *Chain {
+	return n.chain
+}
+
+func (n *Node) SetFaults(faults Faults) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.faults = faults
+}
+
+// FailNext makes the next call of method fail with code and message.
+// Failures queue up when called repeatedly.
+func (n *Node) FailNext(method string, code int, message string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.failures[method] = append(n.failures[method], &Error{Code: code, Message: message})
+}
+
+// Calls returns how often method was called.
+func (n *Node) Calls(method string) int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.calls[method]
+}
+
+func (n *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+
+	reply, err := n.Handle(r.Context(), body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(reply)
+}
+
+// Do implements httpclient.HTTPClient.
+func (n *Node) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := n.Handle(req.Context(), body)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(reply)),
+		Request:    req,
+	}, nil
+}
+
+// Handle answers a single request or a batch.
+func (n *Node) Handle(ctx context.Context, body []byte) ([]byte, error) {
+	n.mutex.Lock()
+	latency := n.faults.Latency
+	n.mutex.Unlock()
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []request
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return json.Marshal(response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: -32700, Message: "parse error"}})
+		}
+		replies := make([]response, len(batch))
+		for i, req := range batch {
+			replies[i] = n.answer(req)
+		}
+		return json.Marshal(replies)
+	}
+
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		return json.Marshal(response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: -32700, Message: "parse error"}})
+	}
+	return json.Marshal(n.answer(req))
+}
+
+func (n *Node) answer(req request) response {
+	resp := response{JSONRPC: "2.0", ID: req.ID}
+	if resp.ID == nil {
+		resp.ID = json.RawMessage("null")
+	}
+
+	if err := n.injected(req.Method); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	result, err := n.dispatch(req.Method, req.Params)
+	if err != nil {
+		resp.Error = err
+		return resp
+	}
+	resp.Result = result
+	if result == nil {
+		// A null result, e.g. for an unknown transaction, still has to be sent.
+		resp.Result = json.RawMessage("null")
+	}
+	return resp
+}
+
+func (n *Node) injected(method string) *Error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.calls[method]++
+	if queue := n.failures[method]; len(queue) > 0 {
+		n.failures[method] = queue[1:]
+		return queue[0]
+	}
+	if n.faults.ErrorRate > 0 && n.rand.Float64() < n.faults.ErrorRate {
+		return &Error{Code: codeInternal, Message: "internal error"}
+	}
+	return nil
+}
+
+func (n *Node) dispatch(method string, params []json.RawMessage) (any, *Error) {
+	switch method {
+	case "eth_chainId":
+		return utils.IntToHex(n.chain.cfg.ChainID), nil
+	case "net_version":
+		return fmt.Sprint(n.chain.cfg.ChainID), nil
+	case "eth_blockNumber":
+		return utils.IntToHex(n.chain.Head()), nil
+	case "eth_gasPrice":
+		return utils.IntToHex(defaultGasPrice), nil
+	case "eth_getBlockByNumber":
+		return n.getBlockByNumber(params)
+	case "eth_getBlockByHash":
+		return n.getBlockByHash(params)
+	case "eth_getTransactionByHash":
+		return n.getTransactionByHash(params)
+	case "eth_getTransactionReceipt":
+		return n.getTransactionReceipt(params)
+	case "eth_getLogs":
+		return n.getLogs(params)
+	case "eth_getBalance":
+		return n.getBalance(params)
+	case "eth_getTransactionCount":
+		return n.getTransactionCount(params)
+	case "eth_call":
+		return n.call(params)
+	}
+	return nil, &Error{Code: codeMethodNotFound, Message: fmt.Sprintf("the method %s does not exist/is not available", method)}
+}
+
+func param[T any](params []json.RawMessage, i int) (T, *Error) {
+	var value T
+	if i >= len(params) {
+		return value, &Error{Code: codeInvalidParams, Message: fmt.Sprintf("missing value for required argument %d", i)}
+	}
+	if err := json.Unmarshal(params[i], &value); err != nil {
+		return value, &Error{Code: codeInvalidParams, Message: fmt.Sprintf("invalid argument %d: %v", i, err)}
+	}
+	return value, nil
+}
+
+// resolveBlock turns a block tag or hex number into a block number. Block
+// numbers past the head resolve to ok == false.
+func (n *Node) resolveBlock(tag string) (number uint64, ok bool, rpcErr *Error) {
+	head := n.chain.Head()
+	switch tag {
+	case "", "latest", "pending":
+		return head, true, nil
+	case "earliest":
+		return 0, true, nil
+	case "safe":
+		return head - min(head, n.chain.cfg.SafeDepth), true, nil
+	case "finalized":
+		return head - min(head, n.chain.cfg.FinalizedDepth), true, nil
+	}
+
+	value, err := utils.HexToInt(tag)
+	if err != nil || value < 0 {
+		return 0, false, &Error{Code: codeInvalidParams, Message: fmt.Sprintf("invalid block number %q", tag)}
+	}
+	return uint64(value), uint64(value) <= head, nil
+}
+
+// blockParam reads an optional block tag, defaulting to latest.
+func (n *Node) blockParam(params []json.RawMessage, i int) (uint64, bool, *Error) {
+	if i >= len(params) {
+		return n.resolveBlock("latest")
+	}
+	tag, rpcErr := param[string](params, i)
+	if rpcErr != nil {
+		return 0, false, rpcErr
+	}
+	return n.resolveBlock(tag)
+}
+
+func (n *Node) getBlockByNumber(params []json.RawMessage) (any, *Error) {
+	number, ok, rpcErr := n.blockParam(params, 0)
+	if rpcErr != nil || !ok {
+		return nil, rpcErr
+	}
+	full, _ := param[bool](params, 1)
+
+	block, ok := n.chain.BlockByNumber(number)
+	if !ok {
+		return nil, nil
+	}
+	return blockJSON(block, full), nil
+}
+
+func (n *Node) getBlockByHash(params []json.RawMessage) (any, *Error) {
+	hash, rpcErr := param[string](params, 0)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	full, _ := param[bool](params, 1)
+
+	block, ok := n.chain.BlockByHash(hash)
+	if !ok {
+		return nil, nil
+	}
+	return blockJSON(block, full), nil
+}
+
+func (n *Node) getTransactionByHash(params []json.RawMessage) (any, *Error) {
+	hash, rpcErr := param[string](params, 0)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	tx, ok := n.chain.Transaction(hash)
+	if !ok {
+		return nil, nil
+	}
+	return txJSON(tx), nil
+}
+
+func (n *Node) getTransactionReceipt(params []json.RawMessage) (any, *Error) {
+	hash, rpcErr := param[string](params, 0)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	tx, ok := n.chain.Transaction(hash)
+	if !ok {
+		return nil, nil
+	}
+	return receiptJSON(tx), nil
+}
+
+type logQuery struct {
+	FromBlock string          `json:"fromBlock"`
+	ToBlock   string          `json:"toBlock"`
+	BlockHash string          `json:"blockHash"`
+	Address   json.RawMessage `json:"address"`
+	Topics    []any           `json:"topics"`
+}
+
+func (n *Node) getLogs(params []json.RawMessage) (any, *Error) {
+	query, rpcErr := param[logQuery](params, 0)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	filter, rpcErr := query.filter()
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	var logs []*Log
+	if query.BlockHash != "" {
+		if query.FromBlock != "" || query.ToBlock != "" {
+			return nil, &Error{Code: codeInvalidParams, Message: "cannot specify both BlockHash and FromBlock/ToBlock, choose one or the other"}
+		}
+		block, ok := n.chain.BlockByHash(query.BlockHash)
+		if !ok {
+			return nil, &Error{Code: codeServer, Message: "unknown block"}
+		}
+		logs = n.chain.Logs(block.Number, block.Number, filter)
+	} else {
+		from, _, rpcErr := n.resolveBlock(query.FromBlock)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		to, _, rpcErr := n.resolveBlock(query.ToBlock)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		if from > to {
+			return nil, &Error{Code: codeServer, Message: "invalid block range params"}
+		}
+
+		n.mutex.Lock()
+		maxRange := n.faults.MaxLogRange
+		n.mutex.Unlock()
+		if maxRange > 0 && to-from+1 > maxRange {
+			return nil, &Error{Code: codeLimitExceeded, Message: fmt.Sprintf("query exceeds max block range %d", maxRange)}
+		}
+		logs = n.chain.Logs(from, to, filter)
+	}
+
+	result := make([]map[string]any, len(logs))
+	for i, l := range logs {
+		result[i] = logJSON(l)
+	}
+	return result, nil
+}
+
+// filter decodes the address (a string or a list) and the topics (each
+// null, a string or a list of alternatives) of an eth_getLogs query.
+func (q logQuery) filter() (LogFilter, *Error) {
+	var filter LogFilter
+	if len(q.Address) > 0 && string(q.Address) != "null" {
+		var single string
+		if err := json.Unmarshal(q.Address, &single); err == nil {
+			filter.Addresses = []string{single}
+		} else if err := json.Unmarshal(q.Address, &filter.Addresses); err != nil {
+			return filter, &Error{Code: codeInvalidParams, Message: "invalid address filter"}
+		}
+	}
+
+	for _, position := range q.Topics {
+		switch topic := position.(type) {
+		case nil:
+			filter.Topics = append(filter.Topics, nil)
+		case string:
+			filter.Topics = append(filter.Topics, []string{topic})
+		case []any:
+			alternatives := make([]string, 0, len(topic))
+			for _, alternative := range topic {
+				value, ok := alternative.(string)
+				if !ok {
+					return filter, &Error{Code: codeInvalidParams, Message: "invalid topic filter"}
+				}
+				alternatives = append(alternatives, value)
+			}
+			filter.Topics = append(filter.Topics, alternatives)
+		default:
+			return filter, &Error{Code: codeInvalidParams, Message: "invalid topic filter"}
+		}
+	}
+	return filter, nil
+}
+
+func (n *Node) getBalance(params []json.RawMessage) (any, *Error) {
+	address, rpcErr := param[string](params, 0)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	block, ok, rpcErr := n.blockParam(params, 1)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	if !ok {
+		return nil, &Error{Code: codeServer, Message: "header not found"}
+	}
+	return bigToHex(n.chain.BalanceAt(address, block)), nil
+}
+
+func (n *Node) getTransactionCount(params []json.RawMessage) (any, *Error) {
+	address, rpcErr := param[string](params, 0)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	block, ok, rpcErr := n.blockParam(params, 1)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	if !ok {
+		return nil, &Error{Code: codeServer, Message: "header not found"}
+	}
+	return utils.IntToHex(n.chain.NonceAt(address, block)), nil
+}
+
+// call only knows ERC-20 balanceOf, answered from the Transfer logs of the
+// called token, and decimals. Every other call returns empty data.
+func (n *Node) call(params []json.RawMessage) (any, *Error) {
+	msg, rpcErr := param[struct {
+		To    string `json:"to"`
+		Data  string `json:"data"`
+		Input string `json:"input"`
+	}](params, 0)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	block, ok, rpcErr := n.blockParam(params, 1)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	if !ok {
+		return nil, &Error{Code: codeServer, Message: "header not found"}
+	}
+
+	data := strings.ToLower(msg.Data)
+	if data == "" {
+		data = strings.ToLower(msg.Input)
+	}
+	if data == decimalsSelector {
+		return fmt.Sprintf("0x%064x", tokenDecimals), nil
+	}
+	if !strings.HasPrefix(data, balanceOfSelector) || len(data) != len(balanceOfSelector)+64 {
+		return "0x", nil
+	}
+
+	holder := utils.TopicToAddress("0x" + data[len(balanceOfSelector):])
+	balance := n.chain.TokenBalanceAt(msg.To, holder, block)
+	return fmt.Sprintf("0x%064x", balance), nil
+}
+
+func blockJSON(block *Block, full bool) map[string]any {
+	txs := make([]any, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		if full {
+			txs[i] = txJSON(tx)
+		} else {
+			txs[i] = tx.Hash
+		}
+	}
+
+	var gasUsed uint64
+	for _, tx := range block.Transactions {
+		gasUsed += tx.GasUsed
+	}
+
+	return map[string]any{
+		"number":       utils.IntToHex(block.Number),
+		"hash":         block.Hash,
+		"parentHash":   block.ParentHash,
+		"timestamp":    utils.IntToHex(block.Timestamp),
+		"logsBloom":    logsBloom(block.Transactions...).String(),
+		"gasLimit":     utils.IntToHex(30_000_000),
+		"gasUsed":      utils.IntToHex(gasUsed),
+		"miner":        "0x0000000000000000000000000000000000000000",
+		"transactions": txs,
+	}
+}
+
+// txJSON is tx as the node reports it. Mine hashes its signed encoding.
+func txJSON(tx *Tx) entity.Transaction {
+	var to *string
+	if tx.To != "" {
+		to = &tx.To
+	}
+	blockHash, blockNumber, index := tx.BlockHash, utils.IntToHex(tx.BlockNumber), utils.IntToHex(tx.Index)
+	t := entity.Transaction{
+		BlockHash:        &blockHash,
+		BlockNumber:      &blockNumber,
+		TransactionIndex: &index,
+		Hash:             tx.Hash,
+		Type:             tx.Type,
+		ChainID:          utils.IntToHex(tx.ChainID),
+		From:             tx.From,
+		To:               to,
+		Value:            bigToHex(tx.Value),
+		Gas:              utils.IntToHex(tx.GasUsed),
+		GasPrice:         bigToHex(tx.GasPrice),
+		Input:            tx.Input,
+		Nonce:            utils.IntToHex(tx.Nonce),
+		R:                bigToHex(tx.R),
+		S:                bigToHex(tx.S),
+	}
+	if tx.Type == entity.LegacyTxType {
+		// Legacy signatures fold the chain ID into v, see EIP-155.
+		t.V = utils.IntToHex(tx.ChainID*2 + 35 + uint64(tx.Parity))
+		return t
+	}
+
+	t.V = utils.IntToHex(uint64(tx.Parity))
+	t.YParity = t.V
+	t.AccessListFields = &entity.AccessListFields{AccessList: entity.AccessList{}}
+	if tx.Type >= entity.DynamicFeeTxType {
+		// The effective gas price stays the priority fee on a zero base fee.
+		t.DynamicFeeFields = &entity.DynamicFeeFields{
+			MaxFeePerGas:         bigToHex(new(big.Int).Mul(tx.GasPrice, big.NewInt(2))),
+			MaxPriorityFeePerGas: bigToHex(tx.GasPrice),
+		}
+	}
+	switch tx.Type {
+	case entity.BlobTxType:
+		t.BlobFields = &entity.BlobFields{
+			MaxFeePerBlobGas:    utils.IntToHex(blobGasPrice),
+			BlobVersionedHashes: []string{"0x01" + strings.Repeat("00", 31)},
+		}
+	case entity.SetCodeTxType:
+		t.SetCodeFields = &entity.SetCodeFields{AuthorizationList: []entity.Authorization{{
+			ChainID: utils.IntToHex(tx.ChainID),
+			Address: tx.To,
+			Nonce:   utils.IntToHex(tx.Nonce + 1),
+			YParity: "0x0",
+			R:       bigToHex(signature("authorization r", tx.From, fmt.Sprint(tx.Nonce))),
+			S:       bigToHex(signature("authorization s", tx.From, fmt.Sprint(tx.Nonce))),
+		}}}
+	}
+	return t
+}
+
+func receiptJSON(tx *Tx) map[string]any {
+	status := "0x1"
+	if tx.Failed {
+		status = "0x0"
+	}
+	logs := make([]map[string]any, len(tx.Logs))
+	for i, l := range tx.Logs {
+		logs[i] = logJSON(l)
+	}
+
+	var to any
+	if tx.To != "" {
+		to = tx.To
+	}
+	fields := map[string]any{
+		"transactionHash":   tx.Hash,
+		"transactionIndex":  utils.IntToHex(tx.Index),
+		"blockHash":         tx.BlockHash,
+		"blockNumber":       utils.IntToHex(tx.BlockNumber),
+		"from":              tx.From,
+		"to":                to,
+		"status":            status,
+		"gasUsed":           utils.IntToHex(tx.GasUsed),
+		"cumulativeGasUsed": utils.IntToHex(tx.GasUsed),
+		"effectiveGasPrice": bigToHex(tx.GasPrice),
+		"logs":              logs,
+		"logsBloom":         logsBloom(tx).String(),
+		"type":              utils.IntToHex(uint64(tx.Type)),
+	}
+	if tx.Type == entity.BlobTxType {
+		fields["blobGasUsed"] = utils.IntToHex(tx.BlobGasUsed())
+		fields["blobGasPrice"] = utils.IntToHex(blobGasPrice)
+	}
+	return fields
+}
+
+// logsBloom is the bloom of the addresses and topics of the logs of txs.
+func logsBloom(txs ...*Tx) bloom.Bloom {
+	var b bloom.Bloom
+	for _, tx := range txs {
+		for _, l := range tx.Logs {
+			b.AddHex(l.Address)
+			for _, topic := range l.Topics {
+				b.AddHex(topic)
+			}
+		}
+	}
+	return b
+}
+
+func logJSON(l *Log) map[string]any {
+	return map[string]any{
+		"address":          l.Address,
+		"topics":           l.Topics,
+		"data":             l.Data,
+		"blockNumber":      utils.IntToHex(l.BlockNumber),
+		"blockHash":        l.BlockHash,
+		"transactionHash":  l.TxHash,
+		"transactionIndex": utils.IntToHex(l.TxIndex),
+		"logIndex":         utils.IntToHex(l.Index),
+		"removed":          false,
+	}
+}
+
+func bigToHex(value *big.Int) string {
+	return "0x" + value.Text(16)
+}
@@ -0,0 +1,198 @@
+package simnode
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+const (
+	alice = "0x1111111111111111111111111111111111111111"
+	bob   = "0x2222222222222222222222222222222222222222"
+	carol = "0x3333333333333333333333333333333333333333"
+	token = "0x4444444444444444444444444444444444444444"
+	other = "0x5555555555555555555555555555555555555555"
+)
+
+func newTestNode() *Node {
+	cfg := DefaultConfig()
+	cfg.Accounts = map[string]*big.Int{alice: big.NewInt(1e18)}
+	chain := NewChain(cfg)
+
+	// Block 1: alice sends ether to bob and tokens to carol.
+	chain.Mine(
+		TxSpec{From: alice, To: bob, Value: big.NewInt(1000)},
+		TxSpec{From: alice, To: token, Logs: []LogSpec{TokenTransfer(token, alice, carol, big.NewInt(50))}},
+	)
+	// Block 2: carol passes tokens on to bob, a second token moves too.
+	chain.Mine(
+		TxSpec{From: carol, To: token, Logs: []LogSpec{TokenTransfer(token, carol, bob, big.NewInt(20))}},
+		TxSpec{From: bob, To: other, Logs: []LogSpec{TokenTransfer(other, bob, alice, big.NewInt(5))}},
+	)
+	// Block 3: a failed transfer emits nothing.
+	chain.Mine(TxSpec{From: carol, To: token, Failed: true, Logs: []LogSpec{TokenTransfer(token, carol, bob, big.NewInt(1))}})
+	return NewNode(chain, 1)
+}
+
+// call sends one request and decodes its result, returning the RPC error.
+func call(t *testing.T, n *Node, method string, params ...any) (json.RawMessage, *Error) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	raw, err := n.Handle(context.Background(), body)
+	if err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *Error          `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		t.Fatalf("invalid response %s: %v", raw, err)
+	}
+	return resp.Result, resp.Error
+}
+
+func TestGetLogsFilter(t *testing.T) {
+	n := newTestNode()
+	block1, _ := n.Chain().BlockByNumber(1)
+	topic := func(address string) string { return "0x" + strings.Repeat("0", 24) + address[2:] }
+
+	tests := []struct {
+		name      string
+		filter    map[string]any
+		wantCount int
+		wantCode  int
+	}{
+		{name: "all logs", filter: map[string]any{"fromBlock": "0x0", "toBlock": "latest"}, wantCount: 3},
+		{name: "default range is the head", filter: map[string]any{}, wantCount: 0},
+		{name: "single address", filter: map[string]any{"fromBlock": "earliest", "address": token}, wantCount: 2},
+		{name: "address list", filter: map[string]any{"fromBlock": "0x1", "address": []string{token, other}}, wantCount: 3},
+		{name: "address is case insensitive", filter: map[string]any{"fromBlock": "0x1", "address": "0x" + strings.ToUpper(other[2:])}, wantCount: 1},
+		{name: "topic position", filter: map[string]any{"fromBlock": "0x1", "topics": []any{TransferTopic, topic(carol)}}, wantCount: 1},
+		{name: "null is a wildcard", filter: map[string]any{"fromBlock": "0x1", "topics": []any{nil, nil, topic(bob)}}, wantCount: 1},
+		{name: "alternatives", filter: map[string]any{"fromBlock": "0x1", "topics": []any{nil, []string{topic(alice), topic(bob)}}}, wantCount: 2},
+		{name: "more topics than the log", filter: map[string]any{"fromBlock": "0x1", "topics": []any{nil, nil, nil, nil}}, wantCount: 0},
+		{name: "block hash", filter: map[string]any{"blockHash": block1.Hash}, wantCount: 1},
+		{name: "block hash with range", filter: map[string]any{"blockHash": block1.Hash, "fromBlock": "0x1"}, wantCode: codeInvalidParams},
+		{name: "inverted range", filter: map[string]any{"fromBlock": "0x2", "toBlock": "0x1"}, wantCode: codeServer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, rpcErr := call(t, n, "eth_getLogs", tt.filter)
+			if tt.wantCode != 0 {
+				if rpcErr == nil || rpcErr.Code != tt.wantCode {
+					t.Fatalf("expected error code %d, got %v", tt.wantCode, rpcErr)
+				}
+				return
+			}
+			if rpcErr != nil {
+				t.Fatalf("unexpected error: %v", rpcErr)
+			}
+
+			var logs []map[string]any
+			json.Unmarshal(result, &logs)
+			if len(logs) != tt.wantCount {
+				t.Errorf("expected %d logs, got %d", tt.wantCount, len(logs))
+			}
+		})
+	}
+}
+
+func TestGetLogsMaxRange(t *testing.T) {
+	n := newTestNode()
+	n.SetFaults(Faults{MaxLogRange: 2})
+
+	if _, rpcErr := call(t, n, "eth_getLogs", map[string]any{"fromBlock": "0x1", "toBlock": "0x3"}); rpcErr == nil || rpcErr.Code != codeLimitExceeded {
+		t.Fatalf("expected limit error, got %v", rpcErr)
+	}
+	if _, rpcErr := call(t, n, "eth_getLogs", map[string]any{"fromBlock": "0x2", "toBlock": "0x3"}); rpcErr != nil {
+		t.Fatalf("unexpected error: %v", rpcErr)
+	}
+}
+
+func TestBalances(t *testing.T) {
+	n := newTestNode()
+
+	// alice paid 1000 wei to bob and two fees of 21000 gas at 1 gwei.
+	want := new(big.Int).Sub(big.NewInt(1e18), big.NewInt(1000+2*21000*defaultGasPrice))
+	result, rpcErr := call(t, n, "eth_getBalance", alice, "latest")
+	if rpcErr != nil {
+		t.Fatalf("unexpected error: %v", rpcErr)
+	}
+	if got := strings.Trim(string(result), `"`); got != bigToHex(want) {
+		t.Errorf("expected balance %s, got %s", bigToHex(want), got)
+	}
+
+	// carol received 50 tokens and sent 20; the failed transfer does not count.
+	data := balanceOfSelector + strings.Repeat("0", 24) + carol[2:]
+	result, _ = call(t, n, "eth_call", map[string]any{"to": token, "data": data}, "latest")
+	var hex string
+	json.Unmarshal(result, &hex)
+	if balance, _ := new(big.Int).SetString(hex[2:], 16); balance.Int64() != 30 {
+		t.Errorf("expected token balance 30, got %s", hex)
+	}
+
+	// Past blocks see the old state.
+	result, _ = call(t, n, "eth_getTransactionCount", alice, "0x0")
+	if string(result) != `"0x0"` {
+		t.Errorf("expected nonce 0x0 at genesis, got %s", result)
+	}
+}
+
+func TestReorg(t *testing.T) {
+	n := newTestNode()
+	chain := n.Chain()
+	old, _ := chain.BlockByNumber(3)
+	oldTx := old.Transactions[0].Hash
+
+	if err := chain.Reorg(1, []TxSpec{{From: alice, To: carol, Value: big.NewInt(7)}}, nil); err != nil {
+		t.Fatalf("Reorg() error = %v", err)
+	}
+
+	if chain.Head() != 4 {
+		t.Errorf("expected head 4, got %d", chain.Head())
+	}
+	replaced, _ := chain.BlockByNumber(3)
+	if replaced.Hash == old.Hash {
+		t.Error("expected a new hash for the replaced block")
+	}
+	parent, _ := chain.BlockByNumber(2)
+	if replaced.ParentHash != parent.Hash {
+		t.Error("replacement does not extend the common ancestor")
+	}
+	if result, _ := call(t, n, "eth_getTransactionByHash", oldTx); string(result) != "null" {
+		t.Errorf("expected the dropped transaction to be unknown, got %s", result)
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	a, b := newTestNode().Chain(), newTestNode().Chain()
+	for number := uint64(0); number <= a.Head(); number++ {
+		blockA, _ := a.BlockByNumber(number)
+		blockB, _ := b.BlockByNumber(number)
+		if blockA.Hash != blockB.Hash {
+			t.Fatalf("block %d differs between runs", number)
+		}
+	}
+}
+
+func TestFaults(t *testing.T) {
+	n := newTestNode()
+	n.FailNext("eth_blockNumber", -32000, "header not found")
+
+	if _, rpcErr := call(t, n, "eth_blockNumber"); rpcErr == nil || rpcErr.Message != "header not found" {
+		t.Fatalf("expected injected error, got %v", rpcErr)
+	}
+	if result, rpcErr := call(t, n, "eth_blockNumber"); rpcErr != nil || string(result) != `"0x3"` {
+		t.Fatalf("expected 0x3 after the injected error, got %s, %v", result, rpcErr)
+	}
+	if _, rpcErr := call(t, n, "txpool_content"); rpcErr == nil || rpcErr.Code != codeMethodNotFound {
+		t.Errorf("expected method not found, got %v", rpcErr)
+	}
+	if n.Calls("eth_blockNumber") != 2 {
+		t.Errorf("expected 2 calls, got %d", n.Calls("eth_blockNumber"))
+	}
+}
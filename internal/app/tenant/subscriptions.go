@@ -0,0 +1,229 @@
+// Package tenant keeps the subscriptions of the teams sharing a deployment.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"eth_parser/internal/app/webhook"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/repository"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded is returned when a tenant subscribes more addresses than
+// its quota allows.
+var ErrQuotaExceeded = errors.New("subscription quota exceeded")
+
+// Quotas caps the number of addresses a tenant can subscribe. Zero means
+// no limit.
+type Quotas struct {
+	Default int
+	// Tenants overrides Default for single tenants.
+	Tenants map[string]int
+}
+
+// Limit returns the quota of tenant.
+func (q Quotas) Limit(tenant string) int {
+	if limit, ok := q.Tenants[tenant]; ok {
+		return limit
+	}
+	return q.Default
+}
+
+// Head tells the latest block of the chain.
+type Head interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+}
+
+// Subscriptions manages the subscriptions of every tenant. The chain data of
+// an address is stored once no matter how many tenants watch it; this only
+// decides who may see it and who is notified.
+type Subscriptions struct {
+	repo   repository.TenantSubscriptionRepo
+	quotas Quotas
+	head   Head
+	now    func() time.Time
+
+	mutex sync.Mutex
+}
+
+func NewSubscriptions(repo repository.TenantSubscriptionRepo, quotas Quotas) *Subscriptions {
+	return &Subscriptions{
+		repo:   repo,
+		quotas: quotas,
+		now:    time.Now,
+	}
+}
+
+// SetHead records the head of the chain on new subscriptions, so what
+// backfills find before it raises no notifications.
+func (s *Subscriptions) SetHead(head Head) {
+	s.head = head
+}
+
+// Subscribe adds address to the subscriptions of sub.Tenant or, when it is
+// already there, updates it. Optional fields left empty or zero keep the
+// values set before.
+func (s *Subscriptions) Subscribe(sub entity.Subscription) (entity.Subscription, error) {
+	if sub.Tenant == "" {
+		return entity.Subscription{}, fmt.Errorf("tenant is required")
+	}
+	if sub.Webhook != "" {
+		if err := webhook.ValidateURL(sub.Webhook); err != nil {
+			return entity.Subscription{}, err
+		}
+	}
+
+	if sub.Confirmations < 0 {
+		return entity.Subscription{}, fmt.Errorf("confirmations must not be negative")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sub.Address = strings.ToLower(sub.Address)
+	sub.CreatedAt = s.now()
+	if existing, ok := s.repo.GetTenantSubscription(sub.Tenant, sub.Address); ok {
+		sub.CreatedAt = existing.CreatedAt
+		sub.CreatedBlock = existing.CreatedBlock
+		if sub.Label == "" {
+			sub.Label = existing.Label
+		}
+		if sub.Webhook == "" {
+			sub.Webhook = existing.Webhook
+		}
+		if sub.Confirmations == 0 {
+			sub.Confirmations = existing.Confirmations
+		}
+		if sub.ENS == "" {
+			sub.ENS = existing.ENS
+		}
+	} else if limit := s.quotas.Limit(sub.Tenant); limit > 0 && len(s.repo.ListTenantSubscriptions(sub.Tenant)) >= limit {
+		return entity.Subscription{}, fmt.Errorf("%w: tenant %s is limited to %d addresses", ErrQuotaExceeded, sub.Tenant, limit)
+	} else {
+		head, err := s.latest()
+		if err != nil {
+			return entity.Subscription{}, err
+		}
+		sub.CreatedBlock = head
+	}
+	if err := s.repo.StoreTenantSubscription(sub); err != nil {
+		return entity.Subscription{}, fmt.Errorf("failed to store subscription: %w", err)
+	}
+	return sub, nil
+}
+
+// Get returns the subscription of tenant to address.
+func (s *Subscriptions) Get(tenant, address string) (entity.Subscription, bool) {
+	return s.repo.GetTenantSubscription(tenant, address)
+}
+
+// List returns the subscriptions of tenant, ordered by address.
+func (s *Subscriptions) List(tenant string) []entity.Subscription {
+	return s.repo.ListTenantSubscriptions(tenant)
+}
+
+// Subscribers returns the subscriptions of all tenants to address.
+func (s *Subscriptions) Subscribers(address string) []entity.Subscription {
+	return s.repo.Subscribers(address)
+}
+
+// Addresses returns the addresses subscribed by any tenant, each once.
+func (s *Subscriptions) Addresses() []string {
+	var addresses []string
+	seen := make(map[string]bool)
+	for _, sub := range s.repo.AllTenantSubscriptions() {
+		if !seen[sub.Address] {
+			seen[sub.Address] = true
+			addresses = append(addresses, sub.Address)
+		}
+	}
+	return addresses
+}
+
+// Named returns the subscriptions of all tenants made by ENS name.
+func (s *Subscriptions) Named() []entity.Subscription {
+	var named []entity.Subscription
+	for _, sub := range s.repo.AllTenantSubscriptions() {
+		if sub.ENS != "" {
+			named = append(named, sub)
+		}
+	}
+	return named
+}
+
+// Follow moves the subscriptions made by the ENS name from old, the address
+// it resolved to, to address, where they start at the current head. A
+// tenant already subscribed to address keeps that subscription, which only
+// takes the label, webhook and confirmations it lacks from the moved one.
+// It returns the subscriptions now at address.
+func (s *Subscriptions) Follow(name, old, address string) ([]entity.Subscription, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	old, address = strings.ToLower(old), strings.ToLower(address)
+	if old == address {
+		return nil, nil
+	}
+	var named []entity.Subscription
+	for _, sub := range s.repo.Subscribers(old) {
+		if sub.ENS == name {
+			named = append(named, sub)
+		}
+	}
+	if len(named) == 0 {
+		return nil, nil
+	}
+	head, err := s.latest()
+	if err != nil {
+		return nil, err
+	}
+
+	var moved []entity.Subscription
+	for _, sub := range named {
+		if existing, ok := s.repo.GetTenantSubscription(sub.Tenant, address); ok {
+			if existing.Label == "" {
+				existing.Label = sub.Label
+			}
+			if existing.Webhook == "" {
+				existing.Webhook = sub.Webhook
+			}
+			if existing.Confirmations == 0 {
+				existing.Confirmations = sub.Confirmations
+			}
+			sub = existing
+		} else {
+			sub.Address = address
+			sub.CreatedBlock = head
+		}
+		if err := s.repo.StoreTenantSubscription(sub); err != nil {
+			return moved, fmt.Errorf("failed to store subscription: %w", err)
+		}
+		// Only the subscription made by name leaves old.
+		if current, ok := s.repo.GetTenantSubscription(sub.Tenant, old); ok && current.ENS == name {
+			if _, err := s.repo.DeleteTenantSubscription(sub.Tenant, old); err != nil {
+				return moved, fmt.Errorf("failed to delete subscription: %w", err)
+			}
+		}
+		moved = append(moved, sub)
+	}
+	return moved, nil
+}
+
+// latest returns the head of the chain, or zero when it is not known.
+func (s *Subscriptions) latest() (uint64, error) {
+	if s.head == nil {
+		return 0, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	head, err := s.head.BlockNumber(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the latest block: %w", err)
+	}
+	return head, nil
+}
@@ -0,0 +1,185 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/domain/entity"
+	"testing"
+	"time"
+)
+
+type headFunc func() uint64
+
+func (f headFunc) BlockNumber(ctx context.Context) (uint64, error) {
+	return f(), nil
+}
+
+func TestSubscribe(t *testing.T) {
+	subs := NewSubscriptions(repo.NewMemoryTenantSubscriptionRepo(), Quotas{})
+	created := time.Unix(1700000000, 0)
+	subs.now = func() time.Time { return created }
+	subs.SetHead(headFunc(func() uint64 { return 100 }))
+
+	tests := []struct {
+		name    string
+		sub     entity.Subscription
+		wantErr bool
+	}{
+		{"first tenant", entity.Subscription{Tenant: "ops", Address: "0xABC", ENS: "hot.eth", Label: "hot wallet", Webhook: "https://203.0.113.10/hook", Confirmations: 12}, false},
+		{"second tenant, same address", entity.Subscription{Tenant: "risk", Address: "0xabc", Label: "watched"}, false},
+		{"missing tenant", entity.Subscription{Address: "0xabc"}, true},
+		{"invalid webhook", entity.Subscription{Tenant: "ops", Address: "0xdef", Webhook: "ftp://ops.example"}, true},
+		{"loopback webhook", entity.Subscription{Tenant: "ops", Address: "0xdef", Webhook: "http://127.0.0.1:8080/hook"}, true},
+		{"localhost webhook", entity.Subscription{Tenant: "ops", Address: "0xdef", Webhook: "http://localhost/hook"}, true},
+		{"private webhook", entity.Subscription{Tenant: "ops", Address: "0xdef", Webhook: "http://10.0.0.7/hook"}, true},
+		{"link-local webhook", entity.Subscription{Tenant: "ops", Address: "0xdef", Webhook: "http://169.254.169.254/latest/meta-data"}, true},
+		{"private IPv6 webhook", entity.Subscription{Tenant: "ops", Address: "0xdef", Webhook: "http://[fd00::1]/hook"}, true},
+		{"negative confirmations", entity.Subscription{Tenant: "ops", Address: "0xdef", Confirmations: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := subs.Subscribe(tt.sub)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Subscribe() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if got := subs.Subscribers("0xAbC"); len(got) != 2 || got[0].Label != "hot wallet" || got[1].Label != "watched" {
+		t.Errorf("unexpected subscribers %+v", got)
+	}
+	if got := subs.List("ops"); len(got) != 1 || got[0].Address != "0xabc" {
+		t.Errorf("unexpected subscriptions %+v", got)
+	}
+
+	// Subscribing again updates the label but keeps the other fields and
+	// the creation time and block.
+	subs.now = func() time.Time { return created.Add(time.Hour) }
+	subs.SetHead(headFunc(func() uint64 { return 400 }))
+	sub, err := subs.Subscribe(entity.Subscription{Tenant: "ops", Address: "0xabc", Label: "cold wallet"})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if sub.Label != "cold wallet" || sub.Webhook != "https://203.0.113.10/hook" || sub.Confirmations != 12 || sub.ENS != "hot.eth" || !sub.CreatedAt.Equal(created) || sub.CreatedBlock != 100 {
+		t.Errorf("unexpected subscription %+v", sub)
+	}
+
+	// Nothing is cleared by a bare subscribe.
+	sub, err = subs.Subscribe(entity.Subscription{Tenant: "ops", Address: "0xabc"})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if sub.Label != "cold wallet" || sub.Webhook != "https://203.0.113.10/hook" || sub.Confirmations != 12 || sub.ENS != "hot.eth" {
+		t.Errorf("unexpected subscription %+v", sub)
+	}
+}
+
+func TestSubscribeQuota(t *testing.T) {
+	subs := NewSubscriptions(repo.NewMemoryTenantSubscriptionRepo(), Quotas{Default: 1, Tenants: map[string]int{"ops": 2, "risk": 0}})
+
+	tests := []struct {
+		name    string
+		tenant  string
+		address string
+		wantErr bool
+	}{
+		{"default quota", "dev", "0x1", false},
+		{"default quota exceeded", "dev", "0x2", true},
+		{"existing address does not count", "dev", "0x1", false},
+		{"tenant quota", "ops", "0x1", false},
+		{"tenant quota not exceeded", "ops", "0x2", false},
+		{"tenant quota exceeded", "ops", "0x3", true},
+		{"unlimited tenant", "risk", "0x1", false},
+		{"unlimited tenant again", "risk", "0x2", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := subs.Subscribe(entity.Subscription{Tenant: tt.tenant, Address: tt.address})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Subscribe() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, ErrQuotaExceeded) {
+				t.Errorf("expected ErrQuotaExceeded, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFollow(t *testing.T) {
+	subs := NewSubscriptions(repo.NewMemoryTenantSubscriptionRepo(), Quotas{})
+	subs.Subscribe(entity.Subscription{Tenant: "ops", Address: "0xa", ENS: "vault.eth", Label: "vault"})
+	subs.Subscribe(entity.Subscription{Tenant: "risk", Address: "0xa"})
+	subs.Subscribe(entity.Subscription{Tenant: "dev", Address: "0xc", ENS: "other.eth"})
+
+	if named := subs.Named(); len(named) != 2 {
+		t.Fatalf("Named() = %+v, want the two named subscriptions", named)
+	}
+
+	moved, err := subs.Follow("vault.eth", "0xa", "0xB")
+	if err != nil {
+		t.Fatalf("Follow() error = %v", err)
+	}
+	if len(moved) != 1 || moved[0].Tenant != "ops" || moved[0].Address != "0xb" {
+		t.Fatalf("Follow() = %+v, want the subscription of ops moved", moved)
+	}
+	if sub, ok := subs.Get("ops", "0xb"); !ok || sub.Label != "vault" || sub.ENS != "vault.eth" {
+		t.Errorf("Get(ops, 0xb) = %+v, %v, want the moved subscription", sub, ok)
+	}
+	if _, ok := subs.Get("ops", "0xa"); ok {
+		t.Error("the old address should no longer be subscribed by ops")
+	}
+	if _, ok := subs.Get("risk", "0xa"); !ok {
+		t.Error("subscriptions by address should stay")
+	}
+}
+
+func TestFollowKeepsSubscriptionsAlreadyAtTheAddress(t *testing.T) {
+	subs := NewSubscriptions(repo.NewMemoryTenantSubscriptionRepo(), Quotas{})
+	head := uint64(100)
+	subs.SetHead(headFunc(func() uint64 { return head }))
+	subs.Subscribe(entity.Subscription{Tenant: "ops", Address: "0xa", ENS: "vault.eth", Label: "vault", Confirmations: 12})
+	subs.Subscribe(entity.Subscription{Tenant: "risk", Address: "0xa", ENS: "vault.eth", Label: "vault", Webhook: "https://203.0.113.10/hook"})
+	// risk already watches the new address directly.
+	subs.Subscribe(entity.Subscription{Tenant: "risk", Address: "0xb", Confirmations: 3})
+
+	head = 200
+	moved, err := subs.Follow("vault.eth", "0xA", "0xb")
+	if err != nil {
+		t.Fatalf("Follow() error = %v", err)
+	}
+	if len(moved) != 2 {
+		t.Fatalf("Follow() = %+v, want both subscriptions at 0xb", moved)
+	}
+
+	if sub, ok := subs.Get("ops", "0xb"); !ok || sub.ENS != "vault.eth" || sub.Confirmations != 12 || sub.CreatedBlock != 200 {
+		t.Errorf("Get(ops, 0xb) = %+v, %v, want the moved subscription starting at the head", sub, ok)
+	}
+	sub, ok := subs.Get("risk", "0xb")
+	if !ok {
+		t.Fatal("the subscription of risk to 0xb was lost")
+	}
+	// The direct subscription keeps its settings and does not start
+	// following the name.
+	if sub.ENS != "" || sub.Confirmations != 3 || sub.CreatedBlock != 100 {
+		t.Errorf("Get(risk, 0xb) = %+v, want the direct subscription kept", sub)
+	}
+	if sub.Label != "vault" || sub.Webhook != "https://203.0.113.10/hook" {
+		t.Errorf("Get(risk, 0xb) = %+v, want the label and webhook it lacked", sub)
+	}
+	for _, tenant := range []string{"ops", "risk"} {
+		if _, ok := subs.Get(tenant, "0xa"); ok {
+			t.Errorf("%s should no longer be subscribed to 0xa", tenant)
+		}
+	}
+
+	// A name resolving to the same address again moves nothing.
+	if moved, err := subs.Follow("vault.eth", "0xb", "0xB"); err != nil || len(moved) != 0 {
+		t.Errorf("Follow() = %+v, %v, want nothing moved", moved, err)
+	}
+	if _, ok := subs.Get("ops", "0xb"); !ok {
+		t.Error("following the same address deleted the subscription")
+	}
+}
@@ -0,0 +1,230 @@
+package trace
+
+import (
+	"context"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/rpc"
+	"eth_parser/internal/utils"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	methodDebugTraceBlock = "debug_traceBlockByNumber"
+	methodTraceBlock      = "trace_block"
+)
+
+// Mode selects the trace API of the node.
+type Mode string
+
+const (
+	// ModeDebug uses debug_traceBlockByNumber with the callTracer (Geth, Reth, Erigon).
+	ModeDebug Mode = "debug"
+	// ModeParity uses trace_block (Erigon, Nethermind, Reth).
+	ModeParity Mode = "parity"
+)
+
+func ParseMode(mode string) (Mode, error) {
+	switch Mode(mode) {
+	case ModeDebug, ModeParity:
+		return Mode(mode), nil
+	}
+	return "", fmt.Errorf("unknown trace mode %q", mode)
+}
+
+// Tracer extracts ether moved by internal calls, which leave no logs and
+// are therefore invisible to eth_getLogs.
+type Tracer struct {
+	caller rpc.Caller
+	mode   Mode
+}
+
+func NewTracer(caller rpc.Caller, mode Mode) *Tracer {
+	return &Tracer{
+		caller: caller,
+		mode:   mode,
+	}
+}
+
+// InternalTransfers returns the value-bearing internal calls of block from
+// or to one of addresses.
+func (t *Tracer) InternalTransfers(ctx context.Context, block uint64, addresses []string) ([]entity.Transfer, error) {
+	var (
+		transfers []entity.Transfer
+		err       error
+	)
+	switch t.mode {
+	case ModeDebug:
+		var traces []txTrace
+		err = t.caller.Call(ctx, methodDebugTraceBlock, []any{utils.IntToHex(block), map[string]any{"tracer": "callTracer"}}, &traces)
+		transfers = callTracerTransfers(traces, block)
+	case ModeParity:
+		var traces []parityTrace
+		err = t.caller.Call(ctx, methodTraceBlock, []any{utils.IntToHex(block)}, &traces)
+		transfers = parityTransfers(traces, block)
+	default:
+		return nil, fmt.Errorf("unknown trace mode %q", t.mode)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to trace block %d: %w", block, err)
+	}
+
+	return involving(transfers, addresses), nil
+}
+
+type txTrace struct {
+	TxHash string    `json:"txHash"`
+	Result callFrame `json:"result"`
+}
+
+type callFrame struct {
+	Type  string      `json:"type"`
+	From  string      `json:"from"`
+	To    string      `json:"to"`
+	Value string      `json:"value"`
+	Error string      `json:"error"`
+	Calls []callFrame `json:"calls"`
+}
+
+func callTracerTransfers(traces []txTrace, block uint64) []entity.Transfer {
+	var transfers []entity.Transfer
+
+	var walk func(txHash string, frame callFrame, path []int)
+	walk = func(txHash string, frame callFrame, path []int) {
+		// A reverted frame takes its whole subtree with it.
+		if frame.Error != "" {
+			return
+		}
+
+		if len(path) > 0 && movesValue(frame.Type) {
+			if transfer, ok := internalTransfer(txHash, block, path, frame.From, frame.To, frame.Value); ok {
+				transfers = append(transfers, transfer)
+			}
+		}
+
+		for i, child := range frame.Calls {
+			walk(txHash, child, append(path[:len(path):len(path)], i))
+		}
+	}
+
+	for _, trace := range traces {
+		walk(trace.TxHash, trace.Result, nil)
+	}
+	return transfers
+}
+
+type parityTrace struct {
+	Type   string `json:"type"`
+	Action struct {
+		CallType      string `json:"callType"`
+		From          string `json:"from"`
+		To            string `json:"to"`
+		Value         string `json:"value"`
+		Address       string `json:"address"`
+		RefundAddress string `json:"refundAddress"`
+		Balance       string `json:"balance"`
+	} `json:"action"`
+	Result *struct {
+		Address string `json:"address"`
+	} `json:"result"`
+	Error           string `json:"error"`
+	TraceAddress    []int  `json:"traceAddress"`
+	TransactionHash string `json:"transactionHash"`
+}
+
+func parityTransfers(traces []parityTrace, block uint64) []entity.Transfer {
+	var transfers []entity.Transfer
+
+	// trace_block lists frames depth first, so a reverted frame is always
+	// seen before its subtree.
+	reverted := make(map[string]bool)
+	for _, trace := range traces {
+		path := tracePath(trace.TraceAddress)
+		key := trace.TransactionHash + ":" + path
+		parent := trace.TransactionHash + ":" + tracePath(trace.TraceAddress[:max(len(trace.TraceAddress)-1, 0)])
+		if trace.Error != "" || (len(trace.TraceAddress) > 0 && reverted[parent]) {
+			reverted[key] = true
+			continue
+		}
+		if len(trace.TraceAddress) == 0 {
+			continue
+		}
+
+		var from, to, value string
+		switch trace.Type {
+		case "call":
+			if !movesValue(trace.Action.CallType) {
+				continue
+			}
+			from, to, value = trace.Action.From, trace.Action.To, trace.Action.Value
+		case "create":
+			if trace.Result == nil {
+				continue
+			}
+			from, to, value = trace.Action.From, trace.Result.Address, trace.Action.Value
+		case "suicide":
+			from, to, value = trace.Action.Address, trace.Action.RefundAddress, trace.Action.Balance
+		default:
+			continue
+		}
+
+		if transfer, ok := internalTransfer(trace.TransactionHash, block, trace.TraceAddress, from, to, value); ok {
+			transfers = append(transfers, transfer)
+		}
+	}
+	return transfers
+}
+
+// movesValue reports whether a call of this type transfers its value to
+// another account. DELEGATECALL and CALLCODE keep value in the caller.
+func movesValue(callType string) bool {
+	switch strings.ToUpper(callType) {
+	case "CALL", "CREATE", "CREATE2", "SELFDESTRUCT":
+		return true
+	}
+	return false
+}
+
+func internalTransfer(txHash string, block uint64, path []int, from, to, value string) (entity.Transfer, bool) {
+	amount, err := utils.HexToBig(value)
+	if err != nil || amount.Sign() <= 0 {
+		return entity.Transfer{}, false
+	}
+
+	tracePath := tracePath(path)
+	return entity.Transfer{
+		ID:          txHash + ":trace:" + tracePath,
+		Kind:        entity.TransferInternal,
+		TxHash:      txHash,
+		BlockNumber: block,
+		Token:       entity.NativeToken,
+		From:        strings.ToLower(from),
+		To:          strings.ToLower(to),
+		Value:       amount.String(),
+		TracePath:   tracePath,
+	}, true
+}
+
+func tracePath(path []int) string {
+	parts := make([]string, len(path))
+	for i, index := range path {
+		parts[i] = strconv.Itoa(index)
+	}
+	return strings.Join(parts, ".")
+}
+
+func involving(transfers []entity.Transfer, addresses []string) []entity.Transfer {
+	watched := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		watched[strings.ToLower(address)] = true
+	}
+
+	var result []entity.Transfer
+	for _, transfer := range transfers {
+		if watched[transfer.From] || watched[transfer.To] {
+			result = append(result, transfer)
+		}
+	}
+	return result
+}
@@ -0,0 +1,106 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"eth_parser/internal/domain/entity"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	watched  = "0x1111111111111111111111111111111111111111"
+	multisig = "0xd9db270c1b5e3bd161e8c8503c55ceabee709552"
+	payoutTx = "0x6f1c9a7e1bd3f5a7a0f1f1b8b33a0c8b51b7e9b0a5b4d1a1b2c3d4e5f6a7b8c9"
+)
+
+// fixtureCaller answers trace calls from recorded node responses in testdata.
+type fixtureCaller struct {
+	t *testing.T
+}
+
+func (c *fixtureCaller) Call(ctx context.Context, method string, params []any, result any) error {
+	data, err := os.ReadFile(filepath.Join("testdata", method+".json"))
+	if err != nil {
+		c.t.Fatalf("no fixture for %s: %v", method, err)
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return err
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+func TestInternalTransfers(t *testing.T) {
+	tests := []struct {
+		name string
+		mode Mode
+		want []entity.Transfer
+	}{
+		{
+			name: "callTracer",
+			mode: ModeDebug,
+			want: []entity.Transfer{
+				{ID: payoutTx + ":trace:0.0", From: multisig, To: watched, Value: "1000000000000000000", TracePath: "0.0"},
+			},
+		},
+		{
+			name: "trace_block",
+			mode: ModeParity,
+			want: []entity.Transfer{
+				{ID: payoutTx + ":trace:0.0", From: multisig, To: watched, Value: "1000000000000000000", TracePath: "0.0"},
+				{ID: payoutTx + ":trace:2", From: "0x7777777777777777777777777777777777777777", To: watched, Value: "2", TracePath: "2"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer := NewTracer(&fixtureCaller{t: t}, tt.mode)
+
+			got, err := tracer.InternalTransfers(context.Background(), 19000000, []string{watched})
+			if err != nil {
+				t.Fatalf("InternalTransfers() error = %v", err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("InternalTransfers() = %+v, want %d transfers", got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				transfer := got[i]
+				if transfer.ID != want.ID || transfer.From != want.From || transfer.To != want.To ||
+					transfer.Value != want.Value || transfer.TracePath != want.TracePath {
+					t.Errorf("transfer %d = %+v, want %+v", i, transfer, want)
+				}
+				if transfer.Kind != entity.TransferInternal || transfer.Token != entity.NativeToken || transfer.BlockNumber != 19000000 {
+					t.Errorf("transfer %d has unexpected kind, token or block: %+v", i, transfer)
+				}
+			}
+		})
+	}
+}
+
+func TestInternalTransfersIgnoresOtherAddresses(t *testing.T) {
+	tracer := NewTracer(&fixtureCaller{t: t}, ModeDebug)
+
+	got, err := tracer.InternalTransfers(context.Background(), 19000000, []string{"0x9999999999999999999999999999999999999999"})
+	if err != nil {
+		t.Fatalf("InternalTransfers() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("InternalTransfers() = %+v, want none", got)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	if _, err := ParseMode("debug"); err != nil {
+		t.Errorf("ParseMode(debug) error = %v", err)
+	}
+	if _, err := ParseMode("geth"); err == nil {
+		t.Error("expected unknown mode to be rejected")
+	}
+}
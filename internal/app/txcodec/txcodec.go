@@ -0,0 +1,567 @@
+// Package txcodec converts transactions between their JSON-RPC form and the
+// signed RLP encoding they are hashed and broadcast in. It lets the parser
+// check the hashes an upstream node reports instead of trusting them.
+package txcodec
+
+import (
+	"encoding/hex"
+	"errors"
+	"eth_parser/internal/crypto"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/rlp"
+	"eth_parser/internal/utils"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedType is returned for transaction types without a known
+	// encoding, such as rollup deposits.
+	ErrUnsupportedType = errors.New("unsupported transaction type")
+	// ErrHashMismatch is returned by Verify when the reported hash is not
+	// the hash of the reported fields.
+	ErrHashMismatch = errors.New("transaction hash mismatch")
+	// ErrSenderMismatch is returned by VerifySender when the signature was
+	// not made by the reported sender.
+	ErrSenderMismatch = errors.New("transaction sender mismatch")
+)
+
+// Encode returns the signed encoding of tx: the RLP list of its fields,
+// prefixed with the type byte for typed transactions (EIP-2718).
+func Encode(tx *entity.Transaction) ([]byte, error) {
+	fields, err := fieldsOf(tx)
+	if err != nil {
+		return nil, err
+	}
+	encoded := rlp.EncodeList(fields...)
+	if tx.Type == entity.LegacyTxType {
+		return encoded, nil
+	}
+	return append([]byte{byte(tx.Type)}, encoded...), nil
+}
+
+// Hash is the Keccak-256 hash of the signed encoding of tx.
+func Hash(tx *entity.Transaction) (string, error) {
+	encoded, err := Encode(tx)
+	if err != nil {
+		return "", err
+	}
+	return "0x" + hex.EncodeToString(crypto.Keccak256(encoded)), nil
+}
+
+// Verify re-derives the hash of tx from its fields and signature and
+// returns ErrHashMismatch when it differs from tx.Hash.
+func Verify(tx *entity.Transaction) error {
+	hash, err := Hash(tx)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(hash, tx.Hash) {
+		return fmt.Errorf("%w: %s hashes to %s", ErrHashMismatch, tx.Hash, hash)
+	}
+	return nil
+}
+
+// SigningHash is the hash the sender of tx signed: the hash of its
+// encoding without the signature, with the chain ID in place of the
+// signature for legacy transactions signed as in EIP-155.
+func SigningHash(tx *entity.Transaction) ([]byte, error) {
+	fields, err := fieldsOf(tx)
+	if err != nil {
+		return nil, err
+	}
+	fields = fields[:len(fields)-3]
+	if tx.Type != entity.LegacyTxType {
+		return crypto.Keccak256([]byte{byte(tx.Type)}, rlp.EncodeList(fields...)), nil
+	}
+
+	v, _ := utils.HexToBig(tx.V)
+	if chainID, ok := legacyChainID(v); ok {
+		fields = append(fields, rlp.EncodeBig(chainID), rlp.EncodeUint(0), rlp.EncodeUint(0))
+	}
+	return crypto.Keccak256(rlp.EncodeList(fields...)), nil
+}
+
+// legacyChainID extracts the chain ID EIP-155 folds into v.
+func legacyChainID(v *big.Int) (*big.Int, bool) {
+	if v.Cmp(big.NewInt(35)) < 0 {
+		return nil, false
+	}
+	chainID := new(big.Int).Sub(v, big.NewInt(35))
+	return chainID.Rsh(chainID, 1), true
+}
+
+// homesteadBlock is the mainnet block from which EIP-2 rejects signatures
+// with a high s. Other chains started with Homestead rules.
+const homesteadBlock = 1_150_000
+
+// preHomestead reports whether tx was mined on mainnet before Homestead:
+// read from chain 1, in a block below it and without the chain ID of
+// EIP-155, which came later. Such transactions do not carry their chain,
+// so it is the one of the node they were read from.
+func preHomestead(tx *entity.Transaction, chainID int64) bool {
+	if chainID != 1 || tx.Type != entity.LegacyTxType || tx.BlockNumber == nil {
+		return false
+	}
+	v, err := utils.HexToBig(tx.V)
+	if err != nil {
+		return false
+	}
+	if _, ok := legacyChainID(v); ok {
+		return false
+	}
+	block, err := utils.HexToInt(*tx.BlockNumber)
+	return err == nil && block < homesteadBlock
+}
+
+// Sender recovers the address that signed tx, read from chain chainID or 0
+// when unknown. Signatures with a high s are only accepted from
+// transactions mined on mainnet before Homestead.
+func Sender(tx *entity.Transaction, chainID int64) (string, error) {
+	hash, err := SigningHash(tx)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := utils.HexToBig(tx.R)
+	if err != nil {
+		return "", fmt.Errorf("invalid r: %w", err)
+	}
+	s, err := utils.HexToBig(tx.S)
+	if err != nil {
+		return "", fmt.Errorf("invalid s: %w", err)
+	}
+	if !crypto.IsLowS(s) && !preHomestead(tx, chainID) {
+		return "", fmt.Errorf("%w: s is above half the curve order", crypto.ErrInvalidSignature)
+	}
+	recoveryID, err := recoveryIDOf(tx)
+	if err != nil {
+		return "", err
+	}
+	return crypto.RecoverAddress(hash, r, s, recoveryID)
+}
+
+// recoveryIDOf is the parity of the signature of tx, which legacy
+// transactions offset by 27, or by 35 plus twice the chain ID.
+func recoveryIDOf(tx *entity.Transaction) (byte, error) {
+	parity := tx.YParity
+	if parity == "" || tx.Type == entity.LegacyTxType {
+		parity = tx.V
+	}
+	v, err := utils.HexToBig(parity)
+	if err != nil {
+		return 0, fmt.Errorf("invalid signature parity: %w", err)
+	}
+
+	if tx.Type == entity.LegacyTxType {
+		if chainID, ok := legacyChainID(v); ok {
+			v.Sub(v, chainID.Lsh(chainID, 1)).Sub(v, big.NewInt(35))
+		} else {
+			v.Sub(v, big.NewInt(27))
+		}
+	}
+	if !v.IsUint64() || v.Uint64() > 1 {
+		return 0, fmt.Errorf("%w: parity %s", crypto.ErrInvalidSignature, parity)
+	}
+	return byte(v.Uint64()), nil
+}
+
+// VerifySender recovers the signer of tx, read from chain chainID, and
+// returns ErrSenderMismatch when it is not tx.From.
+func VerifySender(tx *entity.Transaction, chainID int64) error {
+	sender, err := Sender(tx, chainID)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(sender, tx.From) {
+		return fmt.Errorf("%w: %s claims sender %s but was signed by %s", ErrSenderMismatch, tx.Hash, tx.From, sender)
+	}
+	return nil
+}
+
+// Check runs Verify and VerifySender and sets tx.HashMismatch and
+// tx.SenderMismatch when they fail. Types without a known encoding are not
+// flagged.
+func Check(tx *entity.Transaction, chainID int64) error {
+	var errs []error
+	if err := Verify(tx); err != nil {
+		if errors.Is(err, ErrUnsupportedType) {
+			return nil
+		}
+		tx.HashMismatch = true
+		errs = append(errs, err)
+	}
+	if err := VerifySender(tx, chainID); err != nil {
+		tx.SenderMismatch = true
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// fieldsOf encodes the fields of tx in the order of its type.
+func fieldsOf(tx *entity.Transaction) ([][]byte, error) {
+	e := &encoder{}
+	if tx.Type == entity.LegacyTxType {
+		e.quantity("nonce", tx.Nonce)
+		e.quantity("gasPrice", tx.GasPrice)
+		e.quantity("gas", tx.Gas)
+		e.to(tx.To)
+		e.quantity("value", tx.Value)
+		e.data("input", tx.Input)
+		e.quantity("v", tx.V)
+		e.quantity("r", tx.R)
+		e.quantity("s", tx.S)
+		return e.fields, e.err
+	}
+
+	if tx.Type > entity.SetCodeTxType {
+		return nil, fmt.Errorf("%w %#x", ErrUnsupportedType, uint8(tx.Type))
+	}
+	// Normalize a copy, so that a missing field group is an error rather
+	// than a nil dereference.
+	normalized := *tx
+	if err := normalized.Normalize(); err != nil {
+		return nil, err
+	}
+	tx = &normalized
+
+	e.quantity("chainId", tx.ChainID)
+	e.quantity("nonce", tx.Nonce)
+	if tx.Type == entity.AccessListTxType {
+		e.quantity("gasPrice", tx.GasPrice)
+	} else {
+		e.quantity("maxPriorityFeePerGas", tx.MaxPriorityFeePerGas)
+		e.quantity("maxFeePerGas", tx.MaxFeePerGas)
+	}
+	e.quantity("gas", tx.Gas)
+	e.to(tx.To)
+	e.quantity("value", tx.Value)
+	e.data("input", tx.Input)
+	e.accessList(tx.AccessList)
+	switch tx.Type {
+	case entity.BlobTxType:
+		e.quantity("maxFeePerBlobGas", tx.MaxFeePerBlobGas)
+		hashes := make([][]byte, len(tx.BlobVersionedHashes))
+		for i, hash := range tx.BlobVersionedHashes {
+			hashes[i] = e.fixed("blob versioned hash", hash, 32)
+		}
+		e.fields = append(e.fields, rlp.EncodeList(hashes...))
+	case entity.SetCodeTxType:
+		e.authorizations(tx.AuthorizationList)
+	}
+	parity := tx.YParity
+	if parity == "" {
+		parity = tx.V
+	}
+	e.quantity("yParity", parity)
+	e.quantity("r", tx.R)
+	e.quantity("s", tx.S)
+	return e.fields, e.err
+}
+
+// encoder collects encoded fields, keeping the first error.
+type encoder struct {
+	fields [][]byte
+	err    error
+}
+
+func (e *encoder) quantity(name, value string) {
+	e.fields = append(e.fields, e.quantityOf(name, value))
+}
+
+func (e *encoder) quantityOf(name, value string) []byte {
+	n, err := utils.HexToBig(value)
+	if err == nil && n.Sign() < 0 {
+		err = fmt.Errorf("negative quantity %q", value)
+	}
+	if err != nil {
+		e.fail(fmt.Errorf("invalid %s: %w", name, err))
+		return nil
+	}
+	return rlp.EncodeBig(n)
+}
+
+func (e *encoder) data(name, value string) {
+	b, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+	if err != nil {
+		e.fail(fmt.Errorf("invalid %s: %w", name, err))
+	}
+	e.fields = append(e.fields, rlp.EncodeBytes(b))
+}
+
+// fixed encodes a byte string of exactly size bytes, such as an address.
+func (e *encoder) fixed(name, value string, size int) []byte {
+	b, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+	if err == nil && len(b) != size {
+		err = fmt.Errorf("got %d bytes, want %d", len(b), size)
+	}
+	if err != nil {
+		e.fail(fmt.Errorf("invalid %s %q: %w", name, value, err))
+	}
+	return rlp.EncodeBytes(b)
+}
+
+// to encodes the recipient, which is empty for contract creations.
+func (e *encoder) to(to *string) {
+	if to == nil {
+		e.fields = append(e.fields, rlp.EncodeBytes(nil))
+		return
+	}
+	e.fields = append(e.fields, e.fixed("to", *to, 20))
+}
+
+func (e *encoder) accessList(list entity.AccessList) {
+	tuples := make([][]byte, len(list))
+	for i, tuple := range list {
+		keys := make([][]byte, len(tuple.StorageKeys))
+		for j, key := range tuple.StorageKeys {
+			keys[j] = e.fixed("storage key", key, 32)
+		}
+		tuples[i] = rlp.EncodeList(e.fixed("access list address", tuple.Address, 20), rlp.EncodeList(keys...))
+	}
+	e.fields = append(e.fields, rlp.EncodeList(tuples...))
+}
+
+func (e *encoder) authorizations(list []entity.Authorization) {
+	auths := make([][]byte, len(list))
+	for i, auth := range list {
+		auths[i] = rlp.EncodeList(
+			e.quantityOf("authorization chainId", auth.ChainID),
+			e.fixed("authorization address", auth.Address, 20),
+			e.quantityOf("authorization nonce", auth.Nonce),
+			e.quantityOf("authorization yParity", auth.YParity),
+			e.quantityOf("authorization r", auth.R),
+			e.quantityOf("authorization s", auth.S),
+		)
+	}
+	e.fields = append(e.fields, rlp.EncodeList(auths...))
+}
+
+func (e *encoder) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+// Decode decodes a signed raw transaction into the fields the node would
+// report for it while pending. From is recovered from the signature, and
+// left empty when the signature is invalid. Blob transactions are also
+// accepted in the network form eth_sendRawTransaction takes, which wraps
+// the transaction with its blobs, commitments and proofs (EIP-4844); the
+// sidecar is dropped.
+func Decode(raw []byte) (*entity.Transaction, error) {
+	if len(raw) == 0 {
+		return nil, errors.New("empty transaction")
+	}
+
+	tx := &entity.Transaction{Hash: "0x" + hex.EncodeToString(crypto.Keccak256(raw))}
+	payload := raw
+	if raw[0] < 0xc0 {
+		if raw[0] == 0 || raw[0] > 0x7f {
+			return nil, errors.New("transaction is neither a list nor typed")
+		}
+		tx.Type = entity.TxType(raw[0])
+		payload = raw[1:]
+	}
+
+	item, err := rlp.Decode(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+	if !item.IsList {
+		return nil, errors.New("transaction is not a list")
+	}
+	// The fields of a transaction start with a quantity, those of the
+	// network form with the transaction itself.
+	wrapped := tx.Type == entity.BlobTxType && len(item.List) > 0 && item.List[0].IsList
+	if wrapped {
+		if len(item.List) != 4 {
+			return nil, fmt.Errorf("blob transaction network form has %d fields, want 4", len(item.List))
+		}
+		item = item.List[0]
+	}
+
+	var names []string
+	switch tx.Type {
+	case entity.LegacyTxType:
+		names = []string{"nonce", "gasPrice", "gas", "to", "value", "input", "v", "r", "s"}
+	case entity.AccessListTxType:
+		names = []string{"chainId", "nonce", "gasPrice", "gas", "to", "value", "input", "accessList", "yParity", "r", "s"}
+	case entity.DynamicFeeTxType:
+		names = []string{"chainId", "nonce", "maxPriorityFeePerGas", "maxFeePerGas", "gas", "to", "value", "input", "accessList", "yParity", "r", "s"}
+	case entity.BlobTxType:
+		names = []string{"chainId", "nonce", "maxPriorityFeePerGas", "maxFeePerGas", "gas", "to", "value", "input", "accessList", "maxFeePerBlobGas", "blobVersionedHashes", "yParity", "r", "s"}
+	case entity.SetCodeTxType:
+		names = []string{"chainId", "nonce", "maxPriorityFeePerGas", "maxFeePerGas", "gas", "to", "value", "input", "accessList", "authorizationList", "yParity", "r", "s"}
+	default:
+		return nil, fmt.Errorf("%w %#x", ErrUnsupportedType, uint8(tx.Type))
+	}
+	if len(item.List) != len(names) {
+		return nil, fmt.Errorf("type %#x transaction has %d fields, want %d", uint8(tx.Type), len(item.List), len(names))
+	}
+
+	if tx.Type != entity.LegacyTxType {
+		tx.AccessListFields = &entity.AccessListFields{}
+	}
+	if tx.Type >= entity.DynamicFeeTxType {
+		tx.DynamicFeeFields = &entity.DynamicFeeFields{}
+	}
+	switch tx.Type {
+	case entity.BlobTxType:
+		tx.BlobFields = &entity.BlobFields{}
+	case entity.SetCodeTxType:
+		tx.SetCodeFields = &entity.SetCodeFields{}
+	}
+
+	d := &decoder{}
+	for i, name := range names {
+		field := item.List[i]
+		switch name {
+		case "chainId":
+			tx.ChainID = d.quantity(name, field)
+		case "nonce":
+			tx.Nonce = d.quantity(name, field)
+		case "gasPrice":
+			tx.GasPrice = d.quantity(name, field)
+		case "maxPriorityFeePerGas":
+			tx.MaxPriorityFeePerGas = d.quantity(name, field)
+		case "maxFeePerGas":
+			tx.MaxFeePerGas = d.quantity(name, field)
+		case "gas":
+			tx.Gas = d.quantity(name, field)
+		case "to":
+			if !field.IsList && len(field.Bytes) == 0 {
+				break
+			}
+			to := d.fixed(name, field, 20)
+			tx.To = &to
+		case "value":
+			tx.Value = d.quantity(name, field)
+		case "input":
+			tx.Input = "0x" + hex.EncodeToString(d.bytes(name, field))
+		case "accessList":
+			tx.AccessList = d.accessList(field)
+		case "maxFeePerBlobGas":
+			tx.MaxFeePerBlobGas = d.quantity(name, field)
+		case "blobVersionedHashes":
+			tx.BlobVersionedHashes = []string{}
+			for _, hash := range d.list(name, field) {
+				tx.BlobVersionedHashes = append(tx.BlobVersionedHashes, d.fixed("blob versioned hash", hash, 32))
+			}
+		case "authorizationList":
+			tx.AuthorizationList = d.authorizations(field)
+		case "v":
+			tx.V = d.quantity(name, field)
+		case "yParity":
+			// Nodes report the parity of typed transactions as v too.
+			tx.YParity = d.quantity(name, field)
+			tx.V = tx.YParity
+		case "r":
+			tx.R = d.quantity(name, field)
+		case "s":
+			tx.S = d.quantity(name, field)
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	if tx.Type == entity.LegacyTxType {
+		v, _ := utils.HexToBig(tx.V)
+		if chainID, ok := legacyChainID(v); ok {
+			tx.ChainID = "0x" + chainID.Text(16)
+		}
+	}
+	if err := tx.Normalize(); err != nil {
+		return nil, err
+	}
+	// The hash is that of the transaction without the sidecar.
+	if wrapped {
+		if tx.Hash, err = Hash(tx); err != nil {
+			return nil, err
+		}
+	}
+	// A pending transaction is in no block, so its chain plays no part.
+	tx.From, _ = Sender(tx, 0)
+	return tx, nil
+}
+
+// decoder reads decoded fields, keeping the first error.
+type decoder struct {
+	err error
+}
+
+func (d *decoder) quantity(name string, item rlp.Item) string {
+	n, err := item.Big()
+	if err != nil {
+		d.fail(fmt.Errorf("invalid %s: %w", name, err))
+		return ""
+	}
+	return "0x" + n.Text(16)
+}
+
+func (d *decoder) bytes(name string, item rlp.Item) []byte {
+	if item.IsList {
+		d.fail(fmt.Errorf("invalid %s: expected a byte string, got a list", name))
+	}
+	return item.Bytes
+}
+
+func (d *decoder) fixed(name string, item rlp.Item, size int) string {
+	b := d.bytes(name, item)
+	if len(b) != size {
+		d.fail(fmt.Errorf("invalid %s: got %d bytes, want %d", name, len(b), size))
+	}
+	return "0x" + hex.EncodeToString(b)
+}
+
+func (d *decoder) list(name string, item rlp.Item) []rlp.Item {
+	if !item.IsList {
+		d.fail(fmt.Errorf("invalid %s: expected a list", name))
+	}
+	return item.List
+}
+
+func (d *decoder) accessList(item rlp.Item) entity.AccessList {
+	list := entity.AccessList{}
+	for _, tuple := range d.list("access list", item) {
+		fields := d.list("access list entry", tuple)
+		if len(fields) != 2 {
+			d.fail(fmt.Errorf("invalid access list entry: got %d fields, want 2", len(fields)))
+			continue
+		}
+		entry := entity.AccessTuple{Address: d.fixed("access list address", fields[0], 20), StorageKeys: []string{}}
+		for _, key := range d.list("storage keys", fields[1]) {
+			entry.StorageKeys = append(entry.StorageKeys, d.fixed("storage key", key, 32))
+		}
+		list = append(list, entry)
+	}
+	return list
+}
+
+func (d *decoder) authorizations(item rlp.Item) []entity.Authorization {
+	var auths []entity.Authorization
+	for _, auth := range d.list("authorization list", item) {
+		fields := d.list("authorization", auth)
+		if len(fields) != 6 {
+			d.fail(fmt.Errorf("invalid authorization: got %d fields, want 6", len(fields)))
+			continue
+		}
+		auths = append(auths, entity.Authorization{
+			ChainID: d.quantity("authorization chainId", fields[0]),
+			Address: d.fixed("authorization address", fields[1], 20),
+			Nonce:   d.quantity("authorization nonce", fields[2]),
+			YParity: d.quantity("authorization yParity", fields[3]),
+			R:       d.quantity("authorization r", fields[4]),
+			S:       d.quantity("authorization s", fields[5]),
+		})
+	}
+	return auths
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
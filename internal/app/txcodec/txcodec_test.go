@@ -0,0 +1,331 @@
+package txcodec
+
+import (
+	"encoding/hex"
+	"errors"
+	"eth_parser/internal/crypto"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/rlp"
+	"eth_parser/internal/utils"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// eip155Tx is the signed example transaction of EIP-155.
+const eip155Tx = "f86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83"
+
+func TestDecodeLegacy(t *testing.T) {
+	raw, _ := hex.DecodeString(eip155Tx)
+	tx, err := Decode(raw)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	if tx.Type != entity.LegacyTxType || tx.Nonce != "0x9" || tx.GasPrice != "0x4a817c800" || tx.Gas != "0x5208" ||
+		tx.To == nil || *tx.To != "0x3535353535353535353535353535353535353535" || tx.Value != "0xde0b6b3a7640000" || tx.Input != "0x" {
+		t.Errorf("Decode() = %+v", tx)
+	}
+	if tx.ChainID != "0x1" || tx.V != "0x25" || tx.YParity != "" {
+		t.Errorf("expected an EIP-155 signature on chain 1, got chainId %q, v %q", tx.ChainID, tx.V)
+	}
+	if tx.From != "0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f" {
+		t.Errorf("expected the sender to be recovered, got %q", tx.From)
+	}
+	if hash, err := SigningHash(tx); err != nil || hex.EncodeToString(hash) != "daf5a779ae972f972197303d7b574746c7ef83eadac0f2791ad23db92e4c8e53" {
+		t.Errorf("SigningHash() = %x, %v", hash, err)
+	}
+
+	encoded, err := Encode(tx)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if hex.EncodeToString(encoded) != eip155Tx {
+		t.Errorf("Encode() = %x, want the raw transaction", encoded)
+	}
+	if err := Verify(tx); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
+
+func TestVerifySender(t *testing.T) {
+	key := big.NewInt(0x5eed)
+	sender := crypto.AddressOf(key)
+	to := "0x3535353535353535353535353535353535353535"
+	dynamicFee := &entity.DynamicFeeFields{MaxFeePerGas: "0x2", MaxPriorityFeePerGas: "0x1"}
+
+	tests := []struct {
+		name string
+		tx   entity.Transaction
+	}{
+		{name: "legacy", tx: entity.Transaction{Type: entity.LegacyTxType, GasPrice: "0x1", To: &to}},
+		{name: "legacy with EIP-155", tx: entity.Transaction{Type: entity.LegacyTxType, ChainID: "0x539", GasPrice: "0x1", To: &to}},
+		{name: "access list", tx: entity.Transaction{Type: entity.AccessListTxType, ChainID: "0x1", GasPrice: "0x1", To: &to}},
+		{name: "dynamic fee", tx: entity.Transaction{Type: entity.DynamicFeeTxType, ChainID: "0x1", To: &to, DynamicFeeFields: dynamicFee}},
+		{
+			name: "blob",
+			tx: entity.Transaction{Type: entity.BlobTxType, ChainID: "0x1", To: &to, DynamicFeeFields: dynamicFee,
+				BlobFields: &entity.BlobFields{MaxFeePerBlobGas: "0x1", BlobVersionedHashes: []string{"0x01" + strings.Repeat("00", 31)}}},
+		},
+		{
+			name: "set code",
+			tx: entity.Transaction{Type: entity.SetCodeTxType, ChainID: "0x1", To: &to, DynamicFeeFields: dynamicFee,
+				SetCodeFields: &entity.SetCodeFields{AuthorizationList: []entity.Authorization{{ChainID: "0x1", Address: to, Nonce: "0x0", YParity: "0x0", R: "0x1", S: "0x1"}}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := tt.tx
+			tx.Nonce, tx.Gas, tx.Value, tx.Input = "0x7", "0x5208", "0x64", "0x"
+			sign(t, &tx, key)
+
+			if got, err := Sender(&tx, 1); err != nil || got != sender {
+				t.Fatalf("Sender() = %s, %v, want %s", got, err, sender)
+			}
+
+			tx.From = sender
+			if err := Check(&tx, 1); err != nil || tx.HashMismatch || tx.SenderMismatch {
+				t.Errorf("Check() = %v, flags %t %t", err, tx.HashMismatch, tx.SenderMismatch)
+			}
+
+			// The hash does not cover the sender, only the signature does.
+			tx.From = to
+			if err := Check(&tx, 1); !errors.Is(err, ErrSenderMismatch) || tx.HashMismatch || !tx.SenderMismatch {
+				t.Errorf("Check() = %v, flags %t %t", err, tx.HashMismatch, tx.SenderMismatch)
+			}
+		})
+	}
+}
+
+func TestVerifySenderHighS(t *testing.T) {
+	key := big.NewInt(0x5eed)
+	sender := crypto.AddressOf(key)
+	to := "0x3535353535353535353535353535353535353535"
+	curveN, _ := new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+
+	tests := []struct {
+		name         string
+		chainID      string
+		node         int64
+		block        uint64
+		wantMismatch bool
+	}{
+		{"before Homestead", "", 1, 46147, false},
+		{"at Homestead", "", 1, 1150000, true},
+		{"with EIP-155", "0x1", 1, 46147, true},
+		{"on another chain", "", 5, 46147, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := entity.Transaction{Type: entity.LegacyTxType, ChainID: tt.chainID, GasPrice: "0x1", To: &to, Nonce: "0x7", Gas: "0x5208", Value: "0x64", Input: "0x"}
+			sign(t, &tx, key)
+
+			// The high s twin of the signature, with the other parity.
+			s, _ := utils.HexToBig(tx.S)
+			v, _ := utils.HexToBig(tx.V)
+			tx.S = "0x" + new(big.Int).Sub(curveN, s).Text(16)
+			offset := int64(27)
+			if tt.chainID != "" {
+				offset = 35
+			}
+			if (v.Int64()-offset)%2 == 0 {
+				v.Add(v, big.NewInt(1))
+			} else {
+				v.Sub(v, big.NewInt(1))
+			}
+			tx.V = "0x" + v.Text(16)
+			var err error
+			if tx.Hash, err = Hash(&tx); err != nil {
+				t.Fatalf("Hash() error = %v", err)
+			}
+			block := utils.IntToHex(tt.block)
+			tx.BlockNumber, tx.From = &block, sender
+
+			err = Check(&tx, tt.node)
+			if tx.SenderMismatch != tt.wantMismatch || tx.HashMismatch {
+				t.Errorf("Check() = %v, flags %t %t, want sender mismatch %t", err, tx.HashMismatch, tx.SenderMismatch, tt.wantMismatch)
+			}
+		})
+	}
+}
+
+// sign signs tx with key and fills in its hash.
+func sign(t *testing.T, tx *entity.Transaction, key *big.Int) {
+	t.Helper()
+
+	var offset uint64 = 27
+	if tx.Type == entity.LegacyTxType && tx.ChainID != "" {
+		chainID, _ := utils.HexToBig(tx.ChainID)
+		offset = chainID.Uint64()*2 + 35
+	}
+	tx.V = utils.IntToHex(offset)
+	hash, err := SigningHash(tx)
+	if err != nil {
+		t.Fatalf("SigningHash() error = %v", err)
+	}
+	r, s, recoveryID, err := crypto.Sign(hash, key)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	tx.R, tx.S = "0x"+r.Text(16), "0x"+s.Text(16)
+	if tx.Type == entity.LegacyTxType {
+		tx.V = utils.IntToHex(offset + uint64(recoveryID))
+	} else {
+		tx.V = utils.IntToHex(uint64(recoveryID))
+		tx.YParity = tx.V
+	}
+	if tx.Hash, err = Hash(tx); err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	to := "0x3535353535353535353535353535353535353535"
+	accessList := &entity.AccessListFields{AccessList: entity.AccessList{{
+		Address:     to,
+		StorageKeys: []string{"0x" + strings.Repeat("01", 32)},
+	}}}
+	dynamicFee := &entity.DynamicFeeFields{MaxFeePerGas: "0x77359400", MaxPriorityFeePerGas: "0x3b9aca00"}
+
+	tests := []struct {
+		name string
+		tx   entity.Transaction
+	}{
+		{
+			name: "legacy contract creation",
+			tx:   entity.Transaction{Type: entity.LegacyTxType, Nonce: "0x0", GasPrice: "0x1", Gas: "0x5208", Value: "0x0", Input: "0x6000", V: "0x1b", R: "0x1", S: "0x2"},
+		},
+		{
+			name: "access list",
+			tx: entity.Transaction{Type: entity.AccessListTxType, ChainID: "0x1", Nonce: "0x1", GasPrice: "0x1", Gas: "0x5208", To: &to, Value: "0x1", Input: "0x",
+				YParity: "0x1", R: "0x1", S: "0x2", AccessListFields: accessList},
+		},
+		{
+			name: "dynamic fee",
+			tx: entity.Transaction{Type: entity.DynamicFeeTxType, ChainID: "0x1", Nonce: "0x2", Gas: "0x5208", To: &to, Value: "0x1", Input: "0xa9059cbb",
+				YParity: "0x0", R: "0x1", S: "0x2", AccessListFields: accessList, DynamicFeeFields: dynamicFee},
+		},
+		{
+			name: "blob",
+			tx: entity.Transaction{Type: entity.BlobTxType, ChainID: "0x1", Nonce: "0x3", Gas: "0x5208", To: &to, Value: "0x0", Input: "0x",
+				YParity: "0x1", R: "0x1", S: "0x2", AccessListFields: &entity.AccessListFields{AccessList: entity.AccessList{}}, DynamicFeeFields: dynamicFee,
+				BlobFields: &entity.BlobFields{MaxFeePerBlobGas: "0x1", BlobVersionedHashes: []string{"0x01" + strings.Repeat("ab", 31)}}},
+		},
+		{
+			name: "set code",
+			tx: entity.Transaction{Type: entity.SetCodeTxType, ChainID: "0x1", Nonce: "0x4", Gas: "0x5208", To: &to, Value: "0x0", Input: "0x",
+				YParity: "0x0", R: "0x1", S: "0x2", AccessListFields: &entity.AccessListFields{AccessList: entity.AccessList{}}, DynamicFeeFields: dynamicFee,
+				SetCodeFields: &entity.SetCodeFields{AuthorizationList: []entity.Authorization{{ChainID: "0x0", Address: to, Nonce: "0x5", YParity: "0x1", R: "0x3", S: "0x4"}}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := Encode(&tt.tx)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			decoded, err := Decode(encoded)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if decoded.Type != tt.tx.Type || decoded.Nonce != tt.tx.Nonce {
+				t.Errorf("Decode() = %+v", decoded)
+			}
+			reencoded, err := Encode(decoded)
+			if err != nil {
+				t.Fatalf("Encode() of the decoded transaction error = %v", err)
+			}
+			if hex.EncodeToString(reencoded) != hex.EncodeToString(encoded) {
+				t.Errorf("re-encoding = %x, want %x", reencoded, encoded)
+			}
+
+			hash, err := Hash(&tt.tx)
+			if err != nil || hash != decoded.Hash {
+				t.Errorf("Hash() = %s, %v, want %s", hash, err, decoded.Hash)
+			}
+		})
+	}
+}
+
+func TestDecodeBlobNetworkForm(t *testing.T) {
+	to := "0x3535353535353535353535353535353535353535"
+	tx := entity.Transaction{Type: entity.BlobTxType, ChainID: "0x1", Nonce: "0x3", Gas: "0x5208", To: &to, Value: "0x0", Input: "0x",
+		YParity: "0x1", R: "0x1", S: "0x2", AccessListFields: &entity.AccessListFields{AccessList: entity.AccessList{}},
+		DynamicFeeFields: &entity.DynamicFeeFields{MaxFeePerGas: "0x2", MaxPriorityFeePerGas: "0x1"},
+		BlobFields:       &entity.BlobFields{MaxFeePerBlobGas: "0x1", BlobVersionedHashes: []string{"0x01" + strings.Repeat("ab", 31)}}}
+	fields, err := fieldsOf(&tx)
+	if err != nil {
+		t.Fatalf("fieldsOf() error = %v", err)
+	}
+	hash, err := Hash(&tx)
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	// The transaction followed by its blobs, commitments and proofs.
+	sidecar := func(size int) []byte { return rlp.EncodeList(rlp.EncodeBytes(make([]byte, size))) }
+	raw := append([]byte{byte(entity.BlobTxType)}, rlp.EncodeList(rlp.EncodeList(fields...), sidecar(131072), sidecar(48), sidecar(48))...)
+	decoded, err := Decode(raw)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if decoded.Hash != hash || decoded.Nonce != tx.Nonce || len(decoded.BlobVersionedHashes) != 1 {
+		t.Errorf("Decode() = %+v, want hash %s", decoded, hash)
+	}
+
+	raw = append([]byte{byte(entity.BlobTxType)}, rlp.EncodeList(rlp.EncodeList(fields...), sidecar(131072))...)
+	if _, err := Decode(raw); err == nil {
+		t.Error("expected a network form without proofs to be rejected")
+	}
+}
+
+func TestVerifyMismatch(t *testing.T) {
+	raw, _ := hex.DecodeString(eip155Tx)
+	tx, err := Decode(raw)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	// A node lying about the amount cannot keep the hash.
+	tx.Value = "0xde0b6b3a7640001"
+	if err := Verify(tx); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrHashMismatch)
+	}
+
+	deposit := &entity.Transaction{Type: 0x7e, Hash: tx.Hash}
+	if err := Verify(deposit); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrUnsupportedType)
+	}
+
+	missing := &entity.Transaction{Type: entity.DynamicFeeTxType, Hash: tx.Hash}
+	if err := Verify(missing); err == nil || errors.Is(err, ErrHashMismatch) {
+		t.Errorf("expected missing fee caps to be reported, got %v", err)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{name: "empty", raw: ""},
+		{name: "type zero prefix", raw: "00" + eip155Tx},
+		{name: "unsupported type", raw: "7ec0"},
+		{name: "not a list", raw: "02820400"},
+		{name: "missing fields", raw: "02c3010203"},
+		{name: "trailing bytes", raw: eip155Tx + "00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, _ := hex.DecodeString(tt.raw)
+			if tx, err := Decode(raw); err == nil {
+				t.Errorf("Decode() = %+v, want an error", tx)
+			}
+		})
+	}
+}
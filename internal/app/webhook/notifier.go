@@ -0,0 +1,289 @@
+// Package webhook tells tenants about new transactions of the addresses
+// they subscribed, and delivers other notifications such as alerts.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"eth_parser/internal/app/finality"
+	"eth_parser/internal/domain/entity"
+	httpclient "eth_parser/internal/domain/http_client"
+	"eth_parser/internal/domain/repository"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	queueSize   = 1024
+	maxAttempts = 3
+)
+
+var _ repository.TransactionStore = (*Notifier)(nil)
+
+// Subscribers finds the tenants watching an address.
+type Subscribers interface {
+	Subscribers(address string) []entity.Subscription
+}
+
+// Payload is the body POSTed to a webhook.
+type Payload struct {
+	Tenant      string             `json:"tenant"`
+	Address     string             `json:"address"`
+	Label       string             `json:"label,omitempty"`
+	Transaction entity.Transaction `json:"transaction"`
+}
+
+type delivery struct {
+	tenant string
+	url    string
+	// subject names the payload in logs.
+	subject string
+	payload any
+}
+
+// Notifier wraps the transaction store and queues a webhook call for every
+// tenant subscribed to an address that got a new transaction. Deliveries
+// are sent by Run and retried a few times; a full queue drops them.
+// Subscriptions asking for confirmations are held back, persisted in held,
+// until the finality tracker reports a deep enough head, and dropped when
+// their block is reorganized away. A transaction mined again in another
+// block is stored again, which queues its notification anew.
+type Notifier struct {
+	repository.TransactionStore
+	subscribers Subscribers
+	held        repository.HeldNotificationRepo
+	client      httpclient.HTTPClient
+	queue       chan delivery
+	retryDelay  time.Duration
+	canonical   finality.Canonical
+	now         func() time.Time
+
+	mutex sync.Mutex
+	state finality.State
+	// releasing keeps two states from releasing the same notification.
+	releasing sync.Mutex
+}
+
+func NewNotifier(store repository.TransactionStore, subscribers Subscribers, held repository.HeldNotificationRepo, client httpclient.HTTPClient) *Notifier {
+	return &Notifier{
+		TransactionStore: store,
+		subscribers:      subscribers,
+		held:             held,
+		client:           client,
+		now:              time.Now,
+		queue:            make(chan delivery, queueSize),
+		retryDelay:       time.Second,
+	}
+}
+
+func (n *Notifier) StoreTransaction(address string, tx entity.Transaction) (bool, error) {
+	stored, err := n.TransactionStore.StoreTransaction(address, tx)
+	if err != nil || !stored {
+		return stored, err
+	}
+
+	for _, sub := range n.subscribers.Subscribers(address) {
+		if sub.Webhook == "" || predates(tx, sub) {
+			continue
+		}
+		n.notify(sub, tx)
+	}
+	return true, nil
+}
+
+// predates reports whether tx was mined before sub was created, e.g. when a
+// backfill imports the history of the address.
+func predates(tx entity.Transaction, sub entity.Subscription) bool {
+	block, ok := finality.BlockOf(tx)
+	return ok && block < sub.CreatedBlock
+}
+
+// SetCanonical makes held notifications wait for, and only be released
+// from, canonical blocks. It must be called before the first state is set.
+func (n *Notifier) SetCanonical(canonical finality.Canonical) {
+	n.canonical = canonical
+}
+
+func (n *Notifier) notify(sub entity.Subscription, tx entity.Transaction) {
+	n.mutex.Lock()
+	state := n.state
+	n.mutex.Unlock()
+
+	if sub.Confirmations > 0 && tx.BlockNumber != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		notification := entity.HeldNotification{Subscription: sub, Transaction: tx, HeldAt: n.now()}
+		verdict, ready, err := n.judge(ctx, state, notification)
+		if err != nil && !errors.Is(err, finality.ErrUnknownBlock) {
+			log.Println(fmt.Errorf("failed to check the block of %s: %w", tx.Hash, err))
+		}
+
+		n.mutex.Lock()
+		defer n.mutex.Unlock()
+		switch verdict {
+		case verdictSend:
+			n.sendTransaction(sub, ready)
+		case verdictWait:
+			if err := n.held.StoreHeldNotification(notification); err != nil {
+				log.Println(fmt.Errorf("failed to hold the webhook of %s: %w", tx.Hash, err))
+			}
+		}
+		return
+	}
+	if block, ok := finality.BlockOf(tx); ok {
+		tx.Confirmations, tx.Finality = state.Status(block)
+	}
+	n.sendTransaction(sub, tx)
+}
+
+// SetState sends the held notifications whose transactions reached the
+// confirmations of their subscription. Those in blocks the node does not
+// have yet keep waiting, and when the canonical chain cannot be read, so
+// does the rest. Blocks are looked up without holding the mutex, so storing
+// transactions never waits for the node.
+func (n *Notifier) SetState(state finality.State) {
+	n.releasing.Lock()
+	defer n.releasing.Unlock()
+
+	n.mutex.Lock()
+	n.state = state
+	held := n.held.ListHeldNotifications()
+	n.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, notification := range held {
+		verdict, ready, err := n.judge(ctx, state, notification)
+		if errors.Is(err, finality.ErrUnknownBlock) {
+			continue
+		}
+		if err != nil {
+			log.Println(fmt.Errorf("failed to check the block of %s: %w", notification.Transaction.Hash, err))
+			return
+		}
+		if verdict == verdictWait {
+			continue
+		}
+
+		n.mutex.Lock()
+		if err := n.held.DeleteHeldNotification(notification.ID()); err != nil {
+			log.Println(fmt.Errorf("failed to delete the held webhook of %s: %w", notification.Transaction.Hash, err))
+		}
+		if verdict == verdictSend {
+			n.sendTransaction(notification.Subscription, ready)
+		}
+		n.mutex.Unlock()
+	}
+}
+
+// verdict is what becomes of a held notification.
+type verdict int
+
+const (
+	verdictWait verdict = iota
+	verdictSend
+	// verdictDrop is for notifications whose block is no longer canonical.
+	verdictDrop
+)
+
+// judge tells whether notification is ready to be sent in state, returning
+// its transaction with the confirmations to send it with, or is dropped
+// because its block was reorganized away.
+func (n *Notifier) judge(ctx context.Context, state finality.State, notification entity.HeldNotification) (verdict, entity.Transaction, error) {
+	sub, tx := notification.Subscription, notification.Transaction
+	confirmations, status, err := finality.StatusOf(ctx, state, n.canonical, tx)
+	switch {
+	case err != nil:
+		return verdictWait, tx, err
+	case status == entity.FinalityDropped:
+		// If tx is mined again, storing it in its new block holds a new
+		// notification.
+		log.Printf("%s was reorganized away, dropping the webhook of tenant %s", tx.Hash, sub.Tenant)
+		return verdictDrop, tx, nil
+	case confirmations >= uint64(sub.Confirmations):
+		tx.Confirmations, tx.Finality = confirmations, status
+		return verdictSend, tx, nil
+	}
+	return verdictWait, tx, nil
+}
+
+// sendTransaction queues the webhook call of sub about tx.
+func (n *Notifier) sendTransaction(sub entity.Subscription, tx entity.Transaction) {
+	n.Send(sub.Tenant, sub.Webhook, tx.Hash, Payload{Tenant: sub.Tenant, Address: sub.Address, Label: sub.Label, Transaction: tx})
+}
+
+// Send queues a POST of payload to url on behalf of tenant. subject names
+// the payload in logs.
+func (n *Notifier) Send(tenant, url, subject string, payload any) {
+	select {
+	case n.queue <- delivery{tenant: tenant, url: url, subject: subject, payload: payload}:
+	default:
+		log.Println(fmt.Errorf("webhook queue is full, dropping %s for tenant %s", subject, tenant))
+	}
+}
+
+// Run sends queued deliveries until ctx is cancelled.
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-n.queue:
+			n.deliver(ctx, d)
+		}
+	}
+}
+
+func (n *Notifier) deliver(ctx context.Context, d delivery) {
+	body, err := json.Marshal(d.payload)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to encode webhook payload: %w", err))
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = n.post(ctx, d.url, body)
+		if err == nil {
+			return
+		}
+		if attempt == maxAttempts {
+			log.Println(fmt.Errorf("failed to deliver %s to tenant %s: %w", d.subject, d.tenant, err))
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(n.retryDelay * time.Duration(attempt)):
+		}
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,389 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"eth_parser/internal/app/finality"
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type subscribersFunc func(address string) []entity.Subscription
+
+func (f subscribersFunc) Subscribers(address string) []entity.Subscription {
+	return f(address)
+}
+
+func TestNotifier(t *testing.T) {
+	payloads := make(chan Payload, 4)
+	var failures atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first attempt fails to exercise the retry.
+		if failures.Add(1) == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		var payload Payload
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+	}))
+	defer server.Close()
+
+	subscribers := subscribersFunc(func(address string) []entity.Subscription {
+		return []entity.Subscription{
+			{Tenant: "ops", Address: address, Label: "hot wallet", Webhook: server.URL, CreatedBlock: 10},
+			{Tenant: "risk", Address: address},
+		}
+	})
+	notifier := NewNotifier(repo.NewMemoryTransactionStore(), subscribers, repo.NewMemoryHeldNotificationRepo(), server.Client())
+	notifier.retryDelay = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+
+	// A backfilled transaction older than the subscription is not news.
+	old := "0x5"
+	if _, err := notifier.StoreTransaction("0xabc", entity.Transaction{Hash: "0x0", BlockNumber: &old}); err != nil {
+		t.Fatalf("StoreTransaction() error = %v", err)
+	}
+
+	tx := entity.Transaction{Hash: "0x1"}
+	for range 2 {
+		if _, err := notifier.StoreTransaction("0xabc", tx); err != nil {
+			t.Fatalf("StoreTransaction() error = %v", err)
+		}
+	}
+
+	select {
+	case payload := <-payloads:
+		if payload.Tenant != "ops" || payload.Label != "hot wallet" || payload.Transaction.Hash != "0x1" {
+			t.Errorf("unexpected payload %+v", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("webhook was not called")
+	}
+
+	// The transaction was stored once, so it is delivered once.
+	select {
+	case payload := <-payloads:
+		t.Errorf("unexpected second delivery %+v", payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if txs := notifier.GetTransactions("0xabc"); len(txs) != 2 {
+		t.Errorf("expected 2 stored transactions, got %d", len(txs))
+	}
+}
+
+func TestNotifierConfirmations(t *testing.T) {
+	payloads := make(chan Payload, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Payload
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+	}))
+	defer server.Close()
+
+	subscribers := subscribersFunc(func(address string) []entity.Subscription {
+		return []entity.Subscription{
+			{Tenant: "ops", Address: address, Webhook: server.URL},
+			{Tenant: "risk", Address: address, Webhook: server.URL, Confirmations: 12},
+		}
+	})
+	notifier := NewNotifier(repo.NewMemoryTransactionStore(), subscribers, repo.NewMemoryHeldNotificationRepo(), server.Client())
+	notifier.SetState(finality.State{Head: 100})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+
+	block := utils.IntToHex(100)
+	if _, err := notifier.StoreTransaction("0xabc", entity.Transaction{Hash: "0x1", BlockNumber: &block}); err != nil {
+		t.Fatalf("StoreTransaction() error = %v", err)
+	}
+
+	receive := func() Payload {
+		t.Helper()
+		select {
+		case payload := <-payloads:
+			return payload
+		case <-time.After(time.Second):
+			t.Fatal("webhook was not called")
+			return Payload{}
+		}
+	}
+
+	if payload := receive(); payload.Tenant != "ops" || payload.Transaction.Confirmations != 1 {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+
+	// risk waits for 12 confirmations.
+	notifier.SetState(finality.State{Head: 110})
+	select {
+	case payload := <-payloads:
+		t.Errorf("unexpected early delivery %+v", payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	notifier.SetState(finality.State{Head: 111, Safe: 100})
+	if payload := receive(); payload.Tenant != "risk" || payload.Transaction.Confirmations != 12 || payload.Transaction.Finality != entity.FinalitySafe {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+}
+
+type canonicalFunc func(number uint64) string
+
+func (f canonicalFunc) CanonicalHash(ctx context.Context, number uint64) (string, error) {
+	return f(number), nil
+}
+
+func TestNotifierDropsReorganizedTransactions(t *testing.T) {
+	payloads := make(chan Payload, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Payload
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+	}))
+	defer server.Close()
+
+	subscribers := subscribersFunc(func(address string) []entity.Subscription {
+		return []entity.Subscription{{Tenant: "risk", Address: address, Webhook: server.URL, Confirmations: 3}}
+	})
+	notifier := NewNotifier(repo.NewMemoryTransactionStore(), subscribers, repo.NewMemoryHeldNotificationRepo(), server.Client())
+	var replaced bool
+	notifier.SetCanonical(canonicalFunc(func(number uint64) string {
+		if replaced && number == 100 {
+			return "0xb100"
+		}
+		return "0xa" + strconv.FormatUint(number, 10)
+	}))
+	notifier.SetState(finality.State{Head: 99})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+
+	for _, number := range []uint64{99, 100} {
+		block, hash := utils.IntToHex(number), "0xa"+strconv.FormatUint(number, 10)
+		tx := entity.Transaction{Hash: "0x" + strconv.FormatUint(number, 10), BlockNumber: &block, BlockHash: &hash}
+		if _, err := notifier.StoreTransaction("0xabc", tx); err != nil {
+			t.Fatalf("StoreTransaction() error = %v", err)
+		}
+	}
+
+	notifier.SetState(finality.State{Head: 101})
+	select {
+	case payload := <-payloads:
+		if payload.Transaction.Hash != "0x99" || payload.Transaction.Confirmations != 3 {
+			t.Errorf("unexpected payload %+v", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("webhook was not called")
+	}
+
+	// Block 100 is replaced before its transaction is deep enough.
+	replaced = true
+	notifier.SetState(finality.State{Head: 102})
+	select {
+	case payload := <-payloads:
+		t.Errorf("the reorganized transaction was delivered: %+v", payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if held := notifier.held.ListHeldNotifications(); len(held) != 0 {
+		t.Errorf("expected the reorganized notification to be dropped, %d held", len(held))
+	}
+
+	// The transaction is mined again in block 101 of the new chain, so its
+	// block is updated and the webhook held again.
+	block, hash := utils.IntToHex(101), "0xa101"
+	if stored, err := notifier.StoreTransaction("0xabc", entity.Transaction{Hash: "0x100", BlockNumber: &block, BlockHash: &hash}); !stored || err != nil {
+		t.Fatalf("StoreTransaction() = %v, %v", stored, err)
+	}
+	for _, tx := range notifier.GetTransactions("0xabc") {
+		if tx.Hash == "0x100" && (tx.BlockHash == nil || *tx.BlockHash != hash) {
+			t.Errorf("expected the stored block to move to %s, got %v", hash, tx.BlockHash)
+		}
+	}
+	notifier.SetState(finality.State{Head: 103})
+	select {
+	case payload := <-payloads:
+		if payload.Transaction.Hash != "0x100" || *payload.Transaction.BlockHash != hash || payload.Transaction.Confirmations != 3 {
+			t.Errorf("unexpected payload %+v", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("webhook of the mined again transaction was not called")
+	}
+}
+
+// laggingCanonical is a node that has no block above last.
+type laggingCanonical struct {
+	last uint64
+}
+
+func (c *laggingCanonical) CanonicalHash(ctx context.Context, number uint64) (string, error) {
+	if number > c.last {
+		return "", finality.ErrUnknownBlock
+	}
+	return "0xa" + strconv.FormatUint(number, 10), nil
+}
+
+func TestNotifierHoldsTransactionsOfBlocksTheNodeLacks(t *testing.T) {
+	payloads := make(chan Payload, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Payload
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+	}))
+	defer server.Close()
+
+	subscribers := subscribersFunc(func(address string) []entity.Subscription {
+		return []entity.Subscription{{Tenant: "risk", Address: address, Webhook: server.URL, Confirmations: 3}}
+	})
+	notifier := NewNotifier(repo.NewMemoryTransactionStore(), subscribers, repo.NewMemoryHeldNotificationRepo(), server.Client())
+	canonical := &laggingCanonical{last: 99}
+	notifier.SetCanonical(canonical)
+	notifier.SetState(finality.State{Head: 100})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+
+	block, hash := utils.IntToHex(100), "0xa100"
+	if _, err := notifier.StoreTransaction("0xabc", entity.Transaction{Hash: "0x100", BlockNumber: &block, BlockHash: &hash}); err != nil {
+		t.Fatalf("StoreTransaction() error = %v", err)
+	}
+
+	// The node answering has not seen block 100 yet, which is no reorg.
+	notifier.SetState(finality.State{Head: 102})
+	select {
+	case payload := <-payloads:
+		t.Errorf("unexpected delivery %+v", payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if held := notifier.held.ListHeldNotifications(); len(held) != 1 {
+		t.Fatalf("expected the notification to stay held, %d held", len(held))
+	}
+
+	canonical.last = 102
+	notifier.SetState(finality.State{Head: 103})
+	select {
+	case payload := <-payloads:
+		if payload.Transaction.Hash != "0x100" || payload.Transaction.Confirmations != 4 {
+			t.Errorf("unexpected payload %+v", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("webhook was not called")
+	}
+}
+
+// blockingCanonical answers once release is closed.
+type blockingCanonical struct {
+	asked   chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingCanonical) CanonicalHash(ctx context.Context, number uint64) (string, error) {
+	c.asked <- struct{}{}
+	<-c.release
+	return "0xa" + strconv.FormatUint(number, 10), nil
+}
+
+func TestNotifierStoresTransactionsWhileTheNodeIsSlow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	subscribers := subscribersFunc(func(address string) []entity.Subscription {
+		return []entity.Subscription{{Tenant: "risk", Address: address, Webhook: server.URL, Confirmations: 3}}
+	})
+	held := repo.NewMemoryHeldNotificationRepo()
+	block, hash := utils.IntToHex(100), "0xa100"
+	held.StoreHeldNotification(entity.HeldNotification{
+		Subscription: entity.Subscription{Tenant: "risk", Address: "0xabc", Webhook: server.URL, Confirmations: 3},
+		Transaction:  entity.Transaction{Hash: "0x100", BlockNumber: &block, BlockHash: &hash},
+	})
+	notifier := NewNotifier(repo.NewMemoryTransactionStore(), subscribers, held, server.Client())
+	canonical := &blockingCanonical{asked: make(chan struct{}, 1), release: make(chan struct{})}
+	notifier.SetCanonical(canonical)
+
+	released := make(chan struct{})
+	go func() {
+		notifier.SetState(finality.State{Head: 102})
+		close(released)
+	}()
+	<-canonical.asked
+
+	// A transaction without a block needs no lookup, so it is stored while
+	// the held notification waits for the node.
+	stored := make(chan struct{})
+	go func() {
+		notifier.StoreTransaction("0xdef", entity.Transaction{Hash: "0x1"})
+		close(stored)
+	}()
+	select {
+	case <-stored:
+	case <-time.After(time.Second):
+		t.Fatal("StoreTransaction waited for the node")
+	}
+
+	close(canonical.release)
+	<-released
+	if held := notifier.held.ListHeldNotifications(); len(held) != 0 {
+		t.Errorf("expected the held notification to be sent, %d held", len(held))
+	}
+}
+
+func TestNotifierKeepsHeldNotificationsAcrossRestarts(t *testing.T) {
+	payloads := make(chan Payload, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Payload
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+	}))
+	defer server.Close()
+
+	subscribers := subscribersFunc(func(address string) []entity.Subscription {
+		return []entity.Subscription{{Tenant: "risk", Address: address, Webhook: server.URL, Confirmations: 12}}
+	})
+	path := filepath.Join(t.TempDir(), "held_webhooks.json")
+	held, err := repo.NewFileHeldNotificationRepo(path)
+	if err != nil {
+		t.Fatalf("NewFileHeldNotificationRepo() error = %v", err)
+	}
+	notifier := NewNotifier(repo.NewMemoryTransactionStore(), subscribers, held, server.Client())
+	notifier.SetState(finality.State{Head: 100})
+	block := utils.IntToHex(100)
+	if _, err := notifier.StoreTransaction("0xabc", entity.Transaction{Hash: "0x1", BlockNumber: &block}); err != nil {
+		t.Fatalf("StoreTransaction() error = %v", err)
+	}
+
+	// The restarted notifier sends the call once the head is deep enough.
+	held, err = repo.NewFileHeldNotificationRepo(path)
+	if err != nil {
+		t.Fatalf("NewFileHeldNotificationRepo() error = %v", err)
+	}
+	restarted := NewNotifier(repo.NewMemoryTransactionStore(), subscribers, held, server.Client())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go restarted.Run(ctx)
+
+	restarted.SetState(finality.State{Head: 111})
+	select {
+	case payload := <-payloads:
+		if payload.Tenant != "risk" || payload.Transaction.Hash != "0x1" || payload.Transaction.Confirmations != 12 {
+			t.Errorf("unexpected payload %+v", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("webhook was not called")
+	}
+	if held := held.ListHeldNotifications(); len(held) != 0 {
+		t.Errorf("expected the sent notification to be deleted, %d held", len(held))
+	}
+}
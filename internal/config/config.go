@@ -0,0 +1,130 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	// Port the HTTP server listens on.
+	Port string
+	// RPCURL is the HTTP JSON-RPC endpoint of the node.
+	RPCURL string
+	// WSURL is an optional WebSocket endpoint. When set, requests go over it
+	// and new heads are pushed instead of polled.
+	WSURL string
+	// RecordDir, when set, captures every HTTP JSON-RPC exchange there as a
+	// replayable fixture.
+	RecordDir string
+	// AdminKey is the bootstrap admin API key. It is required unless
+	// AuthDisabled is set.
+	AdminKey string
+	// AuthDisabled opts out of authentication so the API is open to
+	// everyone. Only per-IP rate limiting applies then.
+	AuthDisabled bool
+	// RateLimitPerKey and RateLimitPerIP are the sustained requests per
+	// second allowed for one API key and one client IP, with bursts of twice
+	// as many. Zero disables the limit.
+	RateLimitPerKey float64
+	RateLimitPerIP  float64
+	// SubscriptionQuota caps the addresses a tenant can subscribe, zero means
+	// unlimited. TenantQuotas overrides it per tenant.
+	SubscriptionQuota int
+	TenantQuotas      map[string]int
+	// DataDir holds persisted state such as backfill jobs. Empty keeps everything in memory.
+	DataDir string
+	// TraceMode enables internal transfer extraction: "debug", "parity" or empty to disable.
+	TraceMode string
+	// PollInterval is how often the chain head is polled for new blocks.
+	PollInterval time.Duration
+	// Mempool enables watching pending transactions of subscribed addresses.
+	Mempool bool
+	// BalanceTracking books plain ether transfers so balances add up and
+	// can be reconciled. Every new head is read with its transactions then.
+	BalanceTracking bool
+	// ENSRegistry is the ENS registry contract names are resolved with,
+	// the mainnet one by default. Empty disables ENS names.
+	ENSRegistry string
+	// ENSRefreshInterval is how long ENS answers are cached and how often
+	// the names of subscriptions are resolved again.
+	ENSRefreshInterval time.Duration
+}
+
+// Load reads the configuration from the environment, falling back to defaults.
+func Load() Config {
+	return Config{
+		Port:               getEnv("PORT", "8080"),
+		RPCURL:             getEnv("RPC_URL", "https://ethereum-rpc.publicnode.com/"),
+		WSURL:              getEnv("WS_URL", ""),
+		RecordDir:          getEnv("RPC_RECORD_DIR", ""),
+		AdminKey:           getEnv("ADMIN_KEY", ""),
+		AuthDisabled:       getBool("AUTH_DISABLED", false),
+		RateLimitPerKey:    getFloat("RATE_LIMIT_PER_KEY", 10),
+		RateLimitPerIP:     getFloat("RATE_LIMIT_PER_IP", 20),
+		SubscriptionQuota:  getInt("SUBSCRIPTION_QUOTA", 0),
+		TenantQuotas:       getQuotas("TENANT_QUOTAS"),
+		DataDir:            getEnv("DATA_DIR", "data"),
+		TraceMode:          getEnv("TRACE_MODE", ""),
+		PollInterval:       getDuration("POLL_INTERVAL", 12*time.Second),
+		Mempool:            getBool("MEMPOOL_ENABLED", false),
+		BalanceTracking:    getBool("BALANCE_TRACKING", false),
+		ENSRegistry:        getEnv("ENS_REGISTRY", "0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"),
+		ENSRefreshInterval: getDuration("ENS_REFRESH_INTERVAL", time.Hour),
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return d
+	}
+	return fallback
+}
+
+func getBool(key string, fallback bool) bool {
+	if b, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return b
+	}
+	return fallback
+}
+
+func getFloat(key string, fallback float64) float64 {
+	if f, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return f
+	}
+	return fallback
+}
+
+func getInt(key string, fallback int) int {
+	if i, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return i
+	}
+	return fallback
+}
+
+// getQuotas parses a list of tenant=limit pairs such as "ops=100,risk=20".
+func getQuotas(key string) map[string]int {
+	quotas := make(map[string]int)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		tenant, value, _ := strings.Cut(pair, "=")
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			log.Printf("ignoring invalid %s entry %q", key, pair)
+			continue
+		}
+		quotas[strings.TrimSpace(tenant)] = limit
+	}
+	return quotas
+}
@@ -0,0 +1,100 @@
+// Package crypto implements the hash and signature primitives of Ethereum
+// that the standard library lacks.
+package crypto
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// keccakRate is the number of bytes absorbed per permutation by Keccak-256.
+const keccakRate = 136
+
+var roundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808a, 0x8000000080008000,
+	0x000000000000808b, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008a, 0x0000000000000088, 0x0000000080008009, 0x000000008000000a,
+	0x000000008000808b, 0x800000000000008b, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800a, 0x800000008000000a,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+// rotations and lanes drive the rho and pi steps: lane lanes[i] is rotated
+// by rotations[i] and moved to lanes[i+1].
+var (
+	rotations = [24]int{1, 3, 6, 10, 15, 21, 28, 36, 45, 55, 2, 14, 27, 41, 56, 8, 25, 43, 62, 18, 39, 61, 20, 44}
+	lanes     = [24]int{10, 7, 11, 17, 18, 3, 5, 16, 8, 21, 24, 4, 15, 23, 19, 13, 12, 2, 20, 14, 22, 9, 6, 1}
+)
+
+// Keccak256 hashes the concatenation of data. It is the original Keccak
+// submission used throughout Ethereum, not the padded SHA3-256 standard.
+func Keccak256(data ...[]byte) []byte {
+	var state [25]uint64
+	var block [keccakRate]byte
+	n := 0
+	for _, d := range data {
+		for len(d) > 0 {
+			copied := copy(block[n:], d)
+			n += copied
+			d = d[copied:]
+			if n == keccakRate {
+				absorb(&state, &block)
+				n = 0
+			}
+		}
+	}
+
+	clear(block[n:])
+	block[n] ^= 0x01
+	block[keccakRate-1] ^= 0x80
+	absorb(&state, &block)
+
+	sum := make([]byte, 32)
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(sum[i*8:], state[i])
+	}
+	return sum
+}
+
+func absorb(state *[25]uint64, block *[keccakRate]byte) {
+	for i := 0; i < keccakRate/8; i++ {
+		state[i] ^= binary.LittleEndian.Uint64(block[i*8:])
+	}
+	keccakF(state)
+}
+
+// keccakF is the Keccak-f[1600] permutation.
+func keccakF(a *[25]uint64) {
+	var c [5]uint64
+	for _, rc := range roundConstants {
+		// theta
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d := c[(x+4)%5] ^ bits.RotateLeft64(c[(x+1)%5], 1)
+			for y := 0; y < 25; y += 5 {
+				a[y+x] ^= d
+			}
+		}
+
+		// rho and pi
+		current := a[1]
+		for i, lane := range lanes {
+			next := a[lane]
+			a[lane] = bits.RotateLeft64(current, rotations[i])
+			current = next
+		}
+
+		// chi
+		for y := 0; y < 25; y += 5 {
+			copy(c[:], a[y:y+5])
+			for x := 0; x < 5; x++ {
+				a[y+x] = c[x] ^ (^c[(x+1)%5] & c[(x+2)%5])
+			}
+		}
+
+		// iota
+		a[0] ^= rc
+	}
+}
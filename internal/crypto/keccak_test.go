@@ -0,0 +1,53 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestKeccak256(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []string
+		want  string
+	}{
+		{
+			name: "empty input",
+			want: "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470",
+		},
+		{
+			name:  "event signature",
+			input: []string{"Transfer(address,address,uint256)"},
+			want:  "ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+		},
+		{
+			name:  "split input",
+			input: []string{"Transfer(address,", "address,uint256)"},
+			want:  "ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+		},
+		{
+			// One byte short of the rate, so the padding bytes coincide.
+			name:  "padding in the last byte",
+			input: []string{strings.Repeat("a", 135)},
+			want:  "34367dc248bbd832f4e3e69dfaac2f92638bd0bbd18f2912ba4ef454919cf446",
+		},
+		{
+			name:  "several blocks",
+			input: []string{strings.Repeat("a", 300)},
+			want:  "5b7e0e47a96f32a88b4f14ca177982790807c40e1a105742ba0fc1babe1ef826",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make([][]byte, len(tt.input))
+			for i, s := range tt.input {
+				data[i] = []byte(s)
+			}
+			if got := hex.EncodeToString(Keccak256(data...)); got != tt.want {
+				t.Errorf("Keccak256() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
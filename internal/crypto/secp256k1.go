@@ -0,0 +1,263 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
+)
+
+// The secp256k1 curve y² = x³ + 7 over the field of size p, with base point
+// G of order n.
+var (
+	curveP, _  = new(big.Int).SetString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f", 16)
+	curveN, _  = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+	curveGx, _ = new(big.Int).SetString("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", 16)
+	curveGy, _ = new(big.Int).SetString("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8", 16)
+	halfN      = new(big.Int).Rsh(curveN, 1)
+)
+
+// ErrInvalidSignature is returned for signatures no key could have made.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// point is a curve point in Jacobian coordinates (X/Z², Y/Z³). Z is zero
+// for the point at infinity.
+type point struct {
+	x, y, z *big.Int
+}
+
+func affine(x, y *big.Int) point {
+	return point{x: new(big.Int).Set(x), y: new(big.Int).Set(y), z: big.NewInt(1)}
+}
+
+func (p point) infinity() bool {
+	return p.z.Sign() == 0
+}
+
+// toAffine returns the x and y coordinates of p.
+func (p point) toAffine() (*big.Int, *big.Int) {
+	zInv := new(big.Int).ModInverse(p.z, curveP)
+	zInv2 := mulMod(zInv, zInv)
+	return mulMod(p.x, zInv2), mulMod(p.y, mulMod(zInv2, zInv))
+}
+
+func mulMod(a, b *big.Int) *big.Int {
+	r := new(big.Int).Mul(a, b)
+	return r.Mod(r, curveP)
+}
+
+func subMod(a, b *big.Int) *big.Int {
+	r := new(big.Int).Sub(a, b)
+	return r.Mod(r, curveP)
+}
+
+func (p point) double() point {
+	if p.infinity() || p.y.Sign() == 0 {
+		return point{x: new(big.Int), y: new(big.Int), z: new(big.Int)}
+	}
+	a := mulMod(p.x, p.x)
+	b := mulMod(p.y, p.y)
+	c := mulMod(b, b)
+	xb := new(big.Int).Add(p.x, b)
+	d := subMod(subMod(mulMod(xb, xb), a), c)
+	d = mulMod(d, big.NewInt(2))
+	e := mulMod(a, big.NewInt(3))
+	f := mulMod(e, e)
+
+	x := subMod(f, mulMod(d, big.NewInt(2)))
+	y := subMod(mulMod(e, subMod(d, x)), mulMod(c, big.NewInt(8)))
+	z := mulMod(mulMod(p.y, p.z), big.NewInt(2))
+	return point{x: x, y: y, z: z}
+}
+
+func (p point) add(q point) point {
+	if p.infinity() {
+		return q
+	}
+	if q.infinity() {
+		return p
+	}
+	z1z1 := mulMod(p.z, p.z)
+	z2z2 := mulMod(q.z, q.z)
+	u1 := mulMod(p.x, z2z2)
+	u2 := mulMod(q.x, z1z1)
+	s1 := mulMod(mulMod(p.y, q.z), z2z2)
+	s2 := mulMod(mulMod(q.y, p.z), z1z1)
+	h := subMod(u2, u1)
+	r := subMod(s2, s1)
+	if h.Sign() == 0 {
+		if r.Sign() == 0 {
+			return p.double()
+		}
+		return point{x: new(big.Int), y: new(big.Int), z: new(big.Int)}
+	}
+
+	hh := mulMod(h, h)
+	hhh := mulMod(h, hh)
+	v := mulMod(u1, hh)
+	x := subMod(subMod(mulMod(r, r), hhh), mulMod(v, big.NewInt(2)))
+	y := subMod(mulMod(r, subMod(v, x)), mulMod(s1, hhh))
+	z := mulMod(mulMod(p.z, q.z), h)
+	return point{x: x, y: y, z: z}
+}
+
+func (p point) mul(k *big.Int) point {
+	result := point{x: new(big.Int), y: new(big.Int), z: new(big.Int)}
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		result = result.double()
+		if k.Bit(i) == 1 {
+			result = result.add(p)
+		}
+	}
+	return result
+}
+
+// AddressOf returns the address of the account with private key key.
+func AddressOf(key *big.Int) string {
+	x, y := affine(curveGx, curveGy).mul(key).toAffine()
+	return addressOf(x, y)
+}
+
+// addressOf is the last 20 bytes of the hash of the public key.
+func addressOf(x, y *big.Int) string {
+	pub := make([]byte, 64)
+	x.FillBytes(pub[:32])
+	y.FillBytes(pub[32:])
+	return "0x" + hex.EncodeToString(Keccak256(pub)[12:])
+}
+
+// IsLowS reports whether s is in the lower half of the group order, as
+// EIP-2 requires of transaction signatures since Homestead.
+func IsLowS(s *big.Int) bool {
+	return s.Cmp(halfN) <= 0
+}
+
+// RecoverAddress returns the address of the key that signed hash, given
+// the signature values and the recovery ID that picks one of the keys the
+// values fit. Signatures with a high s are recovered too, since they were
+// valid before Homestead; callers enforce EIP-2 with IsLowS.
+func RecoverAddress(hash []byte, r, s *big.Int, recoveryID byte) (string, error) {
+	if r.Sign() <= 0 || r.Cmp(curveN) >= 0 || s.Sign() <= 0 || s.Cmp(curveN) >= 0 || recoveryID > 3 {
+		return "", ErrInvalidSignature
+	}
+
+	// The x coordinate of the nonce point is r, or r + n in the rare case
+	// that it overflowed the group order.
+	x := new(big.Int).Set(r)
+	if recoveryID >= 2 {
+		x.Add(x, curveN)
+		if x.Cmp(curveP) >= 0 {
+			return "", ErrInvalidSignature
+		}
+	}
+	y, ok := curveY(x, recoveryID&1 == 1)
+	if !ok {
+		return "", ErrInvalidSignature
+	}
+
+	// The key is r⁻¹(sR - eG).
+	e := new(big.Int).SetBytes(hash)
+	e.Neg(e).Mod(e, curveN)
+	rInv := new(big.Int).ModInverse(r, curveN)
+	u1 := new(big.Int).Mul(e, rInv)
+	u2 := new(big.Int).Mul(s, rInv)
+	key := affine(curveGx, curveGy).mul(u1.Mod(u1, curveN)).add(affine(x, y).mul(u2.Mod(u2, curveN)))
+	if key.infinity() {
+		return "", ErrInvalidSignature
+	}
+	return addressOf(key.toAffine()), nil
+}
+
+// curveY returns the y coordinate of the curve point at x with the given
+// parity.
+func curveY(x *big.Int, odd bool) (*big.Int, bool) {
+	y2 := new(big.Int).Exp(x, big.NewInt(3), curveP)
+	y2.Add(y2, big.NewInt(7)).Mod(y2, curveP)
+	// p ≡ 3 (mod 4), so a square root is y2^((p+1)/4).
+	exp := new(big.Int).Add(curveP, big.NewInt(1))
+	y := new(big.Int).Exp(y2, exp.Rsh(exp, 2), curveP)
+	if mulMod(y, y).Cmp(y2) != 0 {
+		return nil, false
+	}
+	if (y.Bit(0) == 1) != odd {
+		y.Sub(curveP, y)
+	}
+	return y, true
+}
+
+// Sign signs hash with key, choosing the nonce deterministically as in
+// RFC 6979 and returning a low s.
+func Sign(hash []byte, key *big.Int) (r, s *big.Int, recoveryID byte, err error) {
+	if key.Sign() <= 0 || key.Cmp(curveN) >= 0 {
+		return nil, nil, 0, errors.New("invalid private key")
+	}
+
+	e := new(big.Int).SetBytes(hash)
+	nonces := newNonceGenerator(key, e)
+	for {
+		k := nonces.next()
+		rx, ry := affine(curveGx, curveGy).mul(k).toAffine()
+		r = new(big.Int).Mod(rx, curveN)
+		if r.Sign() == 0 {
+			continue
+		}
+		s = new(big.Int).Mul(r, key)
+		s.Add(s, e).Mul(s, new(big.Int).ModInverse(k, curveN)).Mod(s, curveN)
+		if s.Sign() == 0 {
+			continue
+		}
+
+		recoveryID = byte(ry.Bit(0))
+		if rx.Cmp(curveN) >= 0 {
+			recoveryID |= 2
+		}
+		if s.Cmp(halfN) > 0 {
+			s.Sub(curveN, s)
+			recoveryID ^= 1
+		}
+		return r, s, recoveryID, nil
+	}
+}
+
+// nonceGenerator yields the candidate nonces of RFC 6979 with HMAC-SHA256.
+type nonceGenerator struct {
+	k, v []byte
+}
+
+func newNonceGenerator(key, e *big.Int) *nonceGenerator {
+	x := key.FillBytes(make([]byte, 32))
+	h := new(big.Int).Mod(e, curveN).FillBytes(make([]byte, 32))
+
+	g := &nonceGenerator{k: make([]byte, 32), v: make([]byte, 32)}
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+	g.k = g.mac(g.v, []byte{0x00}, x, h)
+	g.v = g.mac(g.v)
+	g.k = g.mac(g.v, []byte{0x01}, x, h)
+	g.v = g.mac(g.v)
+	return g
+}
+
+func (g *nonceGenerator) next() *big.Int {
+	for {
+		g.v = g.mac(g.v)
+		k := new(big.Int).SetBytes(g.v)
+		// Prepare the next candidate, in case this one is out of range or
+		// rejected by the caller.
+		g.k = g.mac(g.v, []byte{0x00})
+		g.v = g.mac(g.v)
+		if k.Sign() > 0 && k.Cmp(curveN) < 0 {
+			return k
+		}
+	}
+}
+
+func (g *nonceGenerator) mac(data ...[]byte) []byte {
+	m := hmac.New(sha256.New, g.k)
+	for _, d := range data {
+		m.Write(d)
+	}
+	return m.Sum(nil)
+}
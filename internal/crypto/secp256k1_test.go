@@ -0,0 +1,91 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestAddressOf(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "1", want: "0x7e5f4552091a69125d5dfcb7b8c2659029395bdf"},
+		{key: "4646464646464646464646464646464646464646464646464646464646464646", want: "0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f"},
+	}
+
+	for _, tt := range tests {
+		key, _ := new(big.Int).SetString(tt.key, 16)
+		if got := AddressOf(key); got != tt.want {
+			t.Errorf("AddressOf(%s) = %s, want %s", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestSignAndRecover(t *testing.T) {
+	// The signing hash, key and signature of the example transaction of EIP-155.
+	hash, _ := hex.DecodeString("daf5a779ae972f972197303d7b574746c7ef83eadac0f2791ad23db92e4c8e53")
+	key, _ := new(big.Int).SetString("4646464646464646464646464646464646464646464646464646464646464646", 16)
+	wantR, _ := new(big.Int).SetString("28ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276", 16)
+	wantS, _ := new(big.Int).SetString("67cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83", 16)
+
+	r, s, recoveryID, err := Sign(hash, key)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if r.Cmp(wantR) != 0 || s.Cmp(wantS) != 0 || recoveryID != 0 {
+		t.Errorf("Sign() = %x, %x, %d", r, s, recoveryID)
+	}
+
+	address, err := RecoverAddress(hash, r, s, recoveryID)
+	if err != nil || address != "0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f" {
+		t.Errorf("RecoverAddress() = %s, %v", address, err)
+	}
+	if other, err := RecoverAddress(hash, r, s, recoveryID^1); err == nil && other == address {
+		t.Error("expected the other recovery ID to give another key")
+	}
+}
+
+func TestRecoverInvalid(t *testing.T) {
+	hash := Keccak256([]byte("message"))
+	key := big.NewInt(12345)
+	r, s, recoveryID, err := Sign(hash, key)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		r, s       *big.Int
+		recoveryID byte
+	}{
+		{name: "zero r", r: new(big.Int), s: s, recoveryID: recoveryID},
+		{name: "r above the order", r: new(big.Int).Add(curveN, r), s: s, recoveryID: recoveryID},
+		{name: "s above the order", r: r, s: new(big.Int).Add(curveN, s), recoveryID: recoveryID},
+		{name: "invalid recovery ID", r: r, s: s, recoveryID: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := RecoverAddress(hash, tt.r, tt.s, tt.recoveryID); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("RecoverAddress() error = %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+
+	if address, err := RecoverAddress(hash, r, s, recoveryID); err != nil || address != AddressOf(key) {
+		t.Errorf("RecoverAddress() = %s, %v, want %s", address, err, AddressOf(key))
+	}
+
+	// The high s twin of a signature recovers the same key; only EIP-2
+	// tells them apart.
+	highS := new(big.Int).Sub(curveN, s)
+	if address, err := RecoverAddress(hash, r, highS, recoveryID^1); err != nil || address != AddressOf(key) {
+		t.Errorf("RecoverAddress() with high s = %s, %v, want %s", address, err, AddressOf(key))
+	}
+	if !IsLowS(s) || IsLowS(highS) {
+		t.Errorf("IsLowS() = %t, %t, want true, false", IsLowS(s), IsLowS(highS))
+	}
+}
@@ -0,0 +1,63 @@
+package httpserver
+
+import (
+	"errors"
+	"eth_parser/internal/app/tenant"
+	"eth_parser/internal/delivery/httpserver/middleware"
+	"eth_parser/internal/domain/entity"
+	"net/http"
+)
+
+// Tenants keeps the subscriptions of every tenant.
+type Tenants interface {
+	Subscribe(sub entity.Subscription) (entity.Subscription, error)
+	Get(tenant, address string) (entity.Subscription, bool)
+	List(tenant string) []entity.Subscription
+}
+
+// tenantOf returns the tenant of the caller and whether its reads are
+// limited to the tenant's subscriptions. Admin keys and requests without
+// authentication see every address.
+func tenantOf(r *http.Request) (string, bool) {
+	key, ok := middleware.APIKeyFrom(r.Context())
+	if !ok {
+		return entity.DefaultTenant, false
+	}
+	return key.Tenant, !key.Admin
+}
+
+// canAccess reports whether the caller may see data of address.
+func canAccess(r *http.Request, tenants Tenants, address string) bool {
+	tenant, scoped := tenantOf(r)
+	if !scoped {
+		return true
+	}
+	_, ok := tenants.Get(tenant, address)
+	return ok
+}
+
+// claim subscribes the caller's tenant to address, keeping the label and
+// webhook of an existing subscription.
+func claim(r *http.Request, tenants Tenants, address string) error {
+	tenant, _ := tenantOf(r)
+	if _, ok := tenants.Get(tenant, address); ok {
+		return nil
+	}
+	_, err := tenants.Subscribe(entity.Subscription{Tenant: tenant, Address: address})
+	return err
+}
+
+// isAdmin reports whether the caller may change shared data such as the
+// address book.
+func isAdmin(r *http.Request) bool {
+	key, ok := middleware.APIKeyFrom(r.Context())
+	return !ok || key.Admin
+}
+
+// subscribeStatus maps a failed subscription to its HTTP status.
+func subscribeStatus(err error) int {
+	if errors.Is(err, tenant.ErrQuotaExceeded) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,124 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"eth_parser/internal/domain/entity"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// KeyManager issues and revokes API keys.
+type KeyManager interface {
+	Create(name, tenant string, admin bool) (entity.APIKey, string, error)
+	Revoke(id string) (bool, error)
+	List() []entity.APIKey
+}
+
+type AdminHandler struct {
+	Keys KeyManager
+}
+
+func NewAdminHandler(keys KeyManager) *AdminHandler {
+	return &AdminHandler{
+		Keys: keys,
+	}
+}
+
+// apiKeyView is an API key without its hash.
+type apiKeyView struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Tenant    string     `json:"tenant"`
+	Prefix    string     `json:"prefix"`
+	Admin     bool       `json:"admin"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	// Key is the secret, only returned when the key is created.
+	Key string `json:"key,omitempty"`
+}
+
+func newAPIKeyView(key entity.APIKey) apiKeyView {
+	return apiKeyView{
+		ID:        key.ID,
+		Name:      key.Name,
+		Tenant:    key.Tenant,
+		Prefix:    key.Prefix,
+		Admin:     key.Admin,
+		CreatedAt: key.CreatedAt,
+		RevokedAt: key.RevokedAt,
+	}
+}
+
+// APIKeys serves GET and POST on /admin/keys.
+func (h *AdminHandler) APIKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		keys := h.Keys.List()
+		views := make([]apiKeyView, 0, len(keys))
+		for _, key := range keys {
+			views = append(views, newAPIKeyView(key))
+		}
+		json.NewEncoder(w).Encode(views)
+	case http.MethodPost:
+		var requestBody struct {
+			Name string `json:"name"`
+			// Tenant defaults to the key name.
+			Tenant string `json:"tenant"`
+			Admin  bool   `json:"admin"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if requestBody.Name == "" {
+			http.Error(w, "Name is required", http.StatusBadRequest)
+			return
+		}
+
+		if requestBody.Tenant == "" {
+			requestBody.Tenant = requestBody.Name
+		}
+
+		key, secret, err := h.Keys.Create(requestBody.Name, requestBody.Tenant, requestBody.Admin)
+		if err != nil {
+			log.Printf("failed to create API key: %v", err)
+			http.Error(w, "Failed to create key", http.StatusInternalServerError)
+			return
+		}
+
+		view := newAPIKeyView(key)
+		view.Key = secret
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(view)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// APIKey serves DELETE on /admin/keys/{id}, which revokes the key.
+func (h *AdminHandler) APIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/admin/keys/")
+	if id == "" {
+		http.Error(w, "Key ID is required", http.StatusBadRequest)
+		return
+	}
+
+	found, err := h.Keys.Revoke(id)
+	if err != nil {
+		log.Printf("failed to revoke API key %s: %v", id, err)
+		http.Error(w, "Failed to revoke key", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,135 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"eth_parser/internal/app/alert"
+	"eth_parser/internal/delivery/httpserver/apierror"
+	"eth_parser/internal/domain/entity"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// keepAliveInterval is how often an idle alert stream sends a comment, so
+// proxies do not close it.
+const keepAliveInterval = 15 * time.Second
+
+// Alerts manages the alert rules of tenants and the alerts they raised.
+type Alerts interface {
+	CreateRule(rule entity.AlertRule) (entity.AlertRule, error)
+	GetRule(tenant, id string) (entity.AlertRule, bool)
+	ListRules(tenant string) []entity.AlertRule
+	DeleteRule(tenant, id string) (bool, error)
+	ListAlerts(tenant, ruleID string) []entity.Alert
+	Subscribe(tenant string) (<-chan entity.Alert, func())
+}
+
+func (h *V1Handler) ListRules(w http.ResponseWriter, r *http.Request) {
+	tenant, _ := tenantOf(r)
+	writeJSON(w, http.StatusOK, map[string][]entity.AlertRule{"data": h.Alerts.ListRules(tenant)})
+}
+
+func (h *V1Handler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var requestBody struct {
+		Name       string                `json:"name"`
+		Expression string                `json:"expression"`
+		Channels   []entity.AlertChannel `json:"channels"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tenant, _ := tenantOf(r)
+	rule, err := h.Alerts.CreateRule(entity.AlertRule{
+		Tenant:     tenant,
+		Name:       requestBody.Name,
+		Expression: requestBody.Expression,
+		Channels:   requestBody.Channels,
+	})
+	if errors.Is(err, alert.ErrInvalidRule) {
+		apierror.Write(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Println(fmt.Errorf("failed to create alert rule: %w", err))
+		apierror.Write(w, http.StatusInternalServerError, "Failed to create rule")
+		return
+	}
+
+	w.Header().Set("Location", "/v1/rules/"+rule.ID)
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+func (h *V1Handler) GetRule(w http.ResponseWriter, r *http.Request) {
+	tenant, _ := tenantOf(r)
+	rule, ok := h.Alerts.GetRule(tenant, r.PathValue("id"))
+	if !ok {
+		apierror.Write(w, http.StatusNotFound, "Rule not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func (h *V1Handler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	tenant, _ := tenantOf(r)
+	found, err := h.Alerts.DeleteRule(tenant, r.PathValue("id"))
+	if err != nil {
+		log.Println(fmt.Errorf("failed to delete alert rule: %w", err))
+		apierror.Write(w, http.StatusInternalServerError, "Failed to delete rule")
+		return
+	}
+	if !found {
+		apierror.Write(w, http.StatusNotFound, "Rule not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListAlerts serves the alerts of the caller's tenant, optionally only
+// those of ?rule.
+func (h *V1Handler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	tenant, _ := tenantOf(r)
+	writeJSON(w, http.StatusOK, map[string][]entity.Alert{"data": h.Alerts.ListAlerts(tenant, r.URL.Query().Get("rule"))})
+}
+
+// StreamAlerts sends the new alerts of rules with an sse channel as
+// server-sent events until the client goes away.
+func (h *V1Handler) StreamAlerts(w http.ResponseWriter, r *http.Request) {
+	tenant, _ := tenantOf(r)
+	alerts, cancel := h.Alerts.Subscribe(tenant)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	controller := http.NewResponseController(w)
+	// The stream outlives the server's write timeout.
+	controller.SetWriteDeadline(time.Time{})
+	controller.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case a := <-alerts:
+			data, err := json.Marshal(a)
+			if err != nil {
+				log.Println(fmt.Errorf("failed to encode alert: %w", err))
+				continue
+			}
+			fmt.Fprintf(w, "id: %s\nevent: alert\ndata: %s\n\n", a.ID, data)
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,38 @@
+// Package apierror writes the error envelope of the versioned API.
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Envelope is the body of every /v1 error response.
+type Envelope struct {
+	Error Body `json:"error"`
+}
+
+type Body struct {
+	// Code is the snake_case status text, e.g. "not_found".
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Write sends message with status in the error envelope.
+func Write(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Envelope{Error: Body{Code: Code(status), Message: message}})
+}
+
+// Code returns the error code of status.
+func Code(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// Versioned reports whether r targets the versioned API, whose errors use
+// the envelope instead of plain text.
+func Versioned(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/v1/")
+}
@@ -0,0 +1,108 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/parser"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// BackfillJobs schedules and tracks historical scans.
+type BackfillJobs interface {
+	Create(ctx context.Context, address string, fromBlock, toBlock uint64) (entity.BackfillJob, error)
+	Get(id string) (entity.BackfillJob, bool)
+	Cancel(id string) (bool, error)
+}
+
+type BackfillHandler struct {
+	Parser  parser.Parser
+	Jobs    BackfillJobs
+	Tenants Tenants
+}
+
+func NewBackfillHandler(parser parser.Parser, jobs BackfillJobs, tenants Tenants) *BackfillHandler {
+	return &BackfillHandler{
+		Parser:  parser,
+		Jobs:    jobs,
+		Tenants: tenants,
+	}
+}
+
+func (h *BackfillHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var requestBody struct {
+		Address   string `json:"address"`
+		FromBlock uint64 `json:"from_block"`
+		ToBlock   uint64 `json:"to_block"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if requestBody.Address == "" {
+		http.Error(w, "Address is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := claim(r, h.Tenants, requestBody.Address); err != nil {
+		http.Error(w, err.Error(), subscribeStatus(err))
+		return
+	}
+	if !h.Parser.Subscribe(requestBody.Address) {
+		http.Error(w, "Failed to subscribe address", http.StatusInternalServerError)
+		return
+	}
+
+	job, err := h.Jobs.Create(r.Context(), requestBody.Address, requestBody.FromBlock, requestBody.ToBlock)
+	if err != nil {
+		log.Printf("failed to create backfill job: %v", err)
+		http.Error(w, "Failed to create backfill job", http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// Job serves GET and DELETE on /backfill/{id}.
+func (h *BackfillHandler) Job(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/backfill/")
+	if id == "" {
+		http.Error(w, "Job ID is required", http.StatusBadRequest)
+		return
+	}
+
+	// Jobs of addresses the caller did not subscribe do not exist for them.
+	job, ok := h.Jobs.Get(id)
+	if !ok || !canAccess(r, h.Tenants, job.Address) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(job)
+	case http.MethodDelete:
+		found, err := h.Jobs.Cancel(id)
+		if err != nil {
+			log.Printf("failed to cancel backfill job %s: %v", id, err)
+			http.Error(w, "Failed to cancel job", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
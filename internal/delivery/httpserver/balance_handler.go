@@ -0,0 +1,101 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"eth_parser/internal/app/balance"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/utils"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Balances exposes the running balances of subscribed addresses.
+type Balances interface {
+	GetBalances(address string) []entity.Balance
+	Reconcile(ctx context.Context, address, block string) (entity.Reconciliation, error)
+}
+
+type BalanceHandler struct {
+	Balances Balances
+	Tenants  Tenants
+}
+
+func NewBalanceHandler(balances Balances, tenants Tenants) *BalanceHandler {
+	return &BalanceHandler{
+		Balances: balances,
+		Tenants:  tenants,
+	}
+}
+
+// GetBalances serves /balances/{address}. With ?reconcile=true the tracked
+// balances are compared against the chain at ?block (default latest).
+func (h *BalanceHandler) GetBalances(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	address := strings.TrimPrefix(r.URL.Path, "/balances/")
+	if address == "" {
+		http.Error(w, "Address is required", http.StatusBadRequest)
+		return
+	}
+
+	if !canAccess(r, h.Tenants, address) {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+
+	balances := h.Balances.GetBalances(address)
+	if balances == nil {
+		balances = []entity.Balance{}
+	}
+	response := map[string]interface{}{"address": address, "balances": balances}
+
+	if reconcile, _ := strconv.ParseBool(r.URL.Query().Get("reconcile")); reconcile {
+		block, ok := blockParam(r.URL.Query().Get("block"))
+		if !ok {
+			http.Error(w, "Invalid block", http.StatusBadRequest)
+			return
+		}
+
+		reconciliation, err := h.Balances.Reconcile(r.Context(), address, block)
+		if errors.Is(err, balance.ErrNotScanned) {
+			http.Error(w, "Block is not scanned yet", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("failed to reconcile balances of %s: %v", address, err)
+			http.Error(w, "Failed to reconcile balances", http.StatusBadGateway)
+			return
+		}
+		response["reconciliation"] = reconciliation
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// blockParam accepts a block tag, a decimal block number or a hex block number.
+func blockParam(block string) (string, bool) {
+	switch block {
+	case "":
+		return "latest", true
+	case "latest", "safe", "finalized", "earliest":
+		return block, true
+	}
+
+	if strings.HasPrefix(block, "0x") {
+		_, err := utils.HexToInt(block)
+		return block, err == nil
+	}
+
+	n, err := strconv.ParseUint(block, 10, 64)
+	if err != nil {
+		return "", false
+	}
+	return utils.IntToHex(n), true
+}
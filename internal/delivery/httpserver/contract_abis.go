@@ -0,0 +1,83 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"eth_parser/internal/delivery/httpserver/apierror"
+	"eth_parser/internal/domain/entity"
+	"io"
+	"log"
+	"net/http"
+)
+
+// maxABISize bounds uploaded ABIs; those of large contracts are a few
+// hundred kilobytes.
+const maxABISize = 4 << 20
+
+// ContractABIs holds uploaded contract ABIs and decodes transaction input
+// with them.
+type ContractABIs interface {
+	Set(address string, abi json.RawMessage) (entity.ContractABI, error)
+	Get(address string) (entity.ContractABI, bool)
+	Delete(address string) (bool, error)
+	Annotate(txs []entity.Transaction) []entity.Transaction
+}
+
+// decodeInputs annotates txs with their decoded input.
+func decodeInputs(abis ContractABIs, txs []entity.Transaction) []entity.Transaction {
+	if abis == nil {
+		return txs
+	}
+	return abis.Annotate(txs)
+}
+
+func (h *V1Handler) GetContractABI(w http.ResponseWriter, r *http.Request) {
+	abi, ok := h.ABIs.Get(r.PathValue("address"))
+	if !ok {
+		apierror.Write(w, http.StatusNotFound, "ABI not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, abi)
+}
+
+// PutContractABI stores the JSON ABI in the body, or the one under "abi" in
+// a build artifact.
+func (h *V1Handler) PutContractABI(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r) {
+		apierror.Write(w, http.StatusForbidden, "Only admin keys can upload contract ABIs")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxABISize))
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	abi, err := h.ABIs.Set(r.PathValue("address"), body)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, abi)
+}
+
+func (h *V1Handler) DeleteContractABI(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r) {
+		apierror.Write(w, http.StatusForbidden, "Only admin keys can upload contract ABIs")
+		return
+	}
+
+	found, err := h.ABIs.Delete(r.PathValue("address"))
+	if err != nil {
+		log.Printf("failed to delete ABI: %v", err)
+		apierror.Write(w, http.StatusInternalServerError, "Failed to delete ABI")
+		return
+	}
+	if !found {
+		apierror.Write(w, http.StatusNotFound, "ABI not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
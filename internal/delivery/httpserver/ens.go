@@ -0,0 +1,56 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"eth_parser/internal/app/ens"
+	"eth_parser/internal/domain/entity"
+	"net/http"
+	"strconv"
+)
+
+var errENSDisabled = errors.New("ENS resolution is disabled")
+
+// Names resolves ENS names and annotates transactions with primary names.
+type Names interface {
+	Resolve(ctx context.Context, name string) (string, error)
+	Track(name, address string)
+	Annotate(ctx context.Context, txs []entity.Transaction) []entity.Transaction
+}
+
+// resolveName returns the address an ENS name points to together with the
+// normalized name. Addresses are returned as they are, with an empty name.
+func resolveName(ctx context.Context, names Names, address string) (string, string, error) {
+	if !ens.IsName(address) {
+		return address, "", nil
+	}
+	if names == nil {
+		return "", "", errENSDisabled
+	}
+	name, err := ens.Normalize(address)
+	if err != nil {
+		return "", "", err
+	}
+	resolved, err := names.Resolve(ctx, name)
+	if err != nil {
+		return "", "", err
+	}
+	return resolved, name, nil
+}
+
+// resolveStatus maps an error of resolveName to a status code.
+func resolveStatus(err error) int {
+	if errors.Is(err, ens.ErrInvalidName) || errors.Is(err, ens.ErrNotFound) || errors.Is(err, errENSDisabled) {
+		return http.StatusBadRequest
+	}
+	return http.StatusBadGateway
+}
+
+// nameTransactions annotates txs with the primary ENS names of their
+// counterparties when r asks for them with ?ens=true.
+func nameTransactions(r *http.Request, names Names, txs []entity.Transaction) []entity.Transaction {
+	if want, _ := strconv.ParseBool(r.URL.Query().Get("ens")); !want || names == nil {
+		return txs
+	}
+	return names.Annotate(r.Context(), txs)
+}
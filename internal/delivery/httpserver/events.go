@@ -0,0 +1,98 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"eth_parser/internal/app/events"
+	"eth_parser/internal/delivery/httpserver/apierror"
+	"eth_parser/internal/domain/entity"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Events manages the event log subscriptions of tenants and the logs they
+// collected.
+type Events interface {
+	Subscribe(subscription entity.EventSubscription) (entity.EventSubscription, error)
+	Get(tenant, id string) (entity.EventSubscription, bool)
+	List(tenant string) []entity.EventSubscription
+	Delete(tenant, id string) (bool, error)
+	Logs(tenant, id string) ([]entity.EventLog, bool)
+}
+
+func (h *V1Handler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	tenant, _ := tenantOf(r)
+	writeJSON(w, http.StatusOK, map[string][]entity.EventSubscription{"data": h.Events.List(tenant)})
+}
+
+func (h *V1Handler) CreateEvent(w http.ResponseWriter, r *http.Request) {
+	var requestBody struct {
+		Contract string     `json:"contract"`
+		Event    string     `json:"event"`
+		Topics   [][]string `json:"topics"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tenant, _ := tenantOf(r)
+	subscription, err := h.Events.Subscribe(entity.EventSubscription{
+		Tenant:   tenant,
+		Contract: requestBody.Contract,
+		Event:    requestBody.Event,
+		Topics:   requestBody.Topics,
+	})
+	if errors.Is(err, events.ErrInvalidSubscription) {
+		apierror.Write(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Println(fmt.Errorf("failed to create event subscription: %w", err))
+		apierror.Write(w, http.StatusInternalServerError, "Failed to create event subscription")
+		return
+	}
+
+	w.Header().Set("Location", "/v1/events/"+subscription.ID)
+	writeJSON(w, http.StatusCreated, subscription)
+}
+
+func (h *V1Handler) GetEvent(w http.ResponseWriter, r *http.Request) {
+	tenant, _ := tenantOf(r)
+	subscription, ok := h.Events.Get(tenant, r.PathValue("id"))
+	if !ok {
+		apierror.Write(w, http.StatusNotFound, "Event subscription not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, subscription)
+}
+
+func (h *V1Handler) DeleteEvent(w http.ResponseWriter, r *http.Request) {
+	tenant, _ := tenantOf(r)
+	found, err := h.Events.Delete(tenant, r.PathValue("id"))
+	if err != nil {
+		log.Println(fmt.Errorf("failed to delete event subscription: %w", err))
+		apierror.Write(w, http.StatusInternalServerError, "Failed to delete event subscription")
+		return
+	}
+	if !found {
+		apierror.Write(w, http.StatusNotFound, "Event subscription not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListEventLogs serves the logs collected by an event subscription, in
+// chain order.
+func (h *V1Handler) ListEventLogs(w http.ResponseWriter, r *http.Request) {
+	tenant, _ := tenantOf(r)
+	logs, ok := h.Events.Logs(tenant, r.PathValue("id"))
+	if !ok {
+		apierror.Write(w, http.StatusNotFound, "Event subscription not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string][]entity.EventLog{"data": logs})
+}
@@ -0,0 +1,65 @@
+package httpserver
+
+import (
+	"context"
+	"eth_parser/internal/app/export"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// flushEvery is how many exported rows are sent to the client at once.
+const flushEvery = 100
+
+// Exporter produces the export rows of an address.
+type Exporter interface {
+	Rows(ctx context.Context, address string, window export.TimeRange, emit func(export.Row) error) error
+}
+
+// exportFormat returns the CSV or NDJSON format the Accept header of r asks
+// for, if exporter can serve it.
+func exportFormat(r *http.Request, exporter Exporter) (export.Format, bool) {
+	if exporter == nil {
+		return "", false
+	}
+	return export.FormatFor(r.Header.Get("Accept"))
+}
+
+// exportQuery lists the query parameters of an export. Rows carry no labels
+// or names, so ?label and ?ens are refused rather than ignored.
+var exportQuery = []string{"since", "until"}
+
+// streamExport answers with the rows of address mined in window. Rows are
+// flushed to the client as they are produced.
+func streamExport(w http.ResponseWriter, r *http.Request, exporter Exporter, format export.Format, address string, window export.TimeRange) {
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", address+"."+string(format)))
+	flusher, _ := w.(http.Flusher)
+	// Large exports take longer than the server's write timeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	out := export.NewWriter(format, w)
+	rows := 0
+	err := exporter.Rows(r.Context(), address, window, func(row export.Row) error {
+		if err := out.Write(row); err != nil {
+			return err
+		}
+		if rows++; rows%flushEvery == 0 {
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = out.Flush()
+	}
+	if err != nil {
+		// The status is sent already, the client sees a truncated export.
+		log.Println(fmt.Errorf("failed to export transactions of %s: %w", address, err))
+	}
+}
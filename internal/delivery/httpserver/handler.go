@@ -1,20 +1,43 @@
 package httpserver
 
 import (
+	"context"
 	"encoding/json"
 	"eth_parser/internal/domain/entity"
 	"eth_parser/internal/domain/parser"
+	"log"
 	"net/http"
 	"strings"
 )
 
+// Finality tells how deep transactions are.
+type Finality interface {
+	Annotate(ctx context.Context, txs []entity.Transaction) []entity.Transaction
+}
+
 type TransactionHandler struct {
-	Parser parser.Parser
+	Parser  parser.Parser
+	Jobs    BackfillJobs
+	Tenants Tenants
+	// Exporter serves CSV and NDJSON listings, nil disables them.
+	Exporter Exporter
+	Labels   AddressBook
+	ABIs     ContractABIs
+	// Names resolves ENS names, nil disables them.
+	Names    Names
+	Finality Finality
 }
 
-func NewTransactionHandler(parser parser.Parser) *TransactionHandler {
+func NewTransactionHandler(parser parser.Parser, jobs BackfillJobs, tenants Tenants, exporter Exporter, labels AddressBook, abis ContractABIs, names Names, finality Finality) *TransactionHandler {
 	return &TransactionHandler{
-		Parser: parser,
+		Parser:   parser,
+		Jobs:     jobs,
+		Tenants:  tenants,
+		Exporter: exporter,
+		Labels:   labels,
+		ABIs:     abis,
+		Names:    names,
+		Finality: finality,
 	}
 }
 
@@ -35,22 +58,63 @@ func (h *TransactionHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var requestBody map[string]string
+	var requestBody struct {
+		Address string `json:"address"`
+		// Label, Webhook and Confirmations are kept per tenant.
+		Label         string `json:"label"`
+		Webhook       string `json:"webhook"`
+		Confirmations int    `json:"confirmations"`
+		// BackfillFrom optionally imports the address history starting at this block.
+		BackfillFrom *uint64 `json:"backfill_from"`
+	}
 	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	address, ok := requestBody["address"]
-	if !ok || address == "" {
+	if requestBody.Address == "" {
 		http.Error(w, "Address is required", http.StatusBadRequest)
 		return
 	}
+	// The address may also be an ENS name, which the subscription follows.
+	address, name, err := resolveName(r.Context(), h.Names, requestBody.Address)
+	if err != nil {
+		http.Error(w, err.Error(), resolveStatus(err))
+		return
+	}
+
+	tenant, _ := tenantOf(r)
+	subscription, err := h.Tenants.Subscribe(entity.Subscription{
+		Tenant:        tenant,
+		Address:       address,
+		ENS:           name,
+		Label:         requestBody.Label,
+		Webhook:       requestBody.Webhook,
+		Confirmations: requestBody.Confirmations,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), subscribeStatus(err))
+		return
+	}
 
+	if name != "" {
+		h.Names.Track(name, address)
+	}
 	subscribed := h.Parser.Subscribe(address)
+	response := map[string]interface{}{"status": subscribed, "address": address, "subscription": subscription}
+
+	if requestBody.BackfillFrom != nil && h.Jobs != nil {
+		job, err := h.Jobs.Create(r.Context(), address, *requestBody.BackfillFrom, 0)
+		if err != nil {
+			log.Printf("failed to create backfill job: %v", err)
+			http.Error(w, "Failed to create backfill job", http.StatusBadRequest)
+			return
+		}
+		response["backfill_job"] = job.ID
+	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{"status": subscribed, "address": address})
+	json.NewEncoder(w).Encode(response)
 }
 
 func (h *TransactionHandler) GetTransaction(w http.ResponseWriter, r *http.Request) {
@@ -65,10 +129,51 @@ func (h *TransactionHandler) GetTransaction(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if !canAccess(r, h.Tenants, address) {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+
+	window, err := timeRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format, ok := exportFormat(r, h.Exporter); ok {
+		if err := checkQuery(r, exportQuery...); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		streamExport(w, r, h.Exporter, format, address, window)
+		return
+	}
+	if err := checkQuery(r, "label", "ens", "since", "until"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	transactions := h.Parser.GetTransactions(address)
 	if transactions == nil {
 		json.NewEncoder(w).Encode([]entity.Transaction{})
 		return
 	}
+	transactions = filterByTime(transactions, window)
+	transactions = labelTransactions(r, h.Labels, transactions)
+	transactions = nameTransactions(r, h.Names, transactions)
+	transactions = decodeInputs(h.ABIs, transactions)
+	if h.Finality != nil {
+		transactions = h.Finality.Annotate(r.Context(), transactions)
+	}
 	json.NewEncoder(w).Encode(transactions)
 }
+
+// GetSubscriptions lists the subscriptions of the caller's tenant.
+func (h *TransactionHandler) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenant, _ := tenantOf(r)
+	json.NewEncoder(w).Encode(map[string]interface{}{"tenant": tenant, "subscriptions": h.Tenants.List(tenant)})
+}
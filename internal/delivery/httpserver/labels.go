@@ -0,0 +1,117 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"eth_parser/internal/app/addressbook"
+	"eth_parser/internal/delivery/httpserver/apierror"
+	"eth_parser/internal/domain/entity"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+)
+
+// AddressBook names known addresses.
+type AddressBook interface {
+	Set(label entity.AddressLabel) (entity.AddressLabel, error)
+	Get(address string) (entity.AddressLabel, bool)
+	Delete(address string) (bool, error)
+	List(category string) []entity.AddressLabel
+	Import(r io.Reader, format addressbook.Format) (int, error)
+	Annotate(txs []entity.Transaction) []entity.Transaction
+}
+
+// labelTransactions annotates txs and applies the ?label filter of r.
+func labelTransactions(r *http.Request, labels AddressBook, txs []entity.Transaction) []entity.Transaction {
+	if labels == nil {
+		return txs
+	}
+	txs = labels.Annotate(txs)
+	if label := r.URL.Query().Get("label"); label != "" {
+		txs = addressbook.FilterByLabel(txs, label)
+	}
+	return txs
+}
+
+// ListLabels serves the address book, optionally limited to ?category.
+func (h *V1Handler) ListLabels(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string][]entity.AddressLabel{"data": h.Labels.List(r.URL.Query().Get("category"))})
+}
+
+func (h *V1Handler) GetLabel(w http.ResponseWriter, r *http.Request) {
+	label, ok := h.Labels.Get(r.PathValue("address"))
+	if !ok {
+		apierror.Write(w, http.StatusNotFound, "Label not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, label)
+}
+
+func (h *V1Handler) PutLabel(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r) {
+		apierror.Write(w, http.StatusForbidden, "Only admin keys can edit the address book")
+		return
+	}
+
+	var requestBody struct {
+		Label    string `json:"label"`
+		Category string `json:"category"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	label, err := h.Labels.Set(entity.AddressLabel{
+		Address:  r.PathValue("address"),
+		Label:    requestBody.Label,
+		Category: requestBody.Category,
+	})
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, label)
+}
+
+func (h *V1Handler) DeleteLabel(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r) {
+		apierror.Write(w, http.StatusForbidden, "Only admin keys can edit the address book")
+		return
+	}
+
+	found, err := h.Labels.Delete(r.PathValue("address"))
+	if err != nil {
+		log.Printf("failed to delete label: %v", err)
+		apierror.Write(w, http.StatusInternalServerError, "Failed to delete label")
+		return
+	}
+	if !found {
+		apierror.Write(w, http.StatusNotFound, "Label not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ImportLabels reads labels as CSV or JSON, chosen by the Content-Type.
+func (h *V1Handler) ImportLabels(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r) {
+		apierror.Write(w, http.StatusForbidden, "Only admin keys can edit the address book")
+		return
+	}
+
+	format := addressbook.FormatJSON
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+		format = addressbook.FormatCSV
+	}
+
+	n, err := h.Labels.Import(r.Body, format)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int{"imported": n})
+}
@@ -0,0 +1,28 @@
+package httpserver
+
+import (
+	"eth_parser/internal/app/bloom"
+	"eth_parser/internal/delivery/httpserver/apierror"
+	"net/http"
+)
+
+// BloomStats is a block consumer that skips blocks by their logs bloom,
+// e.g. the parser or the event watcher.
+type BloomStats interface {
+	BloomStats() bloom.Snapshot
+}
+
+// GetMetrics reports how many blocks each consumer in Blooms checked and
+// skipped. The counters cover every tenant, so only admin keys see them.
+func (h *V1Handler) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r) {
+		apierror.Write(w, http.StatusForbidden, "Only admin keys can read metrics")
+		return
+	}
+
+	blooms := make(map[string]bloom.Snapshot, len(h.Blooms))
+	for name, stats := range h.Blooms {
+		blooms[name] = stats.BloomStats()
+	}
+	writeJSON(w, http.StatusOK, map[string]map[string]bloom.Snapshot{"bloom": blooms})
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"eth_parser/internal/delivery/httpserver/apierror"
+	"eth_parser/internal/domain/entity"
+	"net/http"
+	"strings"
+)
+
+type contextKey struct{}
+
+// Authenticator resolves an API key secret to its key.
+type Authenticator interface {
+	Authenticate(secret string) (entity.APIKey, bool)
+}
+
+// Auth rejects requests without a valid API key, given either as
+// "Authorization: Bearer <key>" or in the X-API-Key header. The key is
+// stored in the request context for the handlers.
+func Auth(keys Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := keys.Authenticate(secretFrom(r))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="eth_parser"`)
+			fail(w, r, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, key)))
+	})
+}
+
+// RequireAdmin only lets admin keys through. It must run after Auth.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key, ok := APIKeyFrom(r.Context()); !ok || !key.Admin {
+			fail(w, r, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// APIKeyFrom returns the key the request was authenticated with. It reports
+// false when authentication is disabled.
+func APIKeyFrom(ctx context.Context) (entity.APIKey, bool) {
+	key, ok := ctx.Value(contextKey{}).(entity.APIKey)
+	return key, ok
+}
+
+func secretFrom(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, secret, found := strings.Cut(header, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(secret)
+		}
+		return ""
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// fail answers with the error envelope on the versioned API and with plain
+// text on the old routes.
+func fail(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if apierror.Versioned(r) {
+		apierror.Write(w, status, message)
+		return
+	}
+	http.Error(w, message, status)
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"eth_parser/internal/domain/entity"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type authenticatorFunc func(secret string) (entity.APIKey, bool)
+
+func (f authenticatorFunc) Authenticate(secret string) (entity.APIKey, bool) {
+	return f(secret)
+}
+
+func TestAuth(t *testing.T) {
+	keys := authenticatorFunc(func(secret string) (entity.APIKey, bool) {
+		switch secret {
+		case "user-secret":
+			return entity.APIKey{ID: "user"}, true
+		case "admin-secret":
+			return entity.APIKey{ID: "admin", Admin: true}, true
+		}
+		return entity.APIKey{}, false
+	})
+
+	var seen string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, _ := APIKeyFrom(r.Context())
+		seen = key.ID
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/admin/", RequireAdmin(next))
+	mux.Handle("/", next)
+	handler := Auth(keys, mux)
+
+	tests := []struct {
+		name       string
+		path       string
+		header     string
+		value      string
+		wantStatus int
+		wantKey    string
+	}{
+		{"no key", "/subscribe", "", "", http.StatusUnauthorized, ""},
+		{"unknown key", "/subscribe", "X-API-Key", "nope", http.StatusUnauthorized, ""},
+		{"bearer token", "/subscribe", "Authorization", "Bearer user-secret", http.StatusOK, "user"},
+		{"other scheme", "/subscribe", "Authorization", "Basic user-secret", http.StatusUnauthorized, ""},
+		{"api key header", "/subscribe", "X-API-Key", "user-secret", http.StatusOK, "user"},
+		{"admin route with user key", "/admin/keys", "X-API-Key", "user-secret", http.StatusForbidden, ""},
+		{"admin route with admin key", "/admin/keys", "Authorization", "bearer admin-secret", http.StatusOK, "admin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = ""
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if seen != tt.wantKey {
+				t.Errorf("expected key %q, got %q", tt.wantKey, seen)
+			}
+		})
+	}
+}
+
+func TestAuthErrorEnvelope(t *testing.T) {
+	keys := authenticatorFunc(func(secret string) (entity.APIKey, bool) {
+		return entity.APIKey{}, false
+	})
+	handler := Auth(keys, http.NotFoundHandler())
+
+	tests := []struct {
+		path            string
+		wantContentType string
+	}{
+		{"/get-transaction/0xabc", "text/plain; charset=utf-8"},
+		{"/v1/subscriptions", "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if got := rec.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("expected %s, got %s: %s", tt.wantContentType, got, rec.Body)
+			}
+		})
+	}
+}
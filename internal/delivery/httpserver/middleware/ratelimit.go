@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// sweepEvery is how many Allow calls pass between removing idle buckets.
+const sweepEvery = 1024
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps a token bucket per client. Every bucket holds up to burst
+// tokens and refills at rate tokens per second; a request takes one token.
+type Limiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	buckets map[string]*bucket
+	calls   int
+	mutex   sync.Mutex
+}
+
+func NewLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Decision is the outcome of Allow, with the values for the rate limit headers.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed.
+	RetryAfter time.Duration
+}
+
+// Allow takes a token from the bucket of key if one is left.
+func (l *Limiter) Allow(key string) Decision {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.calls++
+	if l.calls%sweepEvery == 0 {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	d := Decision{Limit: int(l.burst)}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = l.duration(1 - b.tokens)
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = l.duration(l.burst - b.tokens)
+	return d
+}
+
+// sweep forgets buckets that refilled completely, which behave like new ones.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// RateLimitByIP limits requests per client IP. It runs before Auth, so
+// floods of requests with invalid keys are limited as well.
+func RateLimitByIP(limiter *Limiter, next http.Handler) http.Handler {
+	return rateLimit(limiter, next, func(r *http.Request) (string, bool) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return "ip:" + host, true
+	})
+}
+
+// RateLimitByKey limits requests per API key. It must run after Auth.
+func RateLimitByKey(limiter *Limiter, next http.Handler) http.Handler {
+	return rateLimit(limiter, next, func(r *http.Request) (string, bool) {
+		key, ok := APIKeyFrom(r.Context())
+		return "key:" + key.ID, ok
+	})
+}
+
+func rateLimit(limiter *Limiter, next http.Handler, client func(r *http.Request) (string, bool)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := client(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		d := limiter.Allow(key)
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		w.Header().Set("RateLimit-Reset", seconds(d.Reset))
+		if !d.Allowed {
+			w.Header().Set("Retry-After", seconds(d.RetryAfter))
+			fail(w, r, http.StatusTooManyRequests, "Too many requests")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// seconds rounds d up to whole seconds, as the headers expect.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
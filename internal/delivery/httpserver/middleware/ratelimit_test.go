@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"eth_parser/internal/domain/entity"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewLimiter(1, 2)
+	limiter.now = func() time.Time { return now }
+
+	tests := []struct {
+		name          string
+		key           string
+		advance       time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}{
+		{"full bucket", "a", 0, true, 1, 0},
+		{"last token", "a", 0, true, 0, 0},
+		{"empty bucket", "a", 0, false, 0, time.Second},
+		{"other client", "b", 0, true, 1, 0},
+		{"partly refilled", "a", 500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+		{"refilled", "a", 500 * time.Millisecond, true, 0, 0},
+		{"capped at burst", "a", time.Hour, true, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			d := limiter.Allow(tt.key)
+			if d.Allowed != tt.wantAllowed || d.Remaining != tt.wantRemaining || d.RetryAfter != tt.wantRetry {
+				t.Errorf("Allow() = %+v", d)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	keys := authenticatorFunc(func(secret string) (entity.APIKey, bool) {
+		return entity.APIKey{ID: secret}, secret != ""
+	})
+	handler := RateLimitByIP(NewLimiter(1, 3), Auth(keys, RateLimitByKey(NewLimiter(1, 1), next)))
+
+	request := func(ip, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/get-transaction/0xabc", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := request("10.0.0.1", "runaway"); rec.Code != http.StatusOK {
+		t.Fatalf("expected the first request to pass, got %d", rec.Code)
+	}
+	rec := request("10.0.0.1", "runaway")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the key to be limited, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1" || rec.Header().Get("RateLimit-Limit") != "1" || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("unexpected headers %v", rec.Header())
+	}
+
+	// Another key behind the same IP still gets through, until the IP is limited.
+	if rec := request("10.0.0.1", "other"); rec.Code != http.StatusOK {
+		t.Errorf("expected another key to pass, got %d", rec.Code)
+	}
+	if rec := request("10.0.0.1", ""); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the IP to be limited, got %d", rec.Code)
+	}
+	if rec := request("10.0.0.2", "other2"); rec.Code != http.StatusOK {
+		t.Errorf("expected another IP to pass, got %d", rec.Code)
+	}
+}
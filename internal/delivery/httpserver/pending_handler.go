@@ -0,0 +1,46 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"eth_parser/internal/domain/entity"
+	"net/http"
+	"strings"
+)
+
+// PendingTransactions lists mempool transactions of an address.
+type PendingTransactions interface {
+	PendingFor(address string) []entity.PendingTransaction
+}
+
+type PendingHandler struct {
+	Pending PendingTransactions
+	Tenants Tenants
+}
+
+func NewPendingHandler(pending PendingTransactions, tenants Tenants) *PendingHandler {
+	return &PendingHandler{
+		Pending: pending,
+		Tenants: tenants,
+	}
+}
+
+// GetPending serves /pending/{address}.
+func (h *PendingHandler) GetPending(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	address := strings.TrimPrefix(r.URL.Path, "/pending/")
+	if address == "" {
+		http.Error(w, "Address is required", http.StatusBadRequest)
+		return
+	}
+
+	if !canAccess(r, h.Tenants, address) {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(h.Pending.PendingFor(address))
+}
@@ -0,0 +1,66 @@
+package httpserver
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"eth_parser/internal/app/txcodec"
+	"eth_parser/internal/delivery/httpserver/apierror"
+	"eth_parser/internal/domain/entity"
+	"net/http"
+	"strings"
+)
+
+// rawTransactionRequest holds a signed transaction as passed to
+// eth_sendRawTransaction.
+type rawTransactionRequest struct {
+	Raw string `json:"raw"`
+}
+
+func (req rawTransactionRequest) decode() (*entity.Transaction, error) {
+	if req.Raw == "" {
+		return nil, errors.New("raw is required")
+	}
+	raw, err := hex.DecodeString(strings.TrimPrefix(req.Raw, "0x"))
+	if err != nil {
+		return nil, errors.New("raw is not hex")
+	}
+	return txcodec.Decode(raw)
+}
+
+// DecodeRawTransaction returns the fields and hash of a signed raw
+// transaction without broadcasting it, with its decoded input.
+func (h *TransactionHandler) DecodeRawTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var requestBody rawTransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	tx, err := requestBody.decode()
+	if err != nil {
+		http.Error(w, "Invalid raw transaction: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(decodeInputs(h.ABIs, []entity.Transaction{*tx})[0])
+}
+
+func (h *V1Handler) DecodeRawTransaction(w http.ResponseWriter, r *http.Request) {
+	var requestBody rawTransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	tx, err := requestBody.decode()
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid raw transaction: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, decodeInputs(h.ABIs, []entity.Transaction{*tx})[0])
+}
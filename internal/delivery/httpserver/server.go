@@ -2,33 +2,323 @@ package httpserver
 
 import (
 	"context"
+	"errors"
+	"eth_parser/internal/app/abi"
+	"eth_parser/internal/app/addressbook"
+	"eth_parser/internal/app/alert"
+	"eth_parser/internal/app/auth"
+	"eth_parser/internal/app/backfill"
+	"eth_parser/internal/app/balance"
+	"eth_parser/internal/app/ens"
+	"eth_parser/internal/app/events"
+	"eth_parser/internal/app/export"
+	"eth_parser/internal/app/finality"
+	"eth_parser/internal/app/jsonrpc"
+	"eth_parser/internal/app/logfilter"
+	"eth_parser/internal/app/mempool"
 	"eth_parser/internal/app/parser"
 	"eth_parser/internal/app/repo"
+	"eth_parser/internal/app/rpcfixture"
+	"eth_parser/internal/app/scanner"
+	"eth_parser/internal/app/tenant"
+	"eth_parser/internal/app/trace"
+	"eth_parser/internal/app/webhook"
+	"eth_parser/internal/config"
 	"eth_parser/internal/delivery/httpserver/middleware"
+	"eth_parser/internal/domain/entity"
+	httpclient "eth_parser/internal/domain/http_client"
+	"eth_parser/internal/domain/repository"
+	"eth_parser/internal/domain/rpc"
+	"path/filepath"
+	"sync"
 
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"time"
 )
 
 type Server struct {
-	server  *http.Server
-	handler *TransactionHandler
-	port    string
+	server          *http.Server
+	handler         *TransactionHandler
+	backfillHandler *BackfillHandler
+	balanceHandler  *BalanceHandler
+	pendingHandler  *PendingHandler
+	v1Handler       *V1Handler
+	adminHandler    *AdminHandler
+	keys            *auth.Keys
+	notifier        *webhook.Notifier
+	tracker         *finality.Tracker
+	keyLimiter      *middleware.Limiter
+	ipLimiter       *middleware.Limiter
+	jobs            *backfill.JobManager
+	parser          *parser.EthereumParser
+	pollInterval    time.Duration
+	scanner         *scanner.Scanner
+	watcher         *mempool.Watcher
+	resolver        *ens.Resolver
+	transports      []rpc.Transport
+	follower        *jsonrpc.Follower
+	logConsumers    []logConsumer
+	port            string
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 }
 
-func NewServer(port string) *Server {
-	repo := repo.NewMemoryTransactionRepo()
-	parser := parser.NewEthereumParser(&http.Client{Timeout: 5 * time.Second}, repo)
+func NewServer(cfg config.Config) (*Server, error) {
+	switch {
+	case cfg.AdminKey == "" && !cfg.AuthDisabled:
+		return nil, errors.New("ADMIN_KEY is required, set AUTH_DISABLED=true to run without authentication")
+	case cfg.AdminKey != "" && cfg.AuthDisabled:
+		return nil, errors.New("ADMIN_KEY and AUTH_DISABLED are mutually exclusive")
+	}
 
-	// Initialize handler
-	handler := NewTransactionHandler(parser)
+	subscriptions := repo.NewMemoryTransactionRepo()
 
-	return &Server{
-		handler: handler,
-		port:    port,
+	var (
+		transactions repository.TransactionStore       = repo.NewMemoryTransactionStore()
+		checkpoints  repository.CheckpointRepo         = repo.NewMemoryCheckpointRepo()
+		jobRepo      repository.JobRepo                = repo.NewMemoryJobRepo()
+		transfers    repository.TransferStore          = repo.NewMemoryTransferStore()
+		balances     repository.BalanceRepo            = repo.NewMemoryBalanceRepo()
+		apiKeys      repository.APIKeyRepo             = repo.NewMemoryAPIKeyRepo()
+		tenantSubs   repository.TenantSubscriptionRepo = repo.NewMemoryTenantSubscriptionRepo()
+		labelRepo    repository.LabelRepo              = repo.NewMemoryLabelRepo()
+		abiRepo      repository.ABIRepo                = repo.NewMemoryABIRepo()
+		ruleRepo     repository.AlertRuleRepo          = repo.NewMemoryAlertRuleRepo()
+		alertRepo    repository.AlertRepo              = repo.NewMemoryAlertRepo()
+		eventSubs    repository.EventSubscriptionRepo  = repo.NewMemoryEventSubscriptionRepo()
+		eventLogs    repository.EventLogRepo           = repo.NewMemoryEventLogRepo()
+		heldRepo     repository.HeldNotificationRepo   = repo.NewMemoryHeldNotificationRepo()
+	)
+	if cfg.DataDir != "" {
+		var err error
+		if transactions, err = repo.NewFileTransactionStore(filepath.Join(cfg.DataDir, "transactions.jsonl")); err != nil {
+			return nil, err
+		}
+		if checkpoints, err = repo.NewFileCheckpointRepo(filepath.Join(cfg.DataDir, "checkpoints.json")); err != nil {
+			return nil, err
+		}
+		if jobRepo, err = repo.NewFileJobRepo(filepath.Join(cfg.DataDir, "jobs.json")); err != nil {
+			return nil, err
+		}
+		if transfers, err = repo.NewFileTransferStore(filepath.Join(cfg.DataDir, "transfers.jsonl")); err != nil {
+			return nil, err
+		}
+		if balances, err = repo.NewFileBalanceRepo(filepath.Join(cfg.DataDir, "balances.json"), filepath.Join(cfg.DataDir, "opening_balances.json")); err != nil {
+			return nil, err
+		}
+		if apiKeys, err = repo.NewFileAPIKeyRepo(filepath.Join(cfg.DataDir, "keys.json")); err != nil {
+			return nil, err
+		}
+		if tenantSubs, err = repo.NewFileTenantSubscriptionRepo(filepath.Join(cfg.DataDir, "subscriptions.json")); err != nil {
+			return nil, err
+		}
+		if labelRepo, err = repo.NewFileLabelRepo(filepath.Join(cfg.DataDir, "labels.json")); err != nil {
+			return nil, err
+		}
+		if abiRepo, err = repo.NewFileABIRepo(filepath.Join(cfg.DataDir, "abis.json")); err != nil {
+			return nil, err
+		}
+		if ruleRepo, err = repo.NewFileAlertRuleRepo(filepath.Join(cfg.DataDir, "rules.json")); err != nil {
+			return nil, err
+		}
+		if alertRepo, err = repo.NewFileAlertRepo(filepath.Join(cfg.DataDir, "alerts.jsonl")); err != nil {
+			return nil, err
+		}
+		if eventSubs, err = repo.NewFileEventSubscriptionRepo(filepath.Join(cfg.DataDir, "events.json")); err != nil {
+			return nil, err
+		}
+		if eventLogs, err = repo.NewFileEventLogRepo(filepath.Join(cfg.DataDir, "event_logs.jsonl")); err != nil {
+			return nil, err
+		}
+		if heldRepo, err = repo.NewFileHeldNotificationRepo(filepath.Join(cfg.DataDir, "held_webhooks.json")); err != nil {
+			return nil, err
+		}
+	}
+
+	// Transactions are stored once per address and shared by all tenants
+	// watching it; each of them gets its own webhook call.
+	tenants := tenant.NewSubscriptions(tenantSubs, tenant.Quotas{Default: cfg.SubscriptionQuota, Tenants: cfg.TenantQuotas})
+	notifier := webhook.NewNotifier(transactions, tenants, heldRepo, webhook.NewClient(10*time.Second))
+	// The parser and the mempool watcher follow every address a tenant
+	// subscribed, including those persisted before a restart.
+	for _, address := range tenants.Addresses() {
+		if err := subscriptions.StoreSubscription(address); err != nil {
+			return nil, fmt.Errorf("failed to restore subscription of %s: %w", address, err)
+		}
+	}
+	// Alert rules are evaluated on every transfer booked by the scan.
+	labels := addressbook.NewBook(labelRepo)
+	abis := abi.NewDecoder(abiRepo)
+	alerts := alert.NewEngine(transfers, ruleRepo, alertRepo, tenants, labels, notifier)
+
+	var httpClient httpclient.HTTPClient = &http.Client{Timeout: 5 * time.Second}
+	if cfg.RecordDir != "" {
+		recorder, err := rpcfixture.NewRecorder(httpClient, cfg.RecordDir)
+		if err != nil {
+			return nil, err
+		}
+		httpClient = recorder
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// RPC_URL selects HTTP, WebSocket or IPC by its scheme.
+	primary, err := jsonrpc.Dial(ctx, cfg.RPCURL, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", cfg.RPCURL, err)
+	}
+	var (
+		transport  = primary
+		transports = []rpc.Transport{primary}
+		follower   *jsonrpc.Follower
+	)
+	if cfg.WSURL != "" {
+		ws, err := jsonrpc.NewWebSocketTransport(ctx, cfg.WSURL)
+		if err != nil {
+			primary.Close()
+			return nil, fmt.Errorf("failed to connect to %s: %w", cfg.WSURL, err)
+		}
+		transport = ws
+		transports = append(transports, ws)
+		// Missed heads are fetched from RPC_URL while the socket reconnects.
+		follower = jsonrpc.NewFollower(ws, jsonrpc.NewClient(primary))
+	} else if subscriber, ok := primary.(jsonrpc.Subscriber); ok {
+		follower = jsonrpc.NewFollower(subscriber, jsonrpc.NewClient(primary))
 	}
+
+	opts := []parser.Option{
+		parser.WithTransport(transport),
+		parser.WithTransactionStore(notifier),
+		parser.WithCheckpointRepo(checkpoints),
+		parser.WithBalanceTracker(balance.NewTracker(alerts, balances)),
+	}
+	// Plain ether transfers leave no logs, so balances need every new head
+	// read in full.
+	if cfg.BalanceTracking {
+		opts = append(opts, parser.WithPlainTransfers())
+	}
+	if cfg.TraceMode != "" {
+		mode, err := trace.ParseMode(cfg.TraceMode)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, parser.WithTracer(mode))
+	}
+
+	parser := parser.NewEthereumParser(httpClient, subscriptions, opts...)
+	tenants.SetHead(parser)
+	jobs := backfill.NewJobManager(jobRepo, backfill.NewEngine(checkpoints, backfill.DefaultConfig()), parser)
+
+	// Webhooks of subscriptions asking for confirmations wait for the head
+	// to move far enough.
+	tracker := finality.NewTracker(parser, cfg.PollInterval)
+	notifier.SetCanonical(tracker)
+	tracker.OnChange(notifier.SetState)
+
+	// Event subscriptions collect their logs from every new block, and
+	// subscribed addresses scanned up to the head keep up with it. Both skip
+	// blocks whose logs bloom rules them out.
+	blockScanner := scanner.NewScanner(parser, checkpoints, cfg.PollInterval)
+	eventWatcher := events.NewWatcher(parser, eventSubs, eventLogs, abis)
+	blockScanner.AddHandler(parser)
+	blockScanner.AddHandler(eventWatcher)
+	blooms := map[string]BloomStats{"transfers": parser, "events": eventWatcher}
+
+	// Subscriptions made by ENS name move along when the name is pointed
+	// to another address.
+	var (
+		resolver *ens.Resolver
+		names    Names
+	)
+	if cfg.ENSRegistry != "" {
+		resolver = ens.NewResolver(parser, cfg.ENSRegistry, cfg.ENSRefreshInterval)
+		for _, sub := range tenants.Named() {
+			resolver.Track(sub.ENS, sub.Address)
+		}
+		resolver.OnChange(func(name, old, address string) {
+			moved, err := tenants.Follow(name, old, address)
+			if err != nil {
+				log.Println(fmt.Errorf("failed to move the subscriptions of %s: %w", name, err))
+			}
+			if len(moved) > 0 {
+				log.Printf("%s moved from %s to %s, %d subscriptions follow it", name, old, address, len(moved))
+				parser.Subscribe(address)
+			}
+		})
+		names = resolver
+	}
+
+	var watcher *mempool.Watcher
+	if cfg.Mempool {
+		watcher = mempool.NewWatcher(parser, subscriptions, repo.NewMemoryPendingStore(), mempool.DefaultConfig())
+		blockScanner.AddHandler(watcher)
+	}
+
+	var (
+		keys         *auth.Keys
+		adminHandler *AdminHandler
+	)
+	if cfg.AdminKey != "" {
+		keys = auth.NewKeys(apiKeys)
+		if err := keys.EnsureAdmin(cfg.AdminKey); err != nil {
+			return nil, fmt.Errorf("failed to store admin key: %w", err)
+		}
+		adminHandler = NewAdminHandler(keys)
+	} else {
+		log.Println("AUTH_DISABLED is set, the API is open to everyone")
+	}
+
+	var keyLimiter, ipLimiter *middleware.Limiter
+	if cfg.RateLimitPerKey > 0 {
+		keyLimiter = middleware.NewLimiter(cfg.RateLimitPerKey, burst(cfg.RateLimitPerKey))
+	}
+	if cfg.RateLimitPerIP > 0 {
+		ipLimiter = middleware.NewLimiter(cfg.RateLimitPerIP, burst(cfg.RateLimitPerIP))
+	}
+
+	// Initialize handlers
+	exporter := export.NewExporter(parser, parser)
+	handler := NewTransactionHandler(parser, jobs, tenants, exporter, labels, abis, names, tracker)
+	backfillHandler := NewBackfillHandler(parser, jobs, tenants)
+	var balanceHandler *BalanceHandler
+	if cfg.BalanceTracking {
+		balanceHandler = NewBalanceHandler(parser, tenants)
+	}
+
+	v1Handler := NewV1Handler(parser, tenants, exporter, labels, abis, names, alerts, eventWatcher, blooms, tracker)
+
+	var pendingHandler *PendingHandler
+	if watcher != nil {
+		pendingHandler = NewPendingHandler(watcher, tenants)
+	}
+
+	return &Server{
+		handler:         handler,
+		backfillHandler: backfillHandler,
+		balanceHandler:  balanceHandler,
+		pendingHandler:  pendingHandler,
+		v1Handler:       v1Handler,
+		adminHandler:    adminHandler,
+		keys:            keys,
+		notifier:        notifier,
+		tracker:         tracker,
+		keyLimiter:      keyLimiter,
+		ipLimiter:       ipLimiter,
+		jobs:            jobs,
+		parser:          parser,
+		pollInterval:    cfg.PollInterval,
+		scanner:         blockScanner,
+		watcher:         watcher,
+		resolver:        resolver,
+		transports:      transports,
+		follower:        follower,
+		logConsumers:    []logConsumer{parser, eventWatcher},
+		port:            cfg.Port,
+	}, nil
 }
 
 func (s *Server) setup() {
@@ -36,10 +326,41 @@ func (s *Server) setup() {
 
 	mux.HandleFunc("/get-current-block", s.handler.GetCurrentBlock)
 	mux.HandleFunc("/subscribe", s.handler.Subscribe)
+	mux.HandleFunc("/subscriptions", s.handler.GetSubscriptions)
 	mux.HandleFunc("/get-transaction/", s.handler.GetTransaction)
+	mux.HandleFunc("/decode-raw-transaction", s.handler.DecodeRawTransaction)
+	mux.HandleFunc("/backfill", s.backfillHandler.CreateJob)
+	mux.HandleFunc("/backfill/", s.backfillHandler.Job)
+	if s.balanceHandler != nil {
+		mux.HandleFunc("/balances/", s.balanceHandler.GetBalances)
+	}
+	if s.pendingHandler != nil {
+		mux.HandleFunc("/pending/", s.pendingHandler.GetPending)
+	}
+
+	s.v1Handler.Register(mux)
+
+	var handler http.Handler = mux
+	if s.keys != nil {
+		mux.Handle("/admin/keys", middleware.RequireAdmin(http.HandlerFunc(s.adminHandler.APIKeys)))
+		mux.Handle("/admin/keys/", middleware.RequireAdmin(http.HandlerFunc(s.adminHandler.APIKey)))
+		if s.keyLimiter != nil {
+			handler = middleware.RateLimitByKey(s.keyLimiter, handler)
+		}
+		handler = middleware.Auth(s.keys, handler)
+
+		// The API description is public.
+		public := http.NewServeMux()
+		public.HandleFunc("GET /v1/openapi.json", s.v1Handler.OpenAPI)
+		public.Handle("/", handler)
+		handler = public
+	}
+	if s.ipLimiter != nil {
+		handler = middleware.RateLimitByIP(s.ipLimiter, handler)
+	}
 
 	// Wrap the mux with the recovery middleware
-	handler := middleware.Recovery(mux)
+	handler = middleware.Recovery(handler)
 
 	s.server = &http.Server{
 		Addr:         fmt.Sprintf(":%s", s.port),
@@ -53,11 +374,174 @@ func (s *Server) Start(errChan chan error) {
 	s.setup()
 	log.Printf("Server starting on port %s", s.port)
 
+	// Pick up backfill jobs interrupted by the last shutdown
+	s.jobs.Start()
+
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	s.run(func() { s.notifier.Run(ctx) })
+	s.run(func() { s.tracker.Run(ctx) })
+	// Histories of subscribed addresses are imported in the background, not
+	// when they are listed.
+	s.run(func() { s.parser.Run(ctx, s.pollInterval) })
+	if s.scanner != nil {
+		s.run(func() { s.scanner.Run(ctx) })
+	}
+	if s.watcher != nil {
+		s.run(func() { s.watcher.Run(ctx) })
+	}
+	if s.resolver != nil {
+		s.run(func() { s.resolver.Run(ctx) })
+	}
+	if s.follower != nil && s.scanner != nil {
+		s.run(func() { s.followHeads(ctx) })
+		s.run(func() { s.followLogs(ctx) })
+	}
+
 	go func() {
 		errChan <- s.server.ListenAndServe()
 	}()
 }
 
+// burst lets a client spend two seconds worth of requests at once.
+func burst(rate float64) int {
+	return max(1, int(math.Ceil(2*rate)))
+}
+
+// run starts a background worker that is stopped together with the server.
+func (s *Server) run(worker func()) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		worker()
+	}()
+}
+
+// followHeads wakes the scanner whenever the node announces a new head,
+// subscribing again if the subscription is lost for good.
+func (s *Server) followHeads(ctx context.Context) {
+	heads := make(chan *entity.Block, 16)
+	go func() {
+		for range heads {
+			s.scanner.Trigger()
+		}
+	}()
+	defer close(heads)
+
+	for {
+		err := s.follower.Heads(ctx, heads)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Println(fmt.Errorf("failed to follow new heads: %w", err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// logConsumer takes the logs a logs subscription pushes ahead of the
+// scanner, e.g. the parser or the event watcher.
+type logConsumer interface {
+	LogFilters() []logfilter.Filter
+	HandleLog(ctx context.Context, l entity.Log) error
+}
+
+// followLogs subscribes to the logs every consumer is interested in, so
+// they are handled as soon as the node announces them rather than when the
+// scanner reaches their block. The scanner still handles every block, which
+// covers logs a subscription misses. Subscriptions are renewed when the
+// filters change, e.g. after a new address was subscribed.
+func (s *Server) followLogs(ctx context.Context) {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	var (
+		wg      sync.WaitGroup
+		cancel  = func() {}
+		current string
+	)
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	for {
+		var (
+			consumers []logConsumer
+			filters   []logfilter.Filter
+		)
+		for _, consumer := range s.logConsumers {
+			for _, filter := range consumer.LogFilters() {
+				consumers = append(consumers, consumer)
+				filters = append(filters, filter)
+			}
+		}
+
+		if key := fmt.Sprint(filters); key != current {
+			cancel()
+			wg.Wait()
+			current = key
+
+			followCtx, stop := context.WithCancel(ctx)
+			cancel = stop
+			for i, filter := range filters {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					s.followFilter(followCtx, consumers[i], filter)
+				}()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// followFilter hands the logs selected by filter to consumer, subscribing
+// again if the subscription is lost for good.
+func (s *Server) followFilter(ctx context.Context, consumer logConsumer, filter logfilter.Filter) {
+	logs := make(chan entity.Log, 64)
+	go func() {
+		for l := range logs {
+			if err := consumer.HandleLog(ctx, l); err != nil && ctx.Err() == nil {
+				log.Println(fmt.Errorf("failed to handle log %s:%s: %w", l.TransactionHash, l.LogIndex, err))
+			}
+		}
+	}()
+	defer close(logs)
+
+	for {
+		err := s.follower.Logs(ctx, filter.SubscribeParams(), logs)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Println(fmt.Errorf("failed to follow logs: %w", err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
 func (s *Server) Stop(ctx context.Context) error {
-	return s.server.Shutdown(ctx)
+	err := s.server.Shutdown(ctx)
+	s.cancel()
+	s.wg.Wait()
+	s.jobs.Stop()
+	for _, transport := range s.transports {
+		if closeErr := transport.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
 }
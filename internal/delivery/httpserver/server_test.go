@@ -0,0 +1,73 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/app/simnode"
+	"eth_parser/internal/config"
+	"eth_parser/internal/domain/entity"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewServerRequiresAuthentication(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+	}{
+		{"no admin key", config.Config{}},
+		{"admin key and auth disabled", config.Config{AdminKey: "secret", AuthDisabled: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewServer(tt.cfg); err == nil {
+				t.Fatal("NewServer succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestNewServerRestoresSubscriptions(t *testing.T) {
+	node := httptest.NewServer(simnode.NewNode(simnode.NewChain(simnode.DefaultConfig()), 1))
+	defer node.Close()
+	cfg := config.Config{RPCURL: node.URL, DataDir: t.TempDir(), AuthDisabled: true, PollInterval: time.Second}
+
+	first, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	rec := httptest.NewRecorder()
+	first.handler.Subscribe(rec, httptest.NewRequest(http.MethodPost, "/subscribe", strings.NewReader(`{"address": "`+treasury+`"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("subscribe answered %d: %s", rec.Code, rec.Body)
+	}
+
+	// A transaction found before the restart.
+	store, err := repo.NewFileTransactionStore(filepath.Join(cfg.DataDir, "transactions.jsonl"))
+	if err != nil {
+		t.Fatalf("NewFileTransactionStore() error = %v", err)
+	}
+	blockNumber, timestamp := "0x1", "0x65fa0000"
+	if _, err := store.StoreTransaction(treasury, entity.Transaction{Hash: "0xabc", From: treasury, BlockNumber: &blockNumber, BlockTimestamp: &timestamp}); err != nil {
+		t.Fatalf("StoreTransaction() error = %v", err)
+	}
+	store.Close()
+
+	restarted, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	rec = httptest.NewRecorder()
+	restarted.handler.GetTransaction(rec, httptest.NewRequest(http.MethodGet, "/get-transaction/"+treasury, nil))
+	var txs []entity.Transaction
+	if err := json.NewDecoder(rec.Body).Decode(&txs); err != nil {
+		t.Fatalf("failed to decode transactions: %v", err)
+	}
+	if len(txs) != 1 || txs[0].Hash != "0xabc" {
+		t.Errorf("transactions after restart = %+v, want the stored one", txs)
+	}
+}
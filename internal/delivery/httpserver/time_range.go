@@ -0,0 +1,88 @@
+package httpserver
+
+import (
+	"eth_parser/internal/app/export"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/utils"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// parseTime reads an RFC 3339 time or Unix seconds.
+func parseTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor Unix seconds", value)
+	}
+	return t, nil
+}
+
+// timeRange reads the ?since and ?until bounds of r, both inclusive. A
+// missing bound is left open.
+func timeRange(r *http.Request) (export.TimeRange, error) {
+	query := r.URL.Query()
+	var (
+		window export.TimeRange
+		err    error
+	)
+	if value := query.Get("since"); value != "" {
+		if window.Since, err = parseTime(value); err != nil {
+			return export.TimeRange{}, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+	if value := query.Get("until"); value != "" {
+		if window.Until, err = parseTime(value); err != nil {
+			return export.TimeRange{}, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+	return window, nil
+}
+
+// filterByTime keeps the txs mined in window. Transactions without a block
+// timestamp are left out once a bound is set.
+func filterByTime(txs []entity.Transaction, window export.TimeRange) []entity.Transaction {
+	if window.IsZero() {
+		return txs
+	}
+
+	filtered := make([]entity.Transaction, 0, len(txs))
+	for _, tx := range txs {
+		if tx.BlockTimestamp == nil {
+			continue
+		}
+		seconds, err := utils.HexToInt(*tx.BlockTimestamp)
+		if err != nil {
+			continue
+		}
+		if window.Contains(time.Unix(seconds, 0)) {
+			filtered = append(filtered, tx)
+		}
+	}
+	return filtered
+}
+
+// checkQuery rejects query parameters of r other than allowed, which would
+// otherwise be ignored silently.
+func checkQuery(r *http.Request, allowed ...string) error {
+	var unknown []string
+	for name := range r.URL.Query() {
+		known := false
+		for _, a := range allowed {
+			known = known || name == a
+		}
+		if !known {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("unsupported query parameter %q", unknown[0])
+}
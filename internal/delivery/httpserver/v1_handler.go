@@ -0,0 +1,212 @@
+package httpserver
+
+import (
+	_ "embed"
+	"encoding/json"
+	"eth_parser/internal/delivery/httpserver/apierror"
+	"eth_parser/internal/domain/entity"
+	"eth_parser/internal/domain/parser"
+	"net/http"
+)
+
+// openAPISpec documents the routes of V1Handler. TestOpenAPIMatchesRoutes
+// fails when the two drift apart.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// Route is a handler of the versioned API with its method and path pattern.
+type Route struct {
+	Method  string
+	Pattern string
+	Handler http.HandlerFunc
+}
+
+// V1Handler serves the resource-oriented /v1 API. Errors are answered with
+// the apierror envelope.
+type V1Handler struct {
+	Parser   parser.Parser
+	Tenants  Tenants
+	Exporter Exporter
+	Labels   AddressBook
+	ABIs     ContractABIs
+	Names    Names
+	Alerts   Alerts
+	Events   Events
+	Blooms   map[string]BloomStats
+	Finality Finality
+}
+
+func NewV1Handler(parser parser.Parser, tenants Tenants, exporter Exporter, labels AddressBook, abis ContractABIs, names Names, alerts Alerts, events Events, blooms map[string]BloomStats, finality Finality) *V1Handler {
+	return &V1Handler{
+		Parser:   parser,
+		Tenants:  tenants,
+		Exporter: exporter,
+		Labels:   labels,
+		ABIs:     abis,
+		Names:    names,
+		Alerts:   alerts,
+		Events:   events,
+		Blooms:   blooms,
+		Finality: finality,
+	}
+}
+
+// Routes lists every /v1 route, to be registered on a ServeMux.
+func (h *V1Handler) Routes() []Route {
+	return []Route{
+		{http.MethodGet, "/v1/openapi.json", h.OpenAPI},
+		{http.MethodGet, "/v1/blocks/latest", h.LatestBlock},
+		{http.MethodGet, "/v1/subscriptions", h.ListSubscriptions},
+		{http.MethodPost, "/v1/subscriptions", h.CreateSubscription},
+		{http.MethodGet, "/v1/subscriptions/{address}", h.GetSubscription},
+		{http.MethodGet, "/v1/addresses/{address}/transactions", h.ListTransactions},
+		{http.MethodPost, "/v1/transactions/decode", h.DecodeRawTransaction},
+		{http.MethodGet, "/v1/labels", h.ListLabels},
+		{http.MethodPost, "/v1/labels", h.ImportLabels},
+		{http.MethodGet, "/v1/labels/{address}", h.GetLabel},
+		{http.MethodPut, "/v1/labels/{address}", h.PutLabel},
+		{http.MethodDelete, "/v1/labels/{address}", h.DeleteLabel},
+		{http.MethodGet, "/v1/contracts/{address}/abi", h.GetContractABI},
+		{http.MethodPut, "/v1/contracts/{address}/abi", h.PutContractABI},
+		{http.MethodDelete, "/v1/contracts/{address}/abi", h.DeleteContractABI},
+		{http.MethodGet, "/v1/rules", h.ListRules},
+		{http.MethodPost, "/v1/rules", h.CreateRule},
+		{http.MethodGet, "/v1/rules/{id}", h.GetRule},
+		{http.MethodDelete, "/v1/rules/{id}", h.DeleteRule},
+		{http.MethodGet, "/v1/alerts", h.ListAlerts},
+		{http.MethodGet, "/v1/alerts/stream", h.StreamAlerts},
+		{http.MethodGet, "/v1/events", h.ListEvents},
+		{http.MethodPost, "/v1/events", h.CreateEvent},
+		{http.MethodGet, "/v1/events/{id}", h.GetEvent},
+		{http.MethodDelete, "/v1/events/{id}", h.DeleteEvent},
+		{http.MethodGet, "/v1/events/{id}/logs", h.ListEventLogs},
+		{http.MethodGet, "/v1/metrics", h.GetMetrics},
+	}
+}
+
+// Register adds the routes to mux.
+func (h *V1Handler) Register(mux *http.ServeMux) {
+	for _, route := range h.Routes() {
+		mux.HandleFunc(route.Method+" "+route.Pattern, route.Handler)
+	}
+}
+
+func (h *V1Handler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+func (h *V1Handler) LatestBlock(w http.ResponseWriter, r *http.Request) {
+	number := h.Parser.GetCurrentBlock()
+	if number == 0 {
+		apierror.Write(w, http.StatusBadGateway, "Failed to get the latest block from the node")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int{"number": number})
+}
+
+func (h *V1Handler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	tenant, _ := tenantOf(r)
+	writeJSON(w, http.StatusOK, map[string][]entity.Subscription{"data": h.Tenants.List(tenant)})
+}
+
+func (h *V1Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	var requestBody struct {
+		Address       string `json:"address"`
+		Label         string `json:"label"`
+		Webhook       string `json:"webhook"`
+		Confirmations int    `json:"confirmations"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		apierror.Write(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if requestBody.Address == "" {
+		apierror.Write(w, http.StatusBadRequest, "Address is required")
+		return
+	}
+	address, name, err := resolveName(r.Context(), h.Names, requestBody.Address)
+	if err != nil {
+		apierror.Write(w, resolveStatus(err), err.Error())
+		return
+	}
+
+	tenant, _ := tenantOf(r)
+	subscription, err := h.Tenants.Subscribe(entity.Subscription{
+		Tenant:        tenant,
+		Address:       address,
+		ENS:           name,
+		Label:         requestBody.Label,
+		Webhook:       requestBody.Webhook,
+		Confirmations: requestBody.Confirmations,
+	})
+	if err != nil {
+		apierror.Write(w, subscribeStatus(err), err.Error())
+		return
+	}
+	if name != "" {
+		h.Names.Track(name, address)
+	}
+	h.Parser.Subscribe(address)
+
+	w.Header().Set("Location", "/v1/subscriptions/"+subscription.Address)
+	writeJSON(w, http.StatusCreated, subscription)
+}
+
+func (h *V1Handler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	tenant, _ := tenantOf(r)
+	subscription, ok := h.Tenants.Get(tenant, r.PathValue("address"))
+	if !ok {
+		apierror.Write(w, http.StatusNotFound, "Subscription not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, subscription)
+}
+
+func (h *V1Handler) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	address := r.PathValue("address")
+	if !canAccess(r, h.Tenants, address) {
+		apierror.Write(w, http.StatusNotFound, "Address not found")
+		return
+	}
+
+	window, err := timeRange(r)
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if format, ok := exportFormat(r, h.Exporter); ok {
+		if err := checkQuery(r, exportQuery...); err != nil {
+			apierror.Write(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		streamExport(w, r, h.Exporter, format, address, window)
+		return
+	}
+	if err := checkQuery(r, "label", "ens", "since", "until"); err != nil {
+		apierror.Write(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	transactions := h.Parser.GetTransactions(address)
+	if transactions == nil {
+		transactions = []entity.Transaction{}
+	}
+	transactions = filterByTime(transactions, window)
+	transactions = labelTransactions(r, h.Labels, transactions)
+	transactions = nameTransactions(r, h.Names, transactions)
+	transactions = decodeInputs(h.ABIs, transactions)
+	if h.Finality != nil {
+		transactions = h.Finality.Annotate(r.Context(), transactions)
+	}
+	writeJSON(w, http.StatusOK, map[string][]entity.Transaction{"data": transactions})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
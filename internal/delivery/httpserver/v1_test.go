@@ -0,0 +1,362 @@
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"eth_parser/internal/app/abi"
+	"eth_parser/internal/app/addressbook"
+	"eth_parser/internal/app/alert"
+	"eth_parser/internal/app/ens"
+	"eth_parser/internal/app/events"
+	"eth_parser/internal/app/export"
+	"eth_parser/internal/app/repo"
+	"eth_parser/internal/app/tenant"
+	"eth_parser/internal/delivery/httpserver/apierror"
+	"eth_parser/internal/delivery/httpserver/middleware"
+	"eth_parser/internal/domain/entity"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	treasury = "0x1111111111111111111111111111111111111111"
+	binance  = "0x28c6c06298d514db089934071355e5743bf21d60"
+	usdt     = "0xdac17f958d2ee523a2206206994597c13d831ec7"
+	// transferCall sends 1000 base units to the treasury.
+	transferCall = "0xa9059cbb0000000000000000000000001111111111111111111111111111111111111111" +
+		"00000000000000000000000000000000000000000000000000000000000003e8"
+	// eip155Tx is the signed example transaction of EIP-155.
+	eip155Tx = "f86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83"
+)
+
+type finalityFunc func(txs []entity.Transaction) []entity.Transaction
+
+func (f finalityFunc) Annotate(ctx context.Context, txs []entity.Transaction) []entity.Transaction {
+	return f(txs)
+}
+
+type mockParser struct {
+	block        int
+	transactions map[string][]entity.Transaction
+}
+
+func (m *mockParser) GetCurrentBlock() int {
+	return m.block
+}
+
+func (m *mockParser) Subscribe(address string) bool {
+	return true
+}
+
+func (m *mockParser) GetTransactions(address string) []entity.Transaction {
+	return m.transactions[address]
+}
+
+// mockNames resolves the names it maps to addresses, which are their
+// primary names.
+type mockNames map[string]string
+
+func (m mockNames) Resolve(ctx context.Context, name string) (string, error) {
+	if address, ok := m[name]; ok {
+		return address, nil
+	}
+	return "", ens.ErrNotFound
+}
+
+func (m mockNames) Track(name, address string) {}
+
+func (m mockNames) Annotate(ctx context.Context, txs []entity.Transaction) []entity.Transaction {
+	for i := range txs {
+		for name, address := range m {
+			if strings.EqualFold(txs[i].From, address) {
+				txs[i].FromENS = name
+			}
+		}
+	}
+	return txs
+}
+
+type exporterFunc func(ctx context.Context, address string, window export.TimeRange, emit func(export.Row) error) error
+
+func (f exporterFunc) Rows(ctx context.Context, address string, window export.TimeRange, emit func(export.Row) error) error {
+	return f(ctx, address, window, emit)
+}
+
+type keysFunc func(secret string) (entity.APIKey, bool)
+
+func (f keysFunc) Authenticate(secret string) (entity.APIKey, bool) {
+	return f(secret)
+}
+
+type openAPIOperation struct {
+	Parameters []struct {
+		Ref string `json:"$ref"`
+		In  string `json:"in"`
+	} `json:"parameters"`
+	Responses map[string]json.RawMessage `json:"responses"`
+}
+
+func loadSpec(t *testing.T) map[string]map[string]openAPIOperation {
+	t.Helper()
+	var spec struct {
+		OpenAPI string                                 `json:"openapi"`
+		Paths   map[string]map[string]openAPIOperation `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("invalid openapi.json: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Fatalf("expected an OpenAPI 3 document, got %q", spec.OpenAPI)
+	}
+	return spec.Paths
+}
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	paths := loadSpec(t)
+	routes := NewV1Handler(&mockParser{}, nil, nil, nil, nil, nil, nil, nil, nil, nil).Routes()
+
+	documented := 0
+	for _, operations := range paths {
+		documented += len(operations)
+	}
+	if documented != len(routes) {
+		t.Errorf("openapi.json documents %d operations, the handler serves %d routes", documented, len(routes))
+	}
+
+	for _, route := range routes {
+		operation, ok := paths[route.Pattern][strings.ToLower(route.Method)]
+		if !ok {
+			t.Errorf("%s %s is not documented", route.Method, route.Pattern)
+			continue
+		}
+		// Path parameters are shared components, query parameters are inline.
+		pathParameters := 0
+		for _, parameter := range operation.Parameters {
+			if parameter.In != "query" {
+				pathParameters++
+			}
+		}
+		wildcards := len(regexp.MustCompile(`\{\w+\}`).FindAllString(route.Pattern, -1))
+		if pathParameters != wildcards {
+			t.Errorf("%s %s documents %d path parameters, want %d", route.Method, route.Pattern, pathParameters, wildcards)
+		}
+	}
+}
+
+func TestV1Handler(t *testing.T) {
+	paths := loadSpec(t)
+	tenants := tenant.NewSubscriptions(repo.NewMemoryTenantSubscriptionRepo(), tenant.Quotas{Default: 1})
+	tenants.Subscribe(entity.Subscription{Tenant: "ops", Address: "0xabc", Label: "hot wallet"})
+	parser := &mockParser{
+		block: 42,
+		transactions: map[string][]entity.Transaction{
+			"0xabc": {{Hash: "0x1", From: treasury, BlockTimestamp: &[]string{"0x65920080"}[0]}},
+			"0xfed": {{Hash: "0x2", From: "0xfed", To: &[]string{usdt}[0], Input: transferCall}},
+		},
+	}
+	labels := addressbook.NewBook(repo.NewMemoryLabelRepo())
+	labels.Set(entity.AddressLabel{Address: treasury, Label: "Treasury", Category: "own"})
+
+	exporter := exporterFunc(func(ctx context.Context, address string, window export.TimeRange, emit func(export.Row) error) error {
+		row := export.Row{Timestamp: time.Unix(1704067200, 0).UTC(), Block: 7, Hash: "0x1", Direction: export.DirectionIn, Token: "ETH", Amount: "1.5"}
+		if !window.Contains(row.Timestamp) {
+			return nil
+		}
+		return emit(row)
+	})
+	alerts := alert.NewEngine(repo.NewMemoryTransferStore(), repo.NewMemoryAlertRuleRepo(), repo.NewMemoryAlertRepo(), tenants, labels, nil)
+	rule, err := alerts.CreateRule(entity.AlertRule{Tenant: "ops", Name: "treasury outflow", Expression: `fromLabel == "Treasury"`})
+	if err != nil {
+		t.Fatalf("CreateRule() error = %v", err)
+	}
+	alerts.StoreTransfer("0xabc", entity.Transfer{ID: "0x1:native", TxHash: "0x1", From: treasury, To: "0xabc", Value: "1"})
+
+	abis := abi.NewDecoder(repo.NewMemoryABIRepo())
+	watcher := events.NewWatcher(nil, repo.NewMemoryEventSubscriptionRepo(), repo.NewMemoryEventLogRepo(), abis)
+	deposits, err := watcher.Subscribe(entity.EventSubscription{Tenant: "ops", Contract: usdt, Event: "Deposit(address indexed,uint256)"})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	mux := http.NewServeMux()
+	NewV1Handler(parser, tenants, exporter, labels, abis, mockNames{"treasury.eth": treasury}, alerts, watcher, map[string]BloomStats{"events": watcher}, finalityFunc(func(txs []entity.Transaction) []entity.Transaction {
+		for i := range txs {
+			txs[i].Confirmations, txs[i].Finality = 3, entity.FinalityUnsafe
+		}
+		return txs
+	})).Register(mux)
+
+	ops := entity.APIKey{ID: "1", Tenant: "ops"}
+	risk := entity.APIKey{ID: "2", Tenant: "risk"}
+	admin := entity.APIKey{ID: "3", Tenant: entity.DefaultTenant, Admin: true}
+	authenticated := middleware.Auth(keysFunc(func(secret string) (entity.APIKey, bool) {
+		for _, key := range []entity.APIKey{ops, risk, admin} {
+			if key.ID == secret {
+				return key, true
+			}
+		}
+		return entity.APIKey{}, false
+	}), mux)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		pattern    string
+		body       string
+		header     string
+		key        *entity.APIKey
+		wantStatus int
+		wantBody   string
+	}{
+		{"latest block", "GET", "/v1/blocks/latest", "/v1/blocks/latest", "", "", nil, 200, `"number":42`},
+		{"own transactions", "GET", "/v1/addresses/0xabc/transactions", "/v1/addresses/{address}/transactions", "", "", &ops, 200, `"fromLabel":"Treasury","confirmations":3,"finality":"unsafe"`},
+		{"transactions with ENS names", "GET", "/v1/addresses/0xabc/transactions?ens=true", "/v1/addresses/{address}/transactions", "", "", &ops, 200, `"fromEns":"treasury.eth"`},
+		{"transactions since", "GET", "/v1/addresses/0xabc/transactions?since=2024-01-01T00:00:00Z", "/v1/addresses/{address}/transactions", "", "", &ops, 200, `"blockTimestamp":"0x65920080"`},
+		{"transactions until", "GET", "/v1/addresses/0xabc/transactions?until=1704067199", "/v1/addresses/{address}/transactions", "", "", &ops, 200, `"data":[]`},
+		{"transactions in an invalid range", "GET", "/v1/addresses/0xabc/transactions?since=yesterday", "/v1/addresses/{address}/transactions", "", "", &ops, 400, "invalid since"},
+		{"transactions by label", "GET", "/v1/addresses/0xabc/transactions?label=treasury", "/v1/addresses/{address}/transactions", "", "", &ops, 200, `"hash":"0x1"`},
+		{"transactions by other label", "GET", "/v1/addresses/0xabc/transactions?label=Binance", "/v1/addresses/{address}/transactions", "", "", &ops, 200, `"data":[]`},
+		{"transactions of another tenant", "GET", "/v1/addresses/0xabc/transactions", "/v1/addresses/{address}/transactions", "", "", &risk, 404, `"code":"not_found"`},
+		{"csv export", "GET", "/v1/addresses/0xabc/transactions", "/v1/addresses/{address}/transactions", "", "Accept: text/csv", &ops, 200, "timestamp,block,hash,direction,counterparty,token,amount,fee\n2024-01-01T00:00:00Z,7,0x1,in,,ETH,1.5,\n"},
+		{"csv export until", "GET", "/v1/addresses/0xabc/transactions?until=1704067200", "/v1/addresses/{address}/transactions", "", "Accept: text/csv", &ops, 200, "2024-01-01T00:00:00Z,7,0x1"},
+		{"csv export since", "GET", "/v1/addresses/0xabc/transactions?since=2024-01-02T00:00:00Z", "/v1/addresses/{address}/transactions", "", "Accept: text/csv", &ops, 200, "timestamp,block,hash,direction,counterparty,token,amount,fee\n"},
+		{"csv export in an invalid range", "GET", "/v1/addresses/0xabc/transactions?until=tomorrow", "/v1/addresses/{address}/transactions", "", "Accept: text/csv", &ops, 400, "invalid until"},
+		{"csv export by label", "GET", "/v1/addresses/0xabc/transactions?label=treasury", "/v1/addresses/{address}/transactions", "", "Accept: text/csv", &ops, 400, `unsupported query parameter \"label\"`},
+		{"transactions with an unknown parameter", "GET", "/v1/addresses/0xabc/transactions?limit=10", "/v1/addresses/{address}/transactions", "", "", &ops, 400, `unsupported query parameter \"limit\"`},
+		{"ndjson export", "GET", "/v1/addresses/0xabc/transactions", "/v1/addresses/{address}/transactions", "", "Accept: application/x-ndjson", &ops, 200, `"amount":"1.5"`},
+		{"no transactions yet", "GET", "/v1/addresses/0xdef/transactions", "/v1/addresses/{address}/transactions", "", "", nil, 200, `"data":[]`},
+		{"decode raw transaction", "POST", "/v1/transactions/decode", "/v1/transactions/decode", `{"raw":"0x` + eip155Tx + `"}`, "", &ops, 200, `"from":"0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f"`},
+		{"decode non-hex transaction", "POST", "/v1/transactions/decode", "/v1/transactions/decode", `{"raw":"0xzz"}`, "", &ops, 400, "not hex"},
+		{"decode truncated transaction", "POST", "/v1/transactions/decode", "/v1/transactions/decode", `{"raw":"0x` + eip155Tx[:40] + `"}`, "", &ops, 400, "Invalid raw transaction"},
+		{"list subscriptions", "GET", "/v1/subscriptions", "/v1/subscriptions", "", "", &ops, 200, `"label":"hot wallet"`},
+		{"get subscription", "GET", "/v1/subscriptions/0xABC", "/v1/subscriptions/{address}", "", "", &ops, 200, `"tenant":"ops"`},
+		{"get missing subscription", "GET", "/v1/subscriptions/0xabc", "/v1/subscriptions/{address}", "", "", &risk, 404, `"code":"not_found"`},
+		{"create subscription", "POST", "/v1/subscriptions", "/v1/subscriptions", `{"address":"0xabc","confirmations":12}`, "", &risk, 201, `"confirmations":12`},
+		{"negative confirmations", "POST", "/v1/subscriptions", "/v1/subscriptions", `{"address":"0xabc","confirmations":-1}`, "", &risk, 400, `"code":"bad_request"`},
+		{"create without address", "POST", "/v1/subscriptions", "/v1/subscriptions", `{}`, "", &risk, 400, `"code":"bad_request"`},
+		{"subscribe by ENS name", "POST", "/v1/subscriptions", "/v1/subscriptions", `{"address":"Treasury.ETH"}`, "", &admin, 201, `"address":"` + treasury + `","ens":"treasury.eth"`},
+		{"subscribe by unknown ENS name", "POST", "/v1/subscriptions", "/v1/subscriptions", `{"address":"nobody.eth"}`, "", &admin, 400, "ENS name not found"},
+		{"subscribe by invalid ENS name", "POST", "/v1/subscriptions", "/v1/subscriptions", `{"address":"treasury..eth"}`, "", &admin, 400, "invalid ENS name"},
+		{"quota exceeded", "POST", "/v1/subscriptions", "/v1/subscriptions", `{"address":"0xdef"}`, "", &ops, 403, `"code":"forbidden"`},
+		{"list labels", "GET", "/v1/labels?category=own", "/v1/labels", "", "", &ops, 200, `"label":"Treasury"`},
+		{"get label", "GET", "/v1/labels/" + strings.ToUpper(treasury), "/v1/labels/{address}", "", "", &ops, 200, `"category":"own"`},
+		{"edit label without admin key", "PUT", "/v1/labels/" + binance, "/v1/labels/{address}", `{"label":"Binance"}`, "", &ops, 403, `"code":"forbidden"`},
+		{"edit label", "PUT", "/v1/labels/" + binance, "/v1/labels/{address}", `{"label":"Binance","category":"exchange"}`, "", &admin, 200, `"label":"Binance"`},
+		{"invalid label address", "PUT", "/v1/labels/0x12", "/v1/labels/{address}", `{"label":"short"}`, "", &admin, 400, `"code":"bad_request"`},
+		{"import csv", "POST", "/v1/labels", "/v1/labels", "address,label\n" + binance + ",Binance 14\n", "Content-Type: text/csv", &admin, 200, `"imported":1`},
+		{"import json", "POST", "/v1/labels", "/v1/labels", `[{"address":"` + binance + `","label":"Binance"}]`, "Content-Type: application/json", nil, 200, `"imported":1`},
+		{"delete label", "DELETE", "/v1/labels/" + binance, "/v1/labels/{address}", "", "", &admin, 204, ""},
+		{"delete missing label", "DELETE", "/v1/labels/" + binance, "/v1/labels/{address}", "", "", &admin, 404, `"code":"not_found"`},
+		{"input decoded with bundled signature", "GET", "/v1/addresses/0xfed/transactions", "/v1/addresses/{address}/transactions", "", "", nil, 200, `"signature":"transfer(address,uint256)","name":"transfer","source":"signatures"`},
+		{"upload ABI without admin key", "PUT", "/v1/contracts/" + usdt + "/abi", "/v1/contracts/{address}/abi", `[]`, "", &ops, 403, `"code":"forbidden"`},
+		{"upload ABI", "PUT", "/v1/contracts/0x" + strings.ToUpper(usdt[2:]) + "/abi", "/v1/contracts/{address}/abi", `[{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}]}]`, "", &admin, 200, `"address":"` + usdt + `"`},
+		{"upload invalid ABI", "PUT", "/v1/contracts/" + usdt + "/abi", "/v1/contracts/{address}/abi", `[{"name":"f","inputs":[{"type":"uint7"}]}]`, "", &admin, 400, "unknown type"},
+		{"get ABI", "GET", "/v1/contracts/" + usdt + "/abi", "/v1/contracts/{address}/abi", "", "", &ops, 200, `"name":"transfer"`},
+		{"input decoded with ABI", "GET", "/v1/addresses/0xfed/transactions", "/v1/addresses/{address}/transactions", "", "", nil, 200, `"name":"to","type":"address","value":"` + treasury + `"`},
+		{"delete ABI", "DELETE", "/v1/contracts/" + usdt + "/abi", "/v1/contracts/{address}/abi", "", "", &admin, 204, ""},
+		{"get missing ABI", "GET", "/v1/contracts/" + usdt + "/abi", "/v1/contracts/{address}/abi", "", "", &ops, 404, `"code":"not_found"`},
+		{"delete missing ABI", "DELETE", "/v1/contracts/" + usdt + "/abi", "/v1/contracts/{address}/abi", "", "", &admin, 404, `"code":"not_found"`},
+		{"list rules", "GET", "/v1/rules", "/v1/rules", "", "", &ops, 200, `"name":"treasury outflow"`},
+		{"list rules of another tenant", "GET", "/v1/rules", "/v1/rules", "", "", &risk, 200, `"data":[]`},
+		{"create rule", "POST", "/v1/rules", "/v1/rules", `{"name":"whale","expression":"value > 100 ether","channels":[{"type":"sse"}]}`, "", &risk, 201, `"tenant":"risk"`},
+		{"create invalid rule", "POST", "/v1/rules", "/v1/rules", `{"expression":"value > \"1\""}`, "", &risk, 400, "cannot compare"},
+		{"get rule", "GET", "/v1/rules/" + rule.ID, "/v1/rules/{id}", "", "", &ops, 200, `"expression":"fromLabel == \"Treasury\""`},
+		{"get rule of another tenant", "GET", "/v1/rules/" + rule.ID, "/v1/rules/{id}", "", "", &risk, 404, `"code":"not_found"`},
+		{"list alerts", "GET", "/v1/alerts?rule=" + rule.ID, "/v1/alerts", "", "", &ops, 200, `"rule_id":"` + rule.ID + `"`},
+		{"list alerts of another rule", "GET", "/v1/alerts?rule=other", "/v1/alerts", "", "", &ops, 200, `"data":[]`},
+		{"delete rule", "DELETE", "/v1/rules/" + rule.ID, "/v1/rules/{id}", "", "", &ops, 204, ""},
+		{"delete missing rule", "DELETE", "/v1/rules/" + rule.ID, "/v1/rules/{id}", "", "", &ops, 404, `"code":"not_found"`},
+		{"list event subscriptions", "GET", "/v1/events", "/v1/events", "", "", &ops, 200, `"event":"Deposit(address indexed,uint256)"`},
+		{"list event subscriptions of another tenant", "GET", "/v1/events", "/v1/events", "", "", &risk, 200, `"data":[]`},
+		{"subscribe to event", "POST", "/v1/events", "/v1/events", `{"contract":"` + usdt + `","event":"Transfer(address indexed from, address indexed to, uint256 value)","topics":[["` + treasury + `"]]}`, "", &risk, 201, `"topic0":"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"`},
+		{"subscribe to invalid event", "POST", "/v1/events", "/v1/events", `{"contract":"` + usdt + `","event":"Deposit"}`, "", &risk, 400, "invalid event"},
+		{"subscribe with invalid topic", "POST", "/v1/events", "/v1/events", `{"contract":"` + usdt + `","event":"Deposit(address,uint256)","topics":[["alice"]]}`, "", &risk, 400, `"code":"bad_request"`},
+		{"get event subscription", "GET", "/v1/events/" + deposits.ID, "/v1/events/{id}", "", "", &ops, 200, `"contract":"` + usdt + `"`},
+		{"get event subscription of another tenant", "GET", "/v1/events/" + deposits.ID, "/v1/events/{id}", "", "", &risk, 404, `"code":"not_found"`},
+		{"list event logs", "GET", "/v1/events/" + deposits.ID + "/logs", "/v1/events/{id}/logs", "", "", &ops, 200, `"data":[]`},
+		{"list event logs of another tenant", "GET", "/v1/events/" + deposits.ID + "/logs", "/v1/events/{id}/logs", "", "", &risk, 404, `"code":"not_found"`},
+		{"delete event subscription", "DELETE", "/v1/events/" + deposits.ID, "/v1/events/{id}", "", "", &ops, 204, ""},
+		{"delete missing event subscription", "DELETE", "/v1/events/" + deposits.ID, "/v1/events/{id}", "", "", &ops, 404, `"code":"not_found"`},
+		{"metrics", "GET", "/v1/metrics", "/v1/metrics", "", "", &admin, 200, `"bloom":{"events":{"checked":0,"skipped":0,"falsePositives":0}}`},
+		{"metrics without admin key", "GET", "/v1/metrics", "/v1/metrics", "", "", &ops, 403, `"code":"forbidden"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if name, value, ok := strings.Cut(tt.header, ": "); ok {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			if tt.key != nil {
+				req.Header.Set("X-API-Key", tt.key.ID)
+				authenticated.ServeHTTP(rec, req)
+			} else {
+				mux.ServeHTTP(rec, req)
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("expected body to contain %s, got %s", tt.wantBody, rec.Body)
+			}
+			if rec.Code >= 400 {
+				var envelope apierror.Envelope
+				if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil || envelope.Error.Message == "" {
+					t.Errorf("expected an error envelope, got %s", rec.Body)
+				}
+			}
+			if _, ok := paths[tt.pattern][strings.ToLower(tt.method)].Responses[strconv.Itoa(rec.Code)]; !ok {
+				t.Errorf("status %d of %s %s is not documented", rec.Code, tt.method, tt.pattern)
+			}
+		})
+	}
+}
+
+func TestStreamAlerts(t *testing.T) {
+	tenants := tenant.NewSubscriptions(repo.NewMemoryTenantSubscriptionRepo(), tenant.Quotas{})
+	tenants.Subscribe(entity.Subscription{Tenant: entity.DefaultTenant, Address: treasury})
+	alerts := alert.NewEngine(repo.NewMemoryTransferStore(), repo.NewMemoryAlertRuleRepo(), repo.NewMemoryAlertRepo(), tenants, nil, nil)
+	rule, err := alerts.CreateRule(entity.AlertRule{
+		Tenant:     entity.DefaultTenant,
+		Expression: `direction == "out"`,
+		Channels:   []entity.AlertChannel{{Type: entity.AlertSSE}},
+	})
+	if err != nil {
+		t.Fatalf("CreateRule() error = %v", err)
+	}
+
+	mux := http.NewServeMux()
+	NewV1Handler(&mockParser{}, tenants, nil, nil, nil, nil, alerts, nil, nil, nil).Register(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/v1/alerts/stream")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %s", contentType)
+	}
+
+	alerts.StoreTransfer(treasury, entity.Transfer{ID: "0x1:native", TxHash: "0x1", From: treasury, Value: "1"})
+
+	lines := bufio.NewScanner(resp.Body)
+	var event []string
+	for lines.Scan() && lines.Text() != "" {
+		event = append(event, lines.Text())
+	}
+	want := []string{"id: " + rule.ID + ":0x1:native", "event: alert"}
+	if len(event) != 3 || event[0] != want[0] || event[1] != want[1] || !strings.Contains(event[2], `"rule_id":"`+rule.ID+`"`) {
+		t.Errorf("unexpected event %q", event)
+	}
+}
@@ -0,0 +1,10 @@
+package entity
+
+// AddressLabel names an address in the address book, e.g. our own wallets
+// or known exchanges, bridges and contracts.
+type AddressLabel struct {
+	Address string `json:"address"`
+	Label   string `json:"label"`
+	// Category groups labels, e.g. "own", "exchange" or "bridge".
+	Category string `json:"category,omitempty"`
+}
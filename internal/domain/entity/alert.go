@@ -0,0 +1,43 @@
+package entity
+
+import "time"
+
+type AlertChannelType string
+
+const (
+	// AlertWebhook POSTs the alert to a URL.
+	AlertWebhook AlertChannelType = "webhook"
+	// AlertLog writes the alert to the service log.
+	AlertLog AlertChannelType = "log"
+	// AlertSSE pushes the alert to the tenant's server-sent event streams.
+	AlertSSE AlertChannelType = "sse"
+)
+
+// AlertChannel is where the alerts of a rule are sent.
+type AlertChannel struct {
+	Type AlertChannelType `json:"type"`
+	// URL is the target of webhook channels.
+	URL string `json:"url,omitempty"`
+}
+
+// AlertRule raises an alert for every transfer of the tenant's addresses
+// that matches Expression, e.g. `direction == "out" && value > 100 ether`.
+type AlertRule struct {
+	ID         string         `json:"id"`
+	Tenant     string         `json:"tenant"`
+	Name       string         `json:"name"`
+	Expression string         `json:"expression"`
+	Channels   []AlertChannel `json:"channels"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// Alert records a transfer matched by a rule.
+type Alert struct {
+	ID     string `json:"id"`
+	RuleID string `json:"rule_id"`
+	Tenant string `json:"tenant"`
+	// Address is the subscribed address the transfer was booked for.
+	Address   string    `json:"address"`
+	Transfer  Transfer  `json:"transfer"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+// APIKey grants access to the HTTP API. Only the SHA-256 hash of the secret
+// is kept; the secret itself is shown once, when the key is created.
+type APIKey struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Tenant owns the subscriptions made with the key.
+	Tenant string `json:"tenant"`
+	// Prefix is the start of the secret, to tell keys apart in listings.
+	Prefix    string     `json:"prefix"`
+	Hash      string     `json:"hash"`
+	Admin     bool       `json:"admin"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
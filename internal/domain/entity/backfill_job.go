@@ -0,0 +1,33 @@
+package entity
+
+import "time"
+
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobCompleted JobStatus = "completed"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// BackfillJob is a historical scan of one address running in the background.
+type BackfillJob struct {
+	ID                string    `json:"id"`
+	Address           string    `json:"address"`
+	FromBlock         uint64    `json:"from_block"`
+	ToBlock           uint64    `json:"to_block"`
+	Status            JobStatus `json:"status"`
+	BlocksScanned     uint64    `json:"blocks_scanned"`
+	TransactionsFound int       `json:"transactions_found"`
+	PercentComplete   float64   `json:"percent_complete"`
+	Errors            []string  `json:"errors"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// Done reports whether the job reached a final state.
+func (j BackfillJob) Done() bool {
+	return j.Status == JobCompleted || j.Status == JobFailed || j.Status == JobCancelled
+}
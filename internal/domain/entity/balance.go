@@ -0,0 +1,31 @@
+package entity
+
+// Balance is the running balance of one token for an address, in the token's base unit.
+type Balance struct {
+	Token  string `json:"token"`
+	Amount string `json:"amount"`
+}
+
+// OpeningBalance is the balance of one token an address held before Block,
+// the first block its transfers are booked from.
+type OpeningBalance struct {
+	Token  string `json:"token"`
+	Block  uint64 `json:"block"`
+	Amount string `json:"amount"`
+}
+
+// BalanceDrift compares a tracked balance with the one reported by the chain.
+type BalanceDrift struct {
+	Token   string `json:"token"`
+	Tracked string `json:"tracked"`
+	OnChain string `json:"on_chain"`
+	// Drift is OnChain minus Tracked.
+	Drift string `json:"drift"`
+}
+
+type Reconciliation struct {
+	Address  string         `json:"address"`
+	Block    string         `json:"block"`
+	InSync   bool           `json:"in_sync"`
+	Balances []BalanceDrift `json:"balances"`
+}
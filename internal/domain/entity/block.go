@@ -0,0 +1,11 @@
+package entity
+
+type Block struct {
+	Number     uint64 `json:"number"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parentHash"`
+	Timestamp  uint64 `json:"timestamp"`
+	LogsBloom  string `json:"logsBloom"`
+	// Transactions holds the hashes of the transactions in the block.
+	Transactions []string `json:"transactions"`
+}
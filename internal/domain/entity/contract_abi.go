@@ -0,0 +1,33 @@
+package entity
+
+import "encoding/json"
+
+// ContractABI is the JSON ABI uploaded for a contract, used to decode the
+// input of transactions sent to it.
+type ContractABI struct {
+	Address string          `json:"address"`
+	ABI     json.RawMessage `json:"abi"`
+}
+
+// DecodedInput is the function call in the input of a transaction.
+type DecodedInput struct {
+	// Selector is the first four bytes of the input.
+	Selector string `json:"selector"`
+	// Signature is the canonical signature, e.g. "transfer(address,uint256)".
+	Signature string `json:"signature"`
+	Name      string `json:"name"`
+	// Source is "abi" when the contract's uploaded ABI matched and
+	// "signatures" when only the bundled signature database did, in which
+	// case the arguments are unnamed.
+	Source    string            `json:"source"`
+	Arguments []DecodedArgument `json:"arguments"`
+}
+
+// DecodedArgument is an argument of a decoded call. Integers are decimal
+// strings, addresses and byte strings are hex, arrays are lists of values
+// and tuples are lists of DecodedArgument.
+type DecodedArgument struct {
+	Name  string `json:"name,omitempty"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
@@ -0,0 +1,19 @@
+package entity
+
+// Finality tells how likely a mined transaction is to be reorganized away.
+type Finality string
+
+const (
+	// FinalityUnsafe transactions are in the canonical chain but not yet
+	// in a safe block.
+	FinalityUnsafe Finality = "unsafe"
+	// FinalitySafe transactions are in a block at or below the `safe` tag,
+	// which is only reorganized when the network misbehaves.
+	FinalitySafe Finality = "safe"
+	// FinalityFinalized transactions are in a block at or below the
+	// `finalized` tag and cannot be reorganized.
+	FinalityFinalized Finality = "finalized"
+	// FinalityDropped transactions were mined in a block that is no longer
+	// the canonical block at its height.
+	FinalityDropped Finality = "dropped"
+)
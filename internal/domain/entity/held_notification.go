@@ -0,0 +1,20 @@
+package entity
+
+import (
+	"strings"
+	"time"
+)
+
+// HeldNotification is a webhook call of a subscription asking for
+// confirmations, waiting for its transaction to be deep enough.
+type HeldNotification struct {
+	Subscription Subscription `json:"subscription"`
+	Transaction  Transaction  `json:"transaction"`
+	HeldAt       time.Time    `json:"held_at"`
+}
+
+// ID identifies the notification of one tenant about one transaction of an
+// address.
+func (n HeldNotification) ID() string {
+	return n.Subscription.Tenant + ":" + strings.ToLower(n.Subscription.Address) + ":" + strings.ToLower(n.Transaction.Hash)
+}
@@ -0,0 +1,54 @@
+package entity
+
+import "time"
+
+// Log is an event log as returned by eth_getLogs.
+type Log struct {
+	Address         string   `json:"address"`
+	BlockHash       string   `json:"blockHash"`
+	BlockNumber     string   `json:"blockNumber"`
+	TransactionHash string   `json:"transactionHash"`
+	LogIndex        string   `json:"logIndex"`
+	Topics          []string `json:"topics"`
+	Data            string   `json:"data"`
+	// Removed is set by the node for logs of blocks that were reorganized
+	// away.
+	Removed bool `json:"removed,omitempty"`
+}
+
+// EventSubscription stores the logs of one event of a contract for a
+// tenant.
+type EventSubscription struct {
+	ID       string `json:"id"`
+	Tenant   string `json:"tenant"`
+	Contract string `json:"contract"`
+	// Event declares the event, e.g. "Deposit(address,uint256)" or, with
+	// names and the indexed arguments spelled out,
+	// "Deposit(address indexed user, uint256 amount)".
+	Event string `json:"event"`
+	// Topic0 is the hash of the event signature, the first topic of its logs.
+	Topic0 string `json:"topic0"`
+	// Topics filter the indexed arguments by position: a log matches when
+	// every non-empty position holds one of the listed topics.
+	Topics    [][]string `json:"topics,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// EventLog is a log stored for an event subscription, with its decoded
+// arguments.
+type EventLog struct {
+	// ID is the transaction hash and log index, e.g. "0xab…:0x3".
+	ID             string `json:"id"`
+	SubscriptionID string `json:"subscriptionId"`
+	Tenant         string `json:"tenant"`
+	Contract       string `json:"contract"`
+	BlockNumber    uint64 `json:"blockNumber"`
+	BlockHash      string `json:"blockHash"`
+	TxHash         string `json:"txHash"`
+	LogIndex       uint64 `json:"logIndex"`
+	// Event is the canonical signature, e.g. "Deposit(address,uint256)".
+	Event     string            `json:"event"`
+	Topics    []string          `json:"topics"`
+	Data      string            `json:"data"`
+	Arguments []DecodedArgument `json:"arguments,omitempty"`
+}
@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+type PendingState string
+
+const (
+	PendingStatePending  PendingState = "pending"
+	PendingStateIncluded PendingState = "included"
+	PendingStateDropped  PendingState = "dropped"
+)
+
+// PendingTransaction is a mempool transaction involving a subscribed address.
+// Its nonce tells when another transaction of the sender replaced it.
+type PendingTransaction struct {
+	Transaction
+	State      PendingState `json:"state"`
+	IncludedIn *uint64      `json:"includedIn,omitempty"`
+	FirstSeen  time.Time    `json:"firstSeen"`
+	UpdatedAt  time.Time    `json:"updatedAt"`
+}
@@ -0,0 +1,28 @@
+package entity
+
+import "time"
+
+// DefaultTenant owns the subscriptions made while authentication is disabled.
+const DefaultTenant = "default"
+
+// Subscription is a tenant watching an address. Several tenants can watch
+// the same address, each with its own label and webhook, while the chain
+// data found for the address is stored once.
+type Subscription struct {
+	Tenant  string `json:"tenant"`
+	Address string `json:"address"`
+	// ENS is the name the address was resolved from. The subscription
+	// moves along when the name points to another address.
+	ENS   string `json:"ens,omitempty"`
+	Label string `json:"label,omitempty"`
+	// Webhook receives a POST for every new transaction of the address.
+	Webhook string `json:"webhook,omitempty"`
+	// Confirmations holds the webhook back until a transaction has this
+	// many confirmations. Zero notifies as soon as it is found.
+	Confirmations int `json:"confirmations,omitempty"`
+	// CreatedBlock is the head when the subscription was created. Transfers
+	// and transactions of earlier blocks, found by backfills, raise no
+	// alerts or webhooks.
+	CreatedBlock uint64    `json:"created_block,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -1,18 +1,176 @@
 package entity
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TxType is the EIP-2718 type of a transaction.
+type TxType uint8
+
+const (
+	LegacyTxType     TxType = 0x00
+	AccessListTxType TxType = 0x01 // EIP-2930
+	DynamicFeeTxType TxType = 0x02 // EIP-1559
+	BlobTxType       TxType = 0x03 // EIP-4844
+	SetCodeTxType    TxType = 0x04 // EIP-7702
+)
+
+// MarshalText encodes the type as a hex quantity, like the node does.
+func (t TxType) MarshalText() ([]byte, error) {
+	return []byte("0x" + strconv.FormatUint(uint64(t), 16)), nil
+}
+
+func (t *TxType) UnmarshalText(text []byte) error {
+	value, err := strconv.ParseUint(strings.TrimPrefix(string(text), "0x"), 16, 8)
+	if err != nil {
+		return fmt.Errorf("invalid transaction type %q", text)
+	}
+	*t = TxType(value)
+	return nil
+}
+
+// Transaction is a union over the transaction types. The fields every type
+// shares are set directly; the fields a type adds are grouped in embedded
+// structs that are nil for types without them, see Normalize.
 type Transaction struct {
-	BlockHash        *string `json:"-"`
-	BlockNumber      *string `json:"-"`
+	BlockHash   *string `json:"blockHash"`
+	BlockNumber *string `json:"blockNumber"`
+	// BlockTimestamp is the hex Unix time of the block, filled in from the
+	// block header when the node does not send it.
+	BlockTimestamp   *string `json:"blockTimestamp,omitempty"`
 	TransactionIndex *string `json:"transactionIndex"`
 	Hash             string  `json:"hash"`
+	Type             TxType  `json:"type"`
 	From             string  `json:"from"`
 	To               *string `json:"to"`
 	Gas              string  `json:"gas"`
-	GasPrice         string  `json:"gasPrice"`
-	Input            string  `json:"-"`
-	Nonce            string  `json:"-"`
-	Value            string  `json:"value"`
-	V                string  `json:"-"`
-	R                string  `json:"-"`
-	S                string  `json:"-"`
+	// GasPrice is the effective gas price of mined dynamic fee transactions.
+	GasPrice string `json:"gasPrice"`
+	Input    string `json:"input"`
+	Nonce    string `json:"nonce"`
+	Value    string `json:"value"`
+	// ChainID is set on typed transactions and on legacy transactions
+	// signed with EIP-155 replay protection.
+	ChainID string `json:"chainId,omitempty"`
+	V       string `json:"v"`
+	// YParity replaces V in the signature of typed transactions.
+	YParity string `json:"yParity,omitempty"`
+	R       string `json:"r"`
+	S       string `json:"s"`
+
+	*AccessListFields
+	*DynamicFeeFields
+	*BlobFields
+	*SetCodeFields
+
+	// HashMismatch is set when Hash is not the hash of the other fields, so
+	// the node that reported them cannot be trusted about them.
+	HashMismatch bool `json:"hashMismatch,omitempty"`
+	// SenderMismatch is set when the signature was not made by From.
+	SenderMismatch bool `json:"senderMismatch,omitempty"`
+
+	// FromLabel and ToLabel name known addresses from the address book. They
+	// are only filled in for responses.
+	FromLabel string `json:"fromLabel,omitempty"`
+	ToLabel   string `json:"toLabel,omitempty"`
+	// FromENS and ToENS are the verified primary ENS names of the sender
+	// and recipient, filled in for responses that ask for them.
+	FromENS string `json:"fromEns,omitempty"`
+	ToENS   string `json:"toEns,omitempty"`
+	// DecodedInput is the function call in Input, decoded with the ABI of
+	// the recipient or the signature database. Only set for responses.
+	DecodedInput *DecodedInput `json:"decodedInput,omitempty"`
+
+	// Confirmations counts the blocks from the transaction's block to the
+	// head, inclusive. Like Finality, it is only filled in for responses.
+	Confirmations uint64   `json:"confirmations,omitempty"`
+	Finality      Finality `json:"finality,omitempty"`
+}
+
+// AccessListFields are added by EIP-2930 and kept by every later type.
+type AccessListFields struct {
+	AccessList AccessList `json:"accessList"`
+}
+
+// AccessList names the accounts and storage slots a transaction touches.
+type AccessList []AccessTuple
+
+type AccessTuple struct {
+	Address     string   `json:"address"`
+	StorageKeys []string `json:"storageKeys"`
+}
+
+// DynamicFeeFields are added by EIP-1559.
+type DynamicFeeFields struct {
+	MaxFeePerGas         string `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas"`
+}
+
+// BlobFields are added by EIP-4844. The blobs themselves travel beside the
+// block and are not part of the transaction.
+type BlobFields struct {
+	MaxFeePerBlobGas    string   `json:"maxFeePerBlobGas"`
+	BlobVersionedHashes []string `json:"blobVersionedHashes"`
+}
+
+// SetCodeFields are added by EIP-7702.
+type SetCodeFields struct {
+	AuthorizationList []Authorization `json:"authorizationList"`
+}
+
+// Authorization lets an account delegate its code to Address, signed by
+// the account.
+type Authorization struct {
+	ChainID string `json:"chainId"`
+	Address string `json:"address"`
+	Nonce   string `json:"nonce"`
+	YParity string `json:"yParity"`
+	R       string `json:"r"`
+	S       string `json:"s"`
+}
+
+// Normalize drops the field groups that do not belong to the type of tx,
+// which nodes may send as nulls, and reports missing ones. Unknown types,
+// such as rollup deposits, keep only the shared fields.
+func (tx *Transaction) Normalize() error {
+	accessList, dynamicFee, blob, setCode := false, false, false, false
+	switch tx.Type {
+	case LegacyTxType:
+	case AccessListTxType:
+		accessList = true
+	case DynamicFeeTxType:
+		accessList, dynamicFee = true, true
+	case BlobTxType:
+		accessList, dynamicFee, blob = true, true, true
+	case SetCodeTxType:
+		accessList, dynamicFee, setCode = true, true, true
+	}
+
+	if !accessList {
+		tx.AccessListFields = nil
+	} else if tx.AccessListFields == nil {
+		// An empty access list is sometimes left out.
+		tx.AccessListFields = &AccessListFields{AccessList: AccessList{}}
+	}
+	if !dynamicFee {
+		tx.DynamicFeeFields = nil
+	} else if tx.DynamicFeeFields == nil || tx.MaxFeePerGas == "" || tx.MaxPriorityFeePerGas == "" {
+		return fmt.Errorf("type %#x transaction %s has no fee caps", uint8(tx.Type), tx.Hash)
+	}
+	if !blob {
+		tx.BlobFields = nil
+	} else if tx.BlobFields == nil || tx.MaxFeePerBlobGas == "" || len(tx.BlobVersionedHashes) == 0 {
+		return fmt.Errorf("blob transaction %s has no blobs", tx.Hash)
+	}
+	if !setCode {
+		tx.SetCodeFields = nil
+	} else if tx.SetCodeFields == nil || len(tx.AuthorizationList) == 0 {
+		return fmt.Errorf("set code transaction %s has no authorizations", tx.Hash)
+	}
+	if (blob || setCode) && tx.To == nil {
+		return fmt.Errorf("type %#x transaction %s cannot create a contract", uint8(tx.Type), tx.Hash)
+	}
+	return nil
 }
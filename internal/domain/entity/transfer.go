@@ -0,0 +1,33 @@
+package entity
+
+// NativeToken identifies ether in transfers and balances.
+const NativeToken = "ETH"
+
+type TransferKind string
+
+const (
+	// TransferNative is the value of a top-level transaction.
+	TransferNative TransferKind = "native"
+	// TransferToken is an ERC-20 Transfer event.
+	TransferToken TransferKind = "token"
+	// TransferFee is the gas fee paid by the sender of a transaction.
+	TransferFee TransferKind = "fee"
+	// TransferInternal is ether moved by a call made from inside a contract.
+	TransferInternal TransferKind = "internal"
+)
+
+// Transfer is a single movement of value derived from a transaction.
+type Transfer struct {
+	ID          string       `json:"id"`
+	Kind        TransferKind `json:"kind"`
+	TxHash      string       `json:"txHash"`
+	BlockNumber uint64       `json:"blockNumber"`
+	Token       string       `json:"token"`
+	From        string       `json:"from"`
+	To          string       `json:"to"`
+	// Value is a decimal integer in the token's base unit.
+	Value string `json:"value"`
+	// TracePath locates an internal transfer in the call tree of its
+	// transaction, e.g. "0.2" is the third call made by the first subcall.
+	TracePath string `json:"tracePath,omitempty"`
+}
@@ -0,0 +1,14 @@
+package repository
+
+import "eth_parser/internal/domain/entity"
+
+// ABIRepo holds the uploaded contract ABIs. Addresses are compared
+// case-insensitively.
+type ABIRepo interface {
+	// StoreABI adds or replaces the ABI of a contract.
+	StoreABI(abi entity.ContractABI) error
+	GetABI(address string) (entity.ContractABI, bool)
+	// DeleteABI returns false if address had no ABI.
+	DeleteABI(address string) (bool, error)
+	ListABIs() []entity.ContractABI
+}
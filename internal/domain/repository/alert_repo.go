@@ -0,0 +1,19 @@
+package repository
+
+import "eth_parser/internal/domain/entity"
+
+type AlertRuleRepo interface {
+	StoreRule(rule entity.AlertRule) error
+	GetRule(id string) (entity.AlertRule, bool)
+	// DeleteRule removes a rule, returning false if it did not exist.
+	DeleteRule(id string) (bool, error)
+	// ListRules returns the rules of every tenant, oldest first.
+	ListRules() []entity.AlertRule
+}
+
+type AlertRepo interface {
+	// StoreAlert saves alert, returning false if it was already stored.
+	StoreAlert(alert entity.Alert) (bool, error)
+	// ListAlerts returns the alerts of tenant, oldest first.
+	ListAlerts(tenant string) []entity.Alert
+}
@@ -0,0 +1,10 @@
+package repository
+
+import "eth_parser/internal/domain/entity"
+
+type APIKeyRepo interface {
+	StoreKey(key entity.APIKey) error
+	GetKey(id string) (entity.APIKey, bool)
+	GetKeyByHash(hash string) (entity.APIKey, bool)
+	ListKeys() []entity.APIKey
+}
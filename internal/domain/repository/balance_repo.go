@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"eth_parser/internal/domain/entity"
+	"math/big"
+)
+
+type TransferStore interface {
+	// StoreTransfer saves transfer for address, returning false if it was already stored.
+	StoreTransfer(address string, transfer entity.Transfer) (bool, error)
+	GetTransfers(address string) []entity.Transfer
+}
+
+type BalanceRepo interface {
+	AdjustBalance(address, token string, delta *big.Int) error
+	GetBalances(address string) []entity.Balance
+	// StoreOpening saves the opening balance of a token of address,
+	// replacing the one stored before.
+	StoreOpening(address string, opening entity.OpeningBalance) error
+	GetOpenings(address string) []entity.OpeningBalance
+}
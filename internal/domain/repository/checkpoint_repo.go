@@ -0,0 +1,8 @@
+package repository
+
+// CheckpointRepo keeps track of how far a historical scan has progressed.
+// A checkpoint is the next block that still has to be scanned for a key.
+type CheckpointRepo interface {
+	StoreCheckpoint(key string, next uint64) error
+	GetCheckpoint(key string) (uint64, bool)
+}
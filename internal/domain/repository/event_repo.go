@@ -0,0 +1,21 @@
+package repository
+
+import "eth_parser/internal/domain/entity"
+
+type EventSubscriptionRepo interface {
+	StoreEventSubscription(subscription entity.EventSubscription) error
+	GetEventSubscription(id string) (entity.EventSubscription, bool)
+	// DeleteEventSubscription returns false if the subscription did not exist.
+	DeleteEventSubscription(id string) (bool, error)
+	// ListEventSubscriptions returns the subscriptions of every tenant,
+	// oldest first.
+	ListEventSubscriptions() []entity.EventSubscription
+}
+
+type EventLogRepo interface {
+	// StoreEventLog saves log, returning false if it was already stored for
+	// its subscription.
+	StoreEventLog(log entity.EventLog) (bool, error)
+	// ListEventLogs returns the logs of a subscription in chain order.
+	ListEventLogs(subscriptionID string) []entity.EventLog
+}
@@ -0,0 +1,11 @@
+package repository
+
+import "eth_parser/internal/domain/entity"
+
+// HeldNotificationRepo keeps the webhook calls waiting for confirmations.
+type HeldNotificationRepo interface {
+	StoreHeldNotification(notification entity.HeldNotification) error
+	DeleteHeldNotification(id string) error
+	// ListHeldNotifications returns every held notification, oldest first.
+	ListHeldNotifications() []entity.HeldNotification
+}
@@ -0,0 +1,10 @@
+package repository
+
+import "eth_parser/internal/domain/entity"
+
+type JobRepo interface {
+	StoreJob(job entity.BackfillJob) error
+	GetJob(id string) (entity.BackfillJob, bool)
+	DeleteJob(id string) error
+	ListJobs() []entity.BackfillJob
+}
@@ -0,0 +1,13 @@
+package repository
+
+import "eth_parser/internal/domain/entity"
+
+// LabelRepo is the address book. Addresses are compared case-insensitively.
+type LabelRepo interface {
+	// StoreLabels adds or replaces labels at once.
+	StoreLabels(labels ...entity.AddressLabel) error
+	GetLabel(address string) (entity.AddressLabel, bool)
+	// DeleteLabel returns false if address had no label.
+	DeleteLabel(address string) (bool, error)
+	ListLabels() []entity.AddressLabel
+}
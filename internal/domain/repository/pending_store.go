@@ -0,0 +1,11 @@
+package repository
+
+import "eth_parser/internal/domain/entity"
+
+type PendingStore interface {
+	// StorePending inserts or replaces the pending transaction with the same hash.
+	StorePending(tx entity.PendingTransaction) error
+	GetPending(hash string) (entity.PendingTransaction, bool)
+	DeletePending(hash string) error
+	ListPending() []entity.PendingTransaction
+}
@@ -0,0 +1,16 @@
+package repository
+
+import "eth_parser/internal/domain/entity"
+
+// TenantSubscriptionRepo keeps the subscriptions of every tenant.
+// Addresses are compared case-insensitively.
+type TenantSubscriptionRepo interface {
+	StoreTenantSubscription(sub entity.Subscription) error
+	GetTenantSubscription(tenant, address string) (entity.Subscription, bool)
+	ListTenantSubscriptions(tenant string) []entity.Subscription
+	DeleteTenantSubscription(tenant, address string) (bool, error)
+	// AllTenantSubscriptions returns the subscriptions of every tenant.
+	AllTenantSubscriptions() []entity.Subscription
+	// Subscribers returns the subscriptions of all tenants to address.
+	Subscribers(address string) []entity.Subscription
+}
@@ -3,4 +3,6 @@ package repository
 type SubscriptionRepo interface {
 	StoreSubscription(address string) error
 	IsSubscribed(address string) bool
+	// Subscriptions returns the subscribed addresses, lowercase.
+	Subscriptions() []string
 }
@@ -0,0 +1,12 @@
+package repository
+
+import "eth_parser/internal/domain/entity"
+
+// TransactionStore keeps the transactions found for subscribed addresses.
+type TransactionStore interface {
+	// StoreTransaction saves tx for address, returning false if it was
+	// already stored in the same block. A transaction mined again in another
+	// block after a reorg replaces the stored one and returns true.
+	StoreTransaction(address string, tx entity.Transaction) (bool, error)
+	GetTransactions(address string) []entity.Transaction
+}
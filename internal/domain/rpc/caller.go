@@ -0,0 +1,8 @@
+package rpc
+
+import "context"
+
+// Caller sends a JSON-RPC request to the node and decodes its result.
+type Caller interface {
+	Call(ctx context.Context, method string, params []any, result any) error
+}
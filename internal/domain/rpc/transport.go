@@ -0,0 +1,10 @@
+package rpc
+
+import "context"
+
+// Transport carries encoded JSON-RPC messages to a node. body is either a
+// single request or a batch, and the reply has the same shape.
+type Transport interface {
+	RoundTrip(ctx context.Context, body []byte) ([]byte, error)
+	Close() error
+}
@@ -0,0 +1,164 @@
+// Package rlp implements the Recursive Length Prefix encoding Ethereum
+// uses to serialize transactions. Values are byte strings or lists of
+// values; integers are big-endian byte strings without leading zeros.
+package rlp
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrNonCanonical is returned for encodings that decode but could have been
+// shorter, which would give the same value a second hash.
+var ErrNonCanonical = errors.New("non-canonical encoding")
+
+// Item is a decoded value: a byte string, or a list when IsList is set.
+type Item struct {
+	IsList bool
+	Bytes  []byte
+	List   []Item
+}
+
+// EncodeBytes encodes a byte string.
+func EncodeBytes(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return []byte{b[0]}
+	}
+	return append(header(0x80, len(b)), b...)
+}
+
+// EncodeUint encodes an integer.
+func EncodeUint(n uint64) []byte {
+	return EncodeBig(new(big.Int).SetUint64(n))
+}
+
+// EncodeBig encodes a non-negative integer.
+func EncodeBig(n *big.Int) []byte {
+	return EncodeBytes(n.Bytes())
+}
+
+// EncodeList encodes a list of already encoded items.
+func EncodeList(items ...[]byte) []byte {
+	size := 0
+	for _, item := range items {
+		size += len(item)
+	}
+	out := header(0xc0, size)
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+// header is the prefix of a payload of size bytes; offset is 0x80 for byte
+// strings and 0xc0 for lists.
+func header(offset byte, size int) []byte {
+	if size < 56 {
+		return []byte{offset + byte(size)}
+	}
+	length := new(big.Int).SetInt64(int64(size)).Bytes()
+	return append([]byte{offset + 55 + byte(len(length))}, length...)
+}
+
+// Decode decodes data, which must hold exactly one value.
+func Decode(data []byte) (Item, error) {
+	item, rest, err := decode(data)
+	if err != nil {
+		return Item{}, err
+	}
+	if len(rest) > 0 {
+		return Item{}, fmt.Errorf("%d trailing bytes after value", len(rest))
+	}
+	return item, nil
+}
+
+func decode(data []byte) (Item, []byte, error) {
+	if len(data) == 0 {
+		return Item{}, nil, errors.New("unexpected end of input")
+	}
+
+	prefix := data[0]
+	switch {
+	case prefix < 0x80:
+		return Item{Bytes: data[:1]}, data[1:], nil
+	case prefix < 0xc0:
+		payload, rest, err := split(data, 0x80)
+		if err != nil {
+			return Item{}, nil, err
+		}
+		if len(payload) == 1 && payload[0] < 0x80 {
+			return Item{}, nil, fmt.Errorf("%w: single byte %#x with a prefix", ErrNonCanonical, payload[0])
+		}
+		return Item{Bytes: payload}, rest, nil
+	default:
+		payload, rest, err := split(data, 0xc0)
+		if err != nil {
+			return Item{}, nil, err
+		}
+		list := Item{IsList: true, List: []Item{}}
+		for len(payload) > 0 {
+			var item Item
+			item, payload, err = decode(payload)
+			if err != nil {
+				return Item{}, nil, err
+			}
+			list.List = append(list.List, item)
+		}
+		return list, rest, nil
+	}
+}
+
+// split separates the payload of the value at the start of data from the
+// bytes that follow it.
+func split(data []byte, offset byte) ([]byte, []byte, error) {
+	size, start := int(data[0]-offset), 1
+	if size > 55 {
+		lengthSize := size - 55
+		if len(data) < 1+lengthSize {
+			return nil, nil, errors.New("unexpected end of input")
+		}
+		length := data[1 : 1+lengthSize]
+		if length[0] == 0 {
+			return nil, nil, fmt.Errorf("%w: length with leading zeros", ErrNonCanonical)
+		}
+		if lengthSize > 4 {
+			return nil, nil, fmt.Errorf("value of %d length bytes is too large", lengthSize)
+		}
+		size = 0
+		for _, b := range length {
+			size = size<<8 | int(b)
+		}
+		if size < 56 {
+			return nil, nil, fmt.Errorf("%w: long form for %d bytes", ErrNonCanonical, size)
+		}
+		start += lengthSize
+	}
+	if len(data)-start < size {
+		return nil, nil, errors.New("unexpected end of input")
+	}
+	return data[start : start+size], data[start+size:], nil
+}
+
+// Big reads the item as an integer.
+func (i Item) Big() (*big.Int, error) {
+	if i.IsList {
+		return nil, errors.New("expected an integer, got a list")
+	}
+	if len(i.Bytes) > 0 && i.Bytes[0] == 0 {
+		return nil, fmt.Errorf("%w: integer with leading zeros", ErrNonCanonical)
+	}
+	return new(big.Int).SetBytes(i.Bytes), nil
+}
+
+// Uint64 reads the item as an integer of at most 64 bits.
+func (i Item) Uint64() (uint64, error) {
+	n, err := i.Big()
+	if err != nil {
+		return 0, err
+	}
+	if !n.IsUint64() {
+		return 0, fmt.Errorf("integer %s overflows 64 bits", n)
+	}
+	return n.Uint64(), nil
+}
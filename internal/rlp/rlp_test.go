@@ -0,0 +1,111 @@
+package rlp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	long := strings.Repeat("a", 56)
+
+	tests := []struct {
+		name string
+		got  []byte
+		want string
+	}{
+		{name: "empty string", got: EncodeBytes(nil), want: "80"},
+		{name: "single byte", got: EncodeBytes([]byte{0x7f}), want: "7f"},
+		{name: "single high byte", got: EncodeBytes([]byte{0x80}), want: "8180"},
+		{name: "short string", got: EncodeBytes([]byte("dog")), want: "83646f67"},
+		{name: "long string", got: EncodeBytes([]byte(long)), want: "b838" + hex.EncodeToString([]byte(long))},
+		{name: "zero", got: EncodeUint(0), want: "80"},
+		{name: "small integer", got: EncodeUint(15), want: "0f"},
+		{name: "integer", got: EncodeUint(1024), want: "820400"},
+		{name: "empty list", got: EncodeList(), want: "c0"},
+		{name: "list", got: EncodeList(EncodeBytes([]byte("cat")), EncodeBytes([]byte("dog"))), want: "c88363617483646f67"},
+		{name: "nested lists", got: EncodeList(EncodeList(), EncodeList(EncodeList())), want: "c3c0c1c0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hex.EncodeToString(tt.got); got != tt.want {
+				t.Errorf("encoding = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []byte
+		wantErr error
+	}{
+		{name: "long string round trip", input: hex.EncodeToString(EncodeBytes(bytes.Repeat([]byte{1}, 300)))},
+		{name: "nested lists round trip", input: "c3c0c1c0"},
+		{name: "single byte with a prefix", input: "8105", wantErr: ErrNonCanonical},
+		{name: "long form for a short string", input: "b803646f67", wantErr: ErrNonCanonical},
+		{name: "length with leading zeros", input: "b90038" + strings.Repeat("61", 56), wantErr: ErrNonCanonical},
+		{name: "truncated string", input: "83646f"},
+		{name: "truncated list", input: "c88363617483646f"},
+		{name: "trailing bytes", input: "8080"},
+		{name: "empty input", input: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.input)
+			item, err := Decode(data)
+			if strings.HasSuffix(tt.name, "round trip") {
+				if err != nil {
+					t.Fatalf("Decode() error = %v", err)
+				}
+				if got := hex.EncodeToString(reencode(item)); got != tt.input {
+					t.Errorf("re-encoding = %s, want %s", got, tt.input)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Decode() = %+v, want an error", item)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Decode() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestItemIntegers(t *testing.T) {
+	item, err := Decode(EncodeUint(1 << 40))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if n, err := item.Uint64(); err != nil || n != 1<<40 {
+		t.Errorf("Uint64() = %d, %v", n, err)
+	}
+
+	if _, err := (Item{Bytes: []byte{0, 1}}).Big(); !errors.Is(err, ErrNonCanonical) {
+		t.Errorf("expected leading zeros to be rejected, got %v", err)
+	}
+	if _, err := (Item{Bytes: bytes.Repeat([]byte{1}, 9)}).Uint64(); err == nil {
+		t.Error("expected a 72 bit integer to overflow")
+	}
+	if _, err := (Item{IsList: true}).Big(); err == nil {
+		t.Error("expected a list to be rejected")
+	}
+}
+
+func reencode(item Item) []byte {
+	if !item.IsList {
+		return EncodeBytes(item.Bytes)
+	}
+	items := make([][]byte, len(item.List))
+	for i, child := range item.List {
+		items[i] = reencode(child)
+	}
+	return EncodeList(items...)
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
 )
@@ -14,7 +15,56 @@ func HexToInt(hexStr string) (int64, error) {
 	return strconv.ParseInt(hexStr, 16, 64)
 }
 
+// HexToBig parses a hex quantity of any size. "0x" alone is read as zero,
+// which is how nodes encode empty log data.
+func HexToBig(hexStr string) (*big.Int, error) {
+	hexStr = strings.TrimPrefix(hexStr, "0x")
+	if hexStr == "" {
+		return new(big.Int), nil
+	}
+
+	n, ok := new(big.Int).SetString(hexStr, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid hex number %q", hexStr)
+	}
+	return n, nil
+}
+
+func IntToHex(n uint64) string {
+	return "0x" + strconv.FormatUint(n, 16)
+}
+
 func AddressToHex(address string) string {
 	address = strings.ToLower(strings.TrimPrefix(address, "0x"))
 	return "0x" + fmt.Sprintf("%064s", address)
 }
+
+// TopicToAddress extracts the address stored in the low 20 bytes of a 32-byte topic.
+func TopicToAddress(topic string) string {
+	topic = strings.ToLower(strings.TrimPrefix(topic, "0x"))
+	if len(topic) > 40 {
+		topic = topic[len(topic)-40:]
+	}
+	return "0x" + topic
+}
+
+// FormatUnits renders an amount in base units as a decimal number with
+// the given number of decimals, e.g. 1500000 with 6 decimals is "1.5".
+func FormatUnits(amount *big.Int, decimals uint8) string {
+	if decimals == 0 {
+		return amount.String()
+	}
+
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	whole, frac := new(big.Int).QuoRem(new(big.Int).Abs(amount), unit, new(big.Int))
+
+	s := whole.String()
+	if frac.Sign() != 0 {
+		digits := fmt.Sprintf("%0*s", decimals, frac.String())
+		s += "." + strings.TrimRight(digits, "0")
+	}
+	if amount.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
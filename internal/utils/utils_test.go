@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"math/big"
 	"testing"
 )
 
@@ -84,3 +85,74 @@ func TestAddressToHex(t *testing.T) {
 		})
 	}
 }
+
+func TestHexToBig(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "larger than int64",
+			input: "0xde0b6b3a7640000000",
+			want:  "4096000000000000000000",
+		},
+		{
+			name:  "empty data",
+			input: "0x",
+			want:  "0",
+		},
+		{
+			name:    "invalid hex string",
+			input:   "0xzz",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HexToBig(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HexToBig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("HexToBig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopicToAddress(t *testing.T) {
+	topic := "0x000000000000000000000000123456789ABCDEF123456789ABCDEF123456789A"
+	want := "0x123456789abcdef123456789abcdef123456789a"
+	if got := TopicToAddress(topic); got != want {
+		t.Errorf("TopicToAddress() = %v, want %v", got, want)
+	}
+}
+
+func TestFormatUnits(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   string
+		decimals uint8
+		want     string
+	}{
+		{"one ether", "1000000000000000000", 18, "1"},
+		{"fraction", "1500000", 6, "1.5"},
+		{"below one", "1", 18, "0.000000000000000001"},
+		{"zero", "0", 18, "0"},
+		{"no decimals", "42", 0, "42"},
+		{"negative", "-2500", 3, "-2.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, _ := new(big.Int).SetString(tt.amount, 10)
+			if got := FormatUnits(amount, tt.decimals); got != tt.want {
+				t.Errorf("FormatUnits() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}